@@ -27,6 +27,7 @@ import (
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
+	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/mgo.v2/bson"
@@ -355,10 +356,11 @@ func createApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 //   404: Not found
 func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	updateData := app.App{
-		TeamOwner:   r.FormValue("teamOwner"),
-		Plan:        app.Plan{Name: r.FormValue("plan")},
-		Pool:        r.FormValue("pool"),
-		Description: r.FormValue("description"),
+		TeamOwner:      r.FormValue("teamOwner"),
+		Plan:           app.Plan{Name: r.FormValue("plan")},
+		Pool:           r.FormValue("pool"),
+		Description:    r.FormValue("description"),
+		DeployStrategy: r.FormValue("deployStrategy"),
 	}
 	appName := r.URL.Query().Get(":appname")
 	a, err := getAppFromContext(appName, r)
@@ -378,8 +380,11 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	if updateData.TeamOwner != "" {
 		wantedPerms = append(wantedPerms, permission.PermAppUpdateTeamowner)
 	}
+	if updateData.DeployStrategy != "" {
+		wantedPerms = append(wantedPerms, permission.PermAppUpdateDeployStrategy)
+	}
 	if len(wantedPerms) == 0 {
-		msg := "Neither the description, plan, pool or team owner were set. You must define at least one."
+		msg := "Neither the description, plan, pool, team owner or deploy strategy were set. You must define at least one."
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: msg}
 	}
 	for _, perm := range wantedPerms {
@@ -406,7 +411,7 @@ func updateApp(w http.ResponseWriter, r *http.Request, t auth.Token) (err error)
 	w.Header().Set("Content-Type", "application/x-json-stream")
 	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
 	err = a.Update(updateData, writer)
-	if err == app.ErrPlanNotFound {
+	if err == app.ErrPlanNotFound || err == app.ErrInvalidDeployStrategy {
 		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
 	}
 	return err
@@ -1022,6 +1027,138 @@ func unsetCName(w http.ResponseWriter, r *http.Request, t auth.Token) (err error
 	return err
 }
 
+// title: set app certificate
+// path: /apps/{app}/certificate
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func setCertificate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	cname := r.FormValue("cname")
+	certificate := r.FormValue("certificate")
+	key := r.FormValue("key")
+	if cname == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide a cname."}
+	}
+	if certificate == "" || key == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide a certificate and a key."}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateCertificateSet,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateCertificateSet,
+		Owner:      t,
+		CustomData: map[string]interface{}{"cname": cname},
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.SetCertificate(cname, certificate, key)
+	if err != nil {
+		return certificateError(err)
+	}
+	return nil
+}
+
+// title: unset app certificate
+// path: /apps/{app}/certificate
+// method: DELETE
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or certificate not found
+func unsetCertificate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	cname := r.URL.Query().Get("cname")
+	if cname == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide a cname."}
+	}
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateCertificateUnset,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(appName),
+		Kind:       permission.PermAppUpdateCertificateUnset,
+		Owner:      t,
+		CustomData: map[string]interface{}{"cname": cname},
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = a.RemoveCertificate(cname)
+	if err != nil {
+		return certificateError(err)
+	}
+	return nil
+}
+
+// title: list app certificates
+// path: /apps/{app}/certificate
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   400: Router does not support tls
+//   401: Unauthorized
+//   404: App not found
+func listCertificates(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	appName := r.URL.Query().Get(":app")
+	a, err := getAppFromContext(appName, r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppReadCertificate,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	certificates, err := a.GetCertificates()
+	if err != nil {
+		return certificateError(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(certificates)
+}
+
+func certificateError(err error) error {
+	switch err {
+	case app.ErrTLSNotSupported:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	case router.ErrCertificateNotFound:
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if e, ok := err.(*errors.ValidationError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: e.Message}
+	}
+	return err
+}
+
 // title: app log
 // path: /apps/{app}/log
 // method: GET
@@ -1702,6 +1839,52 @@ func appRebuildRoutes(w http.ResponseWriter, r *http.Request, t auth.Token) (err
 	return json.NewEncoder(w).Encode(&result)
 }
 
+// title: migrate app
+// path: /apps/{app}/migrate
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/x-json-stream
+// responses:
+//   200: App migrated
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appMigrate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	poolName := r.FormValue("pool")
+	if poolName == "" {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "You must provide the target pool."}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateMigrate,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:        appTarget(a.Name),
+		Kind:          permission.PermAppUpdateMigrate,
+		Owner:         t,
+		CustomData:    event.FormToCustomData(r.Form),
+		Allowed:       event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+		AllowedCancel: event.Allowed(permission.PermAppUpdateEvents, contextsForApp(&a)...),
+		Cancelable:    true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 30*time.Second, "")
+	defer keepAliveWriter.Stop()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	return a.Migrate(poolName, evt)
+}
+
 func contextsForApp(a *app.App) []permission.PermissionContext {
 	return append(permission.Contexts(permission.CtxTeam, a.Teams),
 		permission.Context(permission.CtxApp, a.Name),
@@ -33,7 +33,9 @@ import (
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/repository/repositorytest"
+	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/rebuild"
+	"github.com/tsuru/tsuru/router/routertest"
 	"github.com/tsuru/tsuru/service"
 	"gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
@@ -1137,6 +1139,40 @@ func (s *S) TestUpdateAppWithPoolOnly(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusOK)
 }
 
+func (s *S) TestUpdateAppDeployStrategy(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("deployStrategy=blue-green")
+	request, err := http.NewRequest("PUT", "/apps/myappx", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	dbApp, err := app.GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployStrategy, check.Equals, provision.DeployStrategyBlueGreen)
+}
+
+func (s *S) TestUpdateAppInvalidDeployStrategy(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("deployStrategy=big-bang")
+	request, err := http.NewRequest("PUT", "/apps/myappx", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, app.ErrInvalidDeployStrategy.Error()+"\n")
+}
+
 func (s *S) TestUpdateAppPoolForbiddenIfTheUserDoesNotHaveAccess(c *check.C) {
 	a := app.App{Name: "myappx", Platform: "zend"}
 	err := s.conn.Apps().Insert(&a)
@@ -1243,7 +1279,7 @@ func (s *S) TestUpdateAppWithoutFlag(c *check.C) {
 	recorder := httptest.NewRecorder()
 	m := RunServer(true)
 	m.ServeHTTP(recorder, request)
-	errorMessage := "Neither the description, plan, pool or team owner were set. You must define at least one.\n"
+	errorMessage := "Neither the description, plan, pool, team owner or deploy strategy were set. You must define at least one.\n"
 	c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
 	c.Check(recorder.Body.String(), check.Equals, errorMessage)
 }
@@ -3271,6 +3307,155 @@ func (s *S) TestRemoveCNameHandlerUserWithoutAccessToTheApp(c *check.C) {
 	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
 }
 
+func (s *S) TestSetCertificate(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, CName: []string{"leper.secretcompany.com"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("leper.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("cname", "leper.secretcompany.com")
+	v.Set("certificate", cert)
+	v.Set("key", key)
+	request, err := http.NewRequest("PUT", "/apps/leper/certificate", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	stored, err := routertest.FakeRouter.GetCertificate("leper.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored, check.Equals, cert)
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget(a.Name),
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.update.certificate.set",
+		StartCustomData: map[string]interface{}{"cname": "leper.secretcompany.com"},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestSetCertificateNotMatchingCName(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, CName: []string{"leper.secretcompany.com"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("other.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("cname", "leper.secretcompany.com")
+	v.Set("certificate", cert)
+	v.Set("key", key)
+	request, err := http.NewRequest("PUT", "/apps/leper/certificate", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "x509: certificate is valid for other.secretcompany.com, not leper.secretcompany.com\n")
+}
+
+func (s *S) TestSetCertificateMissingData(c *check.C) {
+	bodies := []string{"", "cname=leper.secretcompany.com", "cname=leper.secretcompany.com&certificate=cert"}
+	messages := []string{"You must provide a cname.\n", "You must provide a certificate and a key.\n", "You must provide a certificate and a key.\n"}
+	for i, body := range bodies {
+		request, err := http.NewRequest("PUT", "/apps/leper/certificate", strings.NewReader(body))
+		c.Assert(err, check.IsNil)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Authorization", "b "+s.token.GetValue())
+		recorder := httptest.NewRecorder()
+		m := RunServer(true)
+		m.ServeHTTP(recorder, request)
+		c.Check(recorder.Code, check.Equals, http.StatusBadRequest)
+		c.Check(recorder.Body.String(), check.Equals, messages[i])
+	}
+}
+
+func (s *S) TestSetCertificateUserWithoutAccessToTheApp(c *check.C) {
+	a := app.App{Name: "lost", Platform: "vougan", TeamOwner: s.team.Name, CName: []string{"lost.secretcompany.com"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	v := url.Values{}
+	v.Set("cname", "lost.secretcompany.com")
+	v.Set("certificate", "cert")
+	v.Set("key", "key")
+	request, err := http.NewRequest("PUT", "/apps/lost/certificate", strings.NewReader(v.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateCertificateSet,
+		Context: permission.Context(permission.CtxApp, "-invalid-"),
+	})
+	request.Header.Set("Authorization", "b "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestUnsetCertificate(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, CName: []string{"leper.secretcompany.com"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("leper.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("leper.secretcompany.com", cert, key)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/leper/certificate?cname=leper.secretcompany.com", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = routertest.FakeRouter.GetCertificate("leper.secretcompany.com")
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	c.Assert(eventtest.EventDesc{
+		Target:          appTarget(a.Name),
+		Owner:           s.token.GetUserName(),
+		Kind:            "app.update.certificate.unset",
+		StartCustomData: map[string]interface{}{"cname": "leper.secretcompany.com"},
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnsetCertificateNotFound(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, CName: []string{"leper.secretcompany.com"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/leper/certificate?cname=leper.secretcompany.com", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+	c.Assert(recorder.Body.String(), check.Equals, "Certificate not found\n")
+}
+
+func (s *S) TestListCertificates(c *check.C) {
+	a := app.App{Name: "leper", Platform: "zend", TeamOwner: s.team.Name, CName: []string{"leper.secretcompany.com", "blog.tsuru.com"}}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("leper.secretcompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("leper.secretcompany.com", cert, key)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/leper/certificate", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var certificates map[string]string
+	err = json.Unmarshal(recorder.Body.Bytes(), &certificates)
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.DeepEquals, map[string]string{"leper.secretcompany.com": cert})
+}
+
 func (s *S) TestAppLogShouldReturnNotFoundWhenAppDoesNotExist(c *check.C) {
 	request, err := http.NewRequest("GET", "/apps/unknown/log/?:app=unknown&lines=10", nil)
 	c.Assert(err, check.IsNil)
@@ -5075,3 +5260,72 @@ func (s *S) TestRebuildRoutes(c *check.C) {
 	json.Unmarshal(recorder.Body.Bytes(), &parsed)
 	c.Assert(parsed, check.DeepEquals, rebuild.RebuildRoutesResult{})
 }
+
+func (s *S) TestAppMigrateWithoutPool(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/myappx/migrate", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "You must provide the target pool.\n")
+}
+
+func (s *S) TestAppMigrateForbidden(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	token := userWithPermission(c, permission.Permission{
+		Scheme:  permission.PermAppUpdateMigrate,
+		Context: permission.Context(permission.CtxApp, "-other-"),
+	})
+	body := strings.NewReader("pool=test")
+	request, err := http.NewRequest("POST", "/apps/myappx/migrate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAppMigrateAppNotFound(c *check.C) {
+	body := strings.NewReader("pool=test")
+	request, err := http.NewRequest("POST", "/apps/myappx/migrate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppMigrateSameProvisioner(c *check.C) {
+	a := app.App{Name: "myappx", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{Name: "test", Public: true})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("test")
+	body := strings.NewReader("pool=test")
+	request, err := http.NewRequest("POST", "/apps/myappx/migrate", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Body.String(), check.Equals, "pool \"test\" uses the same provisioner as the app, use app-update to change the pool\n")
+	c.Assert(eventtest.EventDesc{
+		Target:       appTarget("myappx"),
+		Owner:        s.token.GetUserName(),
+		Kind:         "app.update.migrate",
+		ErrorMatches: `pool "test" uses the same provisioner as the app.*`,
+	}, eventtest.HasEvent)
+}
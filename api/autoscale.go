@@ -0,0 +1,353 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/tsuru/tsuru/app/autoscale"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/errors"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+)
+
+// title: list app autoscale rules
+// path: /apps/{app}/autoscale
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func appAutoScaleRuleList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	rules, err := autoscale.ListRules(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(rules)
+}
+
+// title: set app autoscale rule
+// path: /apps/{app}/autoscale
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appAutoScaleRuleSet(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAutoscale,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	rule := autoscale.Rule{
+		App:     a.Name,
+		Process: r.FormValue("process"),
+		Metric:  r.FormValue("metric"),
+		Enabled: true,
+	}
+	minUnits, err := strconv.ParseUint(r.FormValue("min"), 10, 32)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid min units: " + r.FormValue("min")}
+	}
+	maxUnits, err := strconv.ParseUint(r.FormValue("max"), 10, 32)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid max units: " + r.FormValue("max")}
+	}
+	rule.MinUnits = uint(minUnits)
+	rule.MaxUnits = uint(maxUnits)
+	rule.Target, err = strconv.ParseFloat(r.FormValue("target"), 64)
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid target: " + r.FormValue("target")}
+	}
+	if enabled := r.FormValue("enabled"); enabled != "" {
+		rule.Enabled, err = strconv.ParseBool(enabled)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid enabled value: " + enabled}
+		}
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = autoscale.SaveRule(&rule)
+	switch err {
+	case autoscale.ErrProcessRequired, autoscale.ErrInvalidMetric, autoscale.ErrInvalidTarget,
+		autoscale.ErrInvalidMinUnits, autoscale.ErrInvalidMaxUnits:
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
+// title: remove app autoscale rule
+// path: /apps/{app}/autoscale/{process}
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App or rule not found
+func appAutoScaleRuleRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAutoscale,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	process := r.URL.Query().Get(":process")
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = autoscale.RemoveRule(a.Name, process)
+	if err == autoscale.ErrRuleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: list app scaling schedules
+// path: /apps/{app}/schedules
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: App not found
+func appScheduleList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppRead,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	schedules, err := autoscale.ListSchedules(a.Name)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(schedules)
+}
+
+func scheduleFromForm(r *http.Request, s *autoscale.Schedule) error {
+	if process := r.FormValue("process"); process != "" {
+		s.Process = process
+	}
+	if spec := r.FormValue("spec"); spec != "" {
+		s.Spec = spec
+	}
+	if _, ok := r.Form["timezone"]; ok {
+		s.Timezone = r.FormValue("timezone")
+	}
+	if units := r.FormValue("units"); units != "" {
+		n, err := strconv.ParseUint(units, 10, 32)
+		if err != nil {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: "invalid units: " + units}
+		}
+		s.Units = uint(n)
+	}
+	return nil
+}
+
+// title: add app scaling schedule
+// path: /apps/{app}/schedules
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Schedule created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App not found
+func appScheduleAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAutoscale,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	schedule := autoscale.Schedule{App: a.Name}
+	err = scheduleFromForm(r, &schedule)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = autoscale.AddSchedule(&schedule)
+	if err != nil {
+		if _, ok := err.(*autoscale.InvalidScheduleError); ok {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(schedule)
+}
+
+// title: update app scaling schedule
+// path: /apps/{app}/schedules/{id}
+// method: PUT
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+//   404: App or schedule not found
+func appScheduleUpdate(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAutoscale,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	schedule, err := autoscale.FindSchedule(a.Name, r.URL.Query().Get(":id"))
+	if err == autoscale.ErrScheduleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	err = scheduleFromForm(r, schedule)
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = autoscale.UpdateSchedule(schedule)
+	if _, ok := err.(*autoscale.InvalidScheduleError); ok {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err == autoscale.ErrScheduleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
+
+// title: remove app scaling schedule
+// path: /apps/{app}/schedules/{id}
+// method: DELETE
+// responses:
+//   200: Ok
+//   401: Unauthorized
+//   404: App or schedule not found
+func appScheduleRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":app"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppUpdateAutoscale,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     appTarget(a.Name),
+		Kind:       permission.PermAppUpdateAutoscale,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = autoscale.RemoveSchedule(a.Name, r.URL.Query().Get(":id"))
+	if err == autoscale.ErrScheduleNotFound {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/autoscale"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppAutoScaleRuleSet(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("process=web&min=2&max=10&metric=cpu&target=60")
+	request, err := http.NewRequest("POST", "/apps/myapp/autoscale", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	rule, err := autoscale.FindRule("myapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(*rule, check.DeepEquals, autoscale.Rule{
+		App:      "myapp",
+		Process:  "web",
+		MinUnits: 2,
+		MaxUnits: 10,
+		Metric:   autoscale.MetricCPU,
+		Target:   60,
+		Enabled:  true,
+	})
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.autoscale",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppAutoScaleRuleSetInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("process=web&min=2&max=1&metric=cpu&target=60")
+	request, err := http.NewRequest("POST", "/apps/myapp/autoscale", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, autoscale.ErrInvalidMaxUnits.Error()+"\n")
+	body = strings.NewReader("process=web&min=1&max=2&metric=cpu&target=abc")
+	request, err = http.NewRequest("POST", "/apps/myapp/autoscale", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, "invalid target: abc\n")
+}
+
+func (s *S) TestAppAutoScaleRuleList(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("GET", "/apps/myapp/autoscale", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNoContent)
+	rule := autoscale.Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 3, Metric: autoscale.MetricMemory, Target: 80}
+	err = autoscale.SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	request, err = http.NewRequest("GET", "/apps/myapp/autoscale", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var rules []autoscale.Rule
+	err = json.NewDecoder(recorder.Body).Decode(&rules)
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.DeepEquals, []autoscale.Rule{rule})
+}
+
+func (s *S) TestAppAutoScaleRuleRemove(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	rule := autoscale.Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 3, Metric: autoscale.MetricMemory, Target: 80}
+	err = autoscale.SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("DELETE", "/apps/myapp/autoscale/web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	_, err = autoscale.FindRule("myapp", "web")
+	c.Assert(err, check.Equals, autoscale.ErrRuleNotFound)
+	request, err = http.NewRequest("DELETE", "/apps/myapp/autoscale/web", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestAppScheduleAdd(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("process=web&units=10&spec=0+8+*+*+1-5&timezone=America/Sao_Paulo")
+	request, err := http.NewRequest("POST", "/apps/myapp/schedules", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var schedule autoscale.Schedule
+	err = json.NewDecoder(recorder.Body).Decode(&schedule)
+	c.Assert(err, check.IsNil)
+	dbSchedule, err := autoscale.FindSchedule("myapp", schedule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSchedule.Process, check.Equals, "web")
+	c.Assert(dbSchedule.Units, check.Equals, uint(10))
+	c.Assert(dbSchedule.Spec, check.Equals, "0 8 * * 1-5")
+	c.Assert(dbSchedule.Timezone, check.Equals, "America/Sao_Paulo")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget("myapp"),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.update.autoscale",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAppScheduleAddInvalid(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	body := strings.NewReader("process=web&units=10&spec=0+25+*+*+*")
+	request, err := http.NewRequest("POST", "/apps/myapp/schedules", body)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, `invalid schedule "0 25 * * *": hour field out of range [0-23]: "25"`+"\n")
+}
+
+func (s *S) TestAppScheduleListUpdateAndRemove(c *check.C) {
+	a := app.App{Name: "myapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	schedule := autoscale.Schedule{App: "myapp", Process: "web", Units: 2, Spec: "0 20 * * *"}
+	err = autoscale.AddSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	url := "/apps/myapp/schedules/" + schedule.ID.Hex()
+	request, err := http.NewRequest("PUT", url, strings.NewReader("units=3"))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	request, err = http.NewRequest("GET", "/apps/myapp/schedules", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	var schedules []autoscale.Schedule
+	err = json.NewDecoder(recorder.Body).Decode(&schedules)
+	c.Assert(err, check.IsNil)
+	c.Assert(schedules, check.HasLen, 1)
+	c.Assert(schedules[0].Units, check.Equals, uint(3))
+	c.Assert(schedules[0].Spec, check.Equals, "0 20 * * *")
+	request, err = http.NewRequest("DELETE", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	request, err = http.NewRequest("DELETE", url, nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "b "+s.token.GetValue())
+	recorder = httptest.NewRecorder()
+	m.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
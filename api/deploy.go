@@ -20,6 +20,7 @@ import (
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/io"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/repository"
 )
 
@@ -279,6 +280,65 @@ func deployRollback(w http.ResponseWriter, r *http.Request, t auth.Token) error
 	return nil
 }
 
+// title: promote canary deploy
+// path: /apps/{appname}/deploy/canary/promote
+// method: POST
+// responses:
+//   200: Canary promoted
+//   400: No canary deploy in progress
+//   401: Unauthorized
+//   404: App not found
+func deployCanaryPromote(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return deployCanaryDecision(r, t, app.PromoteCanary)
+}
+
+// title: abort canary deploy
+// path: /apps/{appname}/deploy/canary/abort
+// method: POST
+// responses:
+//   200: Canary aborted
+//   400: No canary deploy in progress
+//   401: Unauthorized
+//   404: App not found
+func deployCanaryAbort(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	return deployCanaryDecision(r, t, app.AbortCanary)
+}
+
+func deployCanaryDecision(r *http.Request, t auth.Token, decide func(*app.App) error) (err error) {
+	a, err := getAppFromContext(r.URL.Query().Get(":appname"), r)
+	if err != nil {
+		return err
+	}
+	allowed := permission.Check(t, permission.PermAppDeployCanary,
+		contextsForApp(&a)...,
+	)
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	// The running canary deploy holds the app lock while it waits for the
+	// decision, so this event must not try to acquire it.
+	evt, err := event.New(&event.Opts{
+		Target:      appTarget(a.Name),
+		Kind:        permission.PermAppDeployCanary,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermAppReadEvents, contextsForApp(&a)...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = decide(&a)
+	if err != nil {
+		if _, ok := err.(provision.ProvisionerNotSupported); ok || err == provision.ErrCanaryNotFound {
+			return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+		}
+		return err
+	}
+	return nil
+}
+
 // title: deploy list
 // path: /deploys
 // method: GET
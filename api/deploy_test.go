@@ -1126,6 +1126,112 @@ func (s *DeploySuite) TestDeployRollbackHandlerWithInexistVersion(c *check.C) {
 	c.Assert(body, check.DeepEquals, map[string]string{"Message": "", "Error": `invalid version: "v3"`})
 }
 
+func (s *DeploySuite) TestDeployCanaryPromote(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.StartCanary(&a)
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/canary/promote", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(s.provisioner.CanaryState(&a), check.Equals, "promoted")
+	c.Assert(eventtest.EventDesc{
+		Target: appTarget(a.Name),
+		Owner:  s.token.GetUserName(),
+		Kind:   "app.deploy.canary",
+	}, eventtest.HasEvent)
+}
+
+func (s *DeploySuite) TestDeployCanaryPromoteWhileDeployRunning(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.StartCanary(&a)
+	deployEvt, err := event.New(&event.Opts{
+		Target:  appTarget(a.Name),
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	defer deployEvt.Done(nil)
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/canary/promote", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(s.provisioner.CanaryState(&a), check.Equals, "promoted")
+}
+
+func (s *DeploySuite) TestDeployCanaryAbort(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.StartCanary(&a)
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/canary/abort", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(s.provisioner.CanaryState(&a), check.Equals, "aborted")
+}
+
+func (s *DeploySuite) TestDeployCanaryPromoteNotRunning(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/canary/promote", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, provision.ErrCanaryNotFound.Error()+"\n")
+}
+
+func (s *DeploySuite) TestDeployCanaryPromoteForbidden(c *check.C) {
+	user, _ := s.token.User()
+	a := app.App{Name: "otherapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(&a, user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.StartCanary(&a)
+	token := customUserWithPermission(c, "myuser", permission.Permission{
+		Scheme:  permission.PermAppDeployRollback,
+		Context: permission.Context(permission.CtxApp, a.Name),
+	})
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/canary/promote", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusForbidden)
+	c.Assert(s.provisioner.CanaryState(&a), check.Equals, "running")
+}
+
+func (s *DeploySuite) TestDeployCanaryPromoteAppNotFound(c *check.C) {
+	request, err := http.NewRequest("POST", "/apps/otherapp/deploy/canary/promote", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	recorder := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
+}
+
 func (s *DeploySuite) TestDiffDeploy(c *check.C) {
 	diff := `--- hello.go	2015-11-25 16:04:22.409241045 +0000
 +++ hello.go	2015-11-18 18:40:21.385697080 +0000
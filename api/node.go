@@ -226,6 +226,108 @@ func removeNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	return nil
 }
 
+// title: cordon node
+// path: /{provisioner}/node/{address}/cordon
+// method: POST
+// responses:
+//   200: Ok
+//   400: Not supported
+//   401: Unauthorized
+//   404: Not found
+func cordonNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	prov, evt, err := nodeMaintenanceEvent(r, t, permission.PermNodeUpdateCordon)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return prov.CordonNode(evt.Target.Value)
+}
+
+// title: uncordon node
+// path: /{provisioner}/node/{address}/uncordon
+// method: POST
+// responses:
+//   200: Ok
+//   400: Not supported
+//   401: Unauthorized
+//   404: Not found
+func uncordonNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	prov, evt, err := nodeMaintenanceEvent(r, t, permission.PermNodeUpdateUncordon)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	return prov.UncordonNode(evt.Target.Value)
+}
+
+// title: drain node
+// path: /{provisioner}/node/{address}/drain
+// method: POST
+// produce: application/x-json-stream
+// responses:
+//   200: Ok
+//   400: Not supported
+//   401: Unauthorized
+//   404: Not found
+func drainNodeHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	prov, evt, err := nodeMaintenanceEvent(r, t, permission.PermNodeUpdateDrain)
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	w.Header().Set("Content-Type", "application/x-json-stream")
+	keepAliveWriter := tsuruIo.NewKeepAliveWriter(w, 15*time.Second, "")
+	defer keepAliveWriter.Stop()
+	writer := &tsuruIo.SimpleJsonMessageEncoderWriter{Encoder: json.NewEncoder(keepAliveWriter)}
+	evt.SetLogWriter(writer)
+	err = prov.DrainNode(evt.Target.Value, evt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(evt, "Node drained successfully!\n")
+	return nil
+}
+
+func nodeMaintenanceEvent(r *http.Request, t auth.Token, perm *permission.PermissionScheme) (provision.NodeMaintenanceProvisioner, *event.Event, error) {
+	r.ParseForm()
+	address := r.URL.Query().Get(":address")
+	if address == "" {
+		return nil, nil, fmt.Errorf("Node address is required.")
+	}
+	prov, node, err := provision.FindNode(address)
+	if err != nil {
+		if err == provision.ErrNodeNotFound {
+			return nil, nil, &errors.HTTP{
+				Code:    http.StatusNotFound,
+				Message: err.Error(),
+			}
+		}
+		return nil, nil, err
+	}
+	maintenanceProv, ok := prov.(provision.NodeMaintenanceProvisioner)
+	if !ok {
+		return nil, nil, &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: provision.ProvisionerNotSupported{Prov: prov, Action: "node maintenance"}.Error(),
+		}
+	}
+	pool := node.Pool()
+	if !permission.Check(t, perm, permission.Context(permission.CtxPool, pool)) {
+		return nil, nil, permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypeNode, Value: node.Address()},
+		Kind:       perm,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, pool)),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return maintenanceProv, evt, nil
+}
+
 type listNodeResponse struct {
 	Nodes    []json.RawMessage `json:"nodes"`
 	Machines []iaas.Machine    `json:"machines"`
@@ -293,6 +293,77 @@ func (s *S) TestRemoveNodeHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestCordonNodeHandler(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address: "host.com:2375",
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/node/host.com:2375/cordon", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	node, err := s.provisioner.GetNode("host.com:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "cordoned")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "host.com:2375"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update.cordon",
+	}, eventtest.HasEvent)
+	req, err = http.NewRequest("POST", "/node/host.com:2375/uncordon", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	node, err = s.provisioner.GetNode("host.com:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "enabled")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeNode, Value: "host.com:2375"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "node.update.uncordon",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestCordonNodeHandlerNotFound(c *check.C) {
+	req, err := http.NewRequest("POST", "/node/host.com:2375/cordon", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestDrainNodeHandler(c *check.C) {
+	err := s.provisioner.AddNode(provision.AddNodeOptions{
+		Address: "host.com:2375",
+	})
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("POST", "/node/host.com:2375/drain", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	server := RunServer(true)
+	server.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	c.Assert(rec.Header().Get("Content-Type"), check.Equals, "application/x-json-stream")
+	c.Assert(rec.Body.String(), check.Equals, `{"Message":"drain done!"}`+"\n"+`{"Message":"Node drained successfully!\n"}`+"\n")
+	node, err := s.provisioner.GetNode("host.com:2375")
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Status(), check.Equals, "cordoned")
+	c.Assert(eventtest.EventDesc{
+		Target:     event.Target{Type: event.TargetTypeNode, Value: "host.com:2375"},
+		Owner:      s.token.GetUserName(),
+		Kind:       "node.update.drain",
+		LogMatches: `(?s)drain done!.*Node drained successfully!.*`,
+	}, eventtest.HasEvent)
+}
+
 func (s *S) TestRemoveNodeHandlerNoRebalance(c *check.C) {
 	err := s.provisioner.AddNode(provision.AddNodeOptions{
 		Address: "host.com:2375",
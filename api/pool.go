@@ -6,7 +6,9 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/ajg/form"
 	"github.com/tsuru/tsuru/auth"
@@ -276,3 +278,141 @@ func poolUpdateHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (er
 	}
 	return err
 }
+
+// title: pool maintenance list
+// path: /pools/{name}/maintenance
+// method: GET
+// produce: application/json
+// responses:
+//   200: OK
+//   204: No content
+//   401: Unauthorized
+//   404: Pool not found
+func poolMaintenanceList(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolUpdateMaintenance, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	_, err := provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	windows, err := provision.ListMaintenanceWindows(poolName)
+	if err != nil {
+		return err
+	}
+	if len(windows) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(windows)
+}
+
+// title: pool maintenance add
+// path: /pools/{name}/maintenance
+// method: POST
+// consume: application/x-www-form-urlencoded
+// produce: application/json
+// responses:
+//   201: Maintenance window created
+//   400: Invalid data
+//   401: Unauthorized
+//   404: Pool not found
+func poolMaintenanceAdd(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolUpdateMaintenance, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	var window provision.MaintenanceWindow
+	window.Reason = r.FormValue("reason")
+	fields := []struct {
+		name string
+		dst  *time.Time
+	}{{"start", &window.Start}, {"end", &window.End}}
+	for _, f := range fields {
+		value := r.FormValue(f.name)
+		if value == "" {
+			continue
+		}
+		*f.dst, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return &terrors.HTTP{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("invalid %s time %q, expected RFC3339 format", f.name, value),
+			}
+		}
+	}
+	if window.End.IsZero() {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: "end time is required"}
+	}
+	_, err = provision.GetPoolByName(poolName)
+	if err == provision.ErrPoolNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateMaintenance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.AddMaintenanceWindow(poolName, &window)
+	if err == provision.ErrInvalidMaintenanceWindow {
+		return &terrors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(window)
+}
+
+// title: pool maintenance remove
+// path: /pools/{name}/maintenance/{id}
+// method: DELETE
+// responses:
+//   200: Maintenance window removed
+//   401: Unauthorized
+//   404: Maintenance window not found
+func poolMaintenanceRemove(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	r.ParseForm()
+	poolName := r.URL.Query().Get(":name")
+	allowed := permission.Check(t, permission.PermPoolUpdateMaintenance, permission.Context(permission.CtxPool, poolName))
+	if !allowed {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:     event.Target{Type: event.TargetTypePool, Value: poolName},
+		Kind:       permission.PermPoolUpdateMaintenance,
+		Owner:      t,
+		CustomData: event.FormToCustomData(r.Form),
+		Allowed:    event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, poolName)),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = provision.RemoveMaintenanceWindow(poolName, r.URL.Query().Get(":id"))
+	if err == provision.ErrMaintenanceWindowNotFound {
+		return &terrors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	return err
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/event"
@@ -509,3 +510,100 @@ func (s *S) TestPoolUpdateNotFound(c *check.C) {
 	m.ServeHTTP(recorder, request)
 	c.Assert(recorder.Code, check.Equals, http.StatusNotFound)
 }
+
+func (s *S) TestPoolMaintenanceAddAndList(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	end := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	b := bytes.NewBufferString("end=" + end.Format(time.RFC3339) + "&reason=upgrade")
+	req, err := http.NewRequest("POST", "/pools/pool1/maintenance", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusCreated)
+	var created provision.MaintenanceWindow
+	err = json.NewDecoder(rec.Body).Decode(&created)
+	c.Assert(err, check.IsNil)
+	c.Assert(created.ID, check.Not(check.Equals), "")
+	c.Assert(created.End.Equal(end), check.Equals, true)
+	c.Assert(created.Reason, check.Equals, "upgrade")
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.maintenance",
+	}, eventtest.HasEvent)
+	req, err = http.NewRequest("GET", "/pools/pool1/maintenance", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	var windows []provision.MaintenanceWindow
+	err = json.NewDecoder(rec.Body).Decode(&windows)
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 1)
+	c.Assert(windows[0].ID, check.Equals, created.ID)
+}
+
+func (s *S) TestPoolMaintenanceAddInvalid(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	tests := []struct {
+		body string
+		msg  string
+	}{
+		{"reason=x", "end time is required\n"},
+		{"end=tomorrow", "invalid end time \"tomorrow\", expected RFC3339 format\n"},
+		{"start=2016-10-10T10:00:00Z&end=2016-10-10T09:00:00Z", provision.ErrInvalidMaintenanceWindow.Error() + "\n"},
+	}
+	m := RunServer(true)
+	for _, tt := range tests {
+		req, err := http.NewRequest("POST", "/pools/pool1/maintenance", strings.NewReader(tt.body))
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		c.Assert(rec.Code, check.Equals, http.StatusBadRequest)
+		c.Assert(rec.Body.String(), check.Equals, tt.msg)
+	}
+}
+
+func (s *S) TestPoolMaintenanceAddPoolNotFound(c *check.C) {
+	b := bytes.NewBufferString("end=" + time.Now().Add(time.Hour).Format(time.RFC3339))
+	req, err := http.NewRequest("POST", "/pools/not-found/maintenance", b)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
+
+func (s *S) TestPoolMaintenanceRemove(c *check.C) {
+	window := provision.MaintenanceWindow{End: time.Now().Add(time.Hour)}
+	err := provision.AddMaintenanceWindow("pool1", &window)
+	c.Assert(err, check.IsNil)
+	req, err := http.NewRequest("DELETE", "/pools/pool1/maintenance/"+window.ID, nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec := httptest.NewRecorder()
+	m := RunServer(true)
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusOK)
+	windows, err := provision.ListMaintenanceWindows("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 0)
+	req, err = http.NewRequest("DELETE", "/pools/pool1/maintenance/"+window.ID, nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	rec = httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	c.Assert(rec.Code, check.Equals, http.StatusNotFound)
+}
@@ -19,6 +19,8 @@ import (
 	apiRouter "github.com/tsuru/tsuru/api/router"
 	"github.com/tsuru/tsuru/api/shutdown"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/autoscale"
+	"github.com/tsuru/tsuru/app/unithealer"
 	"github.com/tsuru/tsuru/auth"
 	_ "github.com/tsuru/tsuru/auth/native"
 	_ "github.com/tsuru/tsuru/auth/oauth"
@@ -123,6 +125,9 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Get", "/apps/{app}", AuthorizationRequiredHandler(appInfo))
 	m.Add("1.0", "Post", "/apps/{app}/cname", AuthorizationRequiredHandler(setCName))
 	m.Add("1.0", "Delete", "/apps/{app}/cname", AuthorizationRequiredHandler(unsetCName))
+	m.Add("1.0", "Get", "/apps/{app}/certificate", AuthorizationRequiredHandler(listCertificates))
+	m.Add("1.0", "Put", "/apps/{app}/certificate", AuthorizationRequiredHandler(setCertificate))
+	m.Add("1.0", "Delete", "/apps/{app}/certificate", AuthorizationRequiredHandler(unsetCertificate))
 	runHandler := AuthorizationRequiredHandler(runCommand)
 	m.Add("1.0", "Post", "/apps/{app}/run", runHandler)
 	m.Add("1.0", "Post", "/apps/{app}/restart", AuthorizationRequiredHandler(restart))
@@ -151,8 +156,18 @@ func RunServer(dry bool) http.Handler {
 	logPostHandler := AuthorizationRequiredHandler(addLog)
 	m.Add("1.0", "Post", "/apps/{app}/log", logPostHandler)
 	m.Add("1.0", "Post", "/apps/{appname}/deploy/rollback", AuthorizationRequiredHandler(deployRollback))
+	m.Add("1.0", "Post", "/apps/{appname}/deploy/canary/promote", AuthorizationRequiredHandler(deployCanaryPromote))
+	m.Add("1.0", "Post", "/apps/{appname}/deploy/canary/abort", AuthorizationRequiredHandler(deployCanaryAbort))
 	m.Add("1.0", "Get", "/apps/{app}/metric/envs", AuthorizationRequiredHandler(appMetricEnvs))
 	m.Add("1.0", "Post", "/apps/{app}/routes", AuthorizationRequiredHandler(appRebuildRoutes))
+	m.Add("1.0", "Post", "/apps/{app}/migrate", AuthorizationRequiredHandler(appMigrate))
+	m.Add("1.0", "Get", "/apps/{app}/autoscale", AuthorizationRequiredHandler(appAutoScaleRuleList))
+	m.Add("1.0", "Post", "/apps/{app}/autoscale", AuthorizationRequiredHandler(appAutoScaleRuleSet))
+	m.Add("1.0", "Delete", "/apps/{app}/autoscale/{process}", AuthorizationRequiredHandler(appAutoScaleRuleRemove))
+	m.Add("1.0", "Get", "/apps/{app}/schedules", AuthorizationRequiredHandler(appScheduleList))
+	m.Add("1.0", "Post", "/apps/{app}/schedules", AuthorizationRequiredHandler(appScheduleAdd))
+	m.Add("1.0", "Put", "/apps/{app}/schedules/{id}", AuthorizationRequiredHandler(appScheduleUpdate))
+	m.Add("1.0", "Delete", "/apps/{app}/schedules/{id}", AuthorizationRequiredHandler(appScheduleRemove))
 
 	m.Add("1.0", "Post", "/node/status", AuthorizationRequiredHandler(setNodeStatus))
 
@@ -232,6 +247,9 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.0", "Put", "/pools/{name}", AuthorizationRequiredHandler(poolUpdateHandler))
 	m.Add("1.0", "Post", "/pools/{name}/team", AuthorizationRequiredHandler(addTeamToPoolHandler))
 	m.Add("1.0", "Delete", "/pools/{name}/team", AuthorizationRequiredHandler(removeTeamToPoolHandler))
+	m.Add("1.3", "GET", "/pools/{name}/maintenance", AuthorizationRequiredHandler(poolMaintenanceList))
+	m.Add("1.3", "POST", "/pools/{name}/maintenance", AuthorizationRequiredHandler(poolMaintenanceAdd))
+	m.Add("1.3", "DELETE", "/pools/{name}/maintenance/{id}", AuthorizationRequiredHandler(poolMaintenanceRemove))
 
 	m.Add("1.0", "Get", "/roles", AuthorizationRequiredHandler(listRoles))
 	m.Add("1.0", "Post", "/roles", AuthorizationRequiredHandler(addRole))
@@ -262,6 +280,9 @@ func RunServer(dry bool) http.Handler {
 	m.Add("1.2", "POST", "/node", AuthorizationRequiredHandler(addNodeHandler))
 	m.Add("1.2", "PUT", "/node", AuthorizationRequiredHandler(updateNodeHandler))
 	m.Add("1.2", "DELETE", "/node/{address:.*}", AuthorizationRequiredHandler(removeNodeHandler))
+	m.Add("1.3", "POST", "/node/{address:.*}/cordon", AuthorizationRequiredHandler(cordonNodeHandler))
+	m.Add("1.3", "POST", "/node/{address:.*}/uncordon", AuthorizationRequiredHandler(uncordonNodeHandler))
+	m.Add("1.3", "POST", "/node/{address:.*}/drain", AuthorizationRequiredHandler(drainNodeHandler))
 
 	m.Add("1.2", "GET", "/nodecontainers", AuthorizationRequiredHandler(nodeContainerList))
 	m.Add("1.2", "POST", "/nodecontainers", AuthorizationRequiredHandler(nodeContainerCreate))
@@ -432,6 +453,18 @@ func startServer(handler http.Handler) {
 	if err != nil {
 		fatal(err)
 	}
+	_, err = unithealer.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	_, err = autoscale.Initialize()
+	if err != nil {
+		fatal(err)
+	}
+	_, err = autoscale.InitializeScheduleRunner()
+	if err != nil {
+		fatal(err)
+	}
 	fmt.Println("Checking components status:")
 	results := hc.Check()
 	for _, result := range results {
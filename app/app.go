@@ -5,6 +5,8 @@
 package app
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	stderr "errors"
 	"fmt"
@@ -43,6 +45,9 @@ var (
 	ErrNoAccess          = stderr.New("team does not have access to this app")
 	ErrCannotOrphanApp   = stderr.New("cannot revoke access from this team, as it's the unique team with access to the app")
 	ErrDisabledPlatform  = stderr.New("Disabled Platform, only admin users can create applications with the platform")
+
+	ErrInvalidDeployStrategy = stderr.New("invalid deploy strategy, must be rolling, blue-green or canary")
+	ErrTLSNotSupported       = stderr.New("router does not support tls")
 )
 
 const (
@@ -123,6 +128,7 @@ type App struct {
 	Pool           string
 	Description    string
 	RouterOpts     map[string]string
+	DeployStrategy string
 
 	quota.Quota
 	provisioner provision.Provisioner
@@ -154,6 +160,34 @@ func (app *App) Units() ([]provision.Unit, error) {
 	return prov.Units(app)
 }
 
+// UnitsMetrics returns the resource usage of the units in the app, when
+// supported by the app provisioner.
+func (app *App) UnitsMetrics() ([]provision.UnitMetric, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	metricsProv, ok := prov.(provision.UnitsMetricsProvisioner)
+	if !ok {
+		return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "units metrics"}
+	}
+	return metricsProv.UnitsMetrics(app)
+}
+
+// HealUnit destroys a unit which stopped reporting its status, letting a new
+// one be created in its place, when supported by the app provisioner.
+func (app *App) HealUnit(unit provision.Unit) error {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	healerProv, ok := prov.(provision.UnitHealerProvisioner)
+	if !ok {
+		return provision.ProvisionerNotSupported{Prov: prov, Action: "unit healing"}
+	}
+	return healerProv.HealUnit(unit)
+}
+
 func (app *App) GetRouterOpts() map[string]string {
 	return app.RouterOpts
 }
@@ -180,6 +214,7 @@ func (app *App) MarshalJSON() ([]byte, error) {
 	result["teamowner"] = app.TeamOwner
 	result["plan"] = app.Plan
 	result["lock"] = app.Lock
+	result["deployStrategy"] = app.GetDeployStrategy()
 	return json.Marshal(&result)
 }
 
@@ -317,9 +352,18 @@ func (app *App) Update(updateData App, w io.Writer) error {
 	planName := updateData.Plan.Name
 	poolName := updateData.Pool
 	teamOwner := updateData.TeamOwner
+	deployStrategy := updateData.DeployStrategy
 	if description != "" {
 		app.Description = description
 	}
+	if deployStrategy != "" {
+		switch deployStrategy {
+		case provision.DeployStrategyRolling, provision.DeployStrategyBlueGreen, provision.DeployStrategyCanary:
+		default:
+			return ErrInvalidDeployStrategy
+		}
+		app.DeployStrategy = deployStrategy
+	}
 	if poolName != "" {
 		app.Pool = poolName
 		_, err := app.getPoolForApp(app.Pool)
@@ -1230,10 +1274,107 @@ func (app *App) RemoveCName(cnames ...string) error {
 		&removeCNameFromApp,
 	}
 	err := action.NewPipeline(actions...).Execute(app, cnames)
+	if err == nil {
+		app.removeCertificates(cnames)
+	}
 	rebuild.RoutesRebuildOrEnqueue(app.Name)
 	return err
 }
 
+func (app *App) tlsRouter() (router.TLSRouter, error) {
+	r, err := app.Router()
+	if err != nil {
+		return nil, err
+	}
+	tlsRouter, ok := r.(router.TLSRouter)
+	if !ok {
+		return nil, ErrTLSNotSupported
+	}
+	return tlsRouter, nil
+}
+
+func (app *App) hasCName(name string) bool {
+	for _, cname := range app.CName {
+		if cname == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCertificate sets the certificate and key, in PEM format, used to serve
+// the given CName of the app over TLS. The certificate must match the key and
+// be valid for the CName.
+func (app *App) SetCertificate(name, certificate, key string) error {
+	if !app.hasCName(name) {
+		return &errors.ValidationError{Message: fmt.Sprintf("%s is not a cname of the app", name)}
+	}
+	keyPair, err := tls.X509KeyPair([]byte(certificate), []byte(key))
+	if err != nil {
+		return &errors.ValidationError{Message: err.Error()}
+	}
+	x509Cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return &errors.ValidationError{Message: err.Error()}
+	}
+	err = x509Cert.VerifyHostname(name)
+	if err != nil {
+		return &errors.ValidationError{Message: err.Error()}
+	}
+	r, err := app.tlsRouter()
+	if err != nil {
+		return err
+	}
+	return r.AddCertificate(name, certificate, key)
+}
+
+// RemoveCertificate removes the certificate used to serve the given CName of
+// the app over TLS.
+func (app *App) RemoveCertificate(name string) error {
+	if !app.hasCName(name) {
+		return &errors.ValidationError{Message: fmt.Sprintf("%s is not a cname of the app", name)}
+	}
+	r, err := app.tlsRouter()
+	if err != nil {
+		return err
+	}
+	return r.RemoveCertificate(name)
+}
+
+// GetCertificates returns the certificates in PEM format set for the CNames
+// of the app, keyed by CName. CNames without a certificate are omitted.
+func (app *App) GetCertificates() (map[string]string, error) {
+	r, err := app.tlsRouter()
+	if err != nil {
+		return nil, err
+	}
+	certificates := make(map[string]string)
+	for _, cname := range app.CName {
+		certificate, err := r.GetCertificate(cname)
+		if err == router.ErrCertificateNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		certificates[cname] = certificate
+	}
+	return certificates, nil
+}
+
+func (app *App) removeCertificates(cnames []string) {
+	r, err := app.tlsRouter()
+	if err != nil {
+		return
+	}
+	for _, cname := range cnames {
+		err = r.RemoveCertificate(cname)
+		if err != nil && err != router.ErrCertificateNotFound {
+			log.Errorf("[WARNING] unable to remove certificate of cname %s from app %s: %s", cname, app.Name, err)
+		}
+	}
+}
+
 func (app *App) parsedTsuruServices() map[string][]bind.ServiceInstance {
 	var tsuruServices map[string][]bind.ServiceInstance
 	if servicesEnv, ok := app.Env[TsuruServicesEnvVar]; ok {
@@ -1631,6 +1772,13 @@ func (app *App) GetUpdatePlatform() bool {
 	return app.UpdatePlatform
 }
 
+func (app *App) GetDeployStrategy() string {
+	if app.DeployStrategy == "" {
+		return provision.DeployStrategyRolling
+	}
+	return app.DeployStrategy
+}
+
 func (app *App) RegisterUnit(unitId string, customData map[string]interface{}) error {
 	units, err := app.Units()
 	if err != nil {
@@ -29,6 +29,7 @@ import (
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/repository"
 	"github.com/tsuru/tsuru/repository/repositorytest"
+	"github.com/tsuru/tsuru/router"
 	"github.com/tsuru/tsuru/router/routertest"
 	"github.com/tsuru/tsuru/safe"
 	"github.com/tsuru/tsuru/service"
@@ -1576,6 +1577,100 @@ func (s *S) TestRemoveCNameRemovesFromRouter(c *check.C) {
 	c.Assert(hasCName, check.Equals, false)
 }
 
+func (s *S) TestSetCertificate(c *check.C) {
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("ktulu.mycompany.com", cert, key)
+	c.Assert(err, check.IsNil)
+	stored, err := routertest.FakeRouter.GetCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored, check.Equals, cert)
+	certificates, err := a.GetCertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.DeepEquals, map[string]string{"ktulu.mycompany.com": cert})
+}
+
+func (s *S) TestSetCertificateInvalidCName(c *check.C) {
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("ktulu.mycompany.com", cert, key)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, "ktulu.mycompany.com is not a cname of the app")
+}
+
+func (s *S) TestSetCertificateNotMatchingCName(c *check.C) {
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("other.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("ktulu.mycompany.com", cert, key)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, ".*certificate is valid for other.mycompany.com, not ktulu.mycompany.com")
+	_, err = routertest.FakeRouter.GetCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
+
+func (s *S) TestSetCertificateInvalidKey(c *check.C) {
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	cert, _, err := routertest.GenerateCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	_, key, err := routertest.GenerateCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("ktulu.mycompany.com", cert, key)
+	c.Assert(err, check.FitsTypeOf, &errors.ValidationError{})
+	c.Assert(err, check.ErrorMatches, "tls: private key does not match public key")
+}
+
+func (s *S) TestRemoveCertificate(c *check.C) {
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("ktulu.mycompany.com", cert, key)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	certificates, err := a.GetCertificates()
+	c.Assert(err, check.IsNil)
+	c.Assert(certificates, check.DeepEquals, map[string]string{})
+	err = a.RemoveCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
+
+func (s *S) TestRemoveCNameRemovesCertificate(c *check.C) {
+	a := App{Name: "ktulu", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = a.AddCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	err = a.SetCertificate("ktulu.mycompany.com", cert, key)
+	c.Assert(err, check.IsNil)
+	err = a.RemoveCName("ktulu.mycompany.com")
+	c.Assert(err, check.IsNil)
+	_, err = routertest.FakeRouter.GetCertificate("ktulu.mycompany.com")
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
+
 func (s *S) TestAddInstanceFirst(c *check.C) {
 	a := &App{Name: "dark", TeamOwner: s.team.Name}
 	err := CreateApp(a, s.user)
@@ -2437,19 +2532,20 @@ func (s *S) TestAppMarshalJSON(c *check.C) {
 		TeamOwner:   "myteam",
 	}
 	expected := map[string]interface{}{
-		"name":        "name",
-		"platform":    "Framework",
-		"repository":  "git@" + repositorytest.ServerHost + ":name.git",
-		"teams":       []interface{}{"team1"},
-		"units":       nil,
-		"ip":          "10.10.10.1",
-		"cname":       []interface{}{"name.mycompany.com"},
-		"owner":       "appOwner",
-		"deploys":     float64(7),
-		"pool":        "test",
-		"description": "description",
-		"teamowner":   "myteam",
-		"lock":        s.zeroLock,
+		"name":           "name",
+		"platform":       "Framework",
+		"repository":     "git@" + repositorytest.ServerHost + ":name.git",
+		"teams":          []interface{}{"team1"},
+		"units":          nil,
+		"ip":             "10.10.10.1",
+		"cname":          []interface{}{"name.mycompany.com"},
+		"owner":          "appOwner",
+		"deploys":        float64(7),
+		"pool":           "test",
+		"description":    "description",
+		"teamowner":      "myteam",
+		"deployStrategy": "rolling",
+		"lock":           s.zeroLock,
 		"plan": map[string]interface{}{
 			"name":     "myplan",
 			"memory":   float64(64),
@@ -2480,19 +2576,20 @@ func (s *S) TestAppMarshalJSONWithoutRepository(c *check.C) {
 		TeamOwner:   "myteam",
 	}
 	expected := map[string]interface{}{
-		"name":        "name",
-		"platform":    "Framework",
-		"repository":  "",
-		"teams":       []interface{}{"team1"},
-		"units":       nil,
-		"ip":          "10.10.10.1",
-		"cname":       []interface{}{"name.mycompany.com"},
-		"owner":       "appOwner",
-		"deploys":     float64(7),
-		"pool":        "pool1",
-		"description": "description",
-		"teamowner":   "myteam",
-		"lock":        s.zeroLock,
+		"name":           "name",
+		"platform":       "Framework",
+		"repository":     "",
+		"teams":          []interface{}{"team1"},
+		"units":          nil,
+		"ip":             "10.10.10.1",
+		"cname":          []interface{}{"name.mycompany.com"},
+		"owner":          "appOwner",
+		"deploys":        float64(7),
+		"pool":           "pool1",
+		"description":    "description",
+		"teamowner":      "myteam",
+		"deployStrategy": "rolling",
+		"lock":           s.zeroLock,
 		"plan": map[string]interface{}{
 			"name":     "myplan",
 			"memory":   float64(64),
@@ -3663,6 +3760,37 @@ func (s *S) TestAppMetricEnvs(c *check.C) {
 	c.Assert(envs, check.DeepEquals, expected)
 }
 
+func (s *S) TestAppUnitsMetrics(c *check.C) {
+	a := App{Name: "app-name", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	s.provisioner.SetUnitsUsage(&a, "web", 10, 20)
+	metrics, err := a.UnitsMetrics()
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []provision.UnitMetric{
+		{ID: units[0].ID, ProcessName: "web", CPU: 10, Memory: 20},
+		{ID: units[1].ID, ProcessName: "web", CPU: 10, Memory: 20},
+	})
+}
+
+func (s *S) TestAppHealUnit(c *check.C) {
+	a := App{Name: "app-name", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	units, err := s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = a.HealUnit(units[0])
+	c.Assert(err, check.IsNil)
+	newUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(newUnits, check.HasLen, 2)
+	c.Assert(newUnits[0].ID, check.Not(check.Equals), units[0].ID)
+	c.Assert(newUnits[0].ProcessName, check.Equals, "web")
+	c.Assert(newUnits[1].ID, check.Equals, units[1].ID)
+}
+
 func (s *S) TestUpdateDescription(c *check.C) {
 	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Description: "blabla"}
 	err := CreateApp(&app, s.user)
@@ -3675,6 +3803,31 @@ func (s *S) TestUpdateDescription(c *check.C) {
 	c.Assert(dbApp.Description, check.Equals, "bleble")
 }
 
+func (s *S) TestUpdateDeployStrategy(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	c.Assert(app.GetDeployStrategy(), check.Equals, provision.DeployStrategyRolling)
+	updateData := App{Name: "example", DeployStrategy: provision.DeployStrategyBlueGreen}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.IsNil)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.GetDeployStrategy(), check.Equals, provision.DeployStrategyBlueGreen)
+}
+
+func (s *S) TestUpdateInvalidDeployStrategy(c *check.C) {
+	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name}
+	err := CreateApp(&app, s.user)
+	c.Assert(err, check.IsNil)
+	updateData := App{Name: "example", DeployStrategy: "big-bang"}
+	err = app.Update(updateData, new(bytes.Buffer))
+	c.Assert(err, check.Equals, ErrInvalidDeployStrategy)
+	dbApp, err := GetByName(app.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.DeployStrategy, check.Equals, "")
+}
+
 func (s *S) TestUpdateTeamOwner(c *check.C) {
 	app := App{Name: "example", Platform: "python", TeamOwner: s.team.Name, Description: "blabla"}
 	err := CreateApp(&app, s.user)
@@ -0,0 +1,230 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const (
+	eventKind = "autoscale"
+
+	// minMeasuredUnits is the fraction of the units of a process that must
+	// have been measured for the average usage to be trusted.
+	minMeasuredUnits = 0.5
+)
+
+var AutoScalerInstance *AutoScaler
+
+type AutoScaler struct {
+	runInterval       time.Duration
+	scaleUpCooldown   time.Duration
+	scaleDownCooldown time.Duration
+	quit              chan bool
+}
+
+type autoScalerArgs struct {
+	RunInterval       time.Duration
+	ScaleUpCooldown   time.Duration
+	ScaleDownCooldown time.Duration
+}
+
+// EventCustomData is stored in the events created by the autoscaler on
+// the app target, describing a scaling decision.
+type EventCustomData struct {
+	Rule   Rule
+	Usage  float64
+	Units  int
+	Target int
+}
+
+// Initialize starts the background worker responsible for applying the
+// autoscale rules of all apps, when enabled in the config file.
+func Initialize() (*AutoScaler, error) {
+	if AutoScalerInstance != nil {
+		return nil, errors.New("app autoscaler already initialized")
+	}
+	enabled, _ := config.GetBool("app-autoscale:enabled")
+	if !enabled {
+		return nil, nil
+	}
+	runInterval, _ := config.GetInt("app-autoscale:run-interval")
+	if runInterval <= 0 {
+		runInterval = 60
+	}
+	upCooldown, _ := config.GetInt("app-autoscale:scale-up-cooldown")
+	if upCooldown <= 0 {
+		upCooldown = 3 * 60
+	}
+	downCooldown, _ := config.GetInt("app-autoscale:scale-down-cooldown")
+	if downCooldown <= 0 {
+		downCooldown = 10 * 60
+	}
+	AutoScalerInstance = newAutoScaler(autoScalerArgs{
+		RunInterval:       time.Duration(runInterval) * time.Second,
+		ScaleUpCooldown:   time.Duration(upCooldown) * time.Second,
+		ScaleDownCooldown: time.Duration(downCooldown) * time.Second,
+	})
+	shutdown.Register(AutoScalerInstance)
+	return AutoScalerInstance, nil
+}
+
+func newAutoScaler(args autoScalerArgs) *AutoScaler {
+	a := &AutoScaler{
+		runInterval:       args.RunInterval,
+		scaleUpCooldown:   args.ScaleUpCooldown,
+		scaleDownCooldown: args.ScaleDownCooldown,
+		quit:              make(chan bool),
+	}
+	go func() {
+		defer close(a.quit)
+		for {
+			a.runOnce()
+			select {
+			case <-a.quit:
+				return
+			case <-time.After(a.runInterval):
+			}
+		}
+	}()
+	return a
+}
+
+func (a *AutoScaler) Shutdown() {
+	a.quit <- true
+	<-a.quit
+}
+
+func (a *AutoScaler) String() string {
+	return "app autoscaler"
+}
+
+func (a *AutoScaler) runOnce() {
+	rules, err := ListRules("")
+	if err != nil {
+		log.Errorf("[app autoscale] unable to list rules: %s", err)
+		return
+	}
+	for i := range rules {
+		if !rules[i].Enabled {
+			continue
+		}
+		err = a.runRule(&rules[i])
+		if err != nil {
+			log.Errorf("[app autoscale] %s", err)
+		}
+	}
+}
+
+func (a *AutoScaler) runRule(rule *Rule) error {
+	appObj, err := app.GetByName(rule.App)
+	if err == app.ErrAppNotFound {
+		return RemoveRule(rule.App, rule.Process)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to find app %q", rule.App)
+	}
+	appUnits, err := appObj.Units()
+	if err != nil {
+		return errors.Wrapf(err, "unable to list units for app %q", rule.App)
+	}
+	var units int
+	for _, u := range appUnits {
+		if u.ProcessName == rule.Process {
+			units++
+		}
+	}
+	if units == 0 {
+		return nil
+	}
+	metrics, err := appObj.UnitsMetrics()
+	if err != nil {
+		return errors.Wrapf(err, "unable to get units metrics for app %q", rule.App)
+	}
+	var measured int
+	var total float64
+	for _, m := range metrics {
+		if m.ProcessName != rule.Process {
+			continue
+		}
+		measured++
+		if rule.Metric == MetricMemory {
+			total += m.Memory
+		} else {
+			total += m.CPU
+		}
+	}
+	if float64(measured) < float64(units)*minMeasuredUnits {
+		log.Debugf("[app autoscale] skipping process %q of app %q, only %d of %d units measured", rule.Process, rule.App, measured, units)
+		return nil
+	}
+	usage := total / float64(measured)
+	desired := rule.desiredUnits(units, usage)
+	if desired == units {
+		return nil
+	}
+	cooldown := a.scaleUpCooldown
+	if desired < units {
+		cooldown = a.scaleDownCooldown
+	}
+	if time.Since(rule.LastScale) < cooldown {
+		return nil
+	}
+	return a.scale(appObj, rule, units, desired, usage)
+}
+
+func (a *AutoScaler) scale(appObj *app.App, rule *Rule, units, desired int, usage float64) (err error) {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: appObj.Name},
+		InternalKind: eventKind,
+		CustomData: EventCustomData{
+			Rule:   *rule,
+			Usage:  usage,
+			Units:  units,
+			Target: desired,
+		},
+		Allowed: event.Allowed(permission.PermAppReadEvents, append(permission.Contexts(permission.CtxTeam, appObj.Teams),
+			permission.Context(permission.CtxApp, appObj.Name),
+			permission.Context(permission.CtxPool, appObj.Pool),
+		)...),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			log.Debugf("[app autoscale] skipping %s, app is locked", appObj.Name)
+			return nil
+		}
+		return errors.Wrapf(err, "unable to create autoscale event for app %q", appObj.Name)
+	}
+	maintErr := provision.CheckMaintenance(appObj.Pool)
+	if _, ok := maintErr.(*provision.ErrPoolInMaintenance); ok {
+		log.Debugf("[app autoscale] skipping %s: %s", appObj.Name, maintErr)
+		return evt.Done(maintErr)
+	}
+	defer func() { evt.Done(err) }()
+	if maintErr != nil {
+		return errors.Wrapf(maintErr, "unable to check maintenance of pool %q", appObj.Pool)
+	}
+	fmt.Fprintf(evt, "average %s usage of process %q is %.2f%%, target is %.2f%%: scaling from %d to %d units\n",
+		rule.Metric, rule.Process, usage, rule.Target, units, desired)
+	if desired > units {
+		err = appObj.AddUnits(uint(desired-units), rule.Process, evt)
+	} else {
+		err = appObj.RemoveUnits(uint(units-desired), rule.Process, evt)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to scale process %q of app %q", rule.Process, appObj.Name)
+	}
+	return rule.setLastScale(time.Now().UTC())
+}
@@ -0,0 +1,220 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestInitializeDisabled(c *check.C) {
+	config.Unset("app-autoscale:enabled")
+	a, err := Initialize()
+	c.Assert(err, check.IsNil)
+	c.Assert(a, check.IsNil)
+	c.Assert(AutoScalerInstance, check.IsNil)
+}
+
+func (s *S) TestInitialize(c *check.C) {
+	config.Set("app-autoscale:enabled", true)
+	config.Set("app-autoscale:scale-up-cooldown", 30)
+	defer config.Unset("app-autoscale")
+	a, err := Initialize()
+	c.Assert(err, check.IsNil)
+	defer a.Shutdown()
+	c.Assert(a, check.Equals, AutoScalerInstance)
+	c.Assert(a.runInterval, check.Equals, time.Minute)
+	c.Assert(a.scaleUpCooldown, check.Equals, 30*time.Second)
+	c.Assert(a.scaleDownCooldown, check.Equals, 10*time.Minute)
+	_, err = Initialize()
+	c.Assert(err, check.ErrorMatches, "app autoscaler already initialized")
+}
+
+func (s *S) TestAutoScalerRunOnceScaleUp(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	err := SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 4)
+	rule, err := FindRule(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.LastScale.IsZero(), check.Equals, false)
+	c.Assert(eventtest.EventDesc{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       "autoscale",
+		LogMatches: `(?s).*average cpu usage of process "web" is 90.00%, target is 45.00%: scaling from 2 to 4 units.*`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAutoScalerRunOnceScaleDown(c *check.C) {
+	a := s.newApp(c, "myapp", 4)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	err := SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricMemory, Target: 40, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(eventtest.EventDesc{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       "autoscale",
+		LogMatches: `(?s).*average memory usage of process "web" is 10.00%, target is 40.00%: scaling from 4 to 1 units.*`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAutoScalerRunOncePoolInMaintenance(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	a := s.newApp(c, "myapp", 2)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"pool": "pool1"}})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "database migration",
+	})
+	c.Assert(err, check.IsNil)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	err = SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	rule, err := FindRule(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.LastScale.IsZero(), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:         "autoscale",
+		ErrorMatches: `pool "pool1" is under maintenance until .*: database migration`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestAutoScalerRunOnceRespectsCooldown(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	rule := Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45, Enabled: true}
+	err := SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	err = rule.setLastScale(time.Now().UTC().Add(-time.Minute))
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{scaleUpCooldown: 5 * time.Minute, scaleDownCooldown: 5 * time.Minute}
+	scaler.runOnce()
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	scaler.scaleUpCooldown = 30 * time.Second
+	scaler.runOnce()
+	units, err = s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 4)
+}
+
+func (s *S) TestAutoScalerRunOnceIgnoresDisabledRules(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	err := SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+}
+
+func (s *S) TestAutoScalerRunOnceWithinTarget(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	s.provisioner.SetUnitsUsage(a, "web", 47, 10)
+	err := SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestAutoScalerRunOnceCountsUnmeasuredUnits(c *check.C) {
+	a := s.newApp(c, "myapp", 4)
+	s.provisioner.SetUnitsUsage(a, "web", 45, 10)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.SetUnitStatus(units[0], provision.StatusError)
+	c.Assert(err, check.IsNil)
+	err = SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 3, MaxUnits: 4, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err = s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 4)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestAutoScalerRunOnceTooFewMeasuredUnits(c *check.C) {
+	a := s.newApp(c, "myapp", 4)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	for _, u := range units[:3] {
+		err = s.provisioner.SetUnitStatus(u, provision.StatusStopped)
+		c.Assert(err, check.IsNil)
+	}
+	err = SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 8, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	units, err = s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 4)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *S) TestAutoScalerRunOnceRemovesRulesOfRemovedApps(c *check.C) {
+	err := SaveRule(&Rule{App: "gone", Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	_, err = FindRule("gone", "web")
+	c.Assert(err, check.Equals, ErrRuleNotFound)
+}
+
+func (s *S) TestAutoScalerRunOnceScaleError(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	s.provisioner.SetUnitsUsage(a, "web", 90, 10)
+	err := SaveRule(&Rule{App: a.Name, Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 45, Enabled: true})
+	c.Assert(err, check.IsNil)
+	s.provisioner.PrepareFailure("AddUnits", errors.New("no nodes available"))
+	scaler := &AutoScaler{}
+	scaler.runOnce()
+	rule, err := FindRule(a.Name, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.LastScale.IsZero(), check.Equals, true)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:         "autoscale",
+		ErrorMatches: `.*no nodes available.*`,
+	}, eventtest.HasEvent)
+}
@@ -0,0 +1,237 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/tsuru/gnuflag"
+	"github.com/tsuru/tsuru/cmd"
+)
+
+type AppScheduleList struct {
+	cmd.GuessingCommand
+}
+
+func (c *AppScheduleList) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-schedule-list",
+		Usage: "app-schedule-list [-a/--app appname]",
+		Desc:  "Lists the scaling schedules of an app.",
+	}
+}
+
+func (c *AppScheduleList) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/schedules", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNoContent {
+		fmt.Fprintln(context.Stdout, "No scaling schedules found.")
+		return nil
+	}
+	var schedules []Schedule
+	err = json.NewDecoder(response.Body).Decode(&schedules)
+	if err != nil {
+		return err
+	}
+	tbl := cmd.NewTable()
+	tbl.Headers = cmd.Row{"ID", "Process", "Units", "Schedule", "Timezone"}
+	for _, s := range schedules {
+		timezone := s.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		tbl.AddRow(cmd.Row{s.ID.Hex(), s.Process, strconv.Itoa(int(s.Units)), s.Spec, timezone})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
+
+type scheduleFlags struct {
+	cmd.GuessingCommand
+	fs       *gnuflag.FlagSet
+	process  string
+	units    string
+	timezone string
+}
+
+func (c *scheduleFlags) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		desc := "The process name."
+		c.fs.StringVar(&c.process, "process", "", desc)
+		c.fs.StringVar(&c.process, "p", "", desc)
+		desc = "Number of units the process should have when the schedule is applied."
+		c.fs.StringVar(&c.units, "units", "", desc)
+		c.fs.StringVar(&c.units, "u", "", desc)
+		desc = "Timezone used to evaluate the schedule, e.g. America/Sao_Paulo. Defaults to UTC."
+		c.fs.StringVar(&c.timezone, "timezone", "", desc)
+		c.fs.StringVar(&c.timezone, "t", "", desc)
+	}
+	return c.fs
+}
+
+func (c *scheduleFlags) values(spec string) url.Values {
+	values := url.Values{}
+	if c.process != "" {
+		values.Set("process", c.process)
+	}
+	if c.units != "" {
+		values.Set("units", c.units)
+	}
+	if c.timezone != "" {
+		values.Set("timezone", c.timezone)
+	}
+	if spec != "" {
+		values.Set("spec", spec)
+	}
+	return values
+}
+
+type AppScheduleAdd struct {
+	scheduleFlags
+}
+
+func (c *AppScheduleAdd) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-schedule-add",
+		Usage: `app-schedule-add [-a/--app appname] -p/--process <process> -u/--units <units> [-t/--timezone <timezone>] "<schedule>"`,
+		Desc: `Adds a schedule setting the number of units of an app process at given times.
+
+The schedule is a cron expression with five fields: minute, hour, day of
+month, month and day of week. For example, to have 10 units of the web process
+on weekdays at 08:00 and 2 units every day at 20:00:
+
+  app-schedule-add -p web -u 10 "0 8 * * 1-5"
+  app-schedule-add -p web -u 2 "0 20 * * *"`,
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *AppScheduleAdd) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/schedules", appName))
+	if err != nil {
+		return err
+	}
+	values := c.values(context.Args[0])
+	request, err := http.NewRequest("POST", u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var schedule Schedule
+	err = json.NewDecoder(response.Body).Decode(&schedule)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(context.Stdout, "Schedule %s successfully added.\n", schedule.ID.Hex())
+	return nil
+}
+
+type AppScheduleUpdate struct {
+	scheduleFlags
+}
+
+func (c *AppScheduleUpdate) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-schedule-update",
+		Usage: `app-schedule-update [-a/--app appname] <schedule id> [-p/--process <process>] [-u/--units <units>] [-t/--timezone <timezone>] ["<schedule>"]`,
+		Desc: `Updates an existing scaling schedule of an app. Only the given values are
+changed.`,
+		MinArgs: 1,
+		MaxArgs: 2,
+	}
+}
+
+func (c *AppScheduleUpdate) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/schedules/%s", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	var spec string
+	if len(context.Args) > 1 {
+		spec = context.Args[1]
+	}
+	values := c.values(spec)
+	request, err := http.NewRequest("PUT", u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Schedule successfully updated.")
+	return nil
+}
+
+type AppScheduleRemove struct {
+	cmd.GuessingCommand
+}
+
+func (c *AppScheduleRemove) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "app-schedule-remove",
+		Usage:   "app-schedule-remove [-a/--app appname] <schedule id>",
+		Desc:    "Removes a scaling schedule from an app.",
+		MinArgs: 1,
+		MaxArgs: 1,
+	}
+}
+
+func (c *AppScheduleRemove) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/schedules/%s", appName, context.Args[0]))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Schedule successfully removed.")
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/tsuru/tsuru/cmd"
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAppScheduleListRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{}, Stdout: &buf}
+	body := `[
+{"ID": "5820c0ed8f2a1a0d6c0f1a01", "App": "myapp", "Process": "web", "Units": 10, "Spec": "0 8 * * 1-5", "Timezone": "America/Sao_Paulo"},
+{"ID": "5820c0ed8f2a1a0d6c0f1a02", "App": "myapp", "Process": "web", "Units": 2, "Spec": "0 20 * * *"}
+]`
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: body, Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/schedules" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := AppScheduleList{}
+	command.Flags().Parse(true, []string{"-a", "myapp"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, `+--------------------------+---------+-------+-------------+-------------------+
+| ID                       | Process | Units | Schedule    | Timezone          |
++--------------------------+---------+-------+-------------+-------------------+
+| 5820c0ed8f2a1a0d6c0f1a01 | web     | 10    | 0 8 * * 1-5 | America/Sao_Paulo |
+| 5820c0ed8f2a1a0d6c0f1a02 | web     | 2     | 0 20 * * *  | UTC               |
++--------------------------+---------+-------+-------------+-------------------+
+`)
+}
+
+func (s *S) TestAppScheduleAddRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{"0 8 * * 1-5"}, Stdout: &buf}
+	body := `{"ID": "5820c0ed8f2a1a0d6c0f1a01", "App": "myapp", "Process": "web", "Units": 10, "Spec": "0 8 * * 1-5"}`
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: body, Status: http.StatusCreated},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/schedules" && req.Method == "POST" &&
+				req.FormValue("process") == "web" && req.FormValue("units") == "10" &&
+				req.FormValue("spec") == "0 8 * * 1-5" && req.FormValue("timezone") == "America/Sao_Paulo"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := AppScheduleAdd{}
+	command.Flags().Parse(true, []string{"-a", "myapp", "-p", "web", "-u", "10", "-t", "America/Sao_Paulo"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Schedule 5820c0ed8f2a1a0d6c0f1a01 successfully added.\n")
+}
+
+func (s *S) TestAppScheduleUpdateRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{"5820c0ed8f2a1a0d6c0f1a01"}, Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			req.ParseForm()
+			_, hasSpec := req.Form["spec"]
+			return req.URL.Path == "/1.0/apps/myapp/schedules/5820c0ed8f2a1a0d6c0f1a01" && req.Method == "PUT" &&
+				req.FormValue("units") == "3" && !hasSpec
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := AppScheduleUpdate{}
+	command.Flags().Parse(true, []string{"-a", "myapp", "-u", "3"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Schedule successfully updated.\n")
+}
+
+func (s *S) TestAppScheduleRemoveRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{"5820c0ed8f2a1a0d6c0f1a01"}, Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/schedules/5820c0ed8f2a1a0d6c0f1a01" && req.Method == "DELETE"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := AppScheduleRemove{}
+	command.Flags().Parse(true, []string{"-a", "myapp"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Schedule successfully removed.\n")
+}
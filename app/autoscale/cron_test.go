@@ -0,0 +1,53 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestParseCronInvalid(c *check.C) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"* * * *", `invalid schedule "\* \* \* \*", expected 5 fields: .*`},
+		{"60 * * * *", `invalid schedule "60 \* \* \* \*": minute field out of range \[0-59\]: "60"`},
+		{"* 5-2 * * *", `invalid schedule .*: hour field out of range \[0-23\]: "5-2"`},
+		{"* * 0 * *", `invalid schedule .*: day of month field out of range \[1-31\]: "0"`},
+		{"* * * x *", `invalid schedule .*: invalid value in month field: "x"`},
+		{"*/0 * * * *", `invalid schedule .*: invalid step in minute field: "\*/0"`},
+	}
+	for _, t := range tests {
+		_, err := parseCron(t.spec)
+		c.Check(err, check.ErrorMatches, t.err)
+	}
+}
+
+func (s *S) TestCronSpecNext(c *check.C) {
+	base := time.Date(2016, time.November, 4, 10, 30, 15, 0, time.UTC) // friday
+	tests := []struct {
+		spec     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"* * * * *", base, time.Date(2016, time.November, 4, 10, 31, 0, 0, time.UTC)},
+		{"0 8 * * 1-5", base, time.Date(2016, time.November, 7, 8, 0, 0, 0, time.UTC)},
+		{"0 20 * * *", base, time.Date(2016, time.November, 4, 20, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", base, time.Date(2016, time.November, 4, 10, 45, 0, 0, time.UTC)},
+		{"0 0 1 1 *", base, time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * 0", base, time.Date(2016, time.November, 6, 9, 30, 0, 0, time.UTC)},
+		{"30 9 * * 7", base, time.Date(2016, time.November, 6, 9, 30, 0, 0, time.UTC)},
+		{"0 12 15 * 1", base, time.Date(2016, time.November, 7, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", base, time.Time{}},
+	}
+	for _, t := range tests {
+		spec, err := parseCron(t.spec)
+		c.Assert(err, check.IsNil)
+		c.Check(spec.next(t.from), check.DeepEquals, t.expected, check.Commentf("spec %q", t.spec))
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	MetricCPU    = "cpu"
+	MetricMemory = "memory"
+
+	rulesCollection = "app_autoscale_rules"
+
+	// usageTolerance is the relative distance from the target usage inside
+	// which no scaling happens, avoiding changes due to small fluctuations.
+	usageTolerance = 0.1
+)
+
+var (
+	ErrRuleNotFound    = errors.New("autoscale rule not found")
+	ErrProcessRequired = errors.New("process name is required")
+	ErrInvalidMetric   = errors.New("invalid metric, must be cpu or memory")
+	ErrInvalidTarget   = errors.New("invalid target, must be a percentage between 1 and 100")
+	ErrInvalidMinUnits = errors.New("min units must be greater than zero")
+	ErrInvalidMaxUnits = errors.New("max units must be greater than or equal to min units")
+)
+
+// Rule describes how the units of an app process are automatically scaled
+// based on the average usage of a metric across them.
+type Rule struct {
+	App       string
+	Process   string
+	MinUnits  uint
+	MaxUnits  uint
+	Metric    string
+	Target    float64
+	Enabled   bool
+	LastScale time.Time `json:",omitempty"`
+}
+
+func rulesColl() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	coll := conn.Collection(rulesCollection)
+	coll.EnsureIndex(mgo.Index{Key: []string{"app", "process"}, Unique: true})
+	return coll, nil
+}
+
+func (r *Rule) validate() error {
+	if r.Process == "" {
+		return ErrProcessRequired
+	}
+	if r.Metric != MetricCPU && r.Metric != MetricMemory {
+		return ErrInvalidMetric
+	}
+	if r.Target <= 0 || r.Target > 100 {
+		return ErrInvalidTarget
+	}
+	if r.MinUnits == 0 {
+		return ErrInvalidMinUnits
+	}
+	if r.MaxUnits < r.MinUnits {
+		return ErrInvalidMaxUnits
+	}
+	return nil
+}
+
+// SaveRule validates and stores the rule, replacing any existing rule for
+// the same app process.
+func SaveRule(r *Rule) error {
+	err := r.validate()
+	if err != nil {
+		return err
+	}
+	coll, err := rulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.Upsert(bson.M{"app": r.App, "process": r.Process}, r)
+	return err
+}
+
+// FindRule returns the autoscale rule for the given app process.
+func FindRule(appName, process string) (*Rule, error) {
+	coll, err := rulesColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var rule Rule
+	err = coll.Find(bson.M{"app": appName, "process": process}).One(&rule)
+	if err == mgo.ErrNotFound {
+		return nil, ErrRuleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// ListRules returns the autoscale rules of the given app, or the rules of
+// all apps when appName is empty.
+func ListRules(appName string) ([]Rule, error) {
+	coll, err := rulesColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	query := bson.M{}
+	if appName != "" {
+		query["app"] = appName
+	}
+	var rules []Rule
+	err = coll.Find(query).Sort("app", "process").All(&rules)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// RemoveRule removes the autoscale rule for the given app process.
+func RemoveRule(appName, process string) error {
+	coll, err := rulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Remove(bson.M{"app": appName, "process": process})
+	if err == mgo.ErrNotFound {
+		return ErrRuleNotFound
+	}
+	return err
+}
+
+func (r *Rule) setLastScale(t time.Time) error {
+	coll, err := rulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	r.LastScale = t
+	return coll.Update(bson.M{"app": r.App, "process": r.Process}, bson.M{"$set": bson.M{"lastscale": t}})
+}
+
+// desiredUnits returns the number of units needed to bring the average
+// usage closer to the rule target, always within the rule limits.
+func (r *Rule) desiredUnits(current int, usage float64) int {
+	desired := current
+	ratio := usage / r.Target
+	if math.Abs(ratio-1) > usageTolerance {
+		desired = int(math.Ceil(float64(current) * ratio))
+	}
+	if desired < int(r.MinUnits) {
+		desired = int(r.MinUnits)
+	}
+	if desired > int(r.MaxUnits) {
+		desired = int(r.MaxUnits)
+	}
+	return desired
+}
@@ -0,0 +1,97 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSaveRule(c *check.C) {
+	rule := Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 70, Enabled: true}
+	err := SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	dbRule, err := FindRule("myapp", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(*dbRule, check.DeepEquals, rule)
+	rule.MaxUnits = 10
+	err = SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	rules, err := ListRules("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 1)
+	c.Assert(rules[0].MaxUnits, check.Equals, uint(10))
+}
+
+func (s *S) TestSaveRuleInvalid(c *check.C) {
+	tests := []struct {
+		rule Rule
+		err  error
+	}{
+		{Rule{App: "myapp", MinUnits: 1, MaxUnits: 2, Metric: MetricCPU, Target: 50}, ErrProcessRequired},
+		{Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 2, Metric: "disk", Target: 50}, ErrInvalidMetric},
+		{Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 2, Metric: MetricMemory, Target: 0}, ErrInvalidTarget},
+		{Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 2, Metric: MetricMemory, Target: 101}, ErrInvalidTarget},
+		{Rule{App: "myapp", Process: "web", MinUnits: 0, MaxUnits: 2, Metric: MetricCPU, Target: 50}, ErrInvalidMinUnits},
+		{Rule{App: "myapp", Process: "web", MinUnits: 3, MaxUnits: 2, Metric: MetricCPU, Target: 50}, ErrInvalidMaxUnits},
+	}
+	for _, t := range tests {
+		err := SaveRule(&t.rule)
+		c.Check(err, check.Equals, t.err)
+	}
+	rules, err := ListRules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(rules, check.HasLen, 0)
+}
+
+func (s *S) TestListRules(c *check.C) {
+	rules := []Rule{
+		{App: "app2", Process: "web", MinUnits: 1, MaxUnits: 2, Metric: MetricCPU, Target: 50},
+		{App: "app1", Process: "worker", MinUnits: 1, MaxUnits: 2, Metric: MetricCPU, Target: 50},
+		{App: "app1", Process: "web", MinUnits: 1, MaxUnits: 2, Metric: MetricMemory, Target: 50},
+	}
+	for i := range rules {
+		err := SaveRule(&rules[i])
+		c.Assert(err, check.IsNil)
+	}
+	all, err := ListRules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.DeepEquals, []Rule{rules[2], rules[1], rules[0]})
+	app1, err := ListRules("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(app1, check.DeepEquals, []Rule{rules[2], rules[1]})
+}
+
+func (s *S) TestRemoveRule(c *check.C) {
+	rule := Rule{App: "myapp", Process: "web", MinUnits: 1, MaxUnits: 5, Metric: MetricCPU, Target: 70}
+	err := SaveRule(&rule)
+	c.Assert(err, check.IsNil)
+	err = RemoveRule("myapp", "web")
+	c.Assert(err, check.IsNil)
+	_, err = FindRule("myapp", "web")
+	c.Assert(err, check.Equals, ErrRuleNotFound)
+	err = RemoveRule("myapp", "web")
+	c.Assert(err, check.Equals, ErrRuleNotFound)
+}
+
+func (s *S) TestRuleDesiredUnits(c *check.C) {
+	rule := Rule{MinUnits: 2, MaxUnits: 10, Target: 50}
+	tests := []struct {
+		current  int
+		usage    float64
+		expected int
+	}{
+		{4, 50, 4},
+		{4, 54, 4},
+		{4, 100, 8},
+		{4, 90, 8},
+		{4, 25, 2},
+		{4, 10, 2},
+		{8, 100, 10},
+		{1, 40, 2},
+	}
+	for _, t := range tests {
+		c.Check(rule.desiredUnits(t.current, t.usage), check.Equals, t.expected, check.Commentf("%d units with %.2f%%", t.current, t.usage))
+	}
+}
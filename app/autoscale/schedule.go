@@ -0,0 +1,210 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	schedulesCollection = "app_scaling_schedules"
+
+	// maxScheduleCatchUp is how far in the past the runner looks for missed
+	// executions of a schedule, e.g. while tsurud was down.
+	maxScheduleCatchUp = 24 * time.Hour
+)
+
+var ErrScheduleNotFound = errors.New("scaling schedule not found")
+
+// InvalidScheduleError is returned when trying to save a schedule with
+// invalid data.
+type InvalidScheduleError struct {
+	Reason string
+}
+
+func (e *InvalidScheduleError) Error() string {
+	return e.Reason
+}
+
+// Schedule sets the number of units of an app process at the times matched
+// by a cron expression, e.g. "0 8 * * 1-5" for weekdays at 08:00.
+type Schedule struct {
+	ID       bson.ObjectId `bson:"_id"`
+	App      string
+	Process  string
+	Units    uint
+	Spec     string
+	Timezone string
+	LastRun  time.Time `json:",omitempty"`
+}
+
+func schedulesColl() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	coll := conn.Collection(schedulesCollection)
+	coll.EnsureIndex(mgo.Index{Key: []string{"app"}})
+	return coll, nil
+}
+
+func (s *Schedule) location() (*time.Location, error) {
+	return time.LoadLocation(s.Timezone)
+}
+
+func (s *Schedule) validate() error {
+	if s.Process == "" {
+		return &InvalidScheduleError{Reason: ErrProcessRequired.Error()}
+	}
+	if s.Units == 0 {
+		return &InvalidScheduleError{Reason: "units must be greater than zero"}
+	}
+	_, err := parseCron(s.Spec)
+	if err != nil {
+		return &InvalidScheduleError{Reason: err.Error()}
+	}
+	_, err = s.location()
+	if err != nil {
+		return &InvalidScheduleError{Reason: fmt.Sprintf("invalid timezone %q", s.Timezone)}
+	}
+	return nil
+}
+
+// AddSchedule validates and stores a new schedule. Only times after the
+// schedule creation are considered when applying it.
+func AddSchedule(s *Schedule) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	coll, err := schedulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	s.ID = bson.NewObjectId()
+	s.LastRun = time.Now().UTC()
+	return coll.Insert(s)
+}
+
+// UpdateSchedule validates and stores changes to an existing schedule.
+func UpdateSchedule(s *Schedule) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+	coll, err := schedulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Update(bson.M{"_id": s.ID, "app": s.App}, bson.M{"$set": bson.M{
+		"process":  s.Process,
+		"units":    s.Units,
+		"spec":     s.Spec,
+		"timezone": s.Timezone,
+	}})
+	if err == mgo.ErrNotFound {
+		return ErrScheduleNotFound
+	}
+	return err
+}
+
+// FindSchedule returns the schedule with the given id in the app.
+func FindSchedule(appName, id string) (*Schedule, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, ErrScheduleNotFound
+	}
+	coll, err := schedulesColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var s Schedule
+	err = coll.Find(bson.M{"_id": bson.ObjectIdHex(id), "app": appName}).One(&s)
+	if err == mgo.ErrNotFound {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSchedules returns the schedules of the given app, or the schedules of
+// all apps when appName is empty.
+func ListSchedules(appName string) ([]Schedule, error) {
+	coll, err := schedulesColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	query := bson.M{}
+	if appName != "" {
+		query["app"] = appName
+	}
+	var schedules []Schedule
+	err = coll.Find(query).Sort("app", "process", "_id").All(&schedules)
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// RemoveSchedule removes the schedule with the given id from the app.
+func RemoveSchedule(appName, id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrScheduleNotFound
+	}
+	coll, err := schedulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Remove(bson.M{"_id": bson.ObjectIdHex(id), "app": appName})
+	if err == mgo.ErrNotFound {
+		return ErrScheduleNotFound
+	}
+	return err
+}
+
+func (s *Schedule) setLastRun(t time.Time) error {
+	coll, err := schedulesColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	s.LastRun = t
+	return coll.UpdateId(s.ID, bson.M{"$set": bson.M{"lastrun": t}})
+}
+
+// lastDue returns the most recent time up to now matched by the schedule
+// since its last run, or the zero time if the schedule is not due.
+func (s *Schedule) lastDue(now time.Time) (time.Time, error) {
+	spec, err := parseCron(s.Spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := s.location()
+	if err != nil {
+		return time.Time{}, err
+	}
+	since := s.LastRun
+	if since.Before(now.Add(-maxScheduleCatchUp)) {
+		since = now.Add(-maxScheduleCatchUp)
+	}
+	var due time.Time
+	for t := spec.next(since.In(loc)); !t.IsZero() && !t.After(now); t = spec.next(t) {
+		due = t
+	}
+	return due, nil
+}
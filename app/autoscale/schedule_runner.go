@@ -0,0 +1,176 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const scheduleEventKind = "scheduled-scale"
+
+var ScheduleRunnerInstance *ScheduleRunner
+
+// ScheduleRunner applies the scaling schedules of all apps, checking them
+// once every minute.
+type ScheduleRunner struct {
+	quit chan bool
+}
+
+// ScheduleEventCustomData is stored in the events created when a schedule
+// is applied to an app.
+type ScheduleEventCustomData struct {
+	Schedule Schedule
+	Units    int
+}
+
+// InitializeScheduleRunner starts the background worker responsible for
+// applying the scaling schedules of all apps.
+func InitializeScheduleRunner() (*ScheduleRunner, error) {
+	if ScheduleRunnerInstance != nil {
+		return nil, errors.New("app schedule runner already initialized")
+	}
+	ScheduleRunnerInstance = &ScheduleRunner{quit: make(chan bool)}
+	go func() {
+		defer close(ScheduleRunnerInstance.quit)
+		for {
+			ScheduleRunnerInstance.runOnce(time.Now().UTC())
+			select {
+			case <-ScheduleRunnerInstance.quit:
+				return
+			case <-time.After(time.Minute):
+			}
+		}
+	}()
+	shutdown.Register(ScheduleRunnerInstance)
+	return ScheduleRunnerInstance, nil
+}
+
+func (r *ScheduleRunner) Shutdown() {
+	r.quit <- true
+	<-r.quit
+}
+
+func (r *ScheduleRunner) String() string {
+	return "app schedule runner"
+}
+
+func (r *ScheduleRunner) runOnce(now time.Time) {
+	schedules, err := ListSchedules("")
+	if err != nil {
+		log.Errorf("[app schedules] unable to list schedules: %s", err)
+		return
+	}
+	// When more than one schedule of the same process is due, only the most
+	// recent one is applied.
+	latest := map[string]*Schedule{}
+	latestDue := map[string]time.Time{}
+	var due []*Schedule
+	for i := range schedules {
+		s := &schedules[i]
+		dueTime, err := s.lastDue(now)
+		if err != nil {
+			log.Errorf("[app schedules] invalid schedule %s for app %q: %s", s.ID.Hex(), s.App, err)
+			continue
+		}
+		if dueTime.IsZero() {
+			continue
+		}
+		due = append(due, s)
+		key := s.App + "/" + s.Process
+		if dueTime.After(latestDue[key]) {
+			latest[key] = s
+			latestDue[key] = dueTime
+		}
+	}
+	locked := map[string]bool{}
+	for key, s := range latest {
+		err = r.apply(s)
+		if _, ok := errors.Cause(err).(event.ErrEventLocked); ok {
+			// The app is busy, the schedule will be retried in the next run.
+			log.Debugf("[app schedules] skipping %s, app is locked", s.App)
+			locked[key] = true
+			continue
+		}
+		if _, ok := err.(*provision.ErrPoolInMaintenance); ok {
+			// Schedules are applied once the maintenance window is over.
+			log.Debugf("[app schedules] skipping %s: %s", s.App, err)
+			locked[key] = true
+			continue
+		}
+		if err != nil {
+			log.Errorf("[app schedules] %s", err)
+		}
+	}
+	for _, s := range due {
+		if locked[s.App+"/"+s.Process] {
+			continue
+		}
+		err = s.setLastRun(now)
+		if err != nil {
+			log.Errorf("[app schedules] unable to update schedule %s: %s", s.ID.Hex(), err)
+		}
+	}
+}
+
+func (r *ScheduleRunner) apply(s *Schedule) (err error) {
+	appObj, err := app.GetByName(s.App)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find app %q", s.App)
+	}
+	allUnits, err := appObj.Units()
+	if err != nil {
+		return errors.Wrapf(err, "unable to list units for app %q", s.App)
+	}
+	var units int
+	for _, u := range allUnits {
+		if u.ProcessName == s.Process {
+			units++
+		}
+	}
+	if units == int(s.Units) {
+		return nil
+	}
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: appObj.Name},
+		InternalKind: scheduleEventKind,
+		CustomData:   ScheduleEventCustomData{Schedule: *s, Units: units},
+		Allowed: event.Allowed(permission.PermAppReadEvents, append(permission.Contexts(permission.CtxTeam, appObj.Teams),
+			permission.Context(permission.CtxApp, appObj.Name),
+			permission.Context(permission.CtxPool, appObj.Pool),
+		)...),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to create schedule event for app %q", appObj.Name)
+	}
+	maintErr := provision.CheckMaintenance(appObj.Pool)
+	if _, ok := maintErr.(*provision.ErrPoolInMaintenance); ok {
+		evt.Done(maintErr)
+		return maintErr
+	}
+	defer func() { evt.Done(err) }()
+	if maintErr != nil {
+		return errors.Wrapf(maintErr, "unable to check maintenance of pool %q", appObj.Pool)
+	}
+	fmt.Fprintf(evt, "applying schedule %q: scaling process %q from %d to %d units\n",
+		s.Spec, s.Process, units, s.Units)
+	if int(s.Units) > units {
+		err = appObj.AddUnits(s.Units-uint(units), s.Process, evt)
+	} else {
+		err = appObj.RemoveUnits(uint(units)-s.Units, s.Process, evt)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to scale process %q of app %q", s.Process, appObj.Name)
+	}
+	return nil
+}
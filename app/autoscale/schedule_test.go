@@ -0,0 +1,226 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package autoscale
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestAddSchedule(c *check.C) {
+	schedule := Schedule{App: "myapp", Process: "web", Units: 10, Spec: "0 8 * * 1-5", Timezone: "America/Sao_Paulo"}
+	err := AddSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	c.Assert(schedule.ID.Valid(), check.Equals, true)
+	c.Assert(schedule.LastRun.IsZero(), check.Equals, false)
+	dbSchedule, err := FindSchedule("myapp", schedule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSchedule.Spec, check.Equals, "0 8 * * 1-5")
+	c.Assert(dbSchedule.Units, check.Equals, uint(10))
+	_, err = FindSchedule("otherapp", schedule.ID.Hex())
+	c.Assert(err, check.Equals, ErrScheduleNotFound)
+}
+
+func (s *S) TestAddScheduleInvalid(c *check.C) {
+	tests := []struct {
+		schedule Schedule
+		err      string
+	}{
+		{Schedule{App: "myapp", Units: 1, Spec: "* * * * *"}, "process name is required"},
+		{Schedule{App: "myapp", Process: "web", Spec: "* * * * *"}, "units must be greater than zero"},
+		{Schedule{App: "myapp", Process: "web", Units: 1, Spec: "* * *"}, `invalid schedule "\* \* \*".*`},
+		{Schedule{App: "myapp", Process: "web", Units: 1, Spec: "* * * * *", Timezone: "Nowhere/City"}, `invalid timezone "Nowhere/City"`},
+	}
+	for _, t := range tests {
+		err := AddSchedule(&t.schedule)
+		c.Check(err, check.ErrorMatches, t.err)
+		c.Check(err, check.FitsTypeOf, &InvalidScheduleError{})
+	}
+	schedules, err := ListSchedules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(schedules, check.HasLen, 0)
+}
+
+func (s *S) TestUpdateSchedule(c *check.C) {
+	schedule := Schedule{App: "myapp", Process: "web", Units: 10, Spec: "0 8 * * 1-5"}
+	err := AddSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	schedule.Units = 5
+	schedule.Spec = "0 9 * * *"
+	err = UpdateSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	dbSchedule, err := FindSchedule("myapp", schedule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSchedule.Units, check.Equals, uint(5))
+	c.Assert(dbSchedule.Spec, check.Equals, "0 9 * * *")
+	schedule.ID = bson.NewObjectId()
+	err = UpdateSchedule(&schedule)
+	c.Assert(err, check.Equals, ErrScheduleNotFound)
+}
+
+func (s *S) TestListAndRemoveSchedules(c *check.C) {
+	s1 := Schedule{App: "app1", Process: "web", Units: 10, Spec: "0 8 * * *"}
+	s2 := Schedule{App: "app1", Process: "web", Units: 2, Spec: "0 20 * * *"}
+	s3 := Schedule{App: "app2", Process: "web", Units: 2, Spec: "0 20 * * *"}
+	for _, sched := range []*Schedule{&s1, &s2, &s3} {
+		err := AddSchedule(sched)
+		c.Assert(err, check.IsNil)
+	}
+	schedules, err := ListSchedules("app1")
+	c.Assert(err, check.IsNil)
+	c.Assert(schedules, check.HasLen, 2)
+	c.Assert(schedules[0].ID, check.Equals, s1.ID)
+	c.Assert(schedules[1].ID, check.Equals, s2.ID)
+	err = RemoveSchedule("app2", s1.ID.Hex())
+	c.Assert(err, check.Equals, ErrScheduleNotFound)
+	err = RemoveSchedule("app1", s1.ID.Hex())
+	c.Assert(err, check.IsNil)
+	err = RemoveSchedule("app1", "invalid")
+	c.Assert(err, check.Equals, ErrScheduleNotFound)
+	schedules, err = ListSchedules("")
+	c.Assert(err, check.IsNil)
+	c.Assert(schedules, check.HasLen, 2)
+}
+
+func (s *S) TestScheduleLastDue(c *check.C) {
+	now := time.Date(2016, time.November, 7, 8, 10, 0, 0, time.UTC)
+	schedule := Schedule{Spec: "0 8 * * 1-5", LastRun: now.Add(-time.Hour)}
+	due, err := schedule.lastDue(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(due, check.DeepEquals, time.Date(2016, time.November, 7, 8, 0, 0, 0, time.UTC))
+	schedule.LastRun = now.Add(-5 * time.Minute)
+	due, err = schedule.lastDue(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(due.IsZero(), check.Equals, true)
+	schedule.Timezone = "Asia/Tokyo"
+	schedule.LastRun = now.Add(-time.Hour)
+	due, err = schedule.lastDue(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(due.IsZero(), check.Equals, true)
+	schedule.LastRun = time.Time{}
+	due, err = schedule.lastDue(now)
+	c.Assert(err, check.IsNil)
+	c.Assert(due.UTC(), check.DeepEquals, time.Date(2016, time.November, 6, 23, 0, 0, 0, time.UTC))
+}
+
+func (s *S) TestScheduleRunnerRunOnce(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	now := time.Now().UTC()
+	schedule := Schedule{App: a.Name, Process: "web", Units: 5, Spec: "* * * * *"}
+	err := AddSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	err = schedule.setLastRun(now.Add(-2 * time.Minute))
+	c.Assert(err, check.IsNil)
+	runner := &ScheduleRunner{}
+	runner.runOnce(now)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 5)
+	dbSchedule, err := FindSchedule(a.Name, schedule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSchedule.LastRun.Unix(), check.Equals, now.Unix())
+	c.Assert(eventtest.EventDesc{
+		Target:     event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:       "scheduled-scale",
+		LogMatches: `(?s).*applying schedule "\* \* \* \* \*": scaling process "web" from 2 to 5 units.*`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestScheduleRunnerRunOnceAppliesMostRecent(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	now := time.Date(2016, time.November, 7, 20, 30, 0, 0, time.UTC)
+	morning := Schedule{App: a.Name, Process: "web", Units: 10, Spec: "0 8 * * *"}
+	night := Schedule{App: a.Name, Process: "web", Units: 1, Spec: "0 20 * * *"}
+	for _, sched := range []*Schedule{&morning, &night} {
+		err := AddSchedule(sched)
+		c.Assert(err, check.IsNil)
+		err = sched.setLastRun(now.Add(-20 * time.Hour))
+		c.Assert(err, check.IsNil)
+	}
+	runner := &ScheduleRunner{}
+	runner.runOnce(now)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	schedules, err := ListSchedules(a.Name)
+	c.Assert(err, check.IsNil)
+	for _, sched := range schedules {
+		c.Assert(sched.LastRun.Unix(), check.Equals, now.Unix())
+	}
+	runner.runOnce(now.Add(time.Minute))
+	units, err = s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+}
+
+func (s *S) TestScheduleRunnerRunOnceAppLocked(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	now := time.Now().UTC()
+	schedule := Schedule{App: a.Name, Process: "web", Units: 5, Spec: "* * * * *"}
+	err := AddSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	lastRun := now.Add(-2 * time.Minute)
+	err = schedule.setLastRun(lastRun)
+	c.Assert(err, check.IsNil)
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		InternalKind: "other",
+		Allowed:      event.Allowed(permission.PermAppReadEvents),
+	})
+	c.Assert(err, check.IsNil)
+	runner := &ScheduleRunner{}
+	runner.runOnce(now)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	dbSchedule, err := FindSchedule(a.Name, schedule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSchedule.LastRun.Unix(), check.Equals, lastRun.Unix())
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	runner.runOnce(now)
+	units, err = s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 5)
+}
+
+func (s *S) TestScheduleRunnerRunOncePoolInMaintenance(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	a := s.newApp(c, "myapp", 2)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"pool": "pool1"}})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "database migration",
+	})
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC()
+	schedule := Schedule{App: a.Name, Process: "web", Units: 5, Spec: "* * * * *"}
+	err = AddSchedule(&schedule)
+	c.Assert(err, check.IsNil)
+	lastRun := now.Add(-2 * time.Minute)
+	err = schedule.setLastRun(lastRun)
+	c.Assert(err, check.IsNil)
+	runner := &ScheduleRunner{}
+	runner.runOnce(now)
+	units, err := s.provisioner.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	dbSchedule, err := FindSchedule(a.Name, schedule.ID.Hex())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbSchedule.LastRun.Unix(), check.Equals, lastRun.Unix())
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:         "scheduled-scale",
+		ErrorMatches: `pool "pool1" is under maintenance until .*: database migration`,
+	}, eventtest.HasEvent)
+}
@@ -0,0 +1,247 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsuru/gnuflag"
+	"github.com/tsuru/tsuru/cmd"
+)
+
+type certificateFlags struct {
+	cmd.GuessingCommand
+	fs    *gnuflag.FlagSet
+	cname string
+}
+
+func (c *certificateFlags) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		desc := "The CName served with the certificate."
+		c.fs.StringVar(&c.cname, "cname", "", desc)
+		c.fs.StringVar(&c.cname, "c", "", desc)
+	}
+	return c.fs
+}
+
+type CertificateSet struct {
+	certificateFlags
+}
+
+func (c *CertificateSet) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "certificate-set",
+		Usage: "certificate-set [-a/--app appname] -c/--cname <cname> <certificate file> <key file>",
+		Desc: `Sets the TLS certificate and private key used to serve a CName of an app.
+Both files must be in PEM format, the certificate must match the key and be
+valid for the CName. The router of the app must support TLS.`,
+		MinArgs: 2,
+		MaxArgs: 2,
+	}
+}
+
+func (c *CertificateSet) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.cname == "" {
+		return fmt.Errorf("You must set the cname.")
+	}
+	certificate, err := ioutil.ReadFile(context.Args[0])
+	if err != nil {
+		return err
+	}
+	key, err := ioutil.ReadFile(context.Args[1])
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/certificate", appName))
+	if err != nil {
+		return err
+	}
+	values := url.Values{}
+	values.Set("cname", c.cname)
+	values.Set("certificate", string(certificate))
+	values.Set("key", string(key))
+	request, err := http.NewRequest("PUT", u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Certificate set.")
+	return nil
+}
+
+type CertificateUnset struct {
+	certificateFlags
+}
+
+func (c *CertificateUnset) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "certificate-unset",
+		Usage: "certificate-unset [-a/--app appname] -c/--cname <cname>",
+		Desc:  "Removes the TLS certificate used to serve a CName of an app.",
+	}
+}
+
+func (c *CertificateUnset) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.cname == "" {
+		return fmt.Errorf("You must set the cname.")
+	}
+	v := url.Values{}
+	v.Set("cname", c.cname)
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/certificate?%s", appName, v.Encode()))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Certificate removed.")
+	return nil
+}
+
+type CertificateList struct {
+	cmd.GuessingCommand
+}
+
+func (c *CertificateList) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "certificate-list",
+		Usage: "certificate-list [-a/--app appname]",
+		Desc:  "Lists the CNames of an app served with a TLS certificate.",
+	}
+}
+
+func (c *CertificateList) Run(context *cmd.Context, client *cmd.Client) error {
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/certificate", appName))
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var certificates map[string]string
+	err = json.NewDecoder(response.Body).Decode(&certificates)
+	if err != nil {
+		return err
+	}
+	if len(certificates) == 0 {
+		fmt.Fprintln(context.Stdout, "No certificates found.")
+		return nil
+	}
+	cnames := make([]string, 0, len(certificates))
+	for cname := range certificates {
+		cnames = append(cnames, cname)
+	}
+	sort.Strings(cnames)
+	tbl := cmd.NewTable()
+	tbl.Headers = cmd.Row{"CName", "Expires", "Issuer"}
+	for _, cname := range cnames {
+		expires, issuer := "-", "-"
+		block, _ := pem.Decode([]byte(certificates[cname]))
+		if block != nil {
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				expires = cert.NotAfter.UTC().Format(time.RFC3339)
+				issuer = cert.Issuer.CommonName
+				if issuer == "" && len(cert.Issuer.Organization) > 0 {
+					issuer = cert.Issuer.Organization[0]
+				}
+			}
+		}
+		tbl.AddRow(cmd.Row{cname, expires, issuer})
+	}
+	fmt.Fprint(context.Stdout, tbl.String())
+	return nil
+}
+
+type AppMigrate struct {
+	cmd.GuessingCommand
+	fs   *gnuflag.FlagSet
+	pool string
+}
+
+func (c *AppMigrate) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "app-migrate",
+		Usage: "app-migrate [-a/--app appname] -p/--pool <pool>",
+		Desc: `Moves an app to a pool managed by a different provisioner.
+
+The current image of the app is deployed in the new provisioner, with the same
+number of units per process. Routes are only swapped after all new units are
+healthy, and the old units are removed in the end.`,
+	}
+}
+
+func (c *AppMigrate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.GuessingCommand.Flags()
+		desc := "The pool the app will be moved to."
+		c.fs.StringVar(&c.pool, "pool", "", desc)
+		c.fs.StringVar(&c.pool, "p", "", desc)
+	}
+	return c.fs
+}
+
+func (c *AppMigrate) Run(context *cmd.Context, client *cmd.Client) error {
+	context.RawOutput()
+	appName, err := c.Guess()
+	if err != nil {
+		return err
+	}
+	if c.pool == "" {
+		return fmt.Errorf("You must set the pool.")
+	}
+	u, err := cmd.GetURL(fmt.Sprintf("/apps/%s/migrate", appName))
+	if err != nil {
+		return err
+	}
+	v := url.Values{}
+	v.Set("pool", c.pool)
+	request, err := http.NewRequest("POST", u, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	return cmd.StreamJSONResponse(context.Stdout, response)
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+
+	"github.com/tsuru/tsuru/cmd"
+	"github.com/tsuru/tsuru/cmd/cmdtest"
+	tsuruIo "github.com/tsuru/tsuru/io"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCertificateSetRun(c *check.C) {
+	dir := c.MkDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err := ioutil.WriteFile(certFile, []byte("my certificate"), 0600)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(keyFile, []byte("my key"), 0600)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	context := cmd.Context{Args: []string{certFile, keyFile}, Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/certificate" && req.Method == "PUT" &&
+				req.FormValue("cname") == "myapp.example.com" &&
+				req.FormValue("certificate") == "my certificate" && req.FormValue("key") == "my key"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := CertificateSet{}
+	command.Flags().Parse(true, []string{"-a", "myapp", "-c", "myapp.example.com"})
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Certificate set.\n")
+}
+
+func (s *S) TestCertificateSetRunMissingCName(c *check.C) {
+	context := cmd.Context{Args: []string{"cert.pem", "key.pem"}, Stdout: &bytes.Buffer{}}
+	command := CertificateSet{}
+	command.Flags().Parse(true, []string{"-a", "myapp"})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "You must set the cname.")
+}
+
+func (s *S) TestCertificateUnsetRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/certificate" && req.Method == "DELETE" &&
+				req.URL.Query().Get("cname") == "myapp.example.com"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := CertificateUnset{}
+	command.Flags().Parse(true, []string{"-a", "myapp", "--cname", "myapp.example.com"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "Certificate removed.\n")
+}
+
+func (s *S) TestCertificateListRun(c *check.C) {
+	cert, _, err := routertest.GenerateCertificate("myapp.example.com")
+	c.Assert(err, check.IsNil)
+	body, err := json.Marshal(map[string]string{"myapp.example.com": cert, "other.example.com": "invalid"})
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(body), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/certificate" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := CertificateList{}
+	command.Flags().Parse(true, []string{"-a", "myapp"})
+	err = command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*\| CName +\| Expires +\| Issuer +\|.*`+
+		`\| myapp\.example\.com +\| \d{4}-\d{2}-\d{2}T[^ ]+Z \| myapp\.example\.com \|.*`+
+		`\| other\.example\.com +\| - +\| - +\|.*`)
+}
+
+func (s *S) TestCertificateListRunEmpty(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "{}", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/certificate" && req.Method == "GET"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := CertificateList{}
+	command.Flags().Parse(true, []string{"-a", "myapp"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "No certificates found.\n")
+}
+
+func (s *S) TestAppMigrateRun(c *check.C) {
+	var buf bytes.Buffer
+	context := cmd.Context{Stdout: &buf}
+	msg, _ := json.Marshal(tsuruIo.SimpleJsonMessage{Message: "migrated\n"})
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(msg), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/apps/myapp/migrate" && req.Method == "POST" &&
+				req.FormValue("pool") == "pool2"
+		},
+	}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &cmd.Manager{})
+	command := AppMigrate{}
+	command.Flags().Parse(true, []string{"-a", "myapp", "-p", "pool2"})
+	err := command.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "migrated\n")
+}
+
+func (s *S) TestAppMigrateRunMissingPool(c *check.C) {
+	context := cmd.Context{Stdout: &bytes.Buffer{}}
+	command := AppMigrate{}
+	command.Flags().Parse(true, []string{"-a", "myapp"})
+	err := command.Run(&context, nil)
+	c.Assert(err, check.ErrorMatches, "You must set the pool.")
+}
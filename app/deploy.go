@@ -267,6 +267,38 @@ func deployToProvisioner(opts *DeployOptions, evt *event.Event) (string, error)
 	return "", provision.ProvisionerNotSupported{Prov: prov, Action: fmt.Sprintf("%s deploy", opts.GetKind())}
 }
 
+// PromoteCanary promotes the canary deploy in progress for the app, the
+// remaining units are then replaced by units running the new image.
+func PromoteCanary(app *App) error {
+	deployer, err := app.canaryDeployer()
+	if err != nil {
+		return err
+	}
+	return deployer.PromoteCanary(app)
+}
+
+// AbortCanary aborts the canary deploy in progress for the app, removing the
+// canary units and restoring the traffic to the previous units.
+func AbortCanary(app *App) error {
+	deployer, err := app.canaryDeployer()
+	if err != nil {
+		return err
+	}
+	return deployer.AbortCanary(app)
+}
+
+func (app *App) canaryDeployer() (provision.CanaryDeployer, error) {
+	prov, err := app.getProvisioner()
+	if err != nil {
+		return nil, err
+	}
+	deployer, ok := prov.(provision.CanaryDeployer)
+	if !ok {
+		return nil, provision.ProvisionerNotSupported{Prov: prov, Action: "canary deploys"}
+	}
+	return deployer, nil
+}
+
 func ValidateOrigin(origin string) bool {
 	originList := []string{"app-deploy", "git", "rollback", "drag-and-drop", "image"}
 	for _, ol := range originList {
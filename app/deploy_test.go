@@ -788,6 +788,30 @@ func (s *S) TestDeployKind(c *check.C) {
 	}
 }
 
+func (s *S) TestPromoteCanary(c *check.C) {
+	a := App{Name: "otherapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	s.provisioner.StartCanary(&a)
+	err = PromoteCanary(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.CanaryState(&a), check.Equals, "promoted")
+	err = PromoteCanary(&a)
+	c.Assert(err, check.Equals, provision.ErrCanaryNotFound)
+}
+
+func (s *S) TestAbortCanary(c *check.C) {
+	a := App{Name: "otherapp", Platform: "zend", TeamOwner: s.team.Name}
+	err := CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = AbortCanary(&a)
+	c.Assert(err, check.Equals, provision.ErrCanaryNotFound)
+	s.provisioner.StartCanary(&a)
+	err = AbortCanary(&a)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.provisioner.CanaryState(&a), check.Equals, "aborted")
+}
+
 func (s *S) TestMigrateDeploysToEvents(c *check.C) {
 	a := App{Name: "g1", TeamOwner: s.team.Name}
 	err := CreateApp(&a, s.user)
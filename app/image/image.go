@@ -33,6 +33,10 @@ type appImages struct {
 	Count   int
 }
 
+// processSettingsKey is the custom data field holding the per process
+// settings declared in tsuru.yaml, read into provision.TsuruYamlData.Processes.
+const processSettingsKey = "process_settings"
+
 var procfileRegex = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
 var ErrNoImagesAvailable = errors.New("no images available for app")
 
@@ -60,14 +64,26 @@ func SaveImageCustomData(imageName string, customData map[string]interface{}) er
 	}
 	defer coll.Close()
 	var processes map[string]string
+	// Processes declared with a command come from the Procfile, the ones
+	// declared with a map hold the per process settings of tsuru.yaml and are
+	// kept in a separate field of the custom data.
+	processSettings := map[string]interface{}{}
 	if data, ok := customData["processes"]; ok {
 		procs := data.(map[string]interface{})
-		processes = make(map[string]string, len(procs))
-		for name, command := range procs {
-			processes[name] = command.(string)
+		for name, value := range procs {
+			if command, isCommand := value.(string); isCommand {
+				if processes == nil {
+					processes = make(map[string]string, len(procs))
+				}
+				processes[name] = command
+			} else {
+				processSettings[name] = value
+			}
 		}
 		delete(customData, "processes")
-		delete(customData, "procfile")
+		if processes != nil {
+			delete(customData, "procfile")
+		}
 	}
 	if data, ok := customData["procfile"]; ok {
 		procfile := data.(string)
@@ -77,6 +93,9 @@ func SaveImageCustomData(imageName string, customData map[string]interface{}) er
 		}
 		delete(customData, "procfile")
 	}
+	if len(processSettings) > 0 {
+		customData[processSettingsKey] = processSettings
+	}
 	data := ImageMetadata{
 		Name:       imageName,
 		CustomData: customData,
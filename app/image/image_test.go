@@ -279,6 +279,71 @@ func (s *S) TestGetImageWebProcessName(c *check.C) {
 	c.Check(web5, check.Equals, "")
 }
 
+func (s *S) TestSaveImageCustomDataProcessSettings(c *check.C) {
+	img := "tsuru/app-myapp:v1"
+	customData := map[string]interface{}{
+		"procfile": "web: python web.py\nworker: python worker.py",
+		"healthcheck": map[string]interface{}{
+			"path": "/status",
+		},
+		"processes": map[string]interface{}{
+			"worker": map[string]interface{}{
+				"healthcheck": map[string]interface{}{
+					"type":    "command",
+					"command": "pgrep worker",
+				},
+				"allowed_failures": 2,
+			},
+		},
+	}
+	err := image.SaveImageCustomData(img, customData)
+	c.Assert(err, check.IsNil)
+	imageMetaData, err := image.GetImageCustomData(img)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageMetaData.Processes, check.DeepEquals, map[string]string{
+		"web":    "python web.py",
+		"worker": "python worker.py",
+	})
+	yamlData, err := image.GetImageTsuruYamlData(img)
+	c.Assert(err, check.IsNil)
+	allowedFailures := 2
+	c.Assert(yamlData.Processes, check.DeepEquals, map[string]provision.TsuruYamlProcess{
+		"worker": {
+			Healthcheck:     &provision.TsuruYamlHealthcheck{Type: "command", Command: "pgrep worker"},
+			AllowedFailures: &allowedFailures,
+		},
+	})
+	c.Assert(yamlData.ProcessData("web").Healthcheck, check.DeepEquals, provision.TsuruYamlHealthcheck{Path: "/status"})
+	c.Assert(yamlData.ProcessData("worker").Healthcheck, check.DeepEquals, provision.TsuruYamlHealthcheck{
+		Type:            "command",
+		Command:         "pgrep worker",
+		AllowedFailures: 2,
+	})
+}
+
+func (s *S) TestSaveImageCustomDataProcessesWithCommandAndSettings(c *check.C) {
+	img := "tsuru/app-myapp:v1"
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python web.py",
+			"worker": map[string]interface{}{
+				"healthcheck": map[string]interface{}{"type": "tcp"},
+			},
+		},
+	}
+	err := image.SaveImageCustomData(img, customData)
+	c.Assert(err, check.IsNil)
+	imageMetaData, err := image.GetImageCustomData(img)
+	c.Assert(err, check.IsNil)
+	c.Assert(imageMetaData.Processes, check.DeepEquals, map[string]string{
+		"web": "python web.py",
+	})
+	c.Assert(imageMetaData.CustomData["processes"], check.IsNil)
+	yamlData, err := image.GetImageTsuruYamlData(img)
+	c.Assert(err, check.IsNil)
+	c.Assert(yamlData.ProcessData("worker").Healthcheck, check.DeepEquals, provision.TsuruYamlHealthcheck{Type: "tcp"})
+}
+
 func (s *S) TestSavePortInImageCustomData(c *check.C) {
 	img1 := "tsuru/app-myapp:v1"
 	customData1 := map[string]interface{}{
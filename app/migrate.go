@@ -0,0 +1,452 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/router/rebuild"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var ErrMigrationCanceled = errors.New("migration canceled by user action")
+
+var (
+	migrateUnitsTimeout       = 5 * time.Minute
+	migrateUnitsPollInterval  = time.Second
+	migrateHealthcheckTimeout = 5 * time.Second
+)
+
+type migrateArgs struct {
+	app     *App
+	source  *App
+	target  *App
+	oldProv provision.Provisioner
+	newProv provision.Provisioner
+	image   string
+	// units holds the number of units of each process of the app before the
+	// migration, the same number of units must be healthy in the new
+	// provisioner before the routes are swapped.
+	units map[string]int
+	evt   *event.Event
+	w     io.Writer
+}
+
+// Migrate moves the app to a pool managed by a different provisioner. The
+// current image is deployed in the new provisioner, with the same number of
+// units per process, and the routes are only swapped after all new units are
+// started. Old units are removed in the end.
+func (app *App) Migrate(poolName string, evt *event.Event) error {
+	if poolName == "" {
+		return errors.New("pool is required")
+	}
+	if poolName == app.Pool {
+		return fmt.Errorf("app %q is already in pool %q", app.Name, poolName)
+	}
+	_, err := app.getPoolForApp(poolName)
+	if err != nil {
+		return err
+	}
+	pool, err := provision.GetPoolByName(poolName)
+	if err != nil {
+		return err
+	}
+	newProv, err := pool.GetProvisioner()
+	if err != nil {
+		return err
+	}
+	oldProv, err := app.getProvisioner()
+	if err != nil {
+		return err
+	}
+	if newProv.GetName() == oldProv.GetName() {
+		return fmt.Errorf("pool %q uses the same provisioner as the app, use app-update to change the pool", poolName)
+	}
+	if _, ok := newProv.(provision.ImageDeployer); !ok {
+		return provision.ProvisionerNotSupported{Prov: newProv, Action: "app migration"}
+	}
+	images, err := image.ListValidAppImages(app.Name)
+	if err != nil {
+		return err
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("app %q must be deployed before being migrated", app.Name)
+	}
+	imageName, err := image.AppCurrentImageName(app.Name)
+	if err != nil {
+		return err
+	}
+	oldUnits, err := oldProv.Units(app)
+	if err != nil {
+		return err
+	}
+	source := *app
+	target := *app
+	target.Pool = poolName
+	target.provisioner = newProv
+	args := &migrateArgs{
+		app:     app,
+		source:  &source,
+		target:  &target,
+		oldProv: oldProv,
+		newProv: newProv,
+		image:   imageName,
+		units:   unitsByProcess(oldUnits),
+		evt:     evt,
+		w:       evt,
+	}
+	fmt.Fprintf(args.w, "---- Migrating app %q from %q to %q ----\n", app.Name, oldProv.GetName(), newProv.GetName())
+	actions := []*action.Action{
+		&migrateDeployNewUnits,
+		&migrateWaitNewUnits,
+		&migrateAddNewRoutes,
+		&migrateSaveApp,
+		&migrateRemoveOldUnits,
+		&migrateRemoveOldRoutes,
+	}
+	err = action.NewPipeline(actions...).Execute(args)
+	if err != nil {
+		return err
+	}
+	rebuild.RoutesRebuildOrEnqueue(app.Name)
+	fmt.Fprintf(args.w, "---- App %q migrated to pool %q ----\n", app.Name, poolName)
+	return nil
+}
+
+func checkMigrationCanceled(evt *event.Event) error {
+	if evt == nil {
+		return nil
+	}
+	canceled, err := evt.AckCancel()
+	if err != nil {
+		log.Errorf("unable to check if event should be canceled, ignoring: %s", err)
+		return nil
+	}
+	if canceled {
+		return ErrMigrationCanceled
+	}
+	return nil
+}
+
+func unitsByProcess(units []provision.Unit) map[string]int {
+	result := map[string]int{}
+	for _, u := range units {
+		result[u.ProcessName]++
+	}
+	return result
+}
+
+func routableAddresses(prov provision.Provisioner, a provision.App) ([]*url.URL, error) {
+	units, err := prov.RoutableUnits(a)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]*url.URL, len(units))
+	for i := range units {
+		addrs[i] = units[i].Address
+	}
+	return addrs, nil
+}
+
+var migrateDeployNewUnits = action.Action{
+	Name: "migrate-deploy-new-units",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(*migrateArgs)
+		if err := checkMigrationCanceled(args.evt); err != nil {
+			return nil, err
+		}
+		err := args.newProv.Provision(args.target)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(args.w, "---- Deploying image %q in %q ----\n", args.image, args.newProv.GetName())
+		_, err = args.newProv.(provision.ImageDeployer).ImageDeploy(args.target, args.image, args.evt)
+		if err != nil {
+			return nil, err
+		}
+		newUnits, err := args.newProv.Units(args.target)
+		if err != nil {
+			return nil, err
+		}
+		current := unitsByProcess(newUnits)
+		for process, count := range args.units {
+			if count <= current[process] {
+				continue
+			}
+			if err = checkMigrationCanceled(args.evt); err != nil {
+				return nil, err
+			}
+			_, err = args.newProv.AddUnits(args.target, uint(count-current[process]), process, args.w)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(*migrateArgs)
+		units, err := args.newProv.Units(args.target)
+		if err != nil {
+			log.Errorf("BACKWARD ABORTED - failed to list new units: %s", err)
+			return
+		}
+		for process, count := range unitsByProcess(units) {
+			err = args.newProv.RemoveUnits(args.target, uint(count), process, nil)
+			if err != nil {
+				log.Errorf("[migrate-deploy-new-units:Backward] failed to remove units from %q: %s", process, err)
+			}
+		}
+	},
+	MinParams: 1,
+}
+
+// migrateWaitNewUnits waits until the new provisioner has, for each process,
+// as many started units passing the healthcheck of the image as the app had
+// before the migration.
+var migrateWaitNewUnits = action.Action{
+	Name: "migrate-wait-new-units",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(*migrateArgs)
+		yamlData, err := image.GetImageTsuruYamlData(args.image)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(args.w, "---- Waiting for new units to start ----")
+		timeout := time.After(migrateUnitsTimeout)
+		for {
+			if err = checkMigrationCanceled(args.evt); err != nil {
+				return nil, err
+			}
+			units, err := args.newProv.Units(args.target)
+			if err != nil {
+				return nil, err
+			}
+			healthy := map[string]int{}
+			for _, u := range units {
+				if u.Status == provision.StatusError {
+					return nil, fmt.Errorf("unit %q failed to start", u.ID)
+				}
+				if u.Status != provision.StatusStarted {
+					continue
+				}
+				err = checkMigrationUnit(u, yamlData.ProcessData(u.ProcessName).Healthcheck)
+				if err != nil {
+					fmt.Fprintf(args.w, " ---> healthcheck fail(%s): %s\n", u.ID, err)
+					continue
+				}
+				healthy[u.ProcessName]++
+			}
+			ready := true
+			for process, count := range args.units {
+				if healthy[process] < count {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				return nil, nil
+			}
+			select {
+			case <-timeout:
+				return nil, fmt.Errorf("timeout after %v waiting for new units to start", migrateUnitsTimeout)
+			case <-time.After(migrateUnitsPollInterval):
+			}
+		}
+	},
+	MinParams: 1,
+}
+
+// checkMigrationUnit runs the healthcheck against the unit address. Command
+// healthchecks and http healthchecks without a path are left to the new
+// provisioner, as they are during deploys.
+func checkMigrationUnit(u provision.Unit, hc provision.TsuruYamlHealthcheck) error {
+	switch hc.CheckType() {
+	case provision.HealthcheckTypeHTTP:
+		if hc.Path == "" {
+			return nil
+		}
+	case provision.HealthcheckTypeTCP:
+	default:
+		return nil
+	}
+	if u.Address == nil || u.Address.Host == "" {
+		return nil
+	}
+	return hc.CheckAddr(u.Address.Host, migrateHealthcheckTimeout)
+}
+
+var migrateAddNewRoutes = action.Action{
+	Name: "migrate-add-new-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(*migrateArgs)
+		if err := checkMigrationCanceled(args.evt); err != nil {
+			return nil, err
+		}
+		r, err := args.app.Router()
+		if err != nil {
+			return nil, err
+		}
+		addrs, err := routableAddresses(args.newProv, args.target)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(args.w, "---- Adding %d new routes ----\n", len(addrs))
+		return nil, r.AddRoutes(args.app.Name, addrs)
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(*migrateArgs)
+		r, err := args.app.Router()
+		if err != nil {
+			log.Errorf("BACKWARD ABORTED - failed to retrieve router: %s", err)
+			return
+		}
+		newAddrs, err := routableAddresses(args.newProv, args.target)
+		if err != nil {
+			log.Errorf("BACKWARD ABORTED - failed to list new units: %s", err)
+			return
+		}
+		oldAddrs, err := routableAddresses(args.oldProv, args.source)
+		if err != nil {
+			log.Errorf("BACKWARD ABORTED - failed to list old units: %s", err)
+			return
+		}
+		oldMap := make(map[string]struct{}, len(oldAddrs))
+		for _, addr := range oldAddrs {
+			oldMap[addr.String()] = struct{}{}
+		}
+		var toRemove []*url.URL
+		for _, addr := range newAddrs {
+			if _, ok := oldMap[addr.String()]; !ok {
+				toRemove = append(toRemove, addr)
+			}
+		}
+		err = r.RemoveRoutes(args.app.Name, toRemove)
+		if err != nil {
+			log.Errorf("[migrate-add-new-routes:Backward] failed to remove new routes: %s", err)
+		}
+	},
+	MinParams: 1,
+}
+
+var migrateSaveApp = action.Action{
+	Name: "migrate-save-app",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(*migrateArgs)
+		if err := checkMigrationCanceled(args.evt); err != nil {
+			return nil, err
+		}
+		conn, err := db.Conn()
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		err = conn.Apps().Update(bson.M{"name": args.app.Name}, bson.M{"$set": bson.M{"pool": args.target.Pool}})
+		if err != nil {
+			return nil, err
+		}
+		args.app.Pool = args.target.Pool
+		args.app.provisioner = args.newProv
+		return nil, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(*migrateArgs)
+		conn, err := db.Conn()
+		if err != nil {
+			log.Errorf("BACKWARD ABORTED - failed to get database connection: %s", err)
+			return
+		}
+		defer conn.Close()
+		err = conn.Apps().Update(bson.M{"name": args.app.Name}, bson.M{"$set": bson.M{"pool": args.source.Pool}})
+		if err != nil {
+			log.Error(err.Error())
+		}
+		args.app.Pool = args.source.Pool
+		args.app.provisioner = args.oldProv
+	},
+	MinParams: 1,
+}
+
+// migrateRemoveOldUnits never fails because the app is already routed to the
+// new units, errors are only reported to the user. Provisioners able to remove
+// every unit of the app also remove the objects created to run them, the
+// others have their units removed process by process.
+var migrateRemoveOldUnits = action.Action{
+	Name: "migrate-remove-old-units",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(*migrateArgs)
+		if remover, ok := args.oldProv.(provision.UnitsRemoverProvisioner); ok {
+			fmt.Fprintln(args.w, "---- Removing old units ----")
+			err := remover.RemoveAllUnits(args.source)
+			if err != nil {
+				fmt.Fprintf(args.w, "WARNING: unable to remove old units: %s\n", err)
+			}
+			return nil, nil
+		}
+		units, err := args.oldProv.Units(args.source)
+		if err != nil {
+			fmt.Fprintf(args.w, "WARNING: unable to list old units: %s\n", err)
+			return nil, nil
+		}
+		fmt.Fprintf(args.w, "---- Removing %d old units ----\n", len(units))
+		for process, count := range unitsByProcess(units) {
+			err = args.oldProv.RemoveUnits(args.source, uint(count), process, nil)
+			if err != nil {
+				fmt.Fprintf(args.w, "WARNING: unable to remove old units from %q: %s\n", process, err)
+			}
+		}
+		return nil, nil
+	},
+	MinParams: 1,
+}
+
+// migrateRemoveOldRoutes removes any route not pointing to one of the new
+// units, it never fails for the same reason as migrateRemoveOldUnits.
+var migrateRemoveOldRoutes = action.Action{
+	Name: "migrate-remove-old-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(*migrateArgs)
+		r, err := args.app.Router()
+		if err != nil {
+			fmt.Fprintf(args.w, "WARNING: unable to retrieve router: %s\n", err)
+			return nil, nil
+		}
+		routes, err := r.Routes(args.app.Name)
+		if err != nil {
+			fmt.Fprintf(args.w, "WARNING: unable to list routes: %s\n", err)
+			return nil, nil
+		}
+		newAddrs, err := routableAddresses(args.newProv, args.target)
+		if err != nil {
+			fmt.Fprintf(args.w, "WARNING: unable to list new units: %s\n", err)
+			return nil, nil
+		}
+		newMap := make(map[string]struct{}, len(newAddrs))
+		for _, addr := range newAddrs {
+			newMap[addr.String()] = struct{}{}
+		}
+		var toRemove []*url.URL
+		for _, route := range routes {
+			if _, ok := newMap[route.String()]; !ok {
+				toRemove = append(toRemove, route)
+			}
+		}
+		err = r.RemoveRoutes(args.app.Name, toRemove)
+		if err != nil {
+			fmt.Fprintf(args.w, "WARNING: unable to remove old routes: %s\n", err)
+		}
+		return nil, nil
+	},
+	MinParams: 1,
+}
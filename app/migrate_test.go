@@ -0,0 +1,198 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/tsuru/action"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"github.com/tsuru/tsuru/safe"
+	"gopkg.in/check.v1"
+)
+
+type migrateFakeProvisioner struct {
+	*provisiontest.FakeProvisioner
+}
+
+func (p *migrateFakeProvisioner) GetName() string {
+	return "fake-migrate"
+}
+
+var migrateProvisioner = &migrateFakeProvisioner{FakeProvisioner: provisiontest.NewFakeProvisioner()}
+
+func init() {
+	provision.Register("fake-migrate", func() (provision.Provisioner, error) {
+		return migrateProvisioner, nil
+	})
+}
+
+func (s *S) prepareMigrateApp(c *check.C) (*App, *event.Event) {
+	migrateProvisioner.Reset()
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool2", Public: true, Provisioner: "fake-migrate"})
+	c.Assert(err, check.IsNil)
+	a := App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err = CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.Name, "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnits(&a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	_, err = s.provisioner.AddUnits(&a, 1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:        event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		Kind:          permission.PermAppUpdateMigrate,
+		RawOwner:      event.Owner{Type: event.OwnerTypeUser, Name: s.user.Email},
+		Allowed:       event.Allowed(permission.PermApp),
+		AllowedCancel: event.Allowed(permission.PermApp),
+		Cancelable:    true,
+	})
+	c.Assert(err, check.IsNil)
+	return &a, evt
+}
+
+func (s *S) TestMigrate(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	err := a.Migrate("pool2", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(a.Pool, check.Equals, "pool2")
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, "pool2")
+	prov, err := dbApp.getProvisioner()
+	c.Assert(err, check.IsNil)
+	c.Assert(prov.GetName(), check.Equals, "fake-migrate")
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 0)
+	newUnits := migrateProvisioner.GetUnits(a)
+	c.Assert(unitsByProcess(newUnits), check.DeepEquals, map[string]int{"web": 2, "worker": 1})
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 3)
+	for _, u := range newUnits {
+		c.Assert(routertest.FakeRouter.HasRoute(a.Name, u.Address.String()), check.Equals, true)
+	}
+}
+
+func (s *S) TestMigrateRemoveOldUnitsFailure(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	s.provisioner.PrepareFailure("RemoveAllUnits", errors.New("cannot remove services"))
+	w := safe.NewBuffer(nil)
+	args := &migrateArgs{app: a, source: a, oldProv: s.provisioner, evt: evt, w: w}
+	_, err := migrateRemoveOldUnits.Forward(action.FWContext{Params: []interface{}{args}})
+	c.Assert(err, check.IsNil)
+	c.Assert(w.String(), check.Matches, `(?s).*WARNING: unable to remove old units: cannot remove services.*`)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 3)
+}
+
+func (s *S) TestMigrateSamePool(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	err := a.Migrate(s.Pool, evt)
+	c.Assert(err, check.ErrorMatches, `app "myapp" is already in pool "pool1"`)
+}
+
+func (s *S) TestMigrateSameProvisioner(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool3", Public: true})
+	c.Assert(err, check.IsNil)
+	err = a.Migrate("pool3", evt)
+	c.Assert(err, check.ErrorMatches, `pool "pool3" uses the same provisioner as the app, use app-update to change the pool`)
+}
+
+func (s *S) TestMigrateNotDeployed(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	err := image.DeleteAllAppImageNames(a.Name)
+	c.Assert(err, check.IsNil)
+	err = a.Migrate("pool2", evt)
+	c.Assert(err, check.ErrorMatches, `app "myapp" must be deployed before being migrated`)
+}
+
+func (s *S) TestMigrateRollbackOnFailure(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	oldUnits := s.provisioner.GetUnits(a)
+	migrateProvisioner.PrepareFailure("AddUnits", errors.New("add units failed"))
+	err := a.Migrate("pool2", evt)
+	c.Assert(err, check.ErrorMatches, "add units failed")
+	c.Assert(a.Pool, check.Equals, s.Pool)
+	dbApp, err := GetByName(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Pool, check.Equals, s.Pool)
+	c.Assert(migrateProvisioner.GetUnits(a), check.HasLen, 0)
+	c.Assert(s.provisioner.GetUnits(a), check.DeepEquals, oldUnits)
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.HasLen, 3)
+}
+
+func (s *S) TestMigrateWaitNewUnitsError(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	err := migrateProvisioner.Provision(a)
+	c.Assert(err, check.IsNil)
+	migrateProvisioner.AddUnit(a, provision.Unit{ID: "u1", AppName: a.Name, ProcessName: "web", Status: provision.StatusError})
+	args := &migrateArgs{app: a, target: a, newProv: migrateProvisioner, evt: evt, w: evt}
+	_, err = migrateWaitNewUnits.Forward(action.FWContext{Params: []interface{}{args}})
+	c.Assert(err, check.ErrorMatches, `unit "u1" failed to start`)
+}
+
+func (s *S) TestMigrateWaitNewUnitsMissingUnits(c *check.C) {
+	defer func(timeout, interval time.Duration) {
+		migrateUnitsTimeout, migrateUnitsPollInterval = timeout, interval
+	}(migrateUnitsTimeout, migrateUnitsPollInterval)
+	migrateUnitsTimeout, migrateUnitsPollInterval = 100*time.Millisecond, 10*time.Millisecond
+	a, evt := s.prepareMigrateApp(c)
+	err := migrateProvisioner.Provision(a)
+	c.Assert(err, check.IsNil)
+	migrateProvisioner.AddUnit(a, provision.Unit{ID: "u1", AppName: a.Name, ProcessName: "web", Status: provision.StatusStarted})
+	args := &migrateArgs{app: a, target: a, newProv: migrateProvisioner, image: "tsuru/app-myapp:v1", units: map[string]int{"web": 2}, evt: evt, w: evt}
+	_, err = migrateWaitNewUnits.Forward(action.FWContext{Params: []interface{}{args}})
+	c.Assert(err, check.ErrorMatches, `timeout after .* waiting for new units to start`)
+}
+
+func (s *S) TestMigrateWaitNewUnitsHealthcheck(c *check.C) {
+	defer func(interval time.Duration) { migrateUnitsPollInterval = interval }(migrateUnitsPollInterval)
+	migrateUnitsPollInterval = 10 * time.Millisecond
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/hc" || calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	a, evt := s.prepareMigrateApp(c)
+	err := image.SaveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{
+		"healthcheck": map[string]interface{}{"path": "/hc"},
+	})
+	c.Assert(err, check.IsNil)
+	err = migrateProvisioner.Provision(a)
+	c.Assert(err, check.IsNil)
+	addr, _ := url.Parse(server.URL)
+	migrateProvisioner.AddUnit(a, provision.Unit{ID: "u1", AppName: a.Name, ProcessName: "web", Status: provision.StatusStarted, Address: addr})
+	w := safe.NewBuffer(nil)
+	args := &migrateArgs{app: a, target: a, newProv: migrateProvisioner, image: "tsuru/app-myapp:v1", units: map[string]int{"web": 1}, evt: evt, w: w}
+	_, err = migrateWaitNewUnits.Forward(action.FWContext{Params: []interface{}{args}})
+	c.Assert(err, check.IsNil)
+	c.Assert(calls, check.Equals, 3)
+	c.Assert(w.String(), check.Matches, `(?s).*healthcheck fail\(u1\): wrong status code, expected 200, got: 503.*`)
+}
+
+func (s *S) TestMigrateCanceled(c *check.C) {
+	a, evt := s.prepareMigrateApp(c)
+	err := evt.TryCancel("because", s.user.Email)
+	c.Assert(err, check.IsNil)
+	err = a.Migrate("pool2", evt)
+	c.Assert(err, check.Equals, ErrMigrationCanceled)
+	c.Assert(a.Pool, check.Equals, s.Pool)
+	c.Assert(s.provisioner.GetUnits(a), check.HasLen, 3)
+}
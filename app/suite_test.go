@@ -7,6 +7,7 @@ package app
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"testing"
 
 	"github.com/tsuru/config"
@@ -120,9 +121,11 @@ func (s *S) SetUpSuite(c *check.C) {
 	err = json.Unmarshal(data, &s.zeroLock)
 	c.Assert(err, check.IsNil)
 	LogPubSubQueuePrefix = "pubsub:app-test:"
+	os.Setenv("TSURU_TARGET", "http://localhost")
 }
 
 func (s *S) TearDownSuite(c *check.C) {
+	os.Unsetenv("TSURU_TARGET")
 	defer s.conn.Close()
 	defer s.logConn.Close()
 	s.conn.Apps().Database.DropDatabase()
@@ -0,0 +1,67 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unithealer
+
+import (
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type S struct {
+	conn        *db.Storage
+	provisioner *provisiontest.FakeProvisioner
+}
+
+var _ = check.Suite(&S{})
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "tsuru_app_unithealer_tests")
+	config.Set("routers:fake:type", "fake")
+	config.Set("docker:router", "fake")
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+	s.provisioner = provisiontest.ProvisionerInstance
+	provision.DefaultProvisioner = "fake"
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	defer s.conn.Close()
+	s.conn.Apps().Database.DropDatabase()
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	UnitHealerInstance = nil
+	s.provisioner.Reset()
+	routertest.FakeRouter.Reset()
+	dbtest.ClearAllCollections(s.conn.Apps().Database)
+}
+
+func (s *S) newApp(c *check.C, name string, units uint) *app.App {
+	a := app.App{Name: name, Quota: quota.Unlimited, Teams: []string{"myteam"}}
+	err := s.conn.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	err = s.provisioner.Provision(&a)
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.AddBackend(a.Name)
+	c.Assert(err, check.IsNil)
+	if units > 0 {
+		_, err = s.provisioner.AddUnits(&a, units, "web", nil)
+		c.Assert(err, check.IsNil)
+	}
+	return &a
+}
@@ -0,0 +1,167 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package unithealer heals units of provisioners implementing
+// provision.UnitHealerProvisioner, recreating units which stopped reporting
+// their status.
+package unithealer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/api/shutdown"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/healer"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+)
+
+var UnitHealerInstance *UnitHealer
+
+type UnitHealer struct {
+	maxUnresponsiveTime time.Duration
+	quit                chan bool
+}
+
+// Initialize starts the background worker responsible for healing units,
+// when container healing is enabled in the config file.
+func Initialize() (*UnitHealer, error) {
+	if UnitHealerInstance != nil {
+		return nil, errors.New("unit healer already initialized")
+	}
+	healSeconds, _ := config.GetInt("docker:healing:heal-containers-timeout")
+	if healSeconds <= 0 {
+		return nil, nil
+	}
+	UnitHealerInstance = newUnitHealer(time.Duration(healSeconds) * time.Second)
+	shutdown.Register(UnitHealerInstance)
+	return UnitHealerInstance, nil
+}
+
+func newUnitHealer(maxUnresponsiveTime time.Duration) *UnitHealer {
+	h := &UnitHealer{
+		maxUnresponsiveTime: maxUnresponsiveTime,
+		quit:                make(chan bool),
+	}
+	go func() {
+		defer close(h.quit)
+		for {
+			h.runOnce()
+			select {
+			case <-h.quit:
+				return
+			case <-time.After(30 * time.Second):
+			}
+		}
+	}()
+	return h
+}
+
+func (h *UnitHealer) Shutdown() {
+	h.quit <- true
+	<-h.quit
+}
+
+func (h *UnitHealer) String() string {
+	return "unit healer"
+}
+
+func (h *UnitHealer) runOnce() {
+	reports, err := provision.ListUnresponsiveUnitReports(h.maxUnresponsiveTime)
+	if err != nil {
+		log.Errorf("[unit healer] unable to list unresponsive units: %s", err)
+		return
+	}
+	for _, r := range reports {
+		err = h.healUnitIfNeeded(r)
+		if err != nil {
+			log.Errorf("[unit healer] %s", err)
+		}
+	}
+}
+
+func (h *UnitHealer) healUnitIfNeeded(report provision.UnitReport) error {
+	a, err := app.GetByName(report.AppName)
+	if err == app.ErrAppNotFound {
+		return provision.RemoveUnitReport(report.ID)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to heal %q couldn't get app %q", report.ID, report.AppName)
+	}
+	units, err := a.Units()
+	if err != nil {
+		return errors.Wrapf(err, "unable to heal %q couldn't list units of app %q", report.ID, a.Name)
+	}
+	var unit provision.Unit
+	for _, u := range units {
+		if u.ID == report.ID {
+			unit = u
+			break
+		}
+	}
+	if unit.ID == "" {
+		return provision.RemoveUnitReport(report.ID)
+	}
+	if unit.Status == provision.StatusBuilding || unit.Status == provision.StatusAsleep {
+		return nil
+	}
+	locked, err := a.InternalLock("unit healer")
+	if err != nil {
+		return errors.Wrapf(err, "unable to heal %q couldn't lock app %q", unit.ID, a.Name)
+	}
+	if !locked {
+		log.Debugf("[unit healer] skipping %q, app %q is locked", unit.ID, a.Name)
+		return nil
+	}
+	defer a.Unlock()
+	maintErr := provision.CheckMaintenance(a.Pool)
+	if _, inMaintenance := maintErr.(*provision.ErrPoolInMaintenance); maintErr != nil && !inMaintenance {
+		return errors.Wrapf(maintErr, "unable to heal %q couldn't check pool maintenance", unit.ID)
+	}
+	if maintErr == nil {
+		log.Errorf("Initiating healing process for unit %q, unresponsive since %s.", unit.ID, report.LastSuccessStatusUpdate)
+	}
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeContainer, Value: unit.ID},
+		InternalKind: "healer",
+		CustomData:   unit,
+		Allowed: event.Allowed(permission.PermAppReadEvents, append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			return nil
+		}
+		return errors.Wrap(err, "error trying to insert unit healing event, healing aborted")
+	}
+	if maintErr != nil {
+		log.Debugf("[unit healer] skipping %q: %s", unit.ID, maintErr)
+		return evt.DoneCustomData(maintErr, nil)
+	}
+	healer.NotifyHealing(evt, a.Pool)
+	healErr := a.HealUnit(unit)
+	if healErr != nil {
+		healErr = fmt.Errorf("Error healing unit %q: %s", unit.ID, healErr)
+	} else {
+		// The unit was replaced, it's not expected to report its status
+		// anymore.
+		err = provision.RemoveUnitReport(unit.ID)
+		if err != nil {
+			log.Errorf("[unit healer] unable to remove report of unit %q: %s", unit.ID, err)
+		}
+	}
+	err = evt.DoneCustomData(healErr, nil)
+	if err != nil {
+		log.Errorf("[unit healer] error trying to update unit healing event: %s", err)
+	}
+	healer.NotifyHealing(evt, a.Pool)
+	return healErr
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package unithealer
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) addReport(c *check.C, unit provision.Unit, lastSuccess time.Time) {
+	err := s.conn.Collection("unit_reports").Insert(provision.UnitReport{
+		ID:                      unit.ID,
+		AppName:                 unit.AppName,
+		LastSuccessStatusUpdate: lastSuccess,
+	})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) reportIDs(c *check.C) []string {
+	var reports []provision.UnitReport
+	err := s.conn.Collection("unit_reports").Find(nil).Sort("_id").All(&reports)
+	c.Assert(err, check.IsNil)
+	ids := make([]string, len(reports))
+	for i, r := range reports {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func (s *S) TestInitializeDisabled(c *check.C) {
+	config.Unset("docker:healing:heal-containers-timeout")
+	h, err := Initialize()
+	c.Assert(err, check.IsNil)
+	c.Assert(h, check.IsNil)
+	c.Assert(UnitHealerInstance, check.IsNil)
+}
+
+func (s *S) TestInitialize(c *check.C) {
+	config.Set("docker:healing:heal-containers-timeout", 120)
+	defer config.Unset("docker:healing:heal-containers-timeout")
+	h, err := Initialize()
+	c.Assert(err, check.IsNil)
+	defer h.Shutdown()
+	c.Assert(h, check.Equals, UnitHealerInstance)
+	c.Assert(h.maxUnresponsiveTime, check.Equals, 2*time.Minute)
+	_, err = Initialize()
+	c.Assert(err, check.ErrorMatches, "unit healer already initialized")
+}
+
+func (s *S) TestUnitHealerRunOnce(c *check.C) {
+	a := s.newApp(c, "myapp", 2)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	s.addReport(c, units[0], time.Now().UTC().Add(-10*time.Minute))
+	s.addReport(c, units[1], time.Now().UTC())
+	healedID, keptID := units[0].ID, units[1].ID
+	h := &UnitHealer{maxUnresponsiveTime: 5 * time.Minute}
+	h.runOnce()
+	newUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(newUnits, check.HasLen, 2)
+	c.Assert(newUnits[0].ID, check.Not(check.Equals), healedID)
+	c.Assert(newUnits[1].ID, check.Equals, keptID)
+	c.Assert(s.reportIDs(c), check.DeepEquals, []string{keptID})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeContainer, Value: healedID},
+		Kind:   "healer",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitHealerRunOnceHealError(c *check.C) {
+	a := s.newApp(c, "myapp", 1)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	s.addReport(c, units[0], time.Now().UTC().Add(-10*time.Minute))
+	s.provisioner.PrepareFailure("HealUnit", errors.New("my heal error"))
+	h := &UnitHealer{maxUnresponsiveTime: 5 * time.Minute}
+	h.runOnce()
+	newUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(newUnits[0].ID, check.Equals, units[0].ID)
+	c.Assert(s.reportIDs(c), check.DeepEquals, []string{units[0].ID})
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeContainer, Value: units[0].ID},
+		Kind:         "healer",
+		ErrorMatches: `Error healing unit ".*": my heal error`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitHealerRunOnceRemovesReportsOfMissingUnits(c *check.C) {
+	s.newApp(c, "myapp", 1)
+	old := time.Now().UTC().Add(-10 * time.Minute)
+	s.addReport(c, provision.Unit{ID: "gone", AppName: "myapp"}, old)
+	s.addReport(c, provision.Unit{ID: "other", AppName: "removedapp"}, old)
+	h := &UnitHealer{maxUnresponsiveTime: 5 * time.Minute}
+	h.runOnce()
+	c.Assert(s.reportIDs(c), check.HasLen, 0)
+	c.Assert(eventtest.EventDesc{IsEmpty: true}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitHealerRunOnceLockedApp(c *check.C) {
+	a := s.newApp(c, "myapp", 1)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	s.addReport(c, units[0], time.Now().UTC().Add(-10*time.Minute))
+	locked, err := app.AcquireApplicationLock(a.Name, "someone", "deploy")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	h := &UnitHealer{maxUnresponsiveTime: 5 * time.Minute}
+	h.runOnce()
+	newUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(newUnits[0].ID, check.Equals, units[0].ID)
+	c.Assert(eventtest.EventDesc{IsEmpty: true}, eventtest.HasEvent)
+}
+
+func (s *S) TestUnitHealerRunOncePoolInMaintenance(c *check.C) {
+	err := provision.AddPool(provision.AddPoolOptions{Name: "pool1"})
+	c.Assert(err, check.IsNil)
+	a := s.newApp(c, "myapp", 1)
+	err = s.conn.Apps().Update(bson.M{"name": a.Name}, bson.M{"$set": bson.M{"pool": "pool1"}})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "network changes",
+	})
+	c.Assert(err, check.IsNil)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	s.addReport(c, units[0], time.Now().UTC().Add(-10*time.Minute))
+	h := &UnitHealer{maxUnresponsiveTime: 5 * time.Minute}
+	h.runOnce()
+	newUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(newUnits[0].ID, check.Equals, units[0].ID)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: event.TargetTypeContainer, Value: units[0].ID},
+		Kind:         "healer",
+		ErrorMatches: `pool "pool1" is under maintenance until .*: network changes`,
+	}, eventtest.HasEvent)
+}
@@ -24,7 +24,7 @@ func sendResetPassword(u *auth.User, t *passwordToken) {
 		log.Errorf("Failed to send password token to user %q: %s", u.Email, err)
 		return
 	}
-	err = sendEmail(u.Email, body.Bytes())
+	err = SendEmail(u.Email, body.Bytes())
 	if err != nil {
 		log.Errorf("Failed to send password token for user %q: %s", u.Email, err)
 	}
@@ -41,7 +41,7 @@ func sendNewPassword(u *auth.User, password string) {
 		log.Errorf("Failed to send new password to user %q: %s", u.Email, err)
 		return
 	}
-	err = sendEmail(u.Email, body.Bytes())
+	err = SendEmail(u.Email, body.Bytes())
 	if err != nil {
 		log.Errorf("Failed to send new password to user %q: %s", u.Email, err)
 	}
@@ -55,7 +55,9 @@ func generatePassword(length int) string {
 	return string(password)
 }
 
-func sendEmail(email string, data []byte) error {
+// SendEmail sends a message to the given email address, using the SMTP server
+// and credentials defined in the smtp section of the config file.
+func SendEmail(email string, data []byte) error {
 	addr, err := smtpServer()
 	if err != nil {
 		return err
@@ -187,6 +187,7 @@ func (h *NodeHealer) tryHealingNode(node provision.Node, reason string, lastChec
 	}
 	var createdNode *provision.NodeSpec
 	var evtErr error
+	var healing bool
 	defer func() {
 		var updateErr error
 		if evtErr == nil && createdNode == nil {
@@ -197,6 +198,9 @@ func (h *NodeHealer) tryHealingNode(node provision.Node, reason string, lastChec
 		if updateErr != nil {
 			log.Errorf("error trying to update healing event: %s", updateErr.Error())
 		}
+		if healing {
+			NotifyHealing(evt, poolName)
+		}
 	}()
 	_, err = node.Provisioner().GetNode(node.Address())
 	if err != nil {
@@ -214,7 +218,18 @@ func (h *NodeHealer) tryHealingNode(node provision.Node, reason string, lastChec
 	if !shouldHeal {
 		return nil
 	}
+	if err = provision.CheckMaintenance(poolName); err != nil {
+		if _, ok := err.(*provision.ErrPoolInMaintenance); ok {
+			log.Debugf("skipping healing of node %q: %s", node.Address(), err)
+			evtErr = err
+			return nil
+		}
+		evtErr = fmt.Errorf("unable to check pool maintenance: %s", err)
+		return evtErr
+	}
 	log.Errorf("initiating healing process for node %q due to: %s", node.Address(), reason)
+	healing = true
+	NotifyHealing(evt, poolName)
 	createdNode, evtErr = h.healNode(node)
 	return evtErr
 }
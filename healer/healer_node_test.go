@@ -7,6 +7,7 @@ package healer
 import (
 	"bytes"
 	"fmt"
+	"net/http/httptest"
 	"runtime"
 	"sync"
 	"time"
@@ -288,6 +289,102 @@ func (s *S) TestHealerHandleError(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestHealerHandleErrorPoolInMaintenance(c *check.C) {
+	factory, iaasInst := iaasTesting.NewHealerIaaSConstructorWithInst("addr1")
+	iaas.RegisterIaasProvider("my-healer-iaas", factory)
+	_, err := iaas.CreateMachineForIaaS("my-healer-iaas", map[string]string{})
+	c.Assert(err, check.IsNil)
+	iaasInst.Addr = "addr2"
+	p := provisiontest.ProvisionerInstance
+	err = p.AddNode(provision.AddNodeOptions{
+		Address:  "http://addr1:1",
+		Metadata: map[string]string{"iaas": "my-healer-iaas", "pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "hardware replacement",
+	})
+	c.Assert(err, check.IsNil)
+	healer := newNodeHealer(nodeHealerArgs{
+		FailuresBeforeHealing: 1,
+		WaitTimeNewMachine:    time.Minute,
+	})
+	healer.Shutdown()
+	healer.started = time.Now().Add(-3 * time.Second)
+	conf := healerConfig()
+	err = conf.SaveBase(NodeHealerConfig{Enabled: boolPtr(true), MaxUnresponsiveTime: intPtr(1)})
+	c.Assert(err, check.IsNil)
+	err = healer.UpdateNodeData(provision.NodeStatusData{Addrs: []string{"addr1"}})
+	c.Assert(err, check.IsNil)
+	time.Sleep(1200 * time.Millisecond)
+	node, err := p.GetNode("http://addr1:1")
+	c.Assert(err, check.IsNil)
+	node.(*provisiontest.FakeNode).SetHealth(2, true)
+	waitTime := healer.HandleError(node.(provision.NodeHealthChecker))
+	c.Assert(waitTime, check.Equals, time.Duration(0))
+	nodes, err := p.ListNodes(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Address(), check.Equals, "http://addr1:1")
+	machines, err := iaas.ListMachines()
+	c.Assert(err, check.IsNil)
+	c.Assert(machines, check.HasLen, 1)
+	c.Assert(machines[0].Address, check.Equals, "addr1")
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: "node", Value: "http://addr1:1"},
+		Kind:         "healer",
+		ErrorMatches: `pool "pool1" is under maintenance until .*: hardware replacement`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestHealerHandleErrorNotifies(c *check.C) {
+	recorder := &webhookRecorder{}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	config.Set("docker:healing:notifications:oncall:type", "webhook")
+	config.Set("docker:healing:notifications:oncall:url", srv.URL)
+	defer config.Unset("docker:healing:notifications")
+	factory, iaasInst := iaasTesting.NewHealerIaaSConstructorWithInst("addr1")
+	iaas.RegisterIaasProvider("my-healer-iaas", factory)
+	_, err := iaas.CreateMachineForIaaS("my-healer-iaas", map[string]string{})
+	c.Assert(err, check.IsNil)
+	iaasInst.Addr = "addr2"
+	config.Set("iaas:node-protocol", "http")
+	config.Set("iaas:node-port", 2)
+	defer config.Unset("iaas:node-protocol")
+	defer config.Unset("iaas:node-port")
+	p := provisiontest.ProvisionerInstance
+	err = p.AddNode(provision.AddNodeOptions{
+		Address:  "http://addr1:1",
+		Metadata: map[string]string{"iaas": "my-healer-iaas", "pool": "pool1"},
+	})
+	c.Assert(err, check.IsNil)
+	healer := newNodeHealer(nodeHealerArgs{
+		FailuresBeforeHealing: 1,
+		WaitTimeNewMachine:    time.Minute,
+	})
+	healer.Shutdown()
+	healer.started = time.Now().Add(-3 * time.Second)
+	conf := healerConfig()
+	err = conf.SaveBase(NodeHealerConfig{Enabled: boolPtr(true), MaxUnresponsiveTime: intPtr(1)})
+	c.Assert(err, check.IsNil)
+	err = healer.UpdateNodeData(provision.NodeStatusData{
+		Addrs:  []string{"addr1"},
+		Checks: []provision.NodeCheckResult{},
+	})
+	c.Assert(err, check.IsNil)
+	time.Sleep(1200 * time.Millisecond)
+	nodes, err := p.ListNodes(nil)
+	c.Assert(err, check.IsNil)
+	nodes[0].(*provisiontest.FakeNode).SetHealth(2, true)
+	healer.HandleError(nodes[0].(provision.NodeHealthChecker))
+	notificationsWg.Wait()
+	c.Assert(recorder.statuses(), check.DeepEquals, []string{HealingStarted, HealingSucceeded})
+	c.Assert(recorder.notifications[1].Target, check.Equals, "http://addr1:1")
+	c.Assert(recorder.notifications[1].Pool, check.Equals, "pool1")
+}
+
 func (s *S) TestHealerHandleErrorFailureEvent(c *check.C) {
 	factory, iaasInst := iaasTesting.NewHealerIaaSConstructorWithInst("addr1")
 	iaas.RegisterIaasProvider("my-healer-iaas", factory)
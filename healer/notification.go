@@ -0,0 +1,233 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package healer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	tsuruNet "github.com/tsuru/tsuru/net"
+)
+
+const (
+	HealingStarted   = "started"
+	HealingSucceeded = "succeeded"
+	HealingFailed    = "failed"
+
+	notificationsConfigKey = "docker:healing:notifications"
+	defaultWebhookRetries  = 3
+)
+
+var (
+	webhookRetryDelay = 5 * time.Second
+	notificationsWg   sync.WaitGroup
+)
+
+var healingEmailData = template.Must(template.New("healing").Parse(`Subject: [tsuru] Healing of {{.TargetType}} {{.Target}} {{.Status}}
+To: {{.To}}
+
+Healing of {{.TargetType}} {{.Target}} in pool "{{.Pool}}" {{.Status}}.
+
+Event: {{.EventID}}
+Started at: {{.StartTime}}
+{{if .EndTime.IsZero}}{{else}}Finished at: {{.EndTime}}
+{{end}}{{if .Error}}Error: {{.Error}}
+{{end}}`))
+
+// HealingNotification is the payload sent to notification targets when a
+// healing event starts or finishes.
+type HealingNotification struct {
+	EventID    string    `json:"eventID"`
+	Status     string    `json:"status"`
+	TargetType string    `json:"targetType"`
+	Target     string    `json:"target"`
+	Pool       string    `json:"pool"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func newHealingNotification(evt *event.Event, pool string) HealingNotification {
+	n := HealingNotification{
+		EventID:    evt.UniqueID.Hex(),
+		Status:     HealingStarted,
+		TargetType: string(evt.Target.Type),
+		Target:     evt.Target.Value,
+		Pool:       pool,
+		StartTime:  evt.StartTime,
+		EndTime:    evt.EndTime,
+		Error:      evt.Error,
+	}
+	if !evt.Running {
+		if evt.Error == "" {
+			n.Status = HealingSucceeded
+		} else {
+			n.Status = HealingFailed
+		}
+	}
+	return n
+}
+
+type notificationTarget struct {
+	name    string
+	kind    string
+	url     string
+	retries int
+	to      []string
+	pools   []string
+}
+
+func (t *notificationTarget) matchesPool(pool string) bool {
+	if len(t.pools) == 0 {
+		return true
+	}
+	for _, p := range t.pools {
+		if p == pool {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *notificationTarget) send(n HealingNotification) error {
+	switch t.kind {
+	case "webhook":
+		return t.sendWebhook(n)
+	case "email":
+		return t.sendEmail(n)
+	}
+	return errors.Errorf("invalid notification type %q", t.kind)
+}
+
+func (t *notificationTarget) sendWebhook(n HealingNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	for i := 0; i <= t.retries; i++ {
+		if i > 0 {
+			time.Sleep(webhookRetryDelay)
+		}
+		var rsp *http.Response
+		rsp, err = tsuruNet.Dial5Full60ClientNoKeepAlive.Post(t.url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		rsp.Body.Close()
+		if rsp.StatusCode >= 200 && rsp.StatusCode < 300 {
+			return nil
+		}
+		err = errors.Errorf("invalid status code %d from %s", rsp.StatusCode, t.url)
+	}
+	return errors.Wrapf(err, "giving up after %d retries", t.retries)
+}
+
+func (t *notificationTarget) sendEmail(n HealingNotification) error {
+	for _, to := range t.to {
+		var body bytes.Buffer
+		err := healingEmailData.Execute(&body, struct {
+			HealingNotification
+			To string
+		}{n, to})
+		if err != nil {
+			return err
+		}
+		err = native.SendEmail(to, body.Bytes())
+		if err != nil {
+			return errors.Wrapf(err, "unable to send email to %q", to)
+		}
+	}
+	return nil
+}
+
+func notificationTargets() []notificationTarget {
+	data, err := config.Get(notificationsConfigKey)
+	if err != nil {
+		return nil
+	}
+	targetsMap, ok := data.(map[interface{}]interface{})
+	if !ok {
+		log.Errorf("[healer notifications] invalid %q config, expected a map of targets", notificationsConfigKey)
+		return nil
+	}
+	names := make([]string, 0, len(targetsMap))
+	for name := range targetsMap {
+		names = append(names, fmt.Sprint(name))
+	}
+	sort.Strings(names)
+	var targets []notificationTarget
+	for _, name := range names {
+		t, err := readNotificationTarget(name)
+		if err != nil {
+			log.Errorf("[healer notifications] ignoring target %q: %s", name, err)
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+func readNotificationTarget(name string) (notificationTarget, error) {
+	prefix := fmt.Sprintf("%s:%s", notificationsConfigKey, name)
+	t := notificationTarget{name: name}
+	t.kind, _ = config.GetString(prefix + ":type")
+	t.pools, _ = config.GetList(prefix + ":pools")
+	switch t.kind {
+	case "webhook":
+		t.url, _ = config.GetString(prefix + ":url")
+		if t.url == "" {
+			return t, errors.New("webhook url is mandatory")
+		}
+		var err error
+		t.retries, err = config.GetInt(prefix + ":retries")
+		if err != nil || t.retries < 0 {
+			t.retries = defaultWebhookRetries
+		}
+	case "email":
+		t.to, _ = config.GetList(prefix + ":to")
+		if len(t.to) == 0 {
+			return t, errors.New("at least one email recipient is mandatory")
+		}
+	default:
+		return t, errors.Errorf("invalid notification type %q, expected webhook or email", t.kind)
+	}
+	return t, nil
+}
+
+// NotifyHealing sends the current state of the given healing event to every
+// notification target configured for the pool. It should be called once the
+// healing starts and again after the event is done. Notifications are sent in
+// background and failures are only logged.
+func NotifyHealing(evt *event.Event, pool string) {
+	targets := notificationTargets()
+	if len(targets) == 0 {
+		return
+	}
+	n := newHealingNotification(evt, pool)
+	for _, t := range targets {
+		if !t.matchesPool(pool) {
+			continue
+		}
+		notificationsWg.Add(1)
+		go func(t notificationTarget) {
+			defer notificationsWg.Done()
+			err := t.send(n)
+			if err != nil {
+				log.Errorf("[healer notifications] unable to notify %q about healing event %s: %s", t.name, n.EventID, err)
+			}
+		}(t)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package healer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/auth/authtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"gopkg.in/check.v1"
+)
+
+type webhookRecorder struct {
+	sync.Mutex
+	notifications []HealingNotification
+	failures      int
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var n HealingNotification
+	json.NewDecoder(req.Body).Decode(&n)
+	r.notifications = append(r.notifications, n)
+}
+
+func (r *webhookRecorder) statuses() []string {
+	r.Lock()
+	defer r.Unlock()
+	statuses := make([]string, len(r.notifications))
+	for i, n := range r.notifications {
+		statuses[i] = n.Status
+	}
+	return statuses
+}
+
+func newHealingEvent(c *check.C, addr, pool string) *event.Event {
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeNode, Value: addr},
+		InternalKind: "healer",
+		Allowed:      event.Allowed(permission.PermPoolReadEvents, permission.Context(permission.CtxPool, pool)),
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
+
+func (s *S) TestNotifyHealingWebhook(c *check.C) {
+	recorder := &webhookRecorder{}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	config.Set("docker:healing:notifications:oncall:type", "webhook")
+	config.Set("docker:healing:notifications:oncall:url", srv.URL)
+	defer config.Unset("docker:healing:notifications")
+	evt := newHealingEvent(c, "http://addr1:1", "pool1")
+	NotifyHealing(evt, "pool1")
+	notificationsWg.Wait()
+	err := evt.Done(errors.New("something went wrong"))
+	c.Assert(err, check.IsNil)
+	NotifyHealing(evt, "pool1")
+	notificationsWg.Wait()
+	c.Assert(recorder.notifications, check.HasLen, 2)
+	c.Assert(recorder.notifications[0].Status, check.Equals, HealingStarted)
+	c.Assert(recorder.notifications[0].EventID, check.Equals, evt.UniqueID.Hex())
+	c.Assert(recorder.notifications[0].TargetType, check.Equals, "node")
+	c.Assert(recorder.notifications[0].Target, check.Equals, "http://addr1:1")
+	c.Assert(recorder.notifications[0].Pool, check.Equals, "pool1")
+	c.Assert(recorder.notifications[0].Error, check.Equals, "")
+	c.Assert(recorder.notifications[1].Status, check.Equals, HealingFailed)
+	c.Assert(recorder.notifications[1].Error, check.Equals, "something went wrong")
+}
+
+func (s *S) TestNotifyHealingWebhookRetries(c *check.C) {
+	defer func(d time.Duration) { webhookRetryDelay = d }(webhookRetryDelay)
+	webhookRetryDelay = 0
+	recorder := &webhookRecorder{failures: 2}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	config.Set("docker:healing:notifications:oncall:type", "webhook")
+	config.Set("docker:healing:notifications:oncall:url", srv.URL)
+	config.Set("docker:healing:notifications:oncall:retries", 2)
+	defer config.Unset("docker:healing:notifications")
+	evt := newHealingEvent(c, "http://addr1:1", "pool1")
+	err := evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	NotifyHealing(evt, "pool1")
+	notificationsWg.Wait()
+	c.Assert(recorder.statuses(), check.DeepEquals, []string{HealingSucceeded})
+	recorder.failures = 3
+	NotifyHealing(evt, "pool1")
+	notificationsWg.Wait()
+	c.Assert(recorder.statuses(), check.DeepEquals, []string{HealingSucceeded})
+	c.Assert(recorder.failures, check.Equals, 0)
+}
+
+func (s *S) TestNotifyHealingFilterByPool(c *check.C) {
+	recorder1 := &webhookRecorder{}
+	srv1 := httptest.NewServer(recorder1)
+	defer srv1.Close()
+	recorder2 := &webhookRecorder{}
+	srv2 := httptest.NewServer(recorder2)
+	defer srv2.Close()
+	config.Set("docker:healing:notifications:all:type", "webhook")
+	config.Set("docker:healing:notifications:all:url", srv1.URL)
+	config.Set("docker:healing:notifications:pool2only:type", "webhook")
+	config.Set("docker:healing:notifications:pool2only:url", srv2.URL)
+	config.Set("docker:healing:notifications:pool2only:pools", []interface{}{"pool2", "pool3"})
+	defer config.Unset("docker:healing:notifications")
+	NotifyHealing(newHealingEvent(c, "http://addr1:1", "pool1"), "pool1")
+	notificationsWg.Wait()
+	c.Assert(recorder1.statuses(), check.DeepEquals, []string{HealingStarted})
+	c.Assert(recorder2.statuses(), check.DeepEquals, []string{})
+	NotifyHealing(newHealingEvent(c, "http://addr2:2", "pool2"), "pool2")
+	notificationsWg.Wait()
+	c.Assert(recorder1.statuses(), check.DeepEquals, []string{HealingStarted, HealingStarted})
+	c.Assert(recorder2.statuses(), check.DeepEquals, []string{HealingStarted})
+}
+
+func (s *S) TestNotifyHealingEmail(c *check.C) {
+	server, err := authtest.NewSMTPServer()
+	c.Assert(err, check.IsNil)
+	defer server.Stop()
+	config.Set("smtp:server", server.Addr())
+	config.Set("smtp:user", "root")
+	defer config.Unset("smtp")
+	config.Set("docker:healing:notifications:mail:type", "email")
+	config.Set("docker:healing:notifications:mail:to", []interface{}{"oncall@tsuru.io", "ops@tsuru.io"})
+	defer config.Unset("docker:healing:notifications")
+	evt := newHealingEvent(c, "http://addr1:1", "pool1")
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	NotifyHealing(evt, "pool1")
+	notificationsWg.Wait()
+	server.RLock()
+	defer server.RUnlock()
+	c.Assert(server.MailBox, check.HasLen, 2)
+	c.Assert(server.MailBox[0].To, check.DeepEquals, []string{"oncall@tsuru.io"})
+	c.Assert(server.MailBox[1].To, check.DeepEquals, []string{"ops@tsuru.io"})
+	data := string(server.MailBox[0].Data)
+	c.Assert(strings.HasPrefix(data, "Subject: [tsuru] Healing of node http://addr1:1 succeeded\r\n"), check.Equals, true)
+	c.Assert(data, check.Matches, `(?s).*Healing of node http://addr1:1 in pool "pool1" succeeded\..*`)
+	c.Assert(data, check.Matches, `(?s).*Event: `+evt.UniqueID.Hex()+`.*`)
+}
+
+func (s *S) TestNotificationTargetsIgnoresInvalid(c *check.C) {
+	config.Set("docker:healing:notifications:nourl:type", "webhook")
+	config.Set("docker:healing:notifications:noto:type", "email")
+	config.Set("docker:healing:notifications:unknown:type", "pigeon")
+	config.Set("docker:healing:notifications:valid:type", "webhook")
+	config.Set("docker:healing:notifications:valid:url", "http://localhost/hook")
+	defer config.Unset("docker:healing:notifications")
+	targets := notificationTargets()
+	c.Assert(targets, check.DeepEquals, []notificationTarget{
+		{name: "valid", kind: "webhook", url: "http://localhost/hook", retries: defaultWebhookRetries},
+	})
+}
+
+func (s *S) TestNotificationTargetsNotConfigured(c *check.C) {
+	c.Assert(notificationTargets(), check.IsNil)
+}
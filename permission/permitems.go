@@ -19,11 +19,13 @@ var (
 	PermAppDeploy                        = PermissionRegistry.get("app.deploy")                          // [global app team pool]
 	PermAppDeployArchiveUrl              = PermissionRegistry.get("app.deploy.archive-url")              // [global app team pool]
 	PermAppDeployBuild                   = PermissionRegistry.get("app.deploy.build")                    // [global app team pool]
+	PermAppDeployCanary                  = PermissionRegistry.get("app.deploy.canary")                   // [global app team pool]
 	PermAppDeployGit                     = PermissionRegistry.get("app.deploy.git")                      // [global app team pool]
 	PermAppDeployImage                   = PermissionRegistry.get("app.deploy.image")                    // [global app team pool]
 	PermAppDeployRollback                = PermissionRegistry.get("app.deploy.rollback")                 // [global app team pool]
 	PermAppDeployUpload                  = PermissionRegistry.get("app.deploy.upload")                   // [global app team pool]
 	PermAppRead                          = PermissionRegistry.get("app.read")                            // [global app team pool]
+	PermAppReadCertificate               = PermissionRegistry.get("app.read.certificate")                // [global app team pool]
 	PermAppReadDeploy                    = PermissionRegistry.get("app.read.deploy")                     // [global app team pool]
 	PermAppReadEnv                       = PermissionRegistry.get("app.read.env")                        // [global app team pool]
 	PermAppReadEvents                    = PermissionRegistry.get("app.read.events")                     // [global app team pool]
@@ -32,10 +34,15 @@ var (
 	PermAppRun                           = PermissionRegistry.get("app.run")                             // [global app team pool]
 	PermAppRunShell                      = PermissionRegistry.get("app.run.shell")                       // [global app team pool]
 	PermAppUpdate                        = PermissionRegistry.get("app.update")                          // [global app team pool]
+	PermAppUpdateAutoscale               = PermissionRegistry.get("app.update.autoscale")                // [global app team pool]
 	PermAppUpdateBind                    = PermissionRegistry.get("app.update.bind")                     // [global app team pool]
+	PermAppUpdateCertificate             = PermissionRegistry.get("app.update.certificate")              // [global app team pool]
+	PermAppUpdateCertificateSet          = PermissionRegistry.get("app.update.certificate.set")          // [global app team pool]
+	PermAppUpdateCertificateUnset        = PermissionRegistry.get("app.update.certificate.unset")        // [global app team pool]
 	PermAppUpdateCname                   = PermissionRegistry.get("app.update.cname")                    // [global app team pool]
 	PermAppUpdateCnameAdd                = PermissionRegistry.get("app.update.cname.add")                // [global app team pool]
 	PermAppUpdateCnameRemove             = PermissionRegistry.get("app.update.cname.remove")             // [global app team pool]
+	PermAppUpdateDeployStrategy          = PermissionRegistry.get("app.update.deploy-strategy")          // [global app team pool]
 	PermAppUpdateDescription             = PermissionRegistry.get("app.update.description")              // [global app team pool]
 	PermAppUpdateEnv                     = PermissionRegistry.get("app.update.env")                      // [global app team pool]
 	PermAppUpdateEnvSet                  = PermissionRegistry.get("app.update.env.set")                  // [global app team pool]
@@ -43,6 +50,7 @@ var (
 	PermAppUpdateEvents                  = PermissionRegistry.get("app.update.events")                   // [global app team pool]
 	PermAppUpdateGrant                   = PermissionRegistry.get("app.update.grant")                    // [global app team pool]
 	PermAppUpdateLog                     = PermissionRegistry.get("app.update.log")                      // [global app team pool]
+	PermAppUpdateMigrate                 = PermissionRegistry.get("app.update.migrate")                  // [global app team pool]
 	PermAppUpdatePlan                    = PermissionRegistry.get("app.update.plan")                     // [global app team pool]
 	PermAppUpdatePool                    = PermissionRegistry.get("app.update.pool")                     // [global app team pool]
 	PermAppUpdateRestart                 = PermissionRegistry.get("app.update.restart")                  // [global app team pool]
@@ -86,10 +94,13 @@ var (
 	PermNodeDelete                       = PermissionRegistry.get("node.delete")                         // [global pool]
 	PermNodeRead                         = PermissionRegistry.get("node.read")                           // [global pool]
 	PermNodeUpdate                       = PermissionRegistry.get("node.update")                         // [global pool]
+	PermNodeUpdateCordon                 = PermissionRegistry.get("node.update.cordon")                  // [global pool]
+	PermNodeUpdateDrain                  = PermissionRegistry.get("node.update.drain")                   // [global pool]
 	PermNodeUpdateMove                   = PermissionRegistry.get("node.update.move")                    // [global pool]
 	PermNodeUpdateMoveContainer          = PermissionRegistry.get("node.update.move.container")          // [global pool]
 	PermNodeUpdateMoveContainers         = PermissionRegistry.get("node.update.move.containers")         // [global pool]
 	PermNodeUpdateRebalance              = PermissionRegistry.get("node.update.rebalance")               // [global pool]
+	PermNodeUpdateUncordon               = PermissionRegistry.get("node.update.uncordon")                // [global pool]
 	PermNodecontainer                    = PermissionRegistry.get("nodecontainer")                       // [global pool]
 	PermNodecontainerCreate              = PermissionRegistry.get("nodecontainer.create")                // [global pool]
 	PermNodecontainerDelete              = PermissionRegistry.get("nodecontainer.delete")                // [global pool]
@@ -114,6 +125,8 @@ var (
 	PermPoolReadEvents                   = PermissionRegistry.get("pool.read.events")                    // [global pool]
 	PermPoolUpdate                       = PermissionRegistry.get("pool.update")                         // [global pool]
 	PermPoolUpdateLogs                   = PermissionRegistry.get("pool.update.logs")                    // [global pool]
+	PermPoolUpdateMaintenance            = PermissionRegistry.get("pool.update.maintenance")             // [global pool]
+	PermPoolUpdateScheduler              = PermissionRegistry.get("pool.update.scheduler")               // [global pool]
 	PermPoolUpdateTeam                   = PermissionRegistry.get("pool.update.team")                    // [global pool]
 	PermPoolUpdateTeamAdd                = PermissionRegistry.get("pool.update.team.add")                // [global pool]
 	PermPoolUpdateTeamRemove             = PermissionRegistry.get("pool.update.team.remove")             // [global pool]
@@ -14,6 +14,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.description",
 	"app.update.log",
 	"app.update.pool",
+	"app.update.migrate",
 	"app.update.unit.add",
 	"app.update.unit.remove",
 	"app.update.unit.register",
@@ -30,13 +31,18 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.update.teamowner",
 	"app.update.cname.add",
 	"app.update.cname.remove",
+	"app.update.certificate.set",
+	"app.update.certificate.unset",
 	"app.update.plan",
+	"app.update.deploy-strategy",
+	"app.update.autoscale",
 	"app.update.bind",
 	"app.update.events",
 	"app.update.unbind",
 	"app.deploy",
 	"app.deploy.archive-url",
 	"app.deploy.build",
+	"app.deploy.canary",
 	"app.deploy.git",
 	"app.deploy.image",
 	"app.deploy.rollback",
@@ -44,6 +50,7 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"app.read",
 	"app.read.deploy",
 	"app.read.env",
+	"app.read.certificate",
 	"app.read.events",
 	"app.read.metric",
 	"app.read.log",
@@ -61,6 +68,9 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"node.update.move.container",
 	"node.update.move.containers",
 	"node.update.rebalance",
+	"node.update.cordon",
+	"node.update.uncordon",
+	"node.update.drain",
 	"node.delete",
 ).addWithCtx(
 	"node.autoscale", []contextType{},
@@ -155,6 +165,8 @@ var PermissionRegistry = (&registry{}).addWithCtx(
 	"pool.update.team.add",
 	"pool.update.team.remove",
 	"pool.update.logs",
+	"pool.update.scheduler",
+	"pool.update.maintenance",
 	"pool.delete",
 ).add(
 	"debug",
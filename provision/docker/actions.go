@@ -55,6 +55,8 @@ type changeUnitsPipelineArgs struct {
 	appDestroy  bool
 	exposedPort string
 	event       *event.Event
+	blueGreen   bool
+	canary      canaryOptions
 }
 
 type callbackFunc func(*container.Container, chan *container.Container) error
@@ -368,16 +370,22 @@ var bindAndHealthcheck = action.Action{
 		if err != nil {
 			log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
 		}
+		yamlData, err := image.GetImageTsuruYamlData(args.imageId)
+		if err != nil {
+			log.Errorf("[WARNING] cannot get the tsuru.yaml data of the image: %s", err)
+		}
 		newContainers := ctx.Previous.([]container.Container)
 		writer := args.writer
 		if writer == nil {
 			writer = ioutil.Discard
 		}
 		doHealthcheck := true
-		for _, c := range args.toRemove {
-			if c.Status == provision.StatusError.String() || c.Status == provision.StatusStopped.String() {
-				doHealthcheck = false
-				break
+		if !args.blueGreen {
+			for _, c := range args.toRemove {
+				if c.Status == provision.StatusError.String() || c.Status == provision.StatusStopped.String() {
+					doHealthcheck = false
+					break
+				}
 			}
 		}
 		fmt.Fprintf(writer, "\n---- Binding and checking %d new %s ----\n", len(newContainers), pluralize("unit", len(newContainers)))
@@ -388,8 +396,8 @@ var bindAndHealthcheck = action.Action{
 				return err
 			}
 			toRollback <- c
-			if doHealthcheck && c.ProcessName == webProcessName {
-				err = runHealthcheck(c, writer)
+			if doHealthcheck && (c.ProcessName == webProcessName || yamlData.HasProcessHealthcheck(c.ProcessName)) {
+				err = runHealthcheck(args.provisioner, c, writer)
 				if err != nil {
 					return err
 				}
@@ -535,6 +543,11 @@ var setRouterHealthcheck = action.Action{
 		if err != nil {
 			return nil, err
 		}
+		webProcessName, err := image.GetImageWebProcessName(args.imageId)
+		if err != nil {
+			return nil, err
+		}
+		yamlData = yamlData.ProcessData(webProcessName)
 		writer := args.writer
 		if writer == nil {
 			writer = ioutil.Discard
@@ -567,6 +580,8 @@ var setRouterHealthcheck = action.Action{
 		if err != nil {
 			log.Errorf("[set-router-healthcheck:Backward] Error getting yaml data: %s", err.Error())
 		}
+		webProcessName, _ := image.GetImageWebProcessName(currentImageName)
+		yamlData = yamlData.ProcessData(webProcessName)
 		hcData := yamlData.Healthcheck.ToRouterHC()
 		err = hcRouter.SetHealthcheck(args.app.GetName(), hcData)
 		if err != nil {
@@ -672,6 +687,251 @@ var removeOldRoutes = action.Action{
 	MinParams: 1,
 }
 
+// switchRoutes is used by blue/green deploys, it adds the routes to all new
+// units and removes the routes from all old units in a single step, after
+// every new unit passed the healthcheck.
+var switchRoutes = action.Action{
+	Name: "switch-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		if err := checkCanceled(args.event); err != nil {
+			return nil, err
+		}
+		newContainers := ctx.Previous.([]container.Container)
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			return nil, err
+		}
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		newWebProcessName, err := image.GetImageWebProcessName(args.imageId)
+		if err != nil {
+			log.Errorf("[WARNING] cannot get the name of the web process: %s", err)
+		}
+		currentImageName, err := image.AppCurrentImageName(args.app.GetName())
+		if err != nil && err != image.ErrNoImagesAvailable {
+			return nil, err
+		}
+		oldWebProcessName, err := image.GetImageWebProcessName(currentImageName)
+		if err != nil {
+			log.Errorf("[WARNING] cannot get the name of the web process for route removal: %s", err)
+		}
+		var routesToAdd, routesToRemove []*url.URL
+		for i, c := range newContainers {
+			if c.ProcessName == newWebProcessName && c.ValidAddr() {
+				routesToAdd = append(routesToAdd, c.Address())
+				newContainers[i].Routable = true
+			}
+		}
+		for i, c := range args.toRemove {
+			if c.ProcessName == oldWebProcessName && c.ValidAddr() {
+				routesToRemove = append(routesToRemove, c.Address())
+				args.toRemove[i].Routable = true
+			}
+		}
+		fmt.Fprintf(writer, "\n---- Switching routes from %d old to %d new %s ----\n", len(routesToRemove), len(routesToAdd), pluralize("unit", len(routesToAdd)))
+		if len(routesToAdd) > 0 {
+			err = r.AddRoutes(args.app.GetName(), routesToAdd)
+			if err != nil {
+				r.RemoveRoutes(args.app.GetName(), routesToAdd)
+				return nil, err
+			}
+		}
+		if len(routesToRemove) > 0 {
+			err = r.RemoveRoutes(args.app.GetName(), routesToRemove)
+			if err != nil {
+				r.AddRoutes(args.app.GetName(), routesToRemove)
+				if len(routesToAdd) > 0 {
+					r.RemoveRoutes(args.app.GetName(), routesToAdd)
+				}
+				return nil, err
+			}
+		}
+		return newContainers, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		newContainers := ctx.FWResult.([]container.Container)
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			log.Errorf("[switch-routes:Backward] Error geting router: %s", err.Error())
+			return
+		}
+		w := args.writer
+		if w == nil {
+			w = ioutil.Discard
+		}
+		fmt.Fprintf(w, "\n---- Switching routes back to old units ----\n")
+		var routesToAdd, routesToRemove []*url.URL
+		for _, c := range args.toRemove {
+			if c.Routable {
+				routesToAdd = append(routesToAdd, c.Address())
+			}
+		}
+		for _, c := range newContainers {
+			if c.Routable {
+				routesToRemove = append(routesToRemove, c.Address())
+			}
+		}
+		if len(routesToAdd) > 0 {
+			err = r.AddRoutes(args.app.GetName(), routesToAdd)
+			if err != nil {
+				log.Errorf("[switch-routes:Backward] Error adding back route for [%v]: %s", routesToAdd, err.Error())
+			}
+		}
+		if len(routesToRemove) > 0 {
+			err = r.RemoveRoutes(args.app.GetName(), routesToRemove)
+			if err != nil {
+				log.Errorf("[switch-routes:Backward] Error removing route for [%v]: %s", routesToRemove, err.Error())
+			}
+		}
+	},
+	OnError:   rollbackNotice,
+	MinParams: 1,
+}
+
+var addCanaryRoutes = action.Action{
+	Name: "add-canary-routes",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		if err := checkCanceled(args.event); err != nil {
+			return nil, err
+		}
+		newContainers := ctx.Previous.([]container.Container)
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			return nil, err
+		}
+		wRouter, ok := r.(router.WeightedRouter)
+		if !ok {
+			return nil, errCanaryNoRouter
+		}
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		oldRoutes, err := r.Routes(args.app.GetName())
+		if err != nil {
+			return nil, err
+		}
+		var routesToAdd []*url.URL
+		for i, c := range newContainers {
+			if c.ValidAddr() {
+				routesToAdd = append(routesToAdd, c.Address())
+				newContainers[i].Routable = true
+			}
+		}
+		if len(routesToAdd) == 0 {
+			return newContainers, nil
+		}
+		oldWeight, newWeight := canaryWeights(args.canary.percentage, len(oldRoutes), len(routesToAdd))
+		fmt.Fprintf(writer, "\n---- Sending %d%% of the traffic to %d canary %s ----\n", args.canary.percentage, len(routesToAdd), pluralize("unit", len(routesToAdd)))
+		err = r.AddRoutes(args.app.GetName(), routesToAdd)
+		if err == nil {
+			err = wRouter.SetRoutesWeight(args.app.GetName(), routesToAdd, newWeight)
+		}
+		if err == nil && len(oldRoutes) > 0 {
+			err = wRouter.SetRoutesWeight(args.app.GetName(), oldRoutes, oldWeight)
+		}
+		if err != nil {
+			if len(oldRoutes) > 0 {
+				wRouter.SetRoutesWeight(args.app.GetName(), oldRoutes, 1)
+			}
+			r.RemoveRoutes(args.app.GetName(), routesToAdd)
+			return nil, err
+		}
+		return newContainers, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		newContainers := ctx.FWResult.([]container.Container)
+		r, err := getRouterForApp(args.app)
+		if err != nil {
+			log.Errorf("[add-canary-routes:Backward] Error geting router: %s", err.Error())
+			return
+		}
+		w := args.writer
+		if w == nil {
+			w = ioutil.Discard
+		}
+		fmt.Fprintf(w, "\n---- Removing routes from canary units ----\n")
+		var routesToRemove []*url.URL
+		for _, c := range newContainers {
+			if c.Routable {
+				routesToRemove = append(routesToRemove, c.Address())
+			}
+		}
+		if len(routesToRemove) == 0 {
+			return
+		}
+		err = r.RemoveRoutes(args.app.GetName(), routesToRemove)
+		if err != nil {
+			log.Errorf("[add-canary-routes:Backward] Error removing route for [%v]: %s", routesToRemove, err.Error())
+		}
+		oldRoutes, err := r.Routes(args.app.GetName())
+		if err != nil {
+			log.Errorf("[add-canary-routes:Backward] Error getting routes: %s", err.Error())
+			return
+		}
+		if wRouter, ok := r.(router.WeightedRouter); ok && len(oldRoutes) > 0 {
+			err = wRouter.SetRoutesWeight(args.app.GetName(), oldRoutes, 1)
+			if err != nil {
+				log.Errorf("[add-canary-routes:Backward] Error restoring routes weight: %s", err.Error())
+			}
+		}
+	},
+}
+
+var waitCanaryDecision = action.Action{
+	Name: "wait-canary-decision",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		args := ctx.Params[0].(changeUnitsPipelineArgs)
+		newContainers := ctx.Previous.([]container.Container)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		appName := args.app.GetName()
+		err := startCanary(appName, args.imageId)
+		if err != nil {
+			return nil, err
+		}
+		defer removeCanary(appName)
+		fmt.Fprintf(writer, "\n---- Waiting up to %s for the canary to be promoted or aborted ----\n", args.canary.timeout)
+		timeout := time.After(args.canary.timeout)
+		for {
+			if err = checkCanceled(args.event); err != nil {
+				return nil, err
+			}
+			state, err := getCanaryState(appName)
+			if err != nil {
+				return nil, err
+			}
+			switch state {
+			case canaryStatePromoted:
+				fmt.Fprintf(writer, " ---> Canary promoted\n")
+				return newContainers, resetCanaryWeights(args.app)
+			case canaryStateAborted:
+				fmt.Fprintf(writer, " ---> Canary aborted\n")
+				return nil, ErrCanaryAborted
+			}
+			select {
+			case <-timeout:
+				if args.canary.promoteOnTimeout {
+					fmt.Fprintf(writer, " ---> Canary promoted after timeout\n")
+					return newContainers, resetCanaryWeights(args.app)
+				}
+				return nil, ErrCanaryTimeout
+			case <-time.After(canaryPollInterval):
+			}
+		}
+	},
+	Backward: func(ctx action.BWContext) {
+	},
+}
+
 var provisionRemoveOldUnits = action.Action{
 	Name: "provision-remove-old-units",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
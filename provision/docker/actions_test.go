@@ -679,6 +679,100 @@ func (s *S) TestRemoveOldRoutesBackward(c *check.C) {
 	c.Assert(hasRoute, check.Equals, true)
 }
 
+func (s *S) TestSwitchRoutesName(c *check.C) {
+	c.Assert(switchRoutes.Name, check.Equals, "switch-routes")
+}
+
+func (s *S) TestSwitchRoutesForward(c *check.C) {
+	app := provisiontest.NewFakeApp("myapp", "python", 1)
+	imageName := "tsuru/app-" + app.GetName()
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python myapi.py",
+			"worker": "tail -f /dev/null",
+		},
+	}
+	err := image.SaveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	oldCont := container.Container{ID: "old-1", AppName: app.GetName(), ProcessName: "web", HostAddr: "127.0.0.1", HostPort: "1234"}
+	newCont1 := container.Container{ID: "new-1", AppName: app.GetName(), ProcessName: "web", HostAddr: "127.0.0.2", HostPort: "4321"}
+	newCont2 := container.Container{ID: "new-2", AppName: app.GetName(), ProcessName: "worker", HostAddr: "127.0.0.3", HostPort: "8080"}
+	err = routertest.FakeRouter.AddRoute(app.GetName(), oldCont.Address())
+	c.Assert(err, check.IsNil)
+	args := changeUnitsPipelineArgs{
+		app:         app,
+		provisioner: s.p,
+		imageId:     imageName,
+		toRemove:    []container.Container{oldCont},
+		blueGreen:   true,
+	}
+	context := action.FWContext{Previous: []container.Container{newCont1, newCont2}, Params: []interface{}{args}}
+	r, err := switchRoutes.Forward(context)
+	c.Assert(err, check.IsNil)
+	containers := r.([]container.Container)
+	c.Assert(containers, check.HasLen, 2)
+	c.Assert(containers[0].Routable, check.Equals, true)
+	c.Assert(containers[1].Routable, check.Equals, false)
+	c.Assert(args.toRemove[0].Routable, check.Equals, true)
+	routes, err := routertest.FakeRouter.Routes(app.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{newCont1.Address()})
+}
+
+func (s *S) TestSwitchRoutesForwardFailure(c *check.C) {
+	app := provisiontest.NewFakeApp("myapp", "python", 1)
+	imageName := "tsuru/app-" + app.GetName()
+	customData := map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python myapi.py",
+		},
+	}
+	err := image.SaveImageCustomData(imageName, customData)
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	oldCont := container.Container{ID: "old-1", AppName: app.GetName(), ProcessName: "web", HostAddr: "127.0.0.1", HostPort: "1234"}
+	newCont := container.Container{ID: "new-1", AppName: app.GetName(), ProcessName: "web", HostAddr: "127.0.0.2", HostPort: "4321"}
+	err = routertest.FakeRouter.AddRoute(app.GetName(), oldCont.Address())
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.FailForIp(oldCont.Address().String())
+	args := changeUnitsPipelineArgs{
+		app:         app,
+		provisioner: s.p,
+		imageId:     imageName,
+		toRemove:    []container.Container{oldCont},
+		blueGreen:   true,
+	}
+	context := action.FWContext{Previous: []container.Container{newCont}, Params: []interface{}{args}}
+	_, err = switchRoutes.Forward(context)
+	c.Assert(err, check.Equals, routertest.ErrForcedFailure)
+	c.Assert(routertest.FakeRouter.HasRoute(app.GetName(), oldCont.Address().String()), check.Equals, true)
+	c.Assert(routertest.FakeRouter.HasRoute(app.GetName(), newCont.Address().String()), check.Equals, false)
+}
+
+func (s *S) TestSwitchRoutesBackward(c *check.C) {
+	app := provisiontest.NewFakeApp("myapp", "python", 1)
+	routertest.FakeRouter.AddBackend(app.GetName())
+	defer routertest.FakeRouter.RemoveBackend(app.GetName())
+	oldCont := container.Container{ID: "old-1", AppName: app.GetName(), ProcessName: "web", HostAddr: "127.0.0.1", HostPort: "1234", Routable: true}
+	newCont := container.Container{ID: "new-1", AppName: app.GetName(), ProcessName: "web", HostAddr: "127.0.0.2", HostPort: "4321", Routable: true}
+	err := routertest.FakeRouter.AddRoute(app.GetName(), newCont.Address())
+	c.Assert(err, check.IsNil)
+	args := changeUnitsPipelineArgs{
+		app:         app,
+		provisioner: s.p,
+		toRemove:    []container.Container{oldCont},
+		blueGreen:   true,
+	}
+	context := action.BWContext{Params: []interface{}{args}, FWResult: []container.Container{newCont}}
+	switchRoutes.Backward(context)
+	routes, err := routertest.FakeRouter.Routes(app.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{oldCont.Address()})
+}
+
 func (s *S) TestSetNetworkInfoName(c *check.C) {
 	c.Assert(setNetworkInfo.Name, check.Equals, "set-network-info")
 }
@@ -1107,6 +1201,56 @@ func (s *S) TestBindAndHealthcheckDontHealtcheckForErroredApps(c *check.C) {
 	c.Assert(fakeApp.HasBind(&u2), check.Equals, true)
 }
 
+func (s *S) TestBindAndHealthcheckBlueGreenHealthchecksErroredApps(c *check.C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	dbApp := &app.App{Name: "myapp"}
+	err := s.storage.Apps().Insert(dbApp)
+	c.Assert(err, check.IsNil)
+	imageName := "tsuru/app-" + dbApp.Name
+	customData := map[string]interface{}{
+		"healthcheck": map[string]interface{}{
+			"path":   "/x/y",
+			"status": http.StatusOK,
+		},
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	}
+	err = s.newFakeImage(s.p, imageName, customData)
+	c.Assert(err, check.IsNil)
+	fakeApp := provisiontest.NewFakeApp(dbApp.Name, "python", 0)
+	s.p.Provision(fakeApp)
+	defer s.p.Destroy(fakeApp)
+	buf := safe.NewBuffer(nil)
+	contOpts := newContainerOpts{
+		Status: "error",
+	}
+	oldContainer, err := s.newContainer(&contOpts, nil)
+	c.Assert(err, check.IsNil)
+	args := changeUnitsPipelineArgs{
+		app:         fakeApp,
+		provisioner: s.p,
+		writer:      buf,
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 1}},
+		imageId:     "tsuru/app-" + dbApp.Name,
+		toRemove:    []container.Container{*oldContainer},
+		blueGreen:   true,
+	}
+	containers, err := addContainersWithHost(&args)
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 1)
+	url, _ := url.Parse(server.URL)
+	host, port, _ := net.SplitHostPort(url.Host)
+	containers[0].HostAddr = host
+	containers[0].HostPort = port
+	context := action.FWContext{Params: []interface{}{args}, Previous: containers}
+	_, err = bindAndHealthcheck.Forward(context)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(.*?\): wrong status code, expected 200, got: 404`)
+}
+
 func (s *S) TestBindAndHealthcheckDontHealtcheckForStoppedApps(c *check.C) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
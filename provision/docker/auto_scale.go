@@ -22,6 +22,7 @@ import (
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"github.com/tsuru/tsuru/provision/docker/nodecontainer"
 	"github.com/tsuru/tsuru/queue"
@@ -46,6 +47,8 @@ type autoScaleConfig struct {
 	WaitTimeNewMachine  time.Duration
 	RunInterval         time.Duration
 	TotalMemoryMetadata string
+	TotalCpuMetadata    string
+	MaxCpuRatio         float32
 	Enabled             bool
 	provisioner         *dockerProvisioner
 	done                chan bool
@@ -68,7 +71,7 @@ func (r *scalerResult) NoAction() bool {
 }
 
 type autoScaler interface {
-	scale(pool string, nodes []*cluster.Node) (*scalerResult, error)
+	scale(pool string, nodes []*cluster.Node, dryRun bool) (*scalerResult, error)
 }
 
 type metaWithFrequency struct {
@@ -86,6 +89,13 @@ func (a *autoScaleConfig) initialize() {
 	if a.TotalMemoryMetadata == "" {
 		a.TotalMemoryMetadata, _ = config.GetString("docker:scheduler:total-memory-metadata")
 	}
+	if a.TotalCpuMetadata == "" {
+		a.TotalCpuMetadata, _ = config.GetString("docker:scheduler:total-cpu-metadata")
+	}
+	if a.MaxCpuRatio == 0 {
+		maxCpuRatio, _ := config.GetFloat("docker:scheduler:max-used-cpu")
+		a.MaxCpuRatio = float32(maxCpuRatio)
+	}
 	if a.RunInterval == 0 {
 		a.RunInterval = time.Hour
 	}
@@ -98,6 +108,17 @@ func (a *autoScaleConfig) scalerForRule(rule *autoScaleRule) (autoScaler, error)
 	if rule.MaxContainerCount > 0 {
 		return &countScaler{autoScaleConfig: a, rule: rule}, nil
 	}
+	if a.TotalCpuMetadata != "" {
+		// A cpu ratio set in the rule takes precedence over memory settings,
+		// the cpu ratio from the config file is used only when no memory
+		// information is available.
+		if rule.MaxCpuRatio > 0 {
+			return &cpuScaler{autoScaleConfig: a, rule: rule, maxCpuRatio: rule.MaxCpuRatio}, nil
+		}
+		if (a.TotalMemoryMetadata == "" || rule.MaxMemoryRatio <= 0) && a.MaxCpuRatio > 0 {
+			return &cpuScaler{autoScaleConfig: a, rule: rule, maxCpuRatio: a.MaxCpuRatio}, nil
+		}
+	}
 	return &memoryScaler{autoScaleConfig: a, rule: rule}, nil
 }
 
@@ -154,11 +175,22 @@ func (a *autoScaleConfig) runScaler() (retErr error) {
 			retErr = fmt.Errorf("recovered panic, we can never stop! panic: %v", r)
 		}
 	}()
-	nodes, err := a.provisioner.Cluster().Nodes()
+	clusterMap, err := a.nodesByPool()
 	if err != nil {
-		retErr = fmt.Errorf("error getting nodes: %s", err.Error())
+		retErr = err
 		return
 	}
+	for pool, nodes := range clusterMap {
+		a.runScalerInNodes(pool, nodes)
+	}
+	return
+}
+
+func (a *autoScaleConfig) nodesByPool() (map[string][]*cluster.Node, error) {
+	nodes, err := a.provisioner.Cluster().Nodes()
+	if err != nil {
+		return nil, fmt.Errorf("error getting nodes: %s", err.Error())
+	}
 	clusterMap := map[string][]*cluster.Node{}
 	for i := range nodes {
 		node := &nodes[i]
@@ -169,10 +201,99 @@ func (a *autoScaleConfig) runScaler() (retErr error) {
 		}
 		clusterMap[pool] = append(clusterMap[pool], node)
 	}
-	for pool, nodes := range clusterMap {
-		a.runScalerInNodes(pool, nodes)
+	return clusterMap, nil
+}
+
+// autoScaleDryRunResult describes the decision the auto scaler would take
+// for a pool, without acting on it.
+type autoScaleDryRunResult struct {
+	Pool   string
+	Rule   *autoScaleRule
+	Result *scalerResult
+	Error  string `json:",omitempty"`
+}
+
+// dryRun evaluates the scaler for every pool and returns the resulting
+// decisions. No machines are created or removed and no containers are moved.
+func (a *autoScaleConfig) dryRun() ([]autoScaleDryRunResult, error) {
+	a.initialize()
+	clusterMap, err := a.nodesByPool()
+	if err != nil {
+		return nil, err
 	}
-	return
+	pools := make([]string, 0, len(clusterMap))
+	for pool := range clusterMap {
+		pools = append(pools, pool)
+	}
+	sort.Strings(pools)
+	results := make([]autoScaleDryRunResult, len(pools))
+	for i, pool := range pools {
+		results[i] = a.dryRunInNodes(pool, clusterMap[pool])
+	}
+	return results, nil
+}
+
+func (a *autoScaleConfig) dryRunInNodes(pool string, nodes []*cluster.Node) autoScaleDryRunResult {
+	result := autoScaleDryRunResult{Pool: pool}
+	rule, sResult, err := a.scaleDecision(pool, nodes, true, func(string, ...interface{}) {})
+	result.Rule = rule
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if sResult == nil {
+		return result
+	}
+	result.Result = sResult
+	if !rule.PreventRebalance {
+		err = a.checkRebalance(pool, nodes, sResult, true)
+		if err != nil {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
+
+// scaleDecision runs the scaler configured in the auto scale rule of the pool,
+// returning the rule and what should be done with the nodes of the pool. Both
+// the scaler and its dry run use it, so they always take the same decision. A
+// nil result and error means there's nothing to do, either because the pool
+// has no enabled rule or because of a reason logged with logf. Errors of type
+// errAppNotLocked are returned as is, as the scaler may be retried later. Apps
+// in the pool are not locked when dryRun is set.
+func (a *autoScaleConfig) scaleDecision(pool string, nodes []*cluster.Node, dryRun bool, logf func(string, ...interface{})) (*autoScaleRule, *scalerResult, error) {
+	rule, err := autoScaleRuleForPool(pool)
+	if err != nil {
+		if err != mgo.ErrNotFound {
+			return nil, nil, fmt.Errorf("unable to fetch auto scale rules for %s: %s", pool, err)
+		}
+		logf("no auto scale rule for %s", pool)
+		return nil, nil, nil
+	}
+	if !rule.Enabled {
+		logf("auto scale rule disabled for %s", pool)
+		return rule, nil, nil
+	}
+	err = provision.CheckMaintenance(pool)
+	if err != nil {
+		if _, ok := err.(*provision.ErrPoolInMaintenance); ok {
+			return rule, nil, err
+		}
+		return rule, nil, fmt.Errorf("unable to check maintenance for %s: %s", pool, err)
+	}
+	scaler, err := a.scalerForRule(rule)
+	if err != nil {
+		return rule, nil, fmt.Errorf("error getting scaler for %s: %s", pool, err)
+	}
+	logf("running scaler %T for %q: %q", scaler, poolMetadataName, pool)
+	sResult, err := scaler.scale(pool, nodes, dryRun)
+	if err != nil {
+		if _, ok := err.(errAppNotLocked); ok {
+			return rule, nil, err
+		}
+		return rule, nil, fmt.Errorf("error scaling group %s: %s", pool, err.Error())
+	}
+	return rule, sResult, nil
 }
 
 type evtCustomData struct {
@@ -215,35 +336,16 @@ func (a *autoScaleConfig) runScalerInNodes(pool string, nodes []*cluster.Node) {
 			})
 		}
 	}()
-	rule, err = autoScaleRuleForMetadata(pool)
-	if err == mgo.ErrNotFound {
-		rule, err = autoScaleRuleForMetadata("")
-	}
-	if err != nil {
-		if err != mgo.ErrNotFound {
-			retErr = fmt.Errorf("unable to fetch auto scale rules for %s: %s", pool, err)
-			return
-		}
-		evt.Logf("no auto scale rule for %s", pool)
-		return
-	}
-	if !rule.Enabled {
-		evt.Logf("auto scale rule disabled for %s", pool)
-		return
-	}
-	scaler, err := a.scalerForRule(rule)
-	if err != nil {
-		retErr = fmt.Errorf("error getting scaler for %s: %s", pool, err)
-		return
-	}
-	evt.Logf("running scaler %T for %q: %q", scaler, poolMetadataName, pool)
-	sResult, err = scaler.scale(pool, nodes)
+	rule, sResult, err = a.scaleDecision(pool, nodes, false, evt.Logf)
 	if err != nil {
 		if _, ok := err.(errAppNotLocked); ok {
 			evt.Logf("aborting scaler for now, gonna retry later: %s", err)
 			return
 		}
-		retErr = fmt.Errorf("error scaling group %s: %s", pool, err.Error())
+		retErr = err
+		return
+	}
+	if sResult == nil {
 		return
 	}
 	if sResult.ToAdd > 0 {
@@ -278,6 +380,29 @@ func (a *autoScaleConfig) runScalerInNodes(pool string, nodes []*cluster.Node) {
 }
 
 func (a *autoScaleConfig) rebalanceIfNeeded(evt *event.Event, pool string, nodes []*cluster.Node, sResult *scalerResult) error {
+	if len(sResult.ToRemove) > 0 {
+		return nil
+	}
+	err := a.checkRebalance(pool, nodes, sResult, false)
+	if err != nil {
+		return err
+	}
+	if sResult.ToRebalance {
+		evt.Logf("running rebalance, for %q: %#v", pool, sResult)
+		buf := safe.NewBuffer(nil)
+		writer := io.MultiWriter(buf, evt)
+		rebalanceFilter := map[string]string{poolMetadataName: pool}
+		_, err := a.provisioner.rebalanceContainersByFilter(writer, nil, rebalanceFilter, false)
+		if err != nil {
+			return fmt.Errorf("unable to rebalance containers: %s - log: %s", err.Error(), buf.String())
+		}
+	}
+	return nil
+}
+
+// checkRebalance sets sResult.ToRebalance if containers in the pool should be
+// rebalanced, running a dry rebalance to compare the containers gap.
+func (a *autoScaleConfig) checkRebalance(pool string, nodes []*cluster.Node, sResult *scalerResult, dryRun bool) error {
 	if len(sResult.ToRemove) > 0 {
 		return nil
 	}
@@ -287,7 +412,7 @@ func (a *autoScaleConfig) rebalanceIfNeeded(evt *event.Event, pool string, nodes
 	rebalanceFilter := map[string]string{poolMetadataName: pool}
 	if !sResult.ToRebalance {
 		// No action yet, check if we need rebalance
-		_, gap, err := a.provisioner.containerGapInNodes(nodes)
+		_, gap, err := a.provisioner.containerGapInNodes(nodes, dryRun)
 		buf := safe.NewBuffer(nil)
 		dryProvisioner, err := a.provisioner.rebalanceContainersByFilter(buf, nil, rebalanceFilter, true)
 		if err != nil {
@@ -296,7 +421,7 @@ func (a *autoScaleConfig) rebalanceIfNeeded(evt *event.Event, pool string, nodes
 		if dryProvisioner == nil {
 			return nil
 		}
-		_, gapAfter, err := dryProvisioner.containerGapInNodes(nodes)
+		_, gapAfter, err := dryProvisioner.containerGapInNodes(nodes, dryRun)
 		if err != nil {
 			return fmt.Errorf("couldn't find containers from rebalanced nodes: %s", err)
 		}
@@ -307,15 +432,6 @@ func (a *autoScaleConfig) rebalanceIfNeeded(evt *event.Event, pool string, nodes
 			}
 		}
 	}
-	if sResult.ToRebalance {
-		evt.Logf("running rebalance, for %q: %#v", pool, sResult)
-		buf := safe.NewBuffer(nil)
-		writer := io.MultiWriter(buf, evt)
-		_, err := a.provisioner.rebalanceContainersByFilter(writer, nil, rebalanceFilter, false)
-		if err != nil {
-			return fmt.Errorf("unable to rebalance containers: %s - log: %s", err.Error(), buf.String())
-		}
-	}
 	return nil
 }
 
@@ -436,6 +552,41 @@ func (a *autoScaleConfig) removeMultipleNodes(evt *event.Event, chosenNodes []cl
 	return nil
 }
 
+type nodeUsage struct {
+	node  *cluster.Node
+	count int
+}
+
+type nodeUsageList []nodeUsage
+
+func (l nodeUsageList) Len() int           { return len(l) }
+func (l nodeUsageList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l nodeUsageList) Less(i, j int) bool { return l[i].count < l[j].count }
+
+// nodesForRemoval returns the nodes in the order they should be considered for
+// removal. Pools using the binpack scheduler strategy keep units in as few
+// nodes as possible, so nodes with fewer containers come first, freeing the
+// nodes left empty by the scheduler.
+func nodesForRemoval(pool string, nodes []*cluster.Node, containerCount func(*cluster.Node) int) ([]*cluster.Node, error) {
+	strategy, err := schedulerStrategy(pool)
+	if err != nil {
+		return nil, err
+	}
+	if strategy != schedulerStrategyBinpack {
+		return nodes, nil
+	}
+	usage := make(nodeUsageList, len(nodes))
+	for i, n := range nodes {
+		usage[i] = nodeUsage{node: n, count: containerCount(n)}
+	}
+	sort.Stable(usage)
+	result := make([]*cluster.Node, len(usage))
+	for i := range usage {
+		result[i] = usage[i].node
+	}
+	return result, nil
+}
+
 func chooseNodeForRemoval(nodes []*cluster.Node, toRemoveCount int) []cluster.Node {
 	var chosenNodes []cluster.Node
 	remainingNodes := nodes[:]
@@ -490,8 +641,9 @@ func canRemoveNode(chosenNode *cluster.Node, nodes []*cluster.Node) (bool, error
 func cleanMetadata(n *cluster.Node) map[string]string {
 	// iaas-id is ignored because it wasn't created in previous tsuru versions
 	// and having nodes with and without it would cause unbalanced metadata
-	// errors.
-	ignoredMetadata := []string{"iaas-id"}
+	// errors. The same applies to cordoned, which is set only while a node
+	// is under maintenance.
+	ignoredMetadata := []string{"iaas-id", cordonedMetadataName}
 	metadata := n.CleanMetadata()
 	for _, val := range ignoredMetadata {
 		delete(metadata, val)
@@ -571,20 +723,25 @@ func chooseMetadataFromNodes(modelNodes []*cluster.Node) (map[string]string, err
 	return baseMetadata, nil
 }
 
-func (p *dockerProvisioner) runningContainersByNode(nodes []*cluster.Node) (map[string][]container.Container, error) {
-	appNames, err := p.listAppsForNodes(nodes)
-	if err != nil {
-		return nil, err
-	}
-	for _, appName := range appNames {
-		locked, err := app.AcquireApplicationLock(appName, app.InternalAppName, "node auto scale")
+// runningContainersByNode returns the running containers in each node, locking
+// the apps with containers in the nodes while they're listed. Dry runs only
+// read the containers and skip the locks.
+func (p *dockerProvisioner) runningContainersByNode(nodes []*cluster.Node, dryRun bool) (map[string][]container.Container, error) {
+	if !dryRun {
+		appNames, err := p.listAppsForNodes(nodes)
 		if err != nil {
 			return nil, err
 		}
-		if !locked {
-			return nil, errAppNotLocked{app: appName}
+		for _, appName := range appNames {
+			locked, err := app.AcquireApplicationLock(appName, app.InternalAppName, "node auto scale")
+			if err != nil {
+				return nil, err
+			}
+			if !locked {
+				return nil, errAppNotLocked{app: appName}
+			}
+			defer app.ReleaseApplicationLock(appName)
 		}
-		defer app.ReleaseApplicationLock(appName)
 	}
 	result := map[string][]container.Container{}
 	for _, n := range nodes {
@@ -597,11 +754,11 @@ func (p *dockerProvisioner) runningContainersByNode(nodes []*cluster.Node) (map[
 	return result, nil
 }
 
-func (p *dockerProvisioner) containerGapInNodes(nodes []*cluster.Node) (int, int, error) {
+func (p *dockerProvisioner) containerGapInNodes(nodes []*cluster.Node, dryRun bool) (int, int, error) {
 	maxCount := 0
 	minCount := -1
 	totalCount := 0
-	containersMap, err := p.runningContainersByNode(nodes)
+	containersMap, err := p.runningContainersByNode(nodes, dryRun)
 	if err != nil {
 		return 0, 0, err
 	}
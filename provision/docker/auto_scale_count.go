@@ -15,8 +15,8 @@ type countScaler struct {
 	rule *autoScaleRule
 }
 
-func (a *countScaler) scale(groupMetadata string, nodes []*cluster.Node) (*scalerResult, error) {
-	totalCount, _, err := a.provisioner.containerGapInNodes(nodes)
+func (a *countScaler) scale(groupMetadata string, nodes []*cluster.Node, dryRun bool) (*scalerResult, error) {
+	totalCount, _, err := a.provisioner.containerGapInNodes(nodes, dryRun)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,148 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/tsuru/docker-cluster/cluster"
+	"github.com/tsuru/tsuru/app"
+)
+
+type cpuScaler struct {
+	*autoScaleConfig
+	rule        *autoScaleRule
+	maxCpuRatio float32
+}
+
+type nodeCpuData struct {
+	node          *cluster.Node
+	maxCpu        int
+	reserved      int
+	available     int
+	containersCpu map[string]int
+}
+
+func (a *cpuScaler) nodesCpuData(nodes []*cluster.Node, dryRun bool) (map[string]*nodeCpuData, error) {
+	nodesCpuData := make(map[string]*nodeCpuData)
+	containersMap, err := a.provisioner.runningContainersByNode(nodes, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		totalCpu, _ := strconv.ParseFloat(node.Metadata[a.TotalCpuMetadata], 64)
+		if totalCpu == 0.0 {
+			return nil, fmt.Errorf("no value found for cpu metadata (%s) in node %s", a.TotalCpuMetadata, node.Address)
+		}
+		maxCpu := int(float64(a.maxCpuRatio) * totalCpu)
+		data := &nodeCpuData{
+			containersCpu: make(map[string]int),
+			node:          node,
+			maxCpu:        maxCpu,
+		}
+		nodesCpuData[node.Address] = data
+		for _, cont := range containersMap[node.Address] {
+			a, err := app.GetByName(cont.AppName)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't find container app (%s): %s", cont.AppName, err)
+			}
+			data.containersCpu[cont.ID] = a.Plan.CpuShare
+			data.reserved += a.Plan.CpuShare
+		}
+		data.available = data.maxCpu - data.reserved
+	}
+	return nodesCpuData, nil
+}
+
+func (a *cpuScaler) chooseNodeForRemoval(maxPlanCpu int, groupMetadata string, nodes []*cluster.Node, dryRun bool) ([]cluster.Node, error) {
+	cpuData, err := a.nodesCpuData(nodes, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	var totalReserved, totalCpu int
+	for _, node := range nodes {
+		data := cpuData[node.Address]
+		totalReserved += data.reserved
+		totalCpu += data.maxCpu
+	}
+	cpuPerNode := totalCpu / len(nodes)
+	scaledMaxPlan := int(float32(maxPlanCpu) * a.rule.ScaleDownRatio)
+	toRemoveCount := len(nodes) - (((totalReserved + scaledMaxPlan) / cpuPerNode) + 1)
+	if toRemoveCount <= 0 {
+		return nil, nil
+	}
+	candidates, err := nodesForRemoval(groupMetadata, nodes, func(n *cluster.Node) int {
+		return len(cpuData[n.Address].containersCpu)
+	})
+	if err != nil {
+		return nil, err
+	}
+	chosenNodes := chooseNodeForRemoval(candidates, toRemoveCount)
+	if len(chosenNodes) == 0 {
+		return nil, nil
+	}
+	return chosenNodes, nil
+}
+
+func (a *cpuScaler) scale(groupMetadata string, nodes []*cluster.Node, dryRun bool) (*scalerResult, error) {
+	plans, err := app.PlansList()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list plans: %s", err)
+	}
+	var maxPlanCpu int
+	for _, plan := range plans {
+		if plan.CpuShare > maxPlanCpu {
+			maxPlanCpu = plan.CpuShare
+		}
+	}
+	if maxPlanCpu == 0 {
+		var defaultPlan *app.Plan
+		defaultPlan, err = app.DefaultPlan()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get default plan: %s", err)
+		}
+		maxPlanCpu = defaultPlan.CpuShare
+	}
+	chosenNodes, err := a.chooseNodeForRemoval(maxPlanCpu, groupMetadata, nodes, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if chosenNodes != nil {
+		return &scalerResult{
+			ToRemove: chosenNodes,
+			Reason:   fmt.Sprintf("containers can be distributed in only %d nodes", len(nodes)-len(chosenNodes)),
+		}, nil
+	}
+	cpuData, err := a.nodesCpuData(nodes, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	canFitMax := false
+	var totalReserved, totalCpu int
+	for _, node := range nodes {
+		data := cpuData[node.Address]
+		if maxPlanCpu > data.maxCpu {
+			return nil, fmt.Errorf("aborting, impossible to fit max plan cpu of %d shares, node max available cpu is %d", maxPlanCpu, data.maxCpu)
+		}
+		totalReserved += data.reserved
+		totalCpu += data.maxCpu
+		if data.available >= maxPlanCpu {
+			canFitMax = true
+			break
+		}
+	}
+	if canFitMax {
+		return &scalerResult{}, nil
+	}
+	nodesToAdd := (totalReserved + maxPlanCpu) / totalCpu
+	if nodesToAdd == 0 {
+		return &scalerResult{}, nil
+	}
+	return &scalerResult{
+		ToAdd:  nodesToAdd,
+		Reason: fmt.Sprintf("can't add %d cpu shares to an existing node", maxPlanCpu),
+	}, nil
+}
@@ -25,9 +25,9 @@ type nodeMemoryData struct {
 	containersMemory map[string]int64
 }
 
-func (a *memoryScaler) nodesMemoryData(nodes []*cluster.Node) (map[string]*nodeMemoryData, error) {
+func (a *memoryScaler) nodesMemoryData(nodes []*cluster.Node, dryRun bool) (map[string]*nodeMemoryData, error) {
 	nodesMemoryData := make(map[string]*nodeMemoryData)
-	containersMap, err := a.provisioner.runningContainersByNode(nodes)
+	containersMap, err := a.provisioner.runningContainersByNode(nodes, dryRun)
 	if err != nil {
 		return nil, err
 	}
@@ -56,8 +56,8 @@ func (a *memoryScaler) nodesMemoryData(nodes []*cluster.Node) (map[string]*nodeM
 	return nodesMemoryData, nil
 }
 
-func (a *memoryScaler) chooseNodeForRemoval(maxPlanMemory int64, groupMetadata string, nodes []*cluster.Node) ([]cluster.Node, error) {
-	memoryData, err := a.nodesMemoryData(nodes)
+func (a *memoryScaler) chooseNodeForRemoval(maxPlanMemory int64, groupMetadata string, nodes []*cluster.Node, dryRun bool) ([]cluster.Node, error) {
+	memoryData, err := a.nodesMemoryData(nodes, dryRun)
 	if err != nil {
 		return nil, err
 	}
@@ -73,14 +73,20 @@ func (a *memoryScaler) chooseNodeForRemoval(maxPlanMemory int64, groupMetadata s
 	if toRemoveCount <= 0 {
 		return nil, nil
 	}
-	chosenNodes := chooseNodeForRemoval(nodes, toRemoveCount)
+	candidates, err := nodesForRemoval(groupMetadata, nodes, func(n *cluster.Node) int {
+		return len(memoryData[n.Address].containersMemory)
+	})
+	if err != nil {
+		return nil, err
+	}
+	chosenNodes := chooseNodeForRemoval(candidates, toRemoveCount)
 	if len(chosenNodes) == 0 {
 		return nil, nil
 	}
 	return chosenNodes, nil
 }
 
-func (a *memoryScaler) scale(groupMetadata string, nodes []*cluster.Node) (*scalerResult, error) {
+func (a *memoryScaler) scale(groupMetadata string, nodes []*cluster.Node, dryRun bool) (*scalerResult, error) {
 	plans, err := app.PlansList()
 	if err != nil {
 		return nil, fmt.Errorf("couldn't list plans: %s", err)
@@ -99,7 +105,7 @@ func (a *memoryScaler) scale(groupMetadata string, nodes []*cluster.Node) (*scal
 		}
 		maxPlanMemory = defaultPlan.Memory
 	}
-	chosenNodes, err := a.chooseNodeForRemoval(maxPlanMemory, groupMetadata, nodes)
+	chosenNodes, err := a.chooseNodeForRemoval(maxPlanMemory, groupMetadata, nodes, dryRun)
 	if err != nil {
 		return nil, err
 	}
@@ -109,7 +115,7 @@ func (a *memoryScaler) scale(groupMetadata string, nodes []*cluster.Node) (*scal
 			Reason:   fmt.Sprintf("containers can be distributed in only %d nodes", len(nodes)-len(chosenNodes)),
 		}, nil
 	}
-	memoryData, err := a.nodesMemoryData(nodes)
+	memoryData, err := a.nodesMemoryData(nodes, dryRun)
 	if err != nil {
 		return nil, err
 	}
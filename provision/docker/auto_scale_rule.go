@@ -20,6 +20,7 @@ type autoScaleRule struct {
 	MaxContainerCount int
 	ScaleDownRatio    float32
 	MaxMemoryRatio    float32
+	MaxCpuRatio       float32
 	Enabled           bool
 	PreventRebalance  bool
 }
@@ -43,8 +44,22 @@ func (r *autoScaleRule) normalize() error {
 		r.MaxMemoryRatio = float32(maxMemoryRatio)
 	}
 	TotalMemoryMetadata, _ := config.GetString("docker:scheduler:total-memory-metadata")
-	if r.Enabled && r.MaxContainerCount <= 0 && (TotalMemoryMetadata == "" || r.MaxMemoryRatio <= 0) {
-		err := fmt.Errorf("invalid rule, either memory information or max container count must be set")
+	TotalCpuMetadata, _ := config.GetString("docker:scheduler:total-cpu-metadata")
+	if r.MaxCpuRatio < 0 {
+		err := fmt.Errorf("invalid rule, max cpu ratio must not be negative, got %f", r.MaxCpuRatio)
+		r.Error = err.Error()
+		return err
+	}
+	if r.MaxCpuRatio > 0 && TotalCpuMetadata == "" {
+		err := fmt.Errorf("invalid rule, max cpu ratio requires docker:scheduler:total-cpu-metadata to be set")
+		r.Error = err.Error()
+		return err
+	}
+	maxCpuRatio, _ := config.GetFloat("docker:scheduler:max-used-cpu")
+	hasMemoryInfo := TotalMemoryMetadata != "" && r.MaxMemoryRatio > 0
+	hasCpuInfo := TotalCpuMetadata != "" && (r.MaxCpuRatio > 0 || maxCpuRatio > 0)
+	if r.Enabled && r.MaxContainerCount <= 0 && !hasMemoryInfo && !hasCpuInfo {
+		err := fmt.Errorf("invalid rule, either memory information, cpu information or max container count must be set")
 		r.Error = err.Error()
 		return err
 	}
@@ -150,3 +165,13 @@ func autoScaleRuleForMetadata(metadataFilter string) (*autoScaleRule, error) {
 	}
 	return &rule, nil
 }
+
+// autoScaleRuleForPool returns the rule for the pool, falling back to the
+// default rule when the pool has no rule of its own.
+func autoScaleRuleForPool(pool string) (*autoScaleRule, error) {
+	rule, err := autoScaleRuleForMetadata(pool)
+	if err == mgo.ErrNotFound {
+		rule, err = autoScaleRuleForMetadata("")
+	}
+	return rule, err
+}
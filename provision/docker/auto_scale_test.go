@@ -180,6 +180,141 @@ func (s *AutoScaleSuite) TestAutoScaleConfigRun(c *check.C) {
 	c.Assert(locked, check.Equals, true)
 }
 
+func (s *AutoScaleSuite) TestAutoScaleConfigRunPoolInMaintenance(c *check.C) {
+	_, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 4}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "datacenter move",
+	})
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	a.runOnce()
+	nodes, err := s.p.cluster.Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: "pool", Value: "pool1"},
+		Kind:         "autoscale",
+		ErrorMatches: `pool "pool1" is under maintenance until .*: datacenter move`,
+	}, eventtest.HasEvent)
+}
+
+func (s *AutoScaleSuite) TestAutoScaleConfigDryRun(c *check.C) {
+	_, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 4}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	results, err := a.dryRun()
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Pool, check.Equals, "pool1")
+	c.Assert(results[0].Error, check.Equals, "")
+	c.Assert(results[0].Rule.MaxContainerCount, check.Equals, 2)
+	c.Assert(results[0].Result, check.DeepEquals, &scalerResult{
+		ToAdd:       1,
+		ToRebalance: true,
+		Reason:      "number of free slots is -2",
+	})
+	nodes, err := s.p.cluster.Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	containers, err := s.p.listContainersByHost(net.URLToHost(nodes[0].Address))
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 4)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
+func (s *AutoScaleSuite) TestAutoScaleConfigDryRunLockedApp(c *check.C) {
+	_, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 4}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	locked, err := app.AcquireApplicationLock(s.appInstance.GetName(), "tsurud", "something")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer app.ReleaseApplicationLock(s.appInstance.GetName())
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	results, err := a.dryRun()
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Error, check.Equals, "")
+	c.Assert(results[0].Result, check.DeepEquals, &scalerResult{
+		ToAdd:       1,
+		ToRebalance: true,
+		Reason:      "number of free slots is -2",
+	})
+	dbApp, err := app.GetByName(s.appInstance.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbApp.Lock.Owner, check.Equals, "tsurud")
+}
+
+func (s *AutoScaleSuite) TestAutoScaleConfigDryRunPoolInMaintenance(c *check.C) {
+	_, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 4}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "datacenter move",
+	})
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	results, err := a.dryRun()
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Pool, check.Equals, "pool1")
+	c.Assert(results[0].Rule.MaxContainerCount, check.Equals, 2)
+	c.Assert(results[0].Result, check.IsNil)
+	c.Assert(results[0].Error, check.Matches, `pool "pool1" is under maintenance until .*: datacenter move`)
+}
+
+func (s *AutoScaleSuite) TestAutoScaleConfigDryRunRuleDisabled(c *check.C) {
+	config.Unset("docker:auto-scale:max-container-count")
+	rule := autoScaleRule{MetadataFilter: "pool1", Enabled: false, MaxContainerCount: 2, ScaleDownRatio: 1.5}
+	err := rule.update()
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	results, err := a.dryRun()
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Pool, check.Equals, "pool1")
+	c.Assert(results[0].Rule.Enabled, check.Equals, false)
+	c.Assert(results[0].Result, check.IsNil)
+}
+
 func (s *AutoScaleSuite) TestAutoScaleConfigRunNoRebalance(c *check.C) {
 	config.Set("docker:auto-scale:prevent-rebalance", true)
 	defer config.Unset("docker:auto-scale:prevent-rebalance")
@@ -1091,6 +1226,225 @@ func (s *AutoScaleSuite) TestAutoScaleConfigRunScaleDownMemoryScalerMultipleNode
 	c.Assert(containers, check.HasLen, 3)
 }
 
+func (s *AutoScaleSuite) TestAutoScaleConfigRunScaleDownMemoryScalerBinpackRemovesEmptyNode(c *check.C) {
+	config.Set("docker:scheduler:max-used-memory", 0.8)
+	config.Unset("docker:auto-scale:max-container-count")
+	defer config.Unset("docker:scheduler:max-used-memory")
+	config.Set("docker:scheduler:total-memory-metadata", "totalMem")
+	defer config.Unset("docker:scheduler:total-memory-metadata")
+	conf := schedulerConfig{Strategy: schedulerStrategyBinpack}
+	err := conf.save("pool1")
+	c.Assert(err, check.IsNil)
+	otherUrl := fmt.Sprintf("http://localhost:%d/", dockertest.URLPort(s.node2.URL()))
+	node := cluster.Node{Address: otherUrl, Metadata: map[string]string{
+		"pool":     "pool1",
+		"iaas":     "my-scale-iaas",
+		"totalMem": "25165824",
+	}}
+	err = s.p.cluster.Register(node)
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 1}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+		toHost:      "127.0.0.1",
+	})
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	a.runOnce()
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: "pool", Value: "pool1"},
+		Kind:   "autoscale",
+		EndCustomData: map[string]interface{}{
+			"result.toremove":    bson.M{"$size": 1},
+			"result.torebalance": false,
+			"result.reason":      "containers can be distributed in only 1 nodes",
+			"nodes":              bson.M{"$size": 1},
+		},
+	}, eventtest.HasEvent)
+	nodes, err := s.p.cluster.Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(net.URLToHost(nodes[0].Address), check.Equals, "127.0.0.1")
+	containers, err := s.p.listContainersByHost("127.0.0.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 1)
+}
+
+func (s *S) TestNodesForRemoval(c *check.C) {
+	nodes := []*cluster.Node{
+		{Address: "http://server1:1234"},
+		{Address: "http://server2:1234"},
+		{Address: "http://server3:1234"},
+	}
+	counts := map[string]int{
+		"http://server1:1234": 2,
+		"http://server2:1234": 0,
+		"http://server3:1234": 1,
+	}
+	count := func(n *cluster.Node) int { return counts[n.Address] }
+	result, err := nodesForRemoval("pool1", nodes, count)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, nodes)
+	conf := schedulerConfig{Strategy: schedulerStrategyBinpack}
+	err = conf.save("pool1")
+	c.Assert(err, check.IsNil)
+	result, err = nodesForRemoval("pool1", nodes, count)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, []*cluster.Node{nodes[1], nodes[2], nodes[0]})
+}
+
+func (s *AutoScaleSuite) prepareCpuScaler(c *check.C) {
+	config.Unset("docker:auto-scale:max-container-count")
+	config.Set("docker:scheduler:max-used-cpu", 0.8)
+	config.Set("docker:scheduler:total-cpu-metadata", "totalCpu")
+	err := s.S.storage.Apps().Update(
+		bson.M{"name": s.appInstance.GetName()},
+		bson.M{"$set": bson.M{"plan.cpushare": 10}},
+	)
+	c.Assert(err, check.IsNil)
+	nodes, err := s.p.cluster.Nodes()
+	c.Assert(err, check.IsNil)
+	for _, n := range nodes {
+		n.Metadata["totalCpu"] = "50"
+		_, err = s.p.cluster.UpdateNode(n)
+		c.Assert(err, check.IsNil)
+	}
+}
+
+func (s *AutoScaleSuite) TestAutoScaleConfigRunCpuBased(c *check.C) {
+	s.prepareCpuScaler(c)
+	defer config.Unset("docker:scheduler:max-used-cpu")
+	defer config.Unset("docker:scheduler:total-cpu-metadata")
+	_, err := addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 4}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+	})
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	a.runOnce()
+	nodes, err := s.p.cluster.Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 2)
+	c.Assert(nodes[0].Address, check.Not(check.Equals), nodes[1].Address)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: "pool", Value: "pool1"},
+		Kind:   "autoscale",
+		EndCustomData: map[string]interface{}{
+			"result.toadd":       1,
+			"result.torebalance": true,
+			"result.reason":      "can't add 10 cpu shares to an existing node",
+			"nodes":              bson.M{"$size": 1},
+		},
+	}, eventtest.HasEvent)
+	containers1, err := s.p.listContainersByHost(net.URLToHost(nodes[0].Address))
+	c.Assert(err, check.IsNil)
+	containers2, err := s.p.listContainersByHost(net.URLToHost(nodes[1].Address))
+	c.Assert(err, check.IsNil)
+	c.Assert(containers1, check.HasLen, 2)
+	c.Assert(containers2, check.HasLen, 2)
+}
+
+func (s *AutoScaleSuite) TestAutoScaleConfigRunScaleDownCpuScaler(c *check.C) {
+	s.prepareCpuScaler(c)
+	defer config.Unset("docker:scheduler:max-used-cpu")
+	defer config.Unset("docker:scheduler:total-cpu-metadata")
+	otherUrl := fmt.Sprintf("http://localhost:%d/", dockertest.URLPort(s.node2.URL()))
+	node := cluster.Node{Address: otherUrl, Metadata: map[string]string{
+		"pool":     "pool1",
+		"iaas":     "my-scale-iaas",
+		"totalMem": "25165824",
+		"totalCpu": "50",
+	}}
+	err := s.p.cluster.Register(node)
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 1}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+		toHost:      "127.0.0.1",
+	})
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 1}},
+		app:         s.appInstance,
+		imageId:     s.imageId,
+		provisioner: s.p,
+		toHost:      "localhost",
+	})
+	c.Assert(err, check.IsNil)
+	a := autoScaleConfig{
+		done:        make(chan bool),
+		provisioner: s.p,
+	}
+	a.runOnce()
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: "pool", Value: "pool1"},
+		Kind:   "autoscale",
+		EndCustomData: map[string]interface{}{
+			"result.toremove":    bson.M{"$size": 1},
+			"result.torebalance": false,
+			"result.reason":      "containers can be distributed in only 1 nodes",
+			"nodes":              bson.M{"$size": 1},
+		},
+	}, eventtest.HasEvent)
+	nodes, err := s.p.cluster.Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	containers, err := s.p.listContainersByHost(net.URLToHost(nodes[0].Address))
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+}
+
+func (s *S) TestAutoScaleConfigScalerForRule(c *check.C) {
+	a := autoScaleConfig{provisioner: s.p}
+	scaler, err := a.scalerForRule(&autoScaleRule{MaxContainerCount: 2})
+	c.Assert(err, check.IsNil)
+	c.Assert(scaler, check.FitsTypeOf, &countScaler{})
+	scaler, err = a.scalerForRule(&autoScaleRule{})
+	c.Assert(err, check.IsNil)
+	c.Assert(scaler, check.FitsTypeOf, &memoryScaler{})
+	a.TotalCpuMetadata = "totalCpu"
+	a.MaxCpuRatio = 0.8
+	scaler, err = a.scalerForRule(&autoScaleRule{})
+	c.Assert(err, check.IsNil)
+	c.Assert(scaler, check.FitsTypeOf, &cpuScaler{})
+	a.TotalMemoryMetadata = "totalMem"
+	scaler, err = a.scalerForRule(&autoScaleRule{MaxMemoryRatio: 0.8})
+	c.Assert(err, check.IsNil)
+	c.Assert(scaler, check.FitsTypeOf, &memoryScaler{})
+	scaler, err = a.scalerForRule(&autoScaleRule{MaxMemoryRatio: 0.8, MaxCpuRatio: 0.5})
+	c.Assert(err, check.IsNil)
+	c.Assert(scaler, check.FitsTypeOf, &cpuScaler{})
+	c.Assert(scaler.(*cpuScaler).maxCpuRatio, check.Equals, float32(0.5))
+}
+
+func (s *S) TestAutoScaleRuleNormalizeMaxCpuRatio(c *check.C) {
+	config.Unset("docker:scheduler:total-cpu-metadata")
+	defer config.Unset("docker:scheduler:total-cpu-metadata")
+	rule := autoScaleRule{Enabled: true, MaxCpuRatio: 0.8}
+	err := rule.normalize()
+	c.Assert(err, check.ErrorMatches, "invalid rule, max cpu ratio requires docker:scheduler:total-cpu-metadata to be set")
+	config.Set("docker:scheduler:total-cpu-metadata", "totalCpu")
+	rule = autoScaleRule{Enabled: true, MaxCpuRatio: -1}
+	err = rule.normalize()
+	c.Assert(err, check.ErrorMatches, "invalid rule, max cpu ratio must not be negative, got -1.000000")
+	rule = autoScaleRule{Enabled: true, MaxCpuRatio: 0.8}
+	err = rule.normalize()
+	c.Assert(err, check.IsNil)
+	c.Assert(rule.Error, check.Equals, "")
+}
+
 func (s *AutoScaleSuite) TestAutoScaleConfigRunScaleDownRespectsMinNodes(c *check.C) {
 	config.Set("docker:auto-scale:max-container-count", 4)
 	oldNodes, err := s.p.cluster.Nodes()
@@ -1310,7 +1664,7 @@ func (s *S) TestAutoScaleConfigRunParamsError(c *check.C) {
 		provisioner: s.p,
 	}
 	a.runOnce()
-	c.Assert(s.logBuf.String(), check.Matches, `(?s).*invalid rule, either memory information or max container count must be set.*`)
+	c.Assert(s.logBuf.String(), check.Matches, `(?s).*invalid rule, either memory information, cpu information or max container count must be set.*`)
 	config.Set("docker:auto-scale:max-container-count", 10)
 	config.Set("docker:auto-scale:scale-down-ratio", 0.9)
 	defer config.Unset("docker:auto-scale:scale-down-ratio")
@@ -0,0 +1,225 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	canaryStateRunning  = "running"
+	canaryStatePromoted = "promoted"
+	canaryStateAborted  = "aborted"
+)
+
+var (
+	ErrCanaryAborted  = errors.New("canary deploy aborted")
+	ErrCanaryTimeout  = errors.New("canary deploy timed out waiting for promotion")
+	errCanaryNoRouter = errors.New("the app router does not support weighted routes, required by canary deploys")
+
+	canaryPollInterval = 2 * time.Second
+)
+
+type canaryDeploy struct {
+	App       string `bson:"_id"`
+	Image     string
+	State     string
+	StartedAt time.Time
+}
+
+type canaryOptions struct {
+	units            int
+	percentage       int
+	timeout          time.Duration
+	promoteOnTimeout bool
+}
+
+func canaryOptionsFromConfig() canaryOptions {
+	opts := canaryOptions{units: 1, percentage: 10, timeout: 10 * time.Minute}
+	if units, _ := config.GetInt("docker:canary:units"); units > 0 {
+		opts.units = units
+	}
+	if percentage, _ := config.GetInt("docker:canary:percentage"); percentage > 0 && percentage < 100 {
+		opts.percentage = percentage
+	}
+	if timeout, _ := config.GetInt("docker:canary:timeout"); timeout > 0 {
+		opts.timeout = time.Duration(timeout) * time.Second
+	}
+	opts.promoteOnTimeout, _ = config.GetBool("docker:canary:promote-on-timeout")
+	return opts
+}
+
+// canaryWeights returns the weight of each old and new route so that the
+// new routes receive the given percentage of the traffic.
+func canaryWeights(percentage, oldCount, newCount int) (int, int) {
+	if oldCount == 0 || newCount == 0 {
+		return 1, 1
+	}
+	oldWeight := (100 - percentage) * newCount
+	newWeight := percentage * oldCount
+	d := gcd(oldWeight, newWeight)
+	oldWeight, newWeight = oldWeight/d, newWeight/d
+	max := oldWeight
+	if newWeight > max {
+		max = newWeight
+	}
+	if max > router.MaxRouteWeight {
+		factor := float64(router.MaxRouteWeight) / float64(max)
+		oldWeight = int(math.Max(1, math.Floor(float64(oldWeight)*factor+0.5)))
+		newWeight = int(math.Max(1, math.Floor(float64(newWeight)*factor+0.5)))
+	}
+	return oldWeight, newWeight
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func canaryCollection() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	name, err := config.GetString("docker:collection")
+	if err != nil {
+		return nil, err
+	}
+	return conn.Collection(fmt.Sprintf("%s_canary", name)), nil
+}
+
+func startCanary(appName, imageId string) error {
+	coll, err := canaryCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(appName, canaryDeploy{
+		App:       appName,
+		Image:     imageId,
+		State:     canaryStateRunning,
+		StartedAt: time.Now().UTC(),
+	})
+	return err
+}
+
+func getCanaryState(appName string) (string, error) {
+	coll, err := canaryCollection()
+	if err != nil {
+		return "", err
+	}
+	defer coll.Close()
+	var canary canaryDeploy
+	err = coll.FindId(appName).One(&canary)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return "", provision.ErrCanaryNotFound
+		}
+		return "", err
+	}
+	return canary.State, nil
+}
+
+func setCanaryState(appName, state string) error {
+	coll, err := canaryCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Update(
+		bson.M{"_id": appName, "state": canaryStateRunning},
+		bson.M{"$set": bson.M{"state": state}},
+	)
+	if err == mgo.ErrNotFound {
+		return provision.ErrCanaryNotFound
+	}
+	return err
+}
+
+func removeCanary(appName string) error {
+	coll, err := canaryCollection()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(appName)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// resetCanaryWeights sets the weight of all the routes of the app back to 1
+// once the canary is promoted, so the old units keep receiving their share of
+// the traffic while they are replaced.
+func resetCanaryWeights(a provision.App) error {
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return err
+	}
+	wRouter, ok := r.(router.WeightedRouter)
+	if !ok {
+		return errCanaryNoRouter
+	}
+	routes, err := r.Routes(a.GetName())
+	if err != nil {
+		return err
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+	return wRouter.SetRoutesWeight(a.GetName(), routes, 1)
+}
+
+func (p *dockerProvisioner) PromoteCanary(a provision.App) error {
+	return setCanaryState(a.GetName(), canaryStatePromoted)
+}
+
+func (p *dockerProvisioner) AbortCanary(a provision.App) error {
+	return setCanaryState(a.GetName(), canaryStateAborted)
+}
+
+// deployCanary starts the canary units for the web process of the new image
+// and waits for the canary to be promoted, replacing all the old units, or
+// aborted, removing the canary units.
+func (p *dockerProvisioner) deployCanary(evt *event.Event, a provision.App, toAdd map[string]*containersToAdd, oldContainers []container.Container, imageId string) error {
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return err
+	}
+	if _, ok := r.(router.WeightedRouter); !ok {
+		return errCanaryNoRouter
+	}
+	webProcessName, err := image.GetImageWebProcessName(imageId)
+	if err != nil {
+		return err
+	}
+	opts := canaryOptionsFromConfig()
+	canaryToAdd := map[string]*containersToAdd{
+		webProcessName: {Quantity: opts.units},
+	}
+	canaryContainers, err := p.runCanaryPipeline(evt, a, canaryToAdd, imageId, opts)
+	if err != nil {
+		return err
+	}
+	_, err = p.runReplaceUnitsPipeline(evt, a, toAdd, append(oldContainers, canaryContainers...), imageId)
+	return err
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"net/url"
+
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCanaryWeights(c *check.C) {
+	tests := []struct {
+		percentage, oldCount, newCount int
+		oldWeight, newWeight           int
+	}{
+		{10, 1, 1, 9, 1},
+		{25, 3, 1, 1, 1},
+		{50, 2, 1, 1, 2},
+		{10, 3, 0, 1, 1},
+		{1, 1, 1, 99, 1},
+		{1, 1, 3, 100, 1},
+	}
+	for _, tt := range tests {
+		oldWeight, newWeight := canaryWeights(tt.percentage, tt.oldCount, tt.newCount)
+		c.Check(oldWeight, check.Equals, tt.oldWeight, check.Commentf("%#v", tt))
+		c.Check(newWeight, check.Equals, tt.newWeight, check.Commentf("%#v", tt))
+	}
+}
+
+func (s *S) TestCanaryOptionsFromConfig(c *check.C) {
+	opts := canaryOptionsFromConfig()
+	c.Assert(opts.units, check.Equals, 1)
+	c.Assert(opts.percentage, check.Equals, 10)
+	c.Assert(opts.promoteOnTimeout, check.Equals, false)
+}
+
+func (s *S) TestPromoteCanary(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	err := s.p.PromoteCanary(a)
+	c.Assert(err, check.Equals, provision.ErrCanaryNotFound)
+	err = startCanary(a.GetName(), "tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	defer removeCanary(a.GetName())
+	err = s.p.PromoteCanary(a)
+	c.Assert(err, check.IsNil)
+	state, err := getCanaryState(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(state, check.Equals, canaryStatePromoted)
+	err = s.p.AbortCanary(a)
+	c.Assert(err, check.Equals, provision.ErrCanaryNotFound)
+}
+
+func (s *S) TestAbortCanary(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	err := startCanary(a.GetName(), "tsuru/app-myapp:v2")
+	c.Assert(err, check.IsNil)
+	defer removeCanary(a.GetName())
+	err = s.p.AbortCanary(a)
+	c.Assert(err, check.IsNil)
+	state, err := getCanaryState(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(state, check.Equals, canaryStateAborted)
+}
+
+func (s *S) TestResetCanaryWeights(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	routertest.FakeRouter.AddBackend(a.GetName())
+	defer routertest.FakeRouter.RemoveBackend(a.GetName())
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err := routertest.FakeRouter.AddRoutes(a.GetName(), []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = routertest.FakeRouter.SetRoutesWeight(a.GetName(), []*url.URL{addr1}, 9)
+	c.Assert(err, check.IsNil)
+	err = resetCanaryWeights(a)
+	c.Assert(err, check.IsNil)
+	weights, err := routertest.FakeRouter.RoutesWeight(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.Host: 1, addr2.Host: 1})
+}
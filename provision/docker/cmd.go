@@ -93,20 +93,36 @@ func (c *listAutoScaleHistoryCmd) Flags() *gnuflag.FlagSet {
 
 type autoScaleRunCmd struct {
 	cmd.ConfirmationCommand
+	fs     *gnuflag.FlagSet
+	dryRun bool
 }
 
 func (c *autoScaleRunCmd) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:  "docker-autoscale-run",
-		Usage: "docker-autoscale-run [-y/--assume-yes]",
+		Usage: "docker-autoscale-run [-y/--assume-yes] [--dry-run]",
 		Desc: `Run node auto scale checks once. This command will work even if [[docker:auto-
 scale:enabled]] config entry is set to false. Auto scaling checks may trigger
 the addition, removal or rebalancing of docker nodes, as long as these nodes
-were created using an IaaS provider registered in tsuru.`,
+were created using an IaaS provider registered in tsuru.
+
+With [[--dry-run]], the checks are evaluated and the decisions for each pool
+are displayed, but no node is added or removed and no container is moved.`,
+	}
+}
+
+func (c *autoScaleRunCmd) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = c.ConfirmationCommand.Flags()
+		c.fs.BoolVar(&c.dryRun, "dry-run", false, "Only display what auto scale would do, without acting.")
 	}
+	return c.fs
 }
 
 func (c *autoScaleRunCmd) Run(context *cmd.Context, client *cmd.Client) error {
+	if c.dryRun {
+		return c.runDry(context, client)
+	}
 	context.RawOutput()
 	if !c.Confirm(context, "Are you sure you want to run auto scaling checks?") {
 		return nil
@@ -136,6 +152,56 @@ func (c *autoScaleRunCmd) Run(context *cmd.Context, client *cmd.Client) error {
 	return nil
 }
 
+func (c *autoScaleRunCmd) runDry(context *cmd.Context, client *cmd.Client) error {
+	u, err := cmd.GetURL("/docker/autoscale/run?dry=true")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("POST", u, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var results []autoScaleDryRunResult
+	err = json.NewDecoder(response.Body).Decode(&results)
+	if err != nil {
+		return err
+	}
+	table := cmd.NewTable()
+	table.Headers = cmd.Row([]string{"Pool", "Add", "Remove", "Rebalance", "Reason"})
+	for _, r := range results {
+		var reason string
+		switch {
+		case r.Error != "":
+			reason = "error: " + r.Error
+		case r.Rule == nil:
+			reason = "no auto scale rule"
+		case !r.Rule.Enabled:
+			reason = "auto scale rule disabled"
+		case r.Result != nil && r.Result.NoAction():
+			reason = "nothing to do"
+		case r.Result != nil:
+			reason = r.Result.Reason
+		}
+		var toAdd, toRebalance string
+		var toRemove []string
+		if r.Result != nil {
+			toAdd = strconv.Itoa(r.Result.ToAdd)
+			toRebalance = strconv.FormatBool(r.Result.ToRebalance)
+			for _, n := range r.Result.ToRemove {
+				toRemove = append(toRemove, n.Address)
+			}
+		}
+		table.AddRow(cmd.Row([]string{r.Pool, toAdd, strings.Join(toRemove, "\n"), toRebalance, reason}))
+	}
+	context.Stdout.Write(table.Bytes())
+	return nil
+}
+
 type autoScaleInfoCmd struct{}
 
 func (c *autoScaleInfoCmd) Info() *cmd.Info {
@@ -217,6 +283,7 @@ func (c *autoScaleInfoCmd) render(context *cmd.Context, config *autoScaleConfig,
 		"Pool",
 		"Max container count",
 		"Max memory ratio",
+		"Max cpu ratio",
 		"Scale down ratio",
 		"Rebalance on scale",
 		"Enabled",
@@ -227,6 +294,7 @@ func (c *autoScaleInfoCmd) render(context *cmd.Context, config *autoScaleConfig,
 			rule.MetadataFilter,
 			strconv.Itoa(rule.MaxContainerCount),
 			strconv.FormatFloat(float64(rule.MaxMemoryRatio), 'f', 4, 32),
+			strconv.FormatFloat(float64(rule.MaxCpuRatio), 'f', 4, 32),
 			strconv.FormatFloat(float64(rule.ScaleDownRatio), 'f', 4, 32),
 			strconv.FormatBool(!rule.PreventRebalance),
 			strconv.FormatBool(rule.Enabled),
@@ -241,6 +309,7 @@ type autoScaleSetRuleCmd struct {
 	filterValue        string
 	maxContainerCount  int
 	maxMemoryRatio     float64
+	maxCpuRatio        float64
 	scaleDownRatio     float64
 	noRebalanceOnScale bool
 	enable             bool
@@ -250,8 +319,8 @@ type autoScaleSetRuleCmd struct {
 func (c *autoScaleSetRuleCmd) Info() *cmd.Info {
 	return &cmd.Info{
 		Name:  "docker-autoscale-rule-set",
-		Usage: "docker-autoscale-rule-set [-f/--filter-value <pool name>] [-c/--max-container-count 0] [-m/--max-memory-ratio 0.9] [-d/--scale-down-ratio 1.33] [--no-rebalance-on-scale] [--enable] [--disable]",
-		Desc:  "Creates or update an auto-scale rule. Using resources limitation (amount of container or memory usage).",
+		Usage: "docker-autoscale-rule-set [-f/--filter-value <pool name>] [-c/--max-container-count 0] [-m/--max-memory-ratio 0.9] [--max-cpu-ratio 0.9] [-d/--scale-down-ratio 1.33] [--no-rebalance-on-scale] [--enable] [--disable]",
+		Desc:  "Creates or update an auto-scale rule. Using resources limitation (amount of container, memory or cpu usage).",
 	}
 }
 
@@ -263,6 +332,7 @@ func (c *autoScaleSetRuleCmd) Run(context *cmd.Context, client *cmd.Client) erro
 		MetadataFilter:    c.filterValue,
 		MaxContainerCount: c.maxContainerCount,
 		MaxMemoryRatio:    float32(c.maxMemoryRatio),
+		MaxCpuRatio:       float32(c.maxCpuRatio),
 		ScaleDownRatio:    float32(c.scaleDownRatio),
 		PreventRebalance:  c.noRebalanceOnScale,
 		Enabled:           c.enable,
@@ -301,6 +371,8 @@ func (c *autoScaleSetRuleCmd) Flags() *gnuflag.FlagSet {
 		msg = "The maximum memory usage per node. 0 means no limit, 1 means 100%. It is fine to use values greater than 1, which means that tsuru will overcommit memory in Docker nodes. Keep in mind that container count has higher precedence than memory ratio, so if --max-container-count is defined, the value of --max-memory-ratio will be ignored."
 		c.fs.Float64Var(&c.maxMemoryRatio, "max-memory-ratio", .0, msg)
 		c.fs.Float64Var(&c.maxMemoryRatio, "m", .0, msg)
+		msg = "The maximum ratio of cpu shares reserved by containers on every node, based on the node metadata set in docker:scheduler:total-cpu-metadata. 0 means the value from docker:scheduler:max-used-cpu. When set, the rule is scaled by cpu instead of memory, but container count still has higher precedence."
+		c.fs.Float64Var(&c.maxCpuRatio, "max-cpu-ratio", .0, msg)
 		msg = "The ratio for triggering an scale down event. The default value is 1.33, which mean that whenever it gets one third of the resource utilization (memory ratio or container count)."
 		c.fs.Float64Var(&c.scaleDownRatio, "scale-down-ratio", 1.33, msg)
 		c.fs.Float64Var(&c.scaleDownRatio, "d", 1.33, msg)
@@ -501,3 +573,117 @@ func (c *dockerLogInfo) Run(context *cmd.Context, client *cmd.Client) error {
 	}
 	return nil
 }
+
+type dockerSchedulerUpdate struct {
+	fs       *gnuflag.FlagSet
+	pool     string
+	strategy string
+}
+
+func (c *dockerSchedulerUpdate) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("with-flags", gnuflag.ContinueOnError)
+		desc := "Pool name where the scheduling strategy will be used."
+		c.fs.StringVar(&c.pool, "pool", "", desc)
+		c.fs.StringVar(&c.pool, "p", "", desc)
+		desc = "Chosen scheduling strategy, either spread or binpack."
+		c.fs.StringVar(&c.strategy, "strategy", "", desc)
+		c.fs.StringVar(&c.strategy, "s", "", desc)
+	}
+	return c.fs
+}
+
+func (c *dockerSchedulerUpdate) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:  "docker-scheduler-update",
+		Usage: "docker-scheduler-update [-p/--pool poolname] -s/--strategy <spread|binpack>",
+		Desc: `Set the strategy used by the scheduler to choose the node where new units
+will be created.
+
+The 'spread' strategy, used by default, creates new units in the node with the
+least amount of units. The 'binpack' strategy fills nodes up to their memory
+limit before using other nodes, allowing node auto scaling to free empty
+nodes. The 'binpack' strategy requires the scheduler to be configured with
+memory information, otherwise units will be spread.
+
+If --pool is specified the strategy will only be used on the chosen pool.`,
+		MinArgs: 0,
+	}
+}
+
+func (c *dockerSchedulerUpdate) Run(context *cmd.Context, client *cmd.Client) error {
+	u, err := cmd.GetURL("/docker/scheduler")
+	if err != nil {
+		return err
+	}
+	conf := schedulerConfig{Strategy: c.strategy}
+	values, err := form.EncodeToValues(conf)
+	if err != nil {
+		return err
+	}
+	values.Set("pool", c.pool)
+	request, err := http.NewRequest("POST", u, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = client.Do(request)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(context.Stdout, "Scheduler config successfully updated.")
+	return nil
+}
+
+type dockerSchedulerInfo struct{}
+
+func (c *dockerSchedulerInfo) Info() *cmd.Info {
+	return &cmd.Info{
+		Name:    "docker-scheduler-info",
+		Usage:   "docker-scheduler-info",
+		Desc:    "Prints information about the scheduling strategy used in each pool.",
+		MinArgs: 0,
+	}
+}
+
+func (c *dockerSchedulerInfo) Run(context *cmd.Context, client *cmd.Client) error {
+	u, err := cmd.GetURL("/docker/scheduler")
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	var conf map[string]schedulerConfig
+	err = json.NewDecoder(response.Body).Decode(&conf)
+	if err != nil {
+		return err
+	}
+	t := cmd.Table{Headers: cmd.Row([]string{"Pool", "Strategy"})}
+	defaultStrategy := conf[""].Strategy
+	if defaultStrategy == "" {
+		defaultStrategy = schedulerStrategySpread
+	}
+	t.AddRow(cmd.Row([]string{"[default]", defaultStrategy}))
+	delete(conf, "")
+	poolNames := make([]string, 0, len(conf))
+	for poolName := range conf {
+		poolNames = append(poolNames, poolName)
+	}
+	sort.Strings(poolNames)
+	for _, poolName := range poolNames {
+		strategy := conf[poolName].Strategy
+		if strategy == "" {
+			strategy = defaultStrategy
+		}
+		t.AddRow(cmd.Row([]string{poolName, strategy}))
+	}
+	context.Stdout.Write(t.Bytes())
+	return nil
+}
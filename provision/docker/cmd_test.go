@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/ajg/form"
+	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/cmd/cmdtest"
 	tsuruIo "github.com/tsuru/tsuru/io"
@@ -44,6 +45,45 @@ func (s *S) TestAutoScaleRunCmdRun(c *check.C) {
 	c.Assert(stdout.String(), check.Equals, "progress msg")
 }
 
+func (s *S) TestAutoScaleRunCmdRunDryRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	results := []autoScaleDryRunResult{
+		{Pool: "pool1", Rule: &autoScaleRule{Enabled: true}, Result: &scalerResult{ToAdd: 1, ToRebalance: true, Reason: "number of free slots is -2"}},
+		{Pool: "pool2", Rule: &autoScaleRule{Enabled: true}, Result: &scalerResult{ToRemove: []cluster.Node{{Address: "http://n1:2375"}}, Reason: "too many nodes"}},
+		{Pool: "pool3", Rule: &autoScaleRule{Enabled: true}, Result: &scalerResult{}},
+		{Pool: "pool4"},
+	}
+	data, err := json.Marshal(results)
+	c.Assert(err, check.IsNil)
+	context := cmd.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(data), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/docker/autoscale/run" && req.Method == "POST" &&
+				req.URL.Query().Get("dry") == "true"
+		},
+	}
+	manager := cmd.Manager{}
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, &manager)
+	cm := autoScaleRunCmd{}
+	cm.Flags().Parse(true, []string{"--dry-run"})
+	err = cm.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	expected := `+-------+-----+----------------+-----------+----------------------------+
+| Pool  | Add | Remove         | Rebalance | Reason                     |
++-------+-----+----------------+-----------+----------------------------+
+| pool1 | 1   |                | true      | number of free slots is -2 |
+| pool2 | 0   | http://n1:2375 | false     | too many nodes             |
+| pool3 | 0   |                | false     | nothing to do              |
+| pool4 |     |                |           | no auto scale rule         |
++-------+-----+----------------+-----------+----------------------------+
+`
+	c.Assert(stdout.String(), check.Equals, expected)
+}
+
 func (s *S) TestAutoScaleInfoCmdRun(c *check.C) {
 	var calls int
 	config := `{"Enabled":true}`
@@ -101,13 +141,13 @@ func (s *S) TestAutoScaleInfoCmdRun(c *check.C) {
 	err := command.Run(&context, client)
 	c.Assert(err, check.IsNil)
 	expected := `Rules:
-+-------+---------------------+------------------+------------------+--------------------+---------+
-| Pool  | Max container count | Max memory ratio | Scale down ratio | Rebalance on scale | Enabled |
-+-------+---------------------+------------------+------------------+--------------------+---------+
-| pool1 | 6                   | 1.2000           | 1.3300           | true               | true    |
-| pool2 | 13                  | 0.9000           | 1.3300           | false              | true    |
-| pool3 | 50                  | 1.2000           | 1.3300           | true               | false   |
-+-------+---------------------+------------------+------------------+--------------------+---------+
++-------+---------------------+------------------+---------------+------------------+--------------------+---------+
+| Pool  | Max container count | Max memory ratio | Max cpu ratio | Scale down ratio | Rebalance on scale | Enabled |
++-------+---------------------+------------------+---------------+------------------+--------------------+---------+
+| pool1 | 6                   | 1.2000           | 0.0000        | 1.3300           | true               | true    |
+| pool2 | 13                  | 0.9000           | 0.0000        | 1.3300           | false              | true    |
+| pool3 | 50                  | 1.2000           | 0.0000        | 1.3300           | true               | false   |
++-------+---------------------+------------------+---------------+------------------+--------------------+---------+
 `
 	c.Assert(buf.String(), check.Equals, expected)
 	c.Assert(calls, check.Equals, 2)
@@ -150,6 +190,7 @@ func (s *S) TestAutoScaleSetRuleCmdRun(c *check.C) {
 				Enabled:           true,
 				MaxContainerCount: 10,
 				MaxMemoryRatio:    1.2342,
+				MaxCpuRatio:       0.75,
 				ScaleDownRatio:    1.33,
 				PreventRebalance:  false,
 			})
@@ -161,7 +202,7 @@ func (s *S) TestAutoScaleSetRuleCmdRun(c *check.C) {
 	var manager cmd.Manager
 	client := cmd.NewClient(&http.Client{Transport: &transport}, nil, &manager)
 	var command autoScaleSetRuleCmd
-	flags := []string{"-f", "pool1", "-c", "10", "-m", "1.2342", "--enable"}
+	flags := []string{"-f", "pool1", "-c", "10", "-m", "1.2342", "--max-cpu-ratio", "0.75", "--enable"}
 	err := command.Flags().Parse(true, flags)
 	c.Assert(err, check.IsNil)
 	err = command.Run(&context, client)
@@ -362,6 +403,67 @@ Log driver [pool p2]: bs
 `)
 }
 
+func (s *S) TestDockerSchedulerUpdateRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: "", Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			err := req.ParseForm()
+			c.Assert(err, check.IsNil)
+			c.Assert(req.Form, check.DeepEquals, url.Values{
+				"pool":     []string{"p1"},
+				"Strategy": []string{"binpack"},
+			})
+			return req.URL.Path == "/1.0/docker/scheduler" && req.Method == "POST"
+		},
+	}
+	manager := cmd.NewManager("admin", "0.1", "admin-ver", &stdout, &stderr, nil, nil)
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	cmd := dockerSchedulerUpdate{}
+	err := cmd.Flags().Parse(true, []string{"--pool", "p1", "--strategy", "binpack"})
+	c.Assert(err, check.IsNil)
+	err = cmd.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, "Scheduler config successfully updated.\n")
+}
+
+func (s *S) TestDockerSchedulerInfoRun(c *check.C) {
+	var stdout, stderr bytes.Buffer
+	context := cmd.Context{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	conf := map[string]schedulerConfig{
+		"":   {},
+		"p1": {Strategy: "binpack"},
+		"p2": {Strategy: "spread"},
+	}
+	result, _ := json.Marshal(conf)
+	trans := &cmdtest.ConditionalTransport{
+		Transport: cmdtest.Transport{Message: string(result), Status: http.StatusOK},
+		CondFunc: func(req *http.Request) bool {
+			return req.URL.Path == "/1.0/docker/scheduler" && req.Method == "GET"
+		},
+	}
+	manager := cmd.NewManager("admin", "0.1", "admin-ver", &stdout, &stderr, nil, nil)
+	client := cmd.NewClient(&http.Client{Transport: trans}, nil, manager)
+	cmd := dockerSchedulerInfo{}
+	err := cmd.Run(&context, client)
+	c.Assert(err, check.IsNil)
+	c.Assert(stdout.String(), check.Equals, `+-----------+----------+
+| Pool      | Strategy |
++-----------+----------+
+| [default] | spread   |
+| p1        | binpack  |
+| p2        | spread   |
++-----------+----------+
+`)
+}
+
 func (s *S) TestListAutoScaleHistoryCmdRunEmpty(c *check.C) {
 	var buf bytes.Buffer
 	context := cmd.Context{Stdout: &buf}
@@ -72,6 +72,7 @@ type Container struct {
 	LastStatusUpdate        time.Time
 	LastSuccessStatusUpdate time.Time
 	LockedUntil             time.Time
+	Unhealthy               bool
 	Routable                bool `bson:"-"`
 	ExposedPort             string
 }
@@ -0,0 +1,73 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/safe"
+)
+
+// DefaultHealthcheckTimeout is the timeout of a single healthcheck attempt
+// when no other timeout is configured.
+const DefaultHealthcheckTimeout = 5 * time.Second
+
+// CheckHealth runs a single attempt of the healthcheck described by hc
+// against the container, failing if it doesn't finish within timeout. HTTP
+// and TCP healthchecks are run against the address of the container by
+// provision.TsuruYamlHealthcheck.CheckAddr, HTTP healthchecks without a path
+// check the root path.
+func (c *Container) CheckHealth(p DockerProvisioner, hc provision.TsuruYamlHealthcheck, timeout time.Duration) error {
+	var err error
+	switch hc.CheckType() {
+	case provision.HealthcheckTypeHTTP, provision.HealthcheckTypeTCP:
+		if !c.ValidAddr() {
+			err = fmt.Errorf("container has no exposed port")
+		} else {
+			err = hc.CheckAddr(net.JoinHostPort(c.HostAddr, c.HostPort), timeout)
+		}
+	case provision.HealthcheckTypeCommand:
+		err = c.checkCommand(p, hc.Command, timeout)
+	default:
+		err = fmt.Errorf("invalid healthcheck type %q", hc.Type)
+	}
+	if err != nil {
+		wrapped := fmt.Errorf("healthcheck fail(%s): %s", c.ShortID(), err)
+		if _, ok := err.(*provision.UnreachableError); ok {
+			return &provision.UnreachableError{Err: wrapped}
+		}
+		return wrapped
+	}
+	return nil
+}
+
+func (c *Container) checkCommand(p DockerProvisioner, cmd string, timeout time.Duration) error {
+	if cmd == "" {
+		return nil
+	}
+	var buf safe.Buffer
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Exec(p, &buf, &buf, cmd)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-time.After(timeout):
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("command %q failed: %s", cmd, err)
+	if output := strings.TrimSpace(buf.String()); output != "" {
+		err = fmt.Errorf("%s - %s", err, output)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package container
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/tsuru/tsuru/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCheckHealthTCP(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	cont := Container{ID: "abc123", HostAddr: host, HostPort: port}
+	err = cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Type: "TCP"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCheckHealthTCPConnectionError(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+	cont := Container{ID: "abc123", HostAddr: host, HostPort: port}
+	err = cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Type: "tcp"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(abc123\): .*connection refused`)
+	c.Assert(err, check.FitsTypeOf, &provision.UnreachableError{})
+}
+
+func (s *S) TestCheckHealthTCPNoPort(c *check.C) {
+	cont := Container{ID: "abc123", HostAddr: "127.0.0.1"}
+	err := cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Type: "tcp"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(abc123\): container has no exposed port`)
+}
+
+func (s *S) TestCheckHealthCommand(c *check.C) {
+	cont, err := s.newContainer(newContainerOpts{}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	err = cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Type: "command", Command: "pgrep worker"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestCheckHealthCommandFailure(c *check.C) {
+	s.server.CustomHandler("/exec/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ID":"id","ExitCode":1}`))
+	}))
+	cont, err := s.newContainer(newContainerOpts{}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	err = cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Type: "command", Command: "pgrep worker"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(.*\): command "pgrep worker" failed: unexpected exit code: 1`)
+}
+
+func (s *S) TestCheckHealthHTTP(c *check.C) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte("WORKING"))
+	}))
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	cont := Container{ID: "abc123", HostAddr: host, HostPort: port}
+	err := cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Path: "/hc", Match: "WORK"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.IsNil)
+	c.Assert(path, check.Equals, "/hc")
+}
+
+func (s *S) TestCheckHealthHTTPWrongStatus(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	cont := Container{ID: "abc123", HostAddr: host, HostPort: port}
+	err := cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(abc123\): wrong status code, expected 200, got: 500`)
+}
+
+func (s *S) TestCheckHealthHTTPTimeout(c *check.C) {
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer srv.Close()
+	defer close(done)
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	cont := Container{ID: "abc123", HostAddr: host, HostPort: port}
+	err := cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{}, 100*time.Millisecond)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(abc123\): .*context deadline exceeded.*`)
+}
+
+func (s *S) TestCheckHealthInvalidType(c *check.C) {
+	cont := Container{ID: "abc123"}
+	err := cont.CheckHealth(s.p, provision.TsuruYamlHealthcheck{Type: "udp"}, DefaultHealthcheckTimeout)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(abc123\): invalid healthcheck type "udp"`)
+}
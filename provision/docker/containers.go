@@ -123,6 +123,66 @@ func (p *dockerProvisioner) runReplaceUnitsPipeline(w io.Writer, a provision.App
 	return pipeline.Result().([]container.Container), nil
 }
 
+// runBlueGreenPipeline replaces all units of the app with units using the
+// new image. Routes are only switched after all new units are healthy.
+func (p *dockerProvisioner) runBlueGreenPipeline(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, toRemoveContainers []container.Container, imageId string) ([]container.Container, error) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	evt, _ := w.(*event.Event)
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toAdd:       toAdd,
+		toRemove:    toRemoveContainers,
+		writer:      w,
+		imageId:     imageId,
+		provisioner: p,
+		event:       evt,
+		blueGreen:   true,
+	}
+	pipeline := action.NewPipeline(
+		&provisionAddUnitsToHost,
+		&bindAndHealthcheck,
+		&switchRoutes,
+		&setRouterHealthcheck,
+		&updateAppImage,
+		&provisionRemoveOldUnits,
+		&provisionUnbindOldUnits,
+	)
+	err := pipeline.Execute(args)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Result().([]container.Container), nil
+}
+
+func (p *dockerProvisioner) runCanaryPipeline(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, imageId string, opts canaryOptions) ([]container.Container, error) {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	evt, _ := w.(*event.Event)
+	args := changeUnitsPipelineArgs{
+		app:         a,
+		toAdd:       toAdd,
+		writer:      w,
+		imageId:     imageId,
+		provisioner: p,
+		event:       evt,
+		canary:      opts,
+	}
+	pipeline := action.NewPipeline(
+		&provisionAddUnitsToHost,
+		&bindAndHealthcheck,
+		&addCanaryRoutes,
+		&waitCanaryDecision,
+	)
+	err := pipeline.Execute(args)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Result().([]container.Container), nil
+}
+
 func (p *dockerProvisioner) runCreateUnitsPipeline(w io.Writer, a provision.App, toAdd map[string]*containersToAdd, imageId, exposedPort string) ([]container.Container, error) {
 	if w == nil {
 		w = ioutil.Discard
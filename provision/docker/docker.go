@@ -165,10 +165,5 @@ func (p *dockerProvisioner) PushImage(name, tag string) error {
 }
 
 func (p *dockerProvisioner) RegistryAuthConfig() docker.AuthConfiguration {
-	var authConfig docker.AuthConfiguration
-	authConfig.Email, _ = config.GetString("docker:registry-auth:email")
-	authConfig.Username, _ = config.GetString("docker:registry-auth:username")
-	authConfig.Password, _ = config.GetString("docker:registry-auth:password")
-	authConfig.ServerAddress, _ = config.GetString("docker:registry")
-	return authConfig
+	return dockercommon.RegistryAuthConfig()
 }
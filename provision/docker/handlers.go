@@ -50,6 +50,8 @@ func init() {
 	api.RegisterHandler("/docker/bs", "GET", api.AuthorizationRequiredHandler(bsConfigGetHandler))
 	api.RegisterHandler("/docker/logs", "GET", api.AuthorizationRequiredHandler(logsConfigGetHandler))
 	api.RegisterHandler("/docker/logs", "POST", api.AuthorizationRequiredHandler(logsConfigSetHandler))
+	api.RegisterHandler("/docker/scheduler", "GET", api.AuthorizationRequiredHandler(schedulerConfigGetHandler))
+	api.RegisterHandler("/docker/scheduler", "POST", api.AuthorizationRequiredHandler(schedulerConfigSetHandler))
 }
 
 // title: get autoscale config
@@ -436,6 +438,9 @@ func autoScaleHistoryHandler(w http.ResponseWriter, r *http.Request, t auth.Toke
 //   401: Unauthorized
 func autoScaleRunHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
 	r.ParseForm()
+	if dry, _ := strconv.ParseBool(r.FormValue("dry")); dry {
+		return autoScaleDryRun(w, t)
+	}
 	if !permission.Check(t, permission.PermNodeAutoscaleUpdateRun) {
 		return permission.ErrUnauthorized
 	}
@@ -463,6 +468,21 @@ func autoScaleRunHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (
 	return autoScaleConfig.runOnce()
 }
 
+// autoScaleDryRun returns the decisions the auto scaler would take for each
+// pool, without adding or removing nodes or moving containers.
+func autoScaleDryRun(w http.ResponseWriter, t auth.Token) error {
+	if !permission.Check(t, permission.PermNodeAutoscaleRead) {
+		return permission.ErrUnauthorized
+	}
+	autoScaleConfig := mainDockerProvisioner.initAutoScaleConfig()
+	results, err := autoScaleConfig.dryRun()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(results)
+}
+
 func bsEnvSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
 	return stderror.New("this route is deprecated, please use POST /docker/nodecontainer/{name} (node-container-update command)")
 }
@@ -574,6 +594,80 @@ func logsConfigSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token)
 	return nil
 }
 
+// title: scheduler config
+// path: /docker/scheduler
+// method: GET
+// produce: application/json
+// responses:
+//   200: Ok
+//   401: Unauthorized
+func schedulerConfigGetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	pools, err := permission.ListContextValues(t, permission.PermPoolUpdateScheduler, true)
+	if err != nil {
+		return err
+	}
+	configEntries, err := schedulerConfigLoadAll()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(pools) == 0 {
+		return json.NewEncoder(w).Encode(configEntries)
+	}
+	newMap := map[string]schedulerConfig{}
+	for _, p := range pools {
+		if entry, ok := configEntries[p]; ok {
+			newMap[p] = entry
+		}
+	}
+	return json.NewEncoder(w).Encode(newMap)
+}
+
+// title: scheduler config set
+// path: /docker/scheduler
+// method: POST
+// consume: application/x-www-form-urlencoded
+// responses:
+//   200: Ok
+//   400: Invalid data
+//   401: Unauthorized
+func schedulerConfigSetHandler(w http.ResponseWriter, r *http.Request, t auth.Token) (err error) {
+	err = r.ParseForm()
+	if err != nil {
+		return &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("unable to parse form values: %s", err),
+		}
+	}
+	pool := r.FormValue("pool")
+	conf := schedulerConfig{Strategy: r.FormValue("strategy")}
+	var ctxs []permission.PermissionContext
+	if pool != "" {
+		ctxs = append(ctxs, permission.Context(permission.CtxPool, pool))
+	}
+	hasPermission := permission.Check(t, permission.PermPoolUpdateScheduler, ctxs...)
+	if !hasPermission {
+		return permission.ErrUnauthorized
+	}
+	evt, err := event.New(&event.Opts{
+		Target:      event.Target{Type: event.TargetTypePool, Value: pool},
+		Kind:        permission.PermPoolUpdateScheduler,
+		Owner:       t,
+		CustomData:  event.FormToCustomData(r.Form),
+		DisableLock: true,
+		Allowed:     event.Allowed(permission.PermPoolReadEvents, ctxs...),
+	})
+	if err != nil {
+		return err
+	}
+	defer func() { evt.Done(err) }()
+	err = conf.save(pool)
+	if err == ErrInvalidSchedulerStrategy {
+		return &errors.HTTP{Code: http.StatusBadRequest, Message: err.Error()}
+	}
+	return err
+}
+
 func tryRestartAppsByFilter(filter *app.Filter, writer io.Writer) error {
 	apps, err := app.List(filter)
 	if err != nil {
@@ -650,6 +650,34 @@ func (s *HandlersSuite) TestAutoScaleRunHandler(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *HandlersSuite) TestAutoScaleRunHandlerDryRun(c *check.C) {
+	mainDockerProvisioner.cluster, _ = cluster.New(&segregatedScheduler{}, &cluster.MapStorage{},
+		cluster.Node{Address: "localhost:1999", Metadata: map[string]string{
+			"pool": "pool1",
+		}},
+	)
+	config.Set("docker:auto-scale:max-container-count", 2)
+	defer config.Unset("docker:auto-scale:max-container-count")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/autoscale/run?dry=true", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var results []autoScaleDryRunResult
+	err = json.Unmarshal(recorder.Body.Bytes(), &results)
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 1)
+	c.Assert(results[0].Pool, check.Equals, "pool1")
+	c.Assert(results[0].Error, check.Equals, "")
+	c.Assert(results[0].Result.NoAction(), check.Equals, true)
+	evts, err := event.All()
+	c.Assert(err, check.IsNil)
+	c.Assert(evts, check.HasLen, 0)
+}
+
 func (s *HandlersSuite) TestAutoScaleConfigHandler(c *check.C) {
 	config.Set("docker:auto-scale:enabled", true)
 	defer config.Unset("docker:auto-scale:enabled")
@@ -678,7 +706,7 @@ func (s *HandlersSuite) TestAutoScaleListRulesEmpty(c *check.C) {
 	err = json.Unmarshal(recorder.Body.Bytes(), &rules)
 	c.Assert(err, check.IsNil)
 	c.Assert(rules, check.DeepEquals, []autoScaleRule{
-		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information or max container count must be set"},
+		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information, cpu information or max container count must be set"},
 	})
 }
 
@@ -757,7 +785,7 @@ func (s *HandlersSuite) TestAutoScaleSetRule(c *check.C) {
 	rules, err := listAutoScaleRules()
 	c.Assert(err, check.IsNil)
 	c.Assert(rules, check.DeepEquals, []autoScaleRule{
-		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information or max container count must be set"},
+		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information, cpu information or max container count must be set"},
 		rule,
 	})
 	c.Assert(eventtest.EventDesc{
@@ -836,7 +864,7 @@ func (s *HandlersSuite) TestAutoScaleDeleteRule(c *check.C) {
 	rules, err := listAutoScaleRules()
 	c.Assert(err, check.IsNil)
 	c.Assert(rules, check.DeepEquals, []autoScaleRule{
-		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information or max container count must be set"},
+		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information, cpu information or max container count must be set"},
 	})
 	c.Assert(eventtest.EventDesc{
 		Target: event.Target{Type: event.TargetTypePool, Value: ""},
@@ -859,7 +887,7 @@ func (s *HandlersSuite) TestAutoScaleDeleteRuleNonDefault(c *check.C) {
 	rules, err := listAutoScaleRules()
 	c.Assert(err, check.IsNil)
 	c.Assert(rules, check.DeepEquals, []autoScaleRule{
-		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information or max container count must be set"},
+		{Enabled: true, ScaleDownRatio: 1.333, Error: "invalid rule, either memory information, cpu information or max container count must be set"},
 	})
 	c.Assert(eventtest.EventDesc{
 		Target: event.Target{Type: event.TargetTypePool, Value: "mypool"},
@@ -1050,3 +1078,60 @@ func (s *HandlersSuite) TestDockerLogsInfoHandler(c *check.C) {
 		"p1": {Driver: "syslog", LogOpts: map[string]string{}},
 	})
 }
+
+func (s *HandlersSuite) TestSchedulerConfigSetHandler(c *check.C) {
+	values := url.Values{
+		"pool":     []string{"pool1"},
+		"strategy": []string{"binpack"},
+	}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/scheduler", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	strategy, err := schedulerStrategy("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.Equals, schedulerStrategyBinpack)
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypePool, Value: "pool1"},
+		Owner:  s.token.GetUserName(),
+		Kind:   "pool.update.scheduler",
+	}, eventtest.HasEvent)
+}
+
+func (s *HandlersSuite) TestSchedulerConfigSetHandlerInvalidStrategy(c *check.C) {
+	values := url.Values{"strategy": []string{"random"}}
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/docker/scheduler", strings.NewReader(values.Encode()))
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusBadRequest)
+	c.Assert(recorder.Body.String(), check.Equals, ErrInvalidSchedulerStrategy.Error()+"\n")
+}
+
+func (s *HandlersSuite) TestSchedulerConfigGetHandler(c *check.C) {
+	conf := schedulerConfig{Strategy: schedulerStrategyBinpack}
+	err := conf.save("p1")
+	c.Assert(err, check.IsNil)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/docker/scheduler", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer "+s.token.GetValue())
+	server := api.RunServer(true)
+	server.ServeHTTP(recorder, request)
+	c.Assert(recorder.Code, check.Equals, http.StatusOK)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, "application/json")
+	var result map[string]schedulerConfig
+	err = json.Unmarshal(recorder.Body.Bytes(), &result)
+	c.Assert(err, check.IsNil)
+	c.Assert(result, check.DeepEquals, map[string]schedulerConfig{
+		"":   {},
+		"p1": {Strategy: schedulerStrategyBinpack},
+	})
+}
@@ -7,10 +7,13 @@ package healer
 import (
 	"bytes"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/healer"
 	"github.com/tsuru/tsuru/log"
 	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
@@ -18,32 +21,50 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
+const (
+	defaultHealthcheckFailureThreshold = 3
+	healthcheckWorkers                 = 10
+)
+
 type ContainerHealer struct {
-	provisioner         DockerProvisioner
-	maxUnresponsiveTime time.Duration
-	done                chan bool
-	locker              AppLocker
+	provisioner                 DockerProvisioner
+	maxUnresponsiveTime         time.Duration
+	healthcheckFailureThreshold int
+	healthcheckFailures         map[string]int
+	done                        chan bool
+	locker                      AppLocker
 }
 
 type ContainerHealerArgs struct {
 	Provisioner         DockerProvisioner
 	MaxUnresponsiveTime time.Duration
-	Done                chan bool
-	Locker              AppLocker
+	// HealthcheckFailureThreshold is the number of consecutive passes in
+	// which a container must fail its healthcheck before being healed.
+	// Defaults to 3.
+	HealthcheckFailureThreshold int
+	Done                        chan bool
+	Locker                      AppLocker
 }
 
 func NewContainerHealer(args ContainerHealerArgs) *ContainerHealer {
+	threshold := args.HealthcheckFailureThreshold
+	if threshold <= 0 {
+		threshold = defaultHealthcheckFailureThreshold
+	}
 	return &ContainerHealer{
-		provisioner:         args.Provisioner,
-		maxUnresponsiveTime: args.MaxUnresponsiveTime,
-		done:                args.Done,
-		locker:              args.Locker,
+		provisioner:                 args.Provisioner,
+		maxUnresponsiveTime:         args.MaxUnresponsiveTime,
+		healthcheckFailureThreshold: threshold,
+		healthcheckFailures:         map[string]int{},
+		done:                        args.Done,
+		locker:                      args.Locker,
 	}
 }
 
 func (h *ContainerHealer) RunContainerHealer() {
 	for {
 		h.runContainerHealerOnce()
+		h.healUnhealthyContainers()
 		select {
 		case <-h.done:
 			return
@@ -101,13 +122,17 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 		cont.SetStatus(h.provisioner, cont.ExpectedStatus(), true)
 		return nil
 	}
+	return h.healContainerWithEvent(cont, fmt.Sprintf("unresponsive since %s", cont.LastSuccessStatusUpdate))
+}
+
+func (h *ContainerHealer) healContainerWithEvent(cont container.Container, reason string) error {
 	locked := h.locker.Lock(cont.AppName)
 	if !locked {
 		return fmt.Errorf("Containers healing: unable to heal %q couldn't lock app %s", cont.ID, cont.AppName)
 	}
 	defer h.locker.Unlock(cont.AppName)
 	// Sanity check, now we have a lock, let's find out if the container still exists
-	_, err = h.provisioner.GetContainer(cont.ID)
+	_, err := h.provisioner.GetContainer(cont.ID)
 	if err != nil {
 		if _, isNotFound := err.(*provision.UnitNotFoundError); isNotFound {
 			return nil
@@ -118,7 +143,13 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 	if err != nil {
 		return fmt.Errorf("Containers healing: unable to heal %q couldn't get app %q: %s", cont.ID, cont.AppName, err)
 	}
-	log.Errorf("Initiating healing process for container %q, unresponsive since %s.", cont.ID, cont.LastSuccessStatusUpdate)
+	maintErr := provision.CheckMaintenance(a.Pool)
+	if _, inMaintenance := maintErr.(*provision.ErrPoolInMaintenance); maintErr != nil && !inMaintenance {
+		return fmt.Errorf("Containers healing: unable to heal %q couldn't check pool maintenance: %s", cont.ID, maintErr)
+	}
+	if maintErr == nil {
+		log.Errorf("Initiating healing process for container %q, %s.", cont.ID, reason)
+	}
 	evt, err := event.NewInternal(&event.Opts{
 		Target:       event.Target{Type: event.TargetTypeContainer, Value: cont.ID},
 		InternalKind: "healer",
@@ -131,6 +162,15 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 	if err != nil {
 		return fmt.Errorf("Error trying to insert container healing event, healing aborted: %s", err.Error())
 	}
+	if maintErr != nil {
+		log.Debugf("Containers healing: skipping %q: %s", cont.ID, maintErr)
+		err = evt.DoneCustomData(maintErr, nil)
+		if err != nil {
+			log.Errorf("Error trying to update containers healing event: %s", err.Error())
+		}
+		return nil
+	}
+	healer.NotifyHealing(evt, a.Pool)
 	newCont, healErr := h.healContainer(cont)
 	if healErr != nil {
 		healErr = fmt.Errorf("Error healing container %q: %s", cont.ID, healErr.Error())
@@ -139,6 +179,7 @@ func (h *ContainerHealer) healContainerIfNeeded(cont container.Container) error
 	if err != nil {
 		log.Errorf("Error trying to update containers healing event: %s", err.Error())
 	}
+	healer.NotifyHealing(evt, a.Pool)
 	return healErr
 }
 
@@ -155,6 +196,84 @@ func (h *ContainerHealer) runContainerHealerOnce() {
 	}
 }
 
+// healUnhealthyContainers runs the tcp and command healthchecks declared in
+// the tsuru.yaml of started containers, of every process, healing the ones
+// failing them in healthcheckFailureThreshold consecutive passes. TCP
+// healthchecks are only run against containers exposing a port.
+func (h *ContainerHealer) healUnhealthyContainers() {
+	containers, err := h.provisioner.ListContainers(bson.M{
+		"id":          bson.M{"$ne": ""},
+		"appname":     bson.M{"$ne": ""},
+		"processname": bson.M{"$ne": ""},
+		"status":      provision.StatusStarted.String(),
+	})
+	if err != nil {
+		log.Errorf("Containers Healing: couldn't list started containers: %s", err.Error())
+		return
+	}
+	type containerCheck struct {
+		cont container.Container
+		hc   provision.TsuruYamlHealthcheck
+		err  error
+	}
+	var checks []containerCheck
+	imagesData := map[string]provision.TsuruYamlData{}
+	for _, cont := range containers {
+		yamlData, ok := imagesData[cont.Image]
+		if !ok {
+			yamlData, err = image.GetImageTsuruYamlData(cont.Image)
+			if err != nil {
+				log.Errorf("Containers Healing: couldn't get tsuru.yaml data for image %q: %s", cont.Image, err)
+			}
+			imagesData[cont.Image] = yamlData
+		}
+		hc := yamlData.ProcessData(cont.ProcessName).Healthcheck
+		switch hc.CheckType() {
+		case provision.HealthcheckTypeTCP:
+			if !cont.ValidAddr() {
+				continue
+			}
+		case provision.HealthcheckTypeCommand:
+			if hc.Command == "" {
+				continue
+			}
+		default:
+			continue
+		}
+		checks = append(checks, containerCheck{cont: cont, hc: hc})
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, healthcheckWorkers)
+	for i := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(check *containerCheck) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			check.err = check.cont.CheckHealth(h.provisioner, check.hc, container.DefaultHealthcheckTimeout)
+		}(&checks[i])
+	}
+	wg.Wait()
+	failures := make(map[string]int, len(checks))
+	for _, check := range checks {
+		if check.err == nil {
+			continue
+		}
+		count := h.healthcheckFailures[check.cont.ID] + 1
+		if count < h.healthcheckFailureThreshold {
+			failures[check.cont.ID] = count
+			continue
+		}
+		err = h.healContainerWithEvent(check.cont, check.err.Error())
+		if err != nil {
+			log.Error(err.Error())
+		}
+	}
+	h.healthcheckFailures = failures
+}
+
 func listUnresponsiveContainers(p DockerProvisioner, maxUnresponsiveTime time.Duration) ([]container.Container, error) {
 	now := time.Now().UTC()
 	return p.ListContainers(bson.M{
@@ -6,12 +6,14 @@ package healer
 
 import (
 	"errors"
+	stdnet "net"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/event/eventtest"
@@ -134,6 +136,278 @@ func (s *S) TestRunContainerHealer(c *check.C) {
 	}, eventtest.HasEvent)
 }
 
+func (s *S) TestRunContainerHealerPoolInMaintenance(c *check.C) {
+	p, err := dockertest.StartMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	defer p.Destroy()
+	app := newFakeAppInDB("myapp", "python", 2)
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	err = conn.Apps().Update(bson.M{"name": "myapp"}, bson.M{"$set": bson.M{"pool": "pool1"}})
+	c.Assert(err, check.IsNil)
+	err = provision.AddMaintenanceWindow("pool1", &provision.MaintenanceWindow{
+		End:    time.Now().Add(time.Hour),
+		Reason: "docker upgrade",
+	})
+	c.Assert(err, check.IsNil)
+	node1 := p.Servers()[0]
+	containers, err := p.StartContainers(dockertest.StartContainersArgs{
+		Endpoint:  node1.URL(),
+		App:       app,
+		Amount:    map[string]int{"web": 1},
+		Image:     "tsuru/python",
+		PullImage: true,
+	})
+	c.Assert(err, check.IsNil)
+	node1.MutateContainer(containers[0].ID, docker.State{Running: false, Restarting: false})
+	toMoveCont := containers[0]
+	toMoveCont.LastSuccessStatusUpdate = time.Now().UTC().Add(-5 * time.Minute)
+	p.PrepareListResult([]container.Container{toMoveCont}, nil)
+	healer := NewContainerHealer(ContainerHealerArgs{
+		Provisioner:         p,
+		MaxUnresponsiveTime: time.Minute,
+		Locker:              dockertest.NewFakeLocker(),
+	})
+	healer.runContainerHealerOnce()
+	c.Assert(p.Movings(), check.IsNil)
+	c.Assert(eventtest.EventDesc{
+		Target:       event.Target{Type: "container", Value: toMoveCont.ID},
+		Kind:         "healer",
+		ErrorMatches: `pool "pool1" is under maintenance until .*: docker upgrade`,
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestHealUnhealthyContainers(c *check.C) {
+	p, err := dockertest.StartMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	defer p.Destroy()
+	app := newFakeAppInDB("myapp", "python", 2)
+	err = image.SaveImageCustomData("tsuru/python", map[string]interface{}{
+		"healthcheck": map[string]interface{}{"type": "tcp"},
+		"processes":   map[string]interface{}{"web": "python app.py"},
+	})
+	c.Assert(err, check.IsNil)
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer listener.Close()
+	closedListener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	closedListener.Close()
+	node1 := p.Servers()[0]
+	containers, err := p.StartContainers(dockertest.StartContainersArgs{
+		Endpoint:  node1.URL(),
+		App:       app,
+		Amount:    map[string]int{"web": 2},
+		Image:     "tsuru/python",
+		PullImage: true,
+	})
+	c.Assert(err, check.IsNil)
+	unhealthyCont, healthyCont := containers[0], containers[1]
+	unhealthyCont.Status = provision.StatusStarted.String()
+	_, unhealthyCont.HostPort, _ = stdnet.SplitHostPort(closedListener.Addr().String())
+	healthyCont.Status = provision.StatusStarted.String()
+	_, healthyCont.HostPort, _ = stdnet.SplitHostPort(listener.Addr().String())
+	p.PrepareListResult([]container.Container{unhealthyCont, healthyCont}, nil)
+	healer := NewContainerHealer(ContainerHealerArgs{
+		Provisioner:         p,
+		MaxUnresponsiveTime: time.Minute,
+		Locker:              dockertest.NewFakeLocker(),
+	})
+	healer.healUnhealthyContainers()
+	healer.healUnhealthyContainers()
+	c.Assert(p.Movings(), check.IsNil)
+	healer.healUnhealthyContainers()
+	c.Assert(p.Movings(), check.DeepEquals, []dockertest.ContainerMoving{
+		{ContainerID: unhealthyCont.ID, HostFrom: unhealthyCont.HostAddr, HostTo: ""},
+	})
+	query := bson.M{
+		"id":          bson.M{"$ne": ""},
+		"appname":     bson.M{"$ne": ""},
+		"processname": bson.M{"$ne": ""},
+		"status":      provision.StatusStarted.String(),
+	}
+	c.Assert(p.Queries(), check.DeepEquals, []bson.M{query, query, query})
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: "container", Value: unhealthyCont.ID},
+		Kind:   "healer",
+	}, eventtest.HasEvent)
+}
+
+func (s *S) TestHealUnhealthyContainersHTTPHealthcheck(c *check.C) {
+	p, err := dockertest.StartMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	defer p.Destroy()
+	app := newFakeAppInDB("myapp", "python", 1)
+	err = image.SaveImageCustomData("tsuru/python", map[string]interface{}{
+		"healthcheck": map[string]interface{}{"path": "/"},
+		"processes":   map[string]interface{}{"web": "python app.py"},
+	})
+	c.Assert(err, check.IsNil)
+	containers, err := p.StartContainers(dockertest.StartContainersArgs{
+		Endpoint:  p.Servers()[0].URL(),
+		App:       app,
+		Amount:    map[string]int{"web": 1},
+		Image:     "tsuru/python",
+		PullImage: true,
+	})
+	c.Assert(err, check.IsNil)
+	cont := containers[0]
+	cont.Status = provision.StatusStarted.String()
+	cont.HostPort = "1"
+	p.PrepareListResult([]container.Container{cont}, nil)
+	healer := NewContainerHealer(ContainerHealerArgs{
+		Provisioner:         p,
+		MaxUnresponsiveTime: time.Minute,
+		Locker:              dockertest.NewFakeLocker(),
+	})
+	healer.healUnhealthyContainers()
+	c.Assert(p.Movings(), check.IsNil)
+	c.Assert(eventtest.EventDesc{IsEmpty: true}, eventtest.HasEvent)
+}
+
+func (s *S) TestHealUnhealthyContainersProcessHealthcheck(c *check.C) {
+	p, err := dockertest.StartMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	defer p.Destroy()
+	app := newFakeAppInDB("myapp", "python", 2)
+	err = image.SaveImageCustomData("tsuru/python", map[string]interface{}{
+		"procfile": "web: python app.py\nworker: python worker.py\nclock: python clock.py",
+		"processes": map[string]interface{}{
+			"worker": map[string]interface{}{
+				"healthcheck": map[string]interface{}{"type": "tcp"},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	closedListener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	closedListener.Close()
+	_, closedPort, _ := stdnet.SplitHostPort(closedListener.Addr().String())
+	containers, err := p.StartContainers(dockertest.StartContainersArgs{
+		Endpoint:  p.Servers()[0].URL(),
+		App:       app,
+		Amount:    map[string]int{"worker": 1, "clock": 1},
+		Image:     "tsuru/python",
+		PullImage: true,
+	})
+	c.Assert(err, check.IsNil)
+	for i := range containers {
+		containers[i].Status = provision.StatusStarted.String()
+		containers[i].HostPort = closedPort
+	}
+	p.PrepareListResult(containers, nil)
+	healer := NewContainerHealer(ContainerHealerArgs{
+		Provisioner:                 p,
+		MaxUnresponsiveTime:         time.Minute,
+		HealthcheckFailureThreshold: 1,
+		Locker:                      dockertest.NewFakeLocker(),
+	})
+	healer.healUnhealthyContainers()
+	var workerCont container.Container
+	for _, cont := range containers {
+		if cont.ProcessName == "worker" {
+			workerCont = cont
+		}
+	}
+	c.Assert(p.Movings(), check.DeepEquals, []dockertest.ContainerMoving{
+		{ContainerID: workerCont.ID, HostFrom: workerCont.HostAddr, HostTo: ""},
+	})
+}
+
+func (s *S) TestHealUnhealthyContainersRequiresConsecutiveFailures(c *check.C) {
+	p, err := dockertest.StartMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	defer p.Destroy()
+	app := newFakeAppInDB("myapp", "python", 1)
+	err = image.SaveImageCustomData("tsuru/python", map[string]interface{}{
+		"healthcheck": map[string]interface{}{"type": "tcp"},
+		"processes":   map[string]interface{}{"web": "python app.py"},
+	})
+	c.Assert(err, check.IsNil)
+	listener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr := listener.Addr().String()
+	listener.Close()
+	containers, err := p.StartContainers(dockertest.StartContainersArgs{
+		Endpoint:  p.Servers()[0].URL(),
+		App:       app,
+		Amount:    map[string]int{"web": 1},
+		Image:     "tsuru/python",
+		PullImage: true,
+	})
+	c.Assert(err, check.IsNil)
+	cont := containers[0]
+	cont.Status = provision.StatusStarted.String()
+	_, cont.HostPort, _ = stdnet.SplitHostPort(addr)
+	p.PrepareListResult([]container.Container{cont}, nil)
+	healer := NewContainerHealer(ContainerHealerArgs{
+		Provisioner:                 p,
+		MaxUnresponsiveTime:         time.Minute,
+		HealthcheckFailureThreshold: 2,
+		Locker:                      dockertest.NewFakeLocker(),
+	})
+	healer.healUnhealthyContainers()
+	listener, err = stdnet.Listen("tcp", addr)
+	c.Assert(err, check.IsNil)
+	healer.healUnhealthyContainers()
+	listener.Close()
+	healer.healUnhealthyContainers()
+	c.Assert(p.Movings(), check.IsNil)
+	healer.healUnhealthyContainers()
+	c.Assert(p.Movings(), check.DeepEquals, []dockertest.ContainerMoving{
+		{ContainerID: cont.ID, HostFrom: cont.HostAddr, HostTo: ""},
+	})
+}
+
+func (s *S) TestHealUnhealthyContainersAllProcesses(c *check.C) {
+	p, err := dockertest.StartMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	defer p.Destroy()
+	app := newFakeAppInDB("myapp", "python", 2)
+	err = image.SaveImageCustomData("tsuru/python", map[string]interface{}{
+		"procfile":    "worker: python worker.py\nclock: python clock.py",
+		"healthcheck": map[string]interface{}{"type": "tcp"},
+	})
+	c.Assert(err, check.IsNil)
+	closedListener, err := stdnet.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	closedListener.Close()
+	_, closedPort, _ := stdnet.SplitHostPort(closedListener.Addr().String())
+	containers, err := p.StartContainers(dockertest.StartContainersArgs{
+		Endpoint:  p.Servers()[0].URL(),
+		App:       app,
+		Amount:    map[string]int{"worker": 1, "clock": 2},
+		Image:     "tsuru/python",
+		PullImage: true,
+	})
+	c.Assert(err, check.IsNil)
+	expectedIDs := map[string]bool{}
+	withPort := map[string]bool{}
+	for i := range containers {
+		containers[i].Status = provision.StatusStarted.String()
+		containers[i].HostPort = ""
+		if !withPort[containers[i].ProcessName] {
+			withPort[containers[i].ProcessName] = true
+			containers[i].HostPort = closedPort
+			expectedIDs[containers[i].ID] = true
+		}
+	}
+	p.PrepareListResult(containers, nil)
+	healer := NewContainerHealer(ContainerHealerArgs{
+		Provisioner:                 p,
+		MaxUnresponsiveTime:         time.Minute,
+		HealthcheckFailureThreshold: 1,
+		Locker:                      dockertest.NewFakeLocker(),
+	})
+	healer.healUnhealthyContainers()
+	movedIDs := map[string]bool{}
+	for _, moving := range p.Movings() {
+		movedIDs[moving.ContainerID] = true
+	}
+	c.Assert(movedIDs, check.DeepEquals, expectedIDs)
+}
+
 func (s *S) TestRunContainerHealerCreatedContainer(c *check.C) {
 	p, err := dockertest.StartMultipleServersCluster()
 	c.Assert(err, check.IsNil)
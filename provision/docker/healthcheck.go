@@ -7,93 +7,74 @@ package docker
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app/image"
-	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 )
 
-func runHealthcheck(cont *container.Container, w io.Writer) error {
+// deployHealthcheckTimeout is the timeout of each healthcheck attempt run
+// while deploying units.
+const deployHealthcheckTimeout = time.Minute
+
+func runHealthcheck(p container.DockerProvisioner, cont *container.Container, w io.Writer) error {
 	yamlData, err := image.GetImageTsuruYamlData(cont.Image)
 	if err != nil {
 		return err
 	}
-	path := yamlData.Healthcheck.Path
-	method := yamlData.Healthcheck.Method
-	match := yamlData.Healthcheck.Match
-	status := yamlData.Healthcheck.Status
-	allowedFailures := yamlData.Healthcheck.AllowedFailures
-	if path == "" {
-		return nil
-	}
-	path = strings.TrimSpace(strings.TrimLeft(path, "/"))
-	if method == "" {
-		method = "get"
-	}
-	method = strings.ToUpper(method)
-	if status == 0 && match == "" {
-		status = 200
-	}
-	var matchRE *regexp.Regexp
-	if match != "" {
-		match = "(?s)" + match
-		matchRE, err = regexp.Compile(match)
-		if err != nil {
-			return err
+	hc := yamlData.ProcessData(cont.ProcessName).Healthcheck
+	switch hcType := hc.CheckType(); hcType {
+	case provision.HealthcheckTypeHTTP:
+		if hc.Path == "" {
+			return nil
 		}
+	case provision.HealthcheckTypeCommand:
+		if hc.Command == "" {
+			return nil
+		}
+	case provision.HealthcheckTypeTCP:
+	default:
+		return fmt.Errorf("invalid healthcheck type %q, expected one of http, tcp or command", hcType)
 	}
+	return retryHealthcheck(cont, w, hc.AllowedFailures, func() error {
+		return cont.CheckHealth(p, hc, deployHealthcheckTimeout)
+	})
+}
+
+func healthcheckMaxWaitTime() time.Duration {
 	maxWaitTime, _ := config.GetInt("docker:healthcheck:max-time")
 	if maxWaitTime == 0 {
 		maxWaitTime = 120
 	}
-	maxWaitTime = maxWaitTime * int(time.Second)
+	return time.Duration(maxWaitTime) * time.Second
+}
+
+// retryHealthcheck runs check until it succeeds or the max healthcheck time
+// is reached, returning the last error in this case. Failures other than not
+// being able to reach the unit abort the retries once more than
+// allowedFailures of them happen.
+func retryHealthcheck(cont *container.Container, w io.Writer, allowedFailures int, check func() error) error {
+	maxWaitTime := healthcheckMaxWaitTime()
 	sleepTime := 3 * time.Second
 	startedTime := time.Now()
-	url := fmt.Sprintf("http://%s:%s/%s", cont.HostAddr, cont.HostPort, path)
 	for {
-		var lastError error = nil
-		req, err := http.NewRequest(method, url, nil)
-		if err != nil {
-			return err
-		}
-		rsp, err := net.Dial5Full60ClientNoKeepAlive.Do(req)
-		if err != nil {
-			lastError = fmt.Errorf("healthcheck fail(%s): %s", cont.ShortID(), err.Error())
-		} else {
-			defer rsp.Body.Close()
-			if status != 0 && rsp.StatusCode != status {
-				lastError = fmt.Errorf("healthcheck fail(%s): wrong status code, expected %d, got: %d", cont.ShortID(), status, rsp.StatusCode)
-			} else if matchRE != nil {
-				result, err := ioutil.ReadAll(rsp.Body)
-
-				if err != nil {
-					lastError = err
-				}
-				if !matchRE.Match(result) {
-					lastError = fmt.Errorf("healthcheck fail(%s): unexpected result, expected %q, got: %s", cont.ShortID(), match, string(result))
-				}
-			}
-			if lastError != nil {
-				if allowedFailures == 0 {
-					return lastError
-				}
-				allowedFailures--
-			}
-		}
-		if lastError == nil {
+		err := check()
+		if err == nil {
 			fmt.Fprintf(w, " ---> healthcheck successful(%s)\n", cont.ShortID())
 			return nil
 		}
-		if time.Since(startedTime) > time.Duration(maxWaitTime) {
-			return lastError
+		if _, unreachable := err.(*provision.UnreachableError); !unreachable {
+			if allowedFailures == 0 {
+				return err
+			}
+			allowedFailures--
+		}
+		if time.Since(startedTime) > maxWaitTime {
+			return err
 		}
-		fmt.Fprintf(w, " ---> %s. Trying again in %s\n", lastError.Error(), sleepTime)
+		fmt.Fprintf(w, " ---> %s. Trying again in %s\n", err.Error(), sleepTime)
 		time.Sleep(sleepTime)
 	}
 }
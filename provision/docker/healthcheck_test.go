@@ -6,11 +6,14 @@ package docker
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tsuru/config"
@@ -46,7 +49,7 @@ func (s *S) TestHealthcheck(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 1)
 	c.Assert(requests[0].URL.Path, check.Equals, "/x/y")
@@ -84,11 +87,11 @@ func (s *S) TestHealthcheckWithMatch(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.ErrorMatches, ".*unexpected result, expected \"(?s).*some.*\", got: invalid")
 	c.Assert(requests, check.HasLen, 1)
 	c.Assert(requests[0].Method, check.Equals, "GET")
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 2)
 	c.Assert(requests[1].URL.Path, check.Equals, "/x/y")
@@ -118,7 +121,7 @@ func (s *S) TestHealthcheckDefaultCheck(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 1)
 	c.Assert(requests[0].Method, check.Equals, "GET")
@@ -140,7 +143,7 @@ func (s *S) TestHealthcheckNoHealthcheck(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 0)
 }
@@ -169,7 +172,7 @@ func (s *S) TestHealthcheckNoPath(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(requests, check.HasLen, 0)
 }
@@ -208,7 +211,7 @@ func (s *S) TestHealthcheckKeepsTryingWithServerDown(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(buf.String(), check.Matches, `(?s).*---> healthcheck fail.*?Trying again in 3s.*---> healthcheck successful.*`)
 	c.Assert(requests, check.HasLen, 2)
@@ -239,7 +242,7 @@ func (s *S) TestHealthcheckErrorsAfterMaxTime(c *check.C) {
 	defer config.Unset("docker:healthcheck:max-time")
 	done := make(chan struct{})
 	go func() {
-		err = runHealthcheck(&cont, &buf)
+		err = runHealthcheck(s.p, &cont, &buf)
 		close(done)
 	}()
 	select {
@@ -287,7 +290,7 @@ func (s *S) TestHealthcheckSuccessfulWithAllowedFailures(c *check.C) {
 	host, port, _ := net.SplitHostPort(url.Host)
 	cont := container.Container{AppName: a.Name, HostAddr: host, HostPort: port, Image: imageName}
 	buf := bytes.Buffer{}
-	err = runHealthcheck(&cont, &buf)
+	err = runHealthcheck(s.p, &cont, &buf)
 	c.Assert(err, check.IsNil)
 	c.Assert(buf.String(), check.Matches, `(?s).*---> healthcheck fail.*?Trying again in 3s.*---> healthcheck fail.*?Trying again in 3s.*---> healthcheck successful.*`)
 	c.Assert(requests, check.HasLen, 3)
@@ -298,3 +301,144 @@ func (s *S) TestHealthcheckSuccessfulWithAllowedFailures(c *check.C) {
 	c.Assert(requests[2].Method, check.Equals, "GET")
 	c.Assert(requests[2].URL.Path, check.Equals, "/x/y")
 }
+
+func (s *S) TestHealthcheckTCP(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	imageName := "tsuru/app"
+	err = image.SaveImageCustomData(imageName, map[string]interface{}{
+		"healthcheck": map[string]interface{}{"type": "tcp"},
+	})
+	c.Assert(err, check.IsNil)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	cont := container.Container{AppName: "myapp1", HostAddr: host, HostPort: port, Image: imageName}
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, &cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, " ---> healthcheck successful()\n")
+}
+
+func (s *S) TestHealthcheckTCPFailure(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close()
+	imageName := "tsuru/app"
+	err = image.SaveImageCustomData(imageName, map[string]interface{}{
+		"healthcheck": map[string]interface{}{"type": "tcp"},
+	})
+	c.Assert(err, check.IsNil)
+	config.Set("docker:healthcheck:max-time", -1)
+	defer config.Unset("docker:healthcheck:max-time")
+	cont := container.Container{AppName: "myapp1", HostAddr: host, HostPort: port, Image: imageName}
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, &cont, &buf)
+	c.Assert(err, check.ErrorMatches, "healthcheck fail.*connection refused")
+}
+
+func (s *S) TestHealthcheckProcessOverride(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	imageName := "tsuru/app"
+	err = image.SaveImageCustomData(imageName, map[string]interface{}{
+		"procfile": "web: python web.py\nworker: python worker.py",
+		"healthcheck": map[string]interface{}{
+			"path": "/",
+		},
+		"processes": map[string]interface{}{
+			"worker": map[string]interface{}{
+				"healthcheck": map[string]interface{}{"type": "tcp"},
+			},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	cont := container.Container{AppName: "myapp1", HostAddr: host, HostPort: port, Image: imageName, ProcessName: "worker"}
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, &cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, " ---> healthcheck successful()\n")
+}
+
+func (s *S) TestHealthcheckCommand(c *check.C) {
+	cont, err := s.newContainer(&newContainerOpts{
+		Image: "tsuru/app-myapp",
+		ImageCustomData: map[string]interface{}{
+			"healthcheck": map[string]interface{}{
+				"type":    "command",
+				"command": "pgrep -f worker",
+			},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, " ---> healthcheck successful("+cont.ShortID()+")\n")
+}
+
+func (s *S) TestHealthcheckInvalidType(c *check.C) {
+	imageName := "tsuru/app"
+	err := image.SaveImageCustomData(imageName, map[string]interface{}{
+		"healthcheck": map[string]interface{}{"type": "udp"},
+	})
+	c.Assert(err, check.IsNil)
+	cont := container.Container{AppName: "myapp1", Image: imageName}
+	err = runHealthcheck(s.p, &cont, ioutil.Discard)
+	c.Assert(err, check.ErrorMatches, `invalid healthcheck type "udp", expected one of http, tcp or command`)
+}
+
+func (s *S) TestHealthcheckCommandWithAllowedFailures(c *check.C) {
+	var calls int32
+	s.server.CustomHandler("/exec/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exitCode := 0
+		if atomic.AddInt32(&calls, 1) == 1 {
+			exitCode = 1
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"ID":"id","ExitCode":%d}`, exitCode)
+	}))
+	cont, err := s.newContainer(&newContainerOpts{
+		Image: "tsuru/app-myapp",
+		ImageCustomData: map[string]interface{}{
+			"healthcheck": map[string]interface{}{
+				"type":             "command",
+				"command":          "pgrep -f worker",
+				"allowed_failures": 1,
+			},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	buf := bytes.Buffer{}
+	err = runHealthcheck(s.p, cont, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s) ---> healthcheck fail.*?unexpected exit code: 1. Trying again in 3s\n ---> healthcheck successful.*`)
+	c.Assert(atomic.LoadInt32(&calls), check.Equals, int32(2))
+}
+
+func (s *S) TestHealthcheckCommandFailureWithoutAllowedFailures(c *check.C) {
+	var calls int32
+	s.server.CustomHandler("/exec/.*/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"ID":"id","ExitCode":1}`))
+	}))
+	cont, err := s.newContainer(&newContainerOpts{
+		Image: "tsuru/app-myapp",
+		ImageCustomData: map[string]interface{}{
+			"healthcheck": map[string]interface{}{
+				"type":    "command",
+				"command": "pgrep -f worker",
+			},
+		},
+	}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont)
+	err = runHealthcheck(s.p, cont, ioutil.Discard)
+	c.Assert(err, check.ErrorMatches, `healthcheck fail\(.*\): command "pgrep -f worker" failed: unexpected exit code: 1`)
+	c.Assert(atomic.LoadInt32(&calls), check.Equals, int32(1))
+}
@@ -0,0 +1,270 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	defaultLivenessInterval         = 10 * time.Second
+	defaultLivenessTimeout          = 5 * time.Second
+	defaultLivenessFailureThreshold = 3
+
+	livenessRestartTimeout = 10
+)
+
+type livenessConfig struct {
+	webProcess       string
+	healthcheck      provision.TsuruYamlHealthcheck
+	interval         time.Duration
+	timeout          time.Duration
+	failureThreshold int
+}
+
+func imageLivenessConfig(imageID string) (*livenessConfig, error) {
+	yamlData, err := image.GetImageTsuruYamlData(imageID)
+	if err != nil {
+		return nil, err
+	}
+	if yamlData.Liveness == nil {
+		return nil, nil
+	}
+	webProcess, err := image.GetImageWebProcessName(imageID)
+	if err != nil {
+		return nil, err
+	}
+	conf := livenessConfig{
+		webProcess:       webProcess,
+		healthcheck:      yamlData.ProcessData(webProcess).Healthcheck,
+		interval:         time.Duration(yamlData.Liveness.Interval) * time.Second,
+		timeout:          time.Duration(yamlData.Liveness.Timeout) * time.Second,
+		failureThreshold: yamlData.Liveness.FailureThreshold,
+	}
+	if conf.interval <= 0 {
+		conf.interval = defaultLivenessInterval
+	}
+	if conf.timeout <= 0 {
+		conf.timeout = defaultLivenessTimeout
+	}
+	if conf.failureThreshold <= 0 {
+		conf.failureThreshold = defaultLivenessFailureThreshold
+	}
+	return &conf, nil
+}
+
+type livenessState struct {
+	lastCheck time.Time
+	failures  int
+}
+
+// livenessChecker periodically runs the liveness checks declared in the
+// tsuru.yaml of apps against their started web units. Units failing more than
+// the failure threshold are removed from the router and restarted, being
+// added back to the router once they pass the check again.
+type livenessChecker struct {
+	p            *dockerProvisioner
+	tickInterval time.Duration
+	quit         chan struct{}
+	states       map[string]*livenessState
+}
+
+func newLivenessChecker(p *dockerProvisioner, tickInterval time.Duration) *livenessChecker {
+	return &livenessChecker{
+		p:            p,
+		tickInterval: tickInterval,
+		quit:         make(chan struct{}),
+		states:       make(map[string]*livenessState),
+	}
+}
+
+func (l *livenessChecker) run() {
+	for {
+		l.runOnce()
+		select {
+		case <-l.quit:
+			return
+		case <-time.After(l.tickInterval):
+		}
+	}
+}
+
+func (l *livenessChecker) Shutdown() {
+	l.quit <- struct{}{}
+}
+
+func (l *livenessChecker) String() string {
+	return "liveness checker"
+}
+
+func (l *livenessChecker) runOnce() {
+	containers, err := l.p.ListContainers(bson.M{
+		"id":          bson.M{"$ne": ""},
+		"appname":     bson.M{"$ne": ""},
+		"processname": bson.M{"$ne": ""},
+		"status":      provision.StatusStarted.String(),
+	})
+	if err != nil {
+		log.Errorf("[liveness] unable to list started containers: %s", err)
+		return
+	}
+	now := time.Now()
+	configs := map[string]*livenessConfig{}
+	states := map[string]*livenessState{}
+	var wg sync.WaitGroup
+	for i := range containers {
+		cont := &containers[i]
+		conf, ok := configs[cont.Image]
+		if !ok {
+			conf, err = imageLivenessConfig(cont.Image)
+			if err != nil {
+				log.Errorf("[liveness] unable to get liveness config for image %q: %s", cont.Image, err)
+			}
+			configs[cont.Image] = conf
+		}
+		if conf == nil || cont.ProcessName != conf.webProcess {
+			continue
+		}
+		state := l.states[cont.ID]
+		if state == nil {
+			state = &livenessState{}
+		}
+		states[cont.ID] = state
+		if now.Sub(state.lastCheck) < conf.interval {
+			continue
+		}
+		state.lastCheck = now
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.checkContainer(cont, conf, state)
+		}()
+	}
+	wg.Wait()
+	l.states = states
+}
+
+func (l *livenessChecker) checkContainer(cont *container.Container, conf *livenessConfig, state *livenessState) {
+	checkErr := cont.CheckHealth(l.p, conf.healthcheck, conf.timeout)
+	if checkErr == nil {
+		state.failures = 0
+		if cont.Unhealthy {
+			err := l.setUnhealthy(cont, false)
+			if err != nil {
+				log.Errorf("[liveness] unable to add unit %q back to the router: %s", cont.ID, err)
+			}
+		}
+		return
+	}
+	state.failures++
+	log.Debugf("[liveness] unit %q of app %q failed liveness check (%d/%d): %s", cont.ID, cont.AppName, state.failures, conf.failureThreshold, checkErr)
+	if state.failures < conf.failureThreshold {
+		return
+	}
+	restarted, err := l.restartUnit(cont, checkErr)
+	if err != nil {
+		log.Errorf("[liveness] unable to restart unit %q of app %q: %s", cont.ID, cont.AppName, err)
+	}
+	if restarted {
+		state.failures = 0
+	}
+}
+
+// setUnhealthy flags the container as unhealthy, removing it from the router,
+// or clears the flag and adds the container back to the router.
+func (l *livenessChecker) setUnhealthy(cont *container.Container, unhealthy bool) error {
+	a, err := app.GetByName(cont.AppName)
+	if err != nil {
+		return err
+	}
+	r, err := getRouterForApp(a)
+	if err != nil {
+		return err
+	}
+	coll := l.p.Collection()
+	defer coll.Close()
+	err = coll.Update(bson.M{"id": cont.ID}, bson.M{"$set": bson.M{"unhealthy": unhealthy}})
+	if err != nil {
+		return err
+	}
+	cont.Unhealthy = unhealthy
+	if unhealthy {
+		err = r.RemoveRoute(a.Name, cont.Address())
+		if err == router.ErrRouteNotFound {
+			return nil
+		}
+		return err
+	}
+	err = r.AddRoute(a.Name, cont.Address())
+	if err == router.ErrRouteExists {
+		return nil
+	}
+	return err
+}
+
+// restartUnit takes the unit out of the router and restarts it, recording an
+// event in the app. It returns false when the restart was skipped because
+// another operation is running on the app.
+func (l *livenessChecker) restartUnit(cont *container.Container, reason error) (bool, error) {
+	a, err := app.GetByName(cont.AppName)
+	if err != nil {
+		return false, err
+	}
+	evt, err := event.NewInternal(&event.Opts{
+		Target:       event.Target{Type: event.TargetTypeApp, Value: a.Name},
+		InternalKind: "liveness-restart",
+		CustomData: map[string]interface{}{
+			"unit":   cont.ID,
+			"reason": reason.Error(),
+		},
+		Allowed: event.Allowed(permission.PermAppReadEvents, append(permission.Contexts(permission.CtxTeam, a.Teams),
+			permission.Context(permission.CtxApp, a.Name),
+			permission.Context(permission.CtxPool, a.Pool),
+		)...),
+	})
+	if err != nil {
+		if _, ok := err.(event.ErrEventLocked); ok {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "unable to create event")
+	}
+	log.Errorf("[liveness] restarting unit %q of app %q: %s", cont.ID, a.Name, reason)
+	var restartErr error
+	defer func() {
+		if err := evt.Done(restartErr); err != nil {
+			log.Errorf("[liveness] unable to update restart event: %s", err)
+		}
+	}()
+	if !cont.Unhealthy {
+		restartErr = l.setUnhealthy(cont, true)
+		if restartErr != nil {
+			restartErr = errors.Wrap(restartErr, "unable to remove unit from router")
+			return true, restartErr
+		}
+	}
+	done := l.p.ActionLimiter().Start(cont.HostAddr)
+	restartErr = l.p.Cluster().RestartContainer(cont.ID, livenessRestartTimeout)
+	done()
+	if restartErr != nil {
+		return true, restartErr
+	}
+	cont.SetStatus(l.p, provision.StatusStarting, true)
+	if info, infoErr := cont.NetworkInfo(l.p); infoErr == nil {
+		l.p.fixContainer(cont, info)
+	}
+	return true, nil
+}
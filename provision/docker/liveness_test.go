@@ -0,0 +1,131 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/event/eventtest"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/docker/container"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) newLivenessContainer(c *check.C, addr string, unhealthy bool, liveness map[string]interface{}) *container.Container {
+	a := app.App{Name: "myapp", Teams: []string{s.team.Name}}
+	err := s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	routertest.FakeRouter.AddBackend(a.Name)
+	customData := map[string]interface{}{
+		"processes":   map[string]interface{}{"web": "python app.py"},
+		"healthcheck": map[string]interface{}{"type": "tcp"},
+	}
+	if liveness != nil {
+		customData["liveness"] = liveness
+	}
+	cont, err := s.newContainer(&newContainerOpts{
+		AppName:         a.Name,
+		Status:          provision.StatusStarted.String(),
+		Image:           "tsuru/app-myapp",
+		ProcessName:     "web",
+		ImageCustomData: customData,
+	}, nil)
+	c.Assert(err, check.IsNil)
+	cont.HostAddr, cont.HostPort, _ = net.SplitHostPort(addr)
+	cont.Unhealthy = unhealthy
+	coll := s.p.Collection()
+	defer coll.Close()
+	err = coll.Update(bson.M{"id": cont.ID}, cont)
+	c.Assert(err, check.IsNil)
+	if !unhealthy {
+		err = routertest.FakeRouter.AddRoute(a.Name, cont.Address())
+		c.Assert(err, check.IsNil)
+	}
+	return cont
+}
+
+func (s *S) TestLivenessCheckerRestartsFailingUnit(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr := l.Addr().String()
+	l.Close()
+	var restarted []string
+	s.server.CustomHandler("/containers/.*/restart", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		restarted = append(restarted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	cont := s.newLivenessContainer(c, addr, false, map[string]interface{}{"failure_threshold": 2})
+	defer s.removeTestContainer(cont)
+	checker := newLivenessChecker(s.p, time.Minute)
+	checker.runOnce()
+	c.Assert(checker.states[cont.ID].failures, check.Equals, 1)
+	c.Assert(restarted, check.HasLen, 0)
+	c.Assert(routertest.FakeRouter.HasRoute("myapp", cont.Address().String()), check.Equals, true)
+	c.Assert(eventtest.EventDesc{IsEmpty: true}, eventtest.HasEvent)
+	checker.runOnce()
+	c.Assert(checker.states[cont.ID].failures, check.Equals, 1)
+	checker.states[cont.ID].lastCheck = time.Time{}
+	checker.runOnce()
+	c.Assert(checker.states[cont.ID].failures, check.Equals, 0)
+	c.Assert(restarted, check.DeepEquals, []string{"/containers/" + cont.ID + "/restart"})
+	c.Assert(routertest.FakeRouter.HasRoute("myapp", cont.Address().String()), check.Equals, false)
+	dbCont, err := s.p.GetContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbCont.Unhealthy, check.Equals, true)
+	c.Assert(dbCont.Status, check.Equals, provision.StatusStarting.String())
+	c.Assert(eventtest.EventDesc{
+		Target: event.Target{Type: event.TargetTypeApp, Value: "myapp"},
+		Kind:   "liveness-restart",
+	}, eventtest.HasEvent)
+	routable, err := s.p.RoutableUnits(&app.App{Name: "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(routable, check.HasLen, 0)
+}
+
+func (s *S) TestLivenessCheckerUnitRecovers(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	cont := s.newLivenessContainer(c, l.Addr().String(), true, map[string]interface{}{"interval": 1})
+	defer s.removeTestContainer(cont)
+	checker := newLivenessChecker(s.p, time.Minute)
+	checker.runOnce()
+	c.Assert(checker.states[cont.ID].failures, check.Equals, 0)
+	c.Assert(routertest.FakeRouter.HasRoute("myapp", cont.Address().String()), check.Equals, true)
+	dbCont, err := s.p.GetContainer(cont.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(dbCont.Unhealthy, check.Equals, false)
+	c.Assert(eventtest.EventDesc{IsEmpty: true}, eventtest.HasEvent)
+}
+
+func (s *S) TestLivenessCheckerIgnoresAppsWithoutLiveness(c *check.C) {
+	cont := s.newLivenessContainer(c, "127.0.0.1:1", false, nil)
+	defer s.removeTestContainer(cont)
+	checker := newLivenessChecker(s.p, time.Minute)
+	checker.runOnce()
+	c.Assert(checker.states, check.HasLen, 0)
+}
+
+func (s *S) TestImageLivenessConfigDefaults(c *check.C) {
+	err := s.newFakeImage(s.p, "tsuru/app-myapp", map[string]interface{}{
+		"processes": map[string]interface{}{"web": "python app.py", "worker": "python worker.py"},
+		"liveness":  map[string]interface{}{},
+	})
+	c.Assert(err, check.IsNil)
+	conf, err := imageLivenessConfig("tsuru/app-myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(conf, check.DeepEquals, &livenessConfig{
+		webProcess:       "web",
+		interval:         defaultLivenessInterval,
+		timeout:          defaultLivenessTimeout,
+		failureThreshold: defaultLivenessFailureThreshold,
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/url"
 	"path/filepath"
 	"strings"
@@ -26,6 +27,7 @@ import (
 	"github.com/tsuru/tsuru/action"
 	"github.com/tsuru/tsuru/api/shutdown"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/autoscale"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/db"
@@ -43,6 +45,7 @@ import (
 	"github.com/tsuru/tsuru/provision/nodecontainer"
 	"github.com/tsuru/tsuru/queue"
 	"github.com/tsuru/tsuru/router"
+	_ "github.com/tsuru/tsuru/router/configfile"
 	_ "github.com/tsuru/tsuru/router/fusis"
 	_ "github.com/tsuru/tsuru/router/galeb"
 	_ "github.com/tsuru/tsuru/router/hipache"
@@ -120,9 +123,15 @@ func (p *dockerProvisioner) initDockerCluster() error {
 	var nodes []cluster.Node
 	TotalMemoryMetadata, _ := config.GetString("docker:scheduler:total-memory-metadata")
 	maxUsedMemory, _ := config.GetFloat("docker:scheduler:max-used-memory")
+	TotalCpuMetadata, _ := config.GetString("docker:scheduler:total-cpu-metadata")
+	maxUsedCpu, _ := config.GetFloat("docker:scheduler:max-used-cpu")
+	zoneMetadata, _ := config.GetString("docker:scheduler:zone-metadata")
 	p.scheduler = &segregatedScheduler{
 		maxMemoryRatio:      float32(maxUsedMemory),
 		TotalMemoryMetadata: TotalMemoryMetadata,
+		maxCpuRatio:         float32(maxUsedCpu),
+		TotalCpuMetadata:    TotalCpuMetadata,
+		zoneMetadata:        zoneMetadata,
 		provisioner:         p,
 	}
 	caPath, _ := config.GetString("docker:tls:root-path")
@@ -152,15 +161,23 @@ func (p *dockerProvisioner) initDockerCluster() error {
 	}
 	healContainersSeconds, _ := config.GetInt("docker:healing:heal-containers-timeout")
 	if healContainersSeconds > 0 {
+		healthcheckFailureThreshold, _ := config.GetInt("docker:healing:healthcheck-failure-threshold")
 		contHealerInst := healer.NewContainerHealer(healer.ContainerHealerArgs{
-			Provisioner:         p,
-			MaxUnresponsiveTime: time.Duration(healContainersSeconds) * time.Second,
-			Done:                make(chan bool),
-			Locker:              &appLocker{},
+			Provisioner:                 p,
+			MaxUnresponsiveTime:         time.Duration(healContainersSeconds) * time.Second,
+			HealthcheckFailureThreshold: healthcheckFailureThreshold,
+			Done:                        make(chan bool),
+			Locker:                      &appLocker{},
 		})
 		shutdown.Register(contHealerInst)
 		go contHealerInst.RunContainerHealer()
 	}
+	livenessInterval, _ := config.GetInt("docker:healing:liveness-check-interval")
+	if livenessInterval > 0 {
+		liveness := newLivenessChecker(p, time.Duration(livenessInterval)*time.Second)
+		shutdown.Register(liveness)
+		go liveness.run()
+	}
 	activeMonitoring, _ := config.GetInt("docker:healing:active-monitoring-interval")
 	if activeMonitoring > 0 {
 		p.cluster.StartActiveMonitoring(time.Duration(activeMonitoring) * time.Second)
@@ -192,8 +209,12 @@ func (p *dockerProvisioner) initAutoScaleConfig() *autoScaleConfig {
 	waitSecondsNewMachine, _ := config.GetInt("docker:auto-scale:wait-new-time")
 	runInterval, _ := config.GetInt("docker:auto-scale:run-interval")
 	TotalMemoryMetadata, _ := config.GetString("docker:scheduler:total-memory-metadata")
+	TotalCpuMetadata, _ := config.GetString("docker:scheduler:total-cpu-metadata")
+	maxCpuRatio, _ := config.GetFloat("docker:scheduler:max-used-cpu")
 	return &autoScaleConfig{
 		TotalMemoryMetadata: TotalMemoryMetadata,
+		TotalCpuMetadata:    TotalCpuMetadata,
+		MaxCpuRatio:         float32(maxCpuRatio),
 		WaitTimeNewMachine:  time.Duration(waitSecondsNewMachine) * time.Second,
 		RunInterval:         time.Duration(runInterval) * time.Second,
 		Enabled:             enabled,
@@ -212,6 +233,9 @@ func (p *dockerProvisioner) cloneProvisioner(ignoredContainers []container.Conta
 	overridenProvisioner.scheduler = &segregatedScheduler{
 		maxMemoryRatio:      p.scheduler.maxMemoryRatio,
 		TotalMemoryMetadata: p.scheduler.TotalMemoryMetadata,
+		maxCpuRatio:         p.scheduler.maxCpuRatio,
+		TotalCpuMetadata:    p.scheduler.TotalCpuMetadata,
+		zoneMetadata:        p.scheduler.zoneMetadata,
 		provisioner:         &overridenProvisioner,
 		ignoredContainers:   containerIds,
 	}
@@ -249,6 +273,9 @@ func (p *dockerProvisioner) dryMode(ignoredContainers []container.Container) (*d
 	overridenProvisioner.scheduler = &segregatedScheduler{
 		maxMemoryRatio:      p.scheduler.maxMemoryRatio,
 		TotalMemoryMetadata: p.scheduler.TotalMemoryMetadata,
+		maxCpuRatio:         p.scheduler.maxCpuRatio,
+		TotalCpuMetadata:    p.scheduler.TotalCpuMetadata,
+		zoneMetadata:        p.scheduler.zoneMetadata,
 		provisioner:         overridenProvisioner,
 		ignoredContainers:   containerIds,
 	}
@@ -643,7 +670,14 @@ func (p *dockerProvisioner) deploy(a provision.App, imageId string, evt *event.E
 		if err = setQuota(a, toAdd); err != nil {
 			return err
 		}
-		_, err = p.runReplaceUnitsPipeline(evt, a, toAdd, containers, imageId)
+		switch a.GetDeployStrategy() {
+		case provision.DeployStrategyBlueGreen:
+			_, err = p.runBlueGreenPipeline(evt, a, toAdd, containers, imageId)
+		case provision.DeployStrategyCanary:
+			err = p.deployCanary(evt, a, toAdd, containers, imageId)
+		default:
+			_, err = p.runReplaceUnitsPipeline(evt, a, toAdd, containers, imageId)
+		}
 	}
 	return err
 }
@@ -737,7 +771,7 @@ func (p *dockerProvisioner) runRestartAfterHooks(cont *container.Container, w io
 	if err != nil {
 		return err
 	}
-	cmds := yamlData.Hooks.Restart.After
+	cmds := yamlData.ProcessData(cont.ProcessName).Hooks.Restart.After
 	for _, cmd := range cmds {
 		err := cont.Exec(p, w, w, cmd)
 		if err != nil {
@@ -970,6 +1004,9 @@ func (p *dockerProvisioner) ExecuteCommand(stdout, stderr io.Writer, app provisi
 	return nil
 }
 
+// AdminCommands returns the tsuru-admin commands of the docker provisioner,
+// along with the commands of app features not tied to any provisioner, as
+// tsuru-admin only extends its command line through provisioners.
 func (p *dockerProvisioner) AdminCommands() []cmd.Command {
 	return []cmd.Command{
 		&moveContainerCmd{},
@@ -983,6 +1020,8 @@ func (p *dockerProvisioner) AdminCommands() []cmd.Command {
 		&autoScaleDeleteRuleCmd{},
 		&dockerLogInfo{},
 		&dockerLogUpdate{},
+		&dockerSchedulerInfo{},
+		&dockerSchedulerUpdate{},
 		&nodecontainer.NodeContainerList{},
 		&nodecontainer.NodeContainerAdd{},
 		&nodecontainer.NodeContainerInfo{},
@@ -992,6 +1031,14 @@ func (p *dockerProvisioner) AdminCommands() []cmd.Command {
 		&cmd.RemovedCommand{Name: "bs-env-set", Help: "You should use `tsuru-admin node-container-update big-sibling` instead."},
 		&cmd.RemovedCommand{Name: "bs-info", Help: "You should use `tsuru-admin node-container-info big-sibling` instead."},
 		&cmd.RemovedCommand{Name: "bs-upgrade", Help: "You should use `tsuru-admin node-container-upgrade big-sibling` instead."},
+		&app.AppMigrate{},
+		&app.CertificateSet{},
+		&app.CertificateUnset{},
+		&app.CertificateList{},
+		&autoscale.AppScheduleList{},
+		&autoscale.AppScheduleAdd{},
+		&autoscale.AppScheduleUpdate{},
+		&autoscale.AppScheduleRemove{},
 	}
 }
 
@@ -1119,7 +1166,7 @@ func (p *dockerProvisioner) RoutableUnits(app provision.App) ([]provision.Unit,
 	}
 	units := make([]provision.Unit, 0, len(containers))
 	for _, container := range containers {
-		if container.ProcessName == webProcessName && container.ValidAddr() {
+		if container.ProcessName == webProcessName && container.ValidAddr() && !container.Unhealthy {
 			units = append(units, container.AsUnit(app))
 		}
 	}
@@ -1186,6 +1233,119 @@ func (p *dockerProvisioner) MetricEnvs(app provision.App) map[string]string {
 	return envs
 }
 
+const unitsMetricsWorkers = 10
+
+// UnitsMetrics fetches the stats of the started units of the app in
+// parallel. Units whose stats can't be fetched are logged and skipped, so a
+// single unreachable node doesn't prevent the app from being scaled, an error
+// is only returned when no metric could be collected.
+func (p *dockerProvisioner) UnitsMetrics(app provision.App) ([]provision.UnitMetric, error) {
+	containers, err := p.listContainersByApp(app.GetName())
+	if err != nil {
+		return nil, err
+	}
+	var started []container.Container
+	for _, c := range containers {
+		if c.Status == provision.StatusStarted.String() || c.Status == provision.StatusStarting.String() {
+			started = append(started, c)
+		}
+	}
+	metrics := make([]provision.UnitMetric, len(started))
+	errs := make([]error, len(started))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, unitsMetricsWorkers)
+	for i := range started {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			stats, err := p.containerStats(&started[i])
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			metrics[i] = provision.UnitMetric{
+				ID:          started[i].ID,
+				ProcessName: started[i].ProcessName,
+				CPU:         cpuUsagePercent(stats, app.GetCpuShare()),
+				Memory:      memoryUsagePercent(stats),
+			}
+		}(i)
+	}
+	wg.Wait()
+	result := make([]provision.UnitMetric, 0, len(started))
+	var lastErr error
+	for i := range started {
+		if errs[i] != nil {
+			log.Errorf("[units metrics] skipping unit %s of app %s: %s", started[i].ID, app.GetName(), errs[i])
+			lastErr = errs[i]
+			continue
+		}
+		result = append(result, metrics[i])
+	}
+	if len(result) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return result, nil
+}
+
+func (p *dockerProvisioner) containerStats(c *container.Container) (*docker.Stats, error) {
+	node, err := p.GetNodeByHost(c.HostAddr)
+	if err != nil {
+		return nil, err
+	}
+	client, err := node.Client()
+	if err != nil {
+		return nil, err
+	}
+	statsCh := make(chan *docker.Stats, 1)
+	err = client.Stats(docker.StatsOptions{
+		ID:      c.ID,
+		Stats:   statsCh,
+		Stream:  false,
+		Timeout: 10 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get stats for container %s: %s", c.ID, err)
+	}
+	stats := <-statsCh
+	if stats == nil {
+		return nil, fmt.Errorf("no stats available for container %s", c.ID)
+	}
+	return stats, nil
+}
+
+// cpuUsagePercent returns the cpu used by the container as a percentage of the
+// cpus assigned to it, a cpu share of 1024 being a full cpu. Containers
+// without a cpu share are limited by the cpus of the host.
+func cpuUsagePercent(stats *docker.Stats, cpuShare int) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemCPUUsage) - float64(stats.PreCPUStats.SystemCPUUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+	hostCPUs := len(stats.CPUStats.CPUUsage.PercpuUsage)
+	if hostCPUs == 0 {
+		hostCPUs = 1
+	}
+	limit := float64(hostCPUs)
+	if cpuShare > 0 {
+		limit = math.Min(float64(cpuShare)/1024, limit)
+	}
+	usedCPUs := cpuDelta / systemDelta * float64(hostCPUs)
+	return usedCPUs / limit * 100
+}
+
+func memoryUsagePercent(stats *docker.Stats) float64 {
+	if stats.MemoryStats.Limit == 0 {
+		return 0
+	}
+	return float64(stats.MemoryStats.Usage) / float64(stats.MemoryStats.Limit) * 100
+}
+
 func (p *dockerProvisioner) LogsEnabled(app provision.App) (bool, string, error) {
 	const (
 		logBackendsEnv      = "LOG_BACKENDS"
@@ -1268,6 +1428,10 @@ func (p *dockerProvisioner) FilterAppsByUnitStatus(apps []provision.App, status
 	return result, nil
 }
 
+// cordonedMetadataName is the node metadata set on cordoned nodes, no new
+// containers are scheduled to them.
+const cordonedMetadataName = "cordoned"
+
 var _ provision.Node = &clusterNodeWrapper{}
 var _ provision.NodeHealthChecker = &clusterNodeWrapper{}
 
@@ -1395,6 +1559,36 @@ func (p *dockerProvisioner) UpdateNode(opts provision.UpdateNodeOptions) error {
 	return err
 }
 
+func (p *dockerProvisioner) CordonNode(address string) error {
+	return p.setNodeCordoned(address, true)
+}
+
+func (p *dockerProvisioner) UncordonNode(address string) error {
+	return p.setNodeCordoned(address, false)
+}
+
+func (p *dockerProvisioner) setNodeCordoned(address string, cordoned bool) error {
+	value := ""
+	if cordoned {
+		value = "true"
+	}
+	node := cluster.Node{Address: address, Metadata: map[string]string{cordonedMetadataName: value}}
+	_, err := p.Cluster().UpdateNode(node)
+	if err == clusterStorage.ErrNoSuchNode {
+		return provision.ErrNodeNotFound
+	}
+	return err
+}
+
+func (p *dockerProvisioner) DrainNode(address string, w io.Writer) error {
+	err := p.CordonNode(address)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Node %s cordoned, no new units will be scheduled to it.\n", address)
+	return p.MoveContainers(net.URLToHost(address), "", w)
+}
+
 func (p *dockerProvisioner) GetNode(address string) (provision.Node, error) {
 	node, err := p.Cluster().GetNode(address)
 	if err != nil {
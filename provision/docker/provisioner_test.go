@@ -24,6 +24,7 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/docker-cluster/storage"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/autoscale"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/cmd"
 	"github.com/tsuru/tsuru/db"
@@ -684,6 +685,164 @@ func (s *S) TestDeployErasesOldImagesWithLongHistory(c *check.C) {
 	c.Assert(got, check.DeepEquals, expected)
 }
 
+func (s *S) TestDeployBlueGreen(c *check.C) {
+	a := app.App{
+		Name:           "appbluegreen",
+		Platform:       "python",
+		TeamOwner:      s.team.Name,
+		DeployStrategy: provision.DeployStrategyBlueGreen,
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "tsuru/app-"+a.Name+":v1", nil)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "tsuru/app-"+a.Name+":v2", nil)
+	c.Assert(err, check.IsNil)
+	w := safe.NewBuffer(nil)
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: "app", Value: a.Name},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	evt.SetLogWriter(w)
+	err = s.p.deploy(&a, "tsuru/app-"+a.Name+":v1", evt)
+	c.Assert(err, check.IsNil)
+	oldUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(oldUnits, check.HasLen, 1)
+	err = s.p.deploy(&a, "tsuru/app-"+a.Name+":v2", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(w.String(), check.Matches, `(?s).*---- Switching routes from 1 old to 1 new unit ----.*`)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(units[0].ID, check.Not(check.Equals), oldUnits[0].ID)
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{units[0].Address})
+}
+
+func (s *S) prepareCanaryDeploy(c *check.C) (*app.App, *event.Event, *safe.Buffer) {
+	a := app.App{
+		Name:           "appcanary",
+		Platform:       "python",
+		TeamOwner:      s.team.Name,
+		DeployStrategy: provision.DeployStrategyCanary,
+	}
+	err := app.CreateApp(&a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "tsuru/app-"+a.Name+":v1", nil)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(s.p, "tsuru/app-"+a.Name+":v2", nil)
+	c.Assert(err, check.IsNil)
+	w := safe.NewBuffer(nil)
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: "app", Value: a.Name},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermApp),
+	})
+	c.Assert(err, check.IsNil)
+	evt.SetLogWriter(w)
+	err = s.p.deploy(&a, "tsuru/app-"+a.Name+":v1", evt)
+	c.Assert(err, check.IsNil)
+	return &a, evt, w
+}
+
+func waitCanaryRunning(c *check.C, appName string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		state, _ := getCanaryState(appName)
+		if state == canaryStateRunning {
+			return
+		}
+		select {
+		case <-timeout:
+			c.Fatal("timeout waiting for canary to start")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (s *S) TestDeployCanaryPromote(c *check.C) {
+	defer func(d time.Duration) { canaryPollInterval = d }(canaryPollInterval)
+	canaryPollInterval = 10 * time.Millisecond
+	config.Set("docker:canary:percentage", 25)
+	defer config.Unset("docker:canary:percentage")
+	a, evt, w := s.prepareCanaryDeploy(c)
+	oldUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(oldUnits, check.HasLen, 1)
+	done := make(chan error)
+	go func() {
+		done <- s.p.deploy(a, "tsuru/app-"+a.Name+":v2", evt)
+	}()
+	waitCanaryRunning(c, a.Name)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	weights, err := routertest.FakeRouter.RoutesWeight(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.HasLen, 2)
+	c.Assert(weights[oldUnits[0].Address.Host], check.Equals, 3)
+	err = s.p.PromoteCanary(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(<-done, check.IsNil)
+	c.Assert(w.String(), check.Matches, `(?s).*---- Sending 25% of the traffic to 1 canary unit ----.*Canary promoted.*`)
+	units, err = a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(units[0].ID, check.Not(check.Equals), oldUnits[0].ID)
+	routes, err := routertest.FakeRouter.Routes(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{units[0].Address})
+	weights, err = routertest.FakeRouter.RoutesWeight(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{units[0].Address.Host: 1})
+	_, err = getCanaryState(a.Name)
+	c.Assert(err, check.Equals, provision.ErrCanaryNotFound)
+}
+
+func (s *S) TestDeployCanaryAbort(c *check.C) {
+	defer func(d time.Duration) { canaryPollInterval = d }(canaryPollInterval)
+	canaryPollInterval = 10 * time.Millisecond
+	a, evt, w := s.prepareCanaryDeploy(c)
+	oldUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	done := make(chan error)
+	go func() {
+		done <- s.p.deploy(a, "tsuru/app-"+a.Name+":v2", evt)
+	}()
+	waitCanaryRunning(c, a.Name)
+	err = s.p.AbortCanary(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(<-done, check.Equals, ErrCanaryAborted)
+	c.Assert(w.String(), check.Matches, `(?s).*Canary aborted.*Removing routes from canary units.*`)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.DeepEquals, oldUnits)
+	weights, err := routertest.FakeRouter.RoutesWeight(a.Name)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{oldUnits[0].Address.Host: 1})
+}
+
+func (s *S) TestDeployCanaryTimeout(c *check.C) {
+	defer func(d time.Duration) { canaryPollInterval = d }(canaryPollInterval)
+	canaryPollInterval = 10 * time.Millisecond
+	config.Set("docker:canary:timeout", 1)
+	defer config.Unset("docker:canary:timeout")
+	a, evt, _ := s.prepareCanaryDeploy(c)
+	oldUnits, err := a.Units()
+	c.Assert(err, check.IsNil)
+	err = s.p.deploy(a, "tsuru/app-"+a.Name+":v2", evt)
+	c.Assert(err, check.Equals, ErrCanaryTimeout)
+	units, err := a.Units()
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.DeepEquals, oldUnits)
+}
+
 func (s *S) TestProvisionerUploadDeploy(c *check.C) {
 	stopCh := s.stopContainers(s.server.URL(), 1)
 	defer func() { <-stopCh }()
@@ -1996,6 +2155,8 @@ func (s *S) TestAdminCommands(c *check.C) {
 		&autoScaleDeleteRuleCmd{},
 		&dockerLogInfo{},
 		&dockerLogUpdate{},
+		&dockerSchedulerInfo{},
+		&dockerSchedulerUpdate{},
 		&nodecontainer.NodeContainerList{},
 		&nodecontainer.NodeContainerAdd{},
 		&nodecontainer.NodeContainerInfo{},
@@ -2005,6 +2166,14 @@ func (s *S) TestAdminCommands(c *check.C) {
 		&cmd.RemovedCommand{Name: "bs-env-set", Help: "You should use `tsuru-admin node-container-update big-sibling` instead."},
 		&cmd.RemovedCommand{Name: "bs-info", Help: "You should use `tsuru-admin node-container-info big-sibling` instead."},
 		&cmd.RemovedCommand{Name: "bs-upgrade", Help: "You should use `tsuru-admin node-container-upgrade big-sibling` instead."},
+		&app.AppMigrate{},
+		&app.CertificateSet{},
+		&app.CertificateUnset{},
+		&app.CertificateList{},
+		&autoscale.AppScheduleList{},
+		&autoscale.AppScheduleAdd{},
+		&autoscale.AppScheduleUpdate{},
+		&autoscale.AppScheduleRemove{},
 	}
 	c.Assert(s.p.AdminCommands(), check.DeepEquals, expected)
 }
@@ -2863,6 +3032,50 @@ func (s *S) TestRunRestartAfterHooks(c *check.C) {
 	})
 }
 
+func (s *S) TestRunRestartAfterHooksProcessOverride(c *check.C) {
+	a := &app.App{Name: "myrestartafterapp"}
+	customData := map[string]interface{}{
+		"procfile": "web: python web.py\nworker: python worker.py",
+		"hooks": map[string]interface{}{
+			"restart": map[string]interface{}{
+				"after": []string{"cmd1", "cmd2"},
+			},
+		},
+		"processes": map[string]interface{}{
+			"worker": map[string]interface{}{
+				"hooks": map[string]interface{}{
+					"restart": map[string]interface{}{
+						"after": []string{"cmd3"},
+					},
+				},
+			},
+		},
+	}
+	err := image.SaveImageCustomData("tsuru/python:latest", customData)
+	c.Assert(err, check.IsNil)
+	err = s.storage.Apps().Insert(a)
+	c.Assert(err, check.IsNil)
+	opts := newContainerOpts{AppName: a.Name, ProcessName: "worker"}
+	container, err := s.newContainer(&opts, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(container)
+	var reqBodies [][]byte
+	s.server.CustomHandler("/containers/"+container.ID+"/exec", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+		reqBodies = append(reqBodies, data)
+		s.server.DefaultHandler().ServeHTTP(w, r)
+	}))
+	var buf bytes.Buffer
+	err = s.p.runRestartAfterHooks(container, &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(reqBodies, check.HasLen, 1)
+	var req map[string]interface{}
+	err = json.Unmarshal(reqBodies[0], &req)
+	c.Assert(err, check.IsNil)
+	c.Assert(req["Cmd"], check.DeepEquals, []interface{}{"/bin/bash", "-lc", "cmd3"})
+}
+
 func (s *S) TestShellToAnAppByContainerID(c *check.C) {
 	err := s.newFakeImage(s.p, "tsuru/app-almah", nil)
 	c.Assert(err, check.IsNil)
@@ -2939,6 +3152,65 @@ func (s *S) TestMetricEnvs(c *check.C) {
 	c.Assert(envs, check.DeepEquals, expected)
 }
 
+func (s *S) TestUnitsMetrics(c *check.C) {
+	cont1, err := s.newContainer(&newContainerOpts{AppName: "myapp", ProcessName: "web", Status: provision.StatusStarted.String()}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont1)
+	cont2, err := s.newContainer(&newContainerOpts{AppName: "myapp", ProcessName: "web", Status: provision.StatusStopped.String()}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont2)
+	s.server.PrepareStats(cont1.ID, func(string) docker.Stats {
+		var stats docker.Stats
+		stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+		stats.PreCPUStats.SystemCPUUsage = 10000
+		stats.CPUStats.CPUUsage.TotalUsage = 1500
+		stats.CPUStats.CPUUsage.PercpuUsage = []uint64{750, 750}
+		stats.CPUStats.SystemCPUUsage = 12000
+		stats.MemoryStats.Usage = 256
+		stats.MemoryStats.Limit = 1024
+		return stats
+	})
+	metrics, err := s.p.UnitsMetrics(&app.App{Name: "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []provision.UnitMetric{
+		{ID: cont1.ID, ProcessName: "web", CPU: 25, Memory: 25},
+	})
+	metrics, err = s.p.UnitsMetrics(&app.App{Name: "myapp", Plan: app.Plan{CpuShare: 256}})
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []provision.UnitMetric{
+		{ID: cont1.ID, ProcessName: "web", CPU: 200, Memory: 25},
+	})
+}
+
+func (s *S) TestUnitsMetricsSkipsUnavailableUnits(c *check.C) {
+	cont1, err := s.newContainer(&newContainerOpts{AppName: "myapp", ProcessName: "web", Status: provision.StatusStarted.String()}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont1)
+	cont2, err := s.newContainer(&newContainerOpts{AppName: "myapp", ProcessName: "worker", Status: provision.StatusStarted.String()}, nil)
+	c.Assert(err, check.IsNil)
+	defer s.removeTestContainer(cont2)
+	s.server.CustomHandler(fmt.Sprintf("/containers/%s/stats", cont2.ID), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	metrics, err := s.p.UnitsMetrics(&app.App{Name: "myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.HasLen, 1)
+	c.Assert(metrics[0].ID, check.Equals, cont1.ID)
+}
+
+func (s *S) TestCPUUsagePercent(c *check.C) {
+	var stats docker.Stats
+	stats.PreCPUStats.CPUUsage.TotalUsage = 1000
+	stats.PreCPUStats.SystemCPUUsage = 10000
+	stats.CPUStats.CPUUsage.TotalUsage = 2000
+	stats.CPUStats.CPUUsage.PercpuUsage = []uint64{1000, 1000, 0, 0}
+	stats.CPUStats.SystemCPUUsage = 14000
+	c.Assert(cpuUsagePercent(&stats, 0), check.Equals, 25.0)
+	c.Assert(cpuUsagePercent(&stats, 1024), check.Equals, 100.0)
+	c.Assert(cpuUsagePercent(&stats, 2048), check.Equals, 50.0)
+	c.Assert(cpuUsagePercent(&stats, 8192), check.Equals, 25.0)
+}
+
 func (s *S) TestAddContainerDefaultProcess(c *check.C) {
 	customData := map[string]interface{}{
 		"processes": map[string]interface{}{
@@ -3455,6 +3727,90 @@ func (s *S) TestUpdateNodeNotFound(c *check.C) {
 	c.Assert(err, check.Equals, provision.ErrNodeNotFound)
 }
 
+func (s *S) TestCordonNode(c *check.C) {
+	nodes, err := s.p.Cluster().Nodes()
+	c.Assert(err, check.IsNil)
+	err = s.p.CordonNode(nodes[0].Address)
+	c.Assert(err, check.IsNil)
+	nodes, err = s.p.Cluster().Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Metadata, check.DeepEquals, map[string]string{
+		"pool":     "test-default",
+		"cordoned": "true",
+	})
+	err = s.p.UncordonNode(nodes[0].Address)
+	c.Assert(err, check.IsNil)
+	nodes, err = s.p.Cluster().Nodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes[0].Metadata, check.DeepEquals, map[string]string{
+		"pool": "test-default",
+	})
+}
+
+func (s *S) TestCordonNodeNotFound(c *check.C) {
+	err := s.p.CordonNode("http://notfound:1234")
+	c.Assert(err, check.Equals, provision.ErrNodeNotFound)
+	err = s.p.UncordonNode("http://notfound:1234")
+	c.Assert(err, check.Equals, provision.ErrNodeNotFound)
+	err = s.p.DrainNode("http://notfound:1234", ioutil.Discard)
+	c.Assert(err, check.Equals, provision.ErrNodeNotFound)
+}
+
+func (s *S) TestDrainNode(c *check.C) {
+	p, err := s.startMultipleServersCluster()
+	c.Assert(err, check.IsNil)
+	mainDockerProvisioner = p
+	sched := &segregatedScheduler{provisioner: p}
+	p.scheduler = sched
+	oldNodes, err := p.Cluster().Nodes()
+	c.Assert(err, check.IsNil)
+	p.storage = &cluster.MapStorage{}
+	p.cluster, err = cluster.New(sched, p.storage, oldNodes...)
+	c.Assert(err, check.IsNil)
+	err = s.newFakeImage(p, "tsuru/app-myapp", nil)
+	c.Assert(err, check.IsNil)
+	appInstance := provisiontest.NewFakeApp("myapp", "python", 0)
+	appInstance.Pool = "test-default"
+	p.Provision(appInstance)
+	imageId, err := image.AppCurrentImageName(appInstance.GetName())
+	c.Assert(err, check.IsNil)
+	_, err = addContainersWithHost(&changeUnitsPipelineArgs{
+		toHost:      "localhost",
+		toAdd:       map[string]*containersToAdd{"web": {Quantity: 2}},
+		app:         appInstance,
+		imageId:     imageId,
+		provisioner: p,
+	})
+	c.Assert(err, check.IsNil)
+	appStruct := &app.App{
+		Name:     appInstance.GetName(),
+		Platform: appInstance.GetPlatform(),
+		Pool:     "test-default",
+	}
+	err = s.storage.Apps().Insert(appStruct)
+	c.Assert(err, check.IsNil)
+	var drained cluster.Node
+	for _, n := range oldNodes {
+		if net.URLToHost(n.Address) == "localhost" {
+			drained = n
+		}
+	}
+	buf := safe.NewBuffer(nil)
+	err = p.DrainNode(drained.Address, buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s)Node .* cordoned.*Moving 2 units.*`)
+	containers, err := p.listContainersByHost("localhost")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 0)
+	containers, err = p.listContainersByHost("127.0.0.1")
+	c.Assert(err, check.IsNil)
+	c.Assert(containers, check.HasLen, 2)
+	node, err := p.Cluster().GetNode(drained.Address)
+	c.Assert(err, check.IsNil)
+	c.Assert(node.Metadata["cordoned"], check.Equals, "true")
+}
+
 func (s *S) TestUpdateNodeEnableCanMoveContainers(c *check.C) {
 	nodes, err := s.p.Cluster().Nodes()
 	c.Assert(err, check.IsNil)
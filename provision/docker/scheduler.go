@@ -26,7 +26,13 @@ type segregatedScheduler struct {
 	hostMutex           sync.Mutex
 	maxMemoryRatio      float32
 	TotalMemoryMetadata string
-	provisioner         *dockerProvisioner
+	maxCpuRatio         float32
+	TotalCpuMetadata    string
+	// zoneMetadata is the node metadata key describing the failure domain
+	// (zone, rack, etc) of a node. Units of each app process are spread
+	// evenly across domains before being balanced across hosts.
+	zoneMetadata string
+	provisioner  *dockerProvisioner
 	// ignored containers is only set in provisioner returned by
 	// cloneProvisioner which will set this field to exclude some container
 	// ids from balancing (containers being removed by rebalance usually).
@@ -45,11 +51,31 @@ func (s *segregatedScheduler) Schedule(c *cluster.Cluster, opts docker.CreateCon
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
+	nodes, err = filterCordoned(nodes)
+	if err != nil {
+		return cluster.Node{}, &container.SchedulerError{Base: err}
+	}
 	nodes, err = s.filterByMemoryUsage(a, nodes, s.maxMemoryRatio, s.TotalMemoryMetadata)
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
-	node, err := s.chooseNodeToAdd(nodes, opts.Name, schedOpts.AppName, schedOpts.ProcessName)
+	nodes, err = s.filterByCpuUsage(a, nodes, s.maxCpuRatio, s.TotalCpuMetadata)
+	if err != nil {
+		return cluster.Node{}, &container.SchedulerError{Base: err}
+	}
+	strategy := schedulerStrategySpread
+	if a != nil {
+		strategy, err = schedulerStrategy(a.Pool)
+		if err != nil {
+			return cluster.Node{}, &container.SchedulerError{Base: err}
+		}
+	}
+	var node string
+	if strategy == schedulerStrategyBinpack {
+		node, err = s.chooseNodeToPack(a, nodes, opts.Name, schedOpts.ProcessName)
+	} else {
+		node, err = s.chooseNodeToAdd(nodes, opts.Name, schedOpts.AppName, schedOpts.ProcessName)
+	}
 	if err != nil {
 		return cluster.Node{}, &container.SchedulerError{Base: err}
 	}
@@ -59,26 +85,28 @@ func (s *segregatedScheduler) Schedule(c *cluster.Cluster, opts docker.CreateCon
 	return cluster.Node{Address: node}, nil
 }
 
+func filterCordoned(nodes []cluster.Node) ([]cluster.Node, error) {
+	filtered := make([]cluster.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Metadata[cordonedMetadataName] == "" {
+			filtered = append(filtered, n)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, errors.New("all nodes are cordoned")
+	}
+	return filtered, nil
+}
+
 func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.Node, maxMemoryRatio float32, TotalMemoryMetadata string) ([]cluster.Node, error) {
 	if maxMemoryRatio == 0 || TotalMemoryMetadata == "" {
 		return nodes, nil
 	}
-	hosts := make([]string, len(nodes))
-	for i := range nodes {
-		hosts[i] = net.URLToHost(nodes[i].Address)
-	}
-	containers, err := s.provisioner.ListContainers(bson.M{"hostaddr": bson.M{"$in": hosts}, "id": bson.M{"$nin": s.ignoredContainers}})
+	hosts, _ := s.nodesToHosts(nodes)
+	hostReserved, err := s.reservedMemoryByHost(hosts)
 	if err != nil {
 		return nil, err
 	}
-	hostReserved := make(map[string]int64)
-	for _, cont := range containers {
-		contApp, err := app.GetByName(cont.AppName)
-		if err != nil {
-			return nil, err
-		}
-		hostReserved[cont.HostAddr] += contApp.Plan.Memory
-	}
 	megabyte := float64(1024 * 1024)
 	nodeList := make([]cluster.Node, 0, len(nodes))
 	for _, node := range nodes {
@@ -117,6 +145,78 @@ func (s *segregatedScheduler) filterByMemoryUsage(a *app.App, nodes []cluster.No
 	return nodeList, nil
 }
 
+// reservedMemoryByHost sums the plan memory of the containers running in
+// each host.
+func (s *segregatedScheduler) reservedMemoryByHost(hosts []string) (map[string]int64, error) {
+	containers, err := s.provisioner.ListContainers(bson.M{"hostaddr": bson.M{"$in": hosts}, "id": bson.M{"$nin": s.ignoredContainers}})
+	if err != nil {
+		return nil, err
+	}
+	hostReserved := make(map[string]int64)
+	for _, cont := range containers {
+		contApp, err := app.GetByName(cont.AppName)
+		if err != nil {
+			return nil, err
+		}
+		hostReserved[cont.HostAddr] += contApp.Plan.Memory
+	}
+	return hostReserved, nil
+}
+
+func (s *segregatedScheduler) filterByCpuUsage(a *app.App, nodes []cluster.Node, maxCpuRatio float32, TotalCpuMetadata string) ([]cluster.Node, error) {
+	if maxCpuRatio == 0 || TotalCpuMetadata == "" {
+		return nodes, nil
+	}
+	hosts := make([]string, len(nodes))
+	for i := range nodes {
+		hosts[i] = net.URLToHost(nodes[i].Address)
+	}
+	containers, err := s.provisioner.ListContainers(bson.M{"hostaddr": bson.M{"$in": hosts}, "id": bson.M{"$nin": s.ignoredContainers}})
+	if err != nil {
+		return nil, err
+	}
+	hostReserved := make(map[string]int)
+	for _, cont := range containers {
+		contApp, err := app.GetByName(cont.AppName)
+		if err != nil {
+			return nil, err
+		}
+		hostReserved[cont.HostAddr] += contApp.Plan.CpuShare
+	}
+	nodeList := make([]cluster.Node, 0, len(nodes))
+	for _, node := range nodes {
+		totalCpu, _ := strconv.ParseFloat(node.Metadata[TotalCpuMetadata], 64)
+		shouldAdd := true
+		if totalCpu != 0 {
+			maxCpu := totalCpu * float64(maxCpuRatio)
+			host := net.URLToHost(node.Address)
+			nodeReserved := hostReserved[host] + a.Plan.CpuShare
+			if nodeReserved > int(maxCpu) {
+				shouldAdd = false
+				log.Errorf("Node %q has reached its cpu limit. "+
+					"Limit %d cpu shares. Reserved: %d cpu shares. Needed additional %d cpu shares",
+					host, int(maxCpu), hostReserved[host], a.Plan.CpuShare)
+			}
+		}
+		if shouldAdd {
+			nodeList = append(nodeList, node)
+		}
+	}
+	if len(nodeList) == 0 {
+		autoScaleEnabled, _ := config.GetBool("docker:auto-scale:enabled")
+		errMsg := fmt.Sprintf("no nodes found with enough cpu for container of %q: %d cpu shares",
+			a.Name, a.Plan.CpuShare)
+		if autoScaleEnabled {
+			// Allow going over quota temporarily because auto-scale will be
+			// able to detect this and automatically add a new nodes.
+			log.Errorf("WARNING: %s. Will ignore cpu restrictions.", errMsg)
+			return nodes, nil
+		}
+		return nil, errors.New(errMsg)
+	}
+	return nodeList, nil
+}
+
 type nodeAggregate struct {
 	HostAddr string `bson:"_id"`
 	Count    int
@@ -233,6 +333,60 @@ func (s *segregatedScheduler) chooseNodeToAdd(nodes []cluster.Node, contName str
 	return chosenNode, err
 }
 
+// chooseNodeToPack finds the node with the most reserved memory that is still
+// able to fit a container of the app, filling nodes before using new ones. If
+// memory information is not available or no node is able to fit the container
+// it falls back to chooseNodeToAdd.
+func (s *segregatedScheduler) chooseNodeToPack(a *app.App, nodes []cluster.Node, contName, process string) (string, error) {
+	chosenNode, err := s.packNode(a, nodes, contName)
+	if err != nil || chosenNode != "" {
+		return chosenNode, err
+	}
+	return s.chooseNodeToAdd(nodes, contName, a.Name, process)
+}
+
+func (s *segregatedScheduler) packNode(a *app.App, nodes []cluster.Node, contName string) (string, error) {
+	if s.maxMemoryRatio == 0 || s.TotalMemoryMetadata == "" {
+		return "", nil
+	}
+	log.Debugf("[scheduler] Possible nodes for packing container %s: %#v", contName, nodes)
+	s.hostMutex.Lock()
+	defer s.hostMutex.Unlock()
+	hosts, hostsMap := s.nodesToHosts(nodes)
+	hostReserved, err := s.reservedMemoryByHost(hosts)
+	if err != nil {
+		return "", err
+	}
+	var chosenHost string
+	var maxReserved int64 = -1
+	for _, node := range nodes {
+		totalMemory, _ := strconv.ParseFloat(node.Metadata[s.TotalMemoryMetadata], 64)
+		if totalMemory == 0 {
+			continue
+		}
+		host := net.URLToHost(node.Address)
+		reserved := hostReserved[host]
+		if reserved+a.Plan.Memory > int64(totalMemory*float64(s.maxMemoryRatio)) {
+			continue
+		}
+		if reserved > maxReserved {
+			maxReserved = reserved
+			chosenHost = host
+		}
+	}
+	if chosenHost == "" {
+		return "", nil
+	}
+	chosenNode := hostsMap[chosenHost]
+	log.Debugf("[scheduler] Chosen node for packing container %s: %#v", contName, chosenNode)
+	if contName != "" {
+		coll := s.provisioner.Collection()
+		defer coll.Close()
+		err = coll.Update(bson.M{"name": contName}, bson.M{"$set": bson.M{"hostaddr": chosenHost}})
+	}
+	return chosenNode, err
+}
+
 // chooseContainerToRemove finds a container from the the node with maximum
 // number of containers and returns it
 func (s *segregatedScheduler) chooseContainerToRemove(nodes []cluster.Node, appName, process string) (string, error) {
@@ -260,9 +414,26 @@ func appGroupCount(hostGroups map[string]int, appCountHost map[string]int) map[s
 	return result
 }
 
+func (s *segregatedScheduler) hostZones(nodes []cluster.Node) map[string]int {
+	zoneIndexes := map[string]int{}
+	hostZoneMap := map[string]int{}
+	for _, n := range nodes {
+		zone := n.Metadata[s.zoneMetadata]
+		idx, ok := zoneIndexes[zone]
+		if !ok {
+			idx = len(zoneIndexes)
+			zoneIndexes[zone] = idx
+		}
+		hostZoneMap[net.URLToHost(n.Address)] = idx
+	}
+	return hostZoneMap
+}
+
 // Find the host with the minimum (good to add a new container) and maximum
-// (good to remove a container) value for the pair [(number of containers for
-// app-process), (number of containers in host)]
+// (good to remove a container) value for the tuple [(number of containers for
+// app-process in zone), (number of containers for app-process in metadata
+// group), (number of containers for app-process), (number of containers in
+// host)]. The zone entry is only considered if a zone metadata is configured.
 func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process string) (string, string, error) {
 	nodesPtr := make([]*cluster.Node, len(nodes))
 	for i := range nodes {
@@ -288,6 +459,10 @@ func (s *segregatedScheduler) minMaxNodes(nodes []cluster.Node, appName, process
 		return "", "", err
 	}
 	priorityEntries := []map[string]int{appGroupCount(hostGroupMap, appCountMap), appCountMap, hostCountMap}
+	if s.zoneMetadata != "" {
+		zoneCount := appGroupCount(s.hostZones(nodes), appCountMap)
+		priorityEntries = append([]map[string]int{zoneCount}, priorityEntries...)
+	}
 	var minHost, maxHost string
 	var minScore uint64 = math.MaxUint64
 	var maxScore uint64 = 0
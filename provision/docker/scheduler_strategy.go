@@ -0,0 +1,65 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import (
+	"errors"
+
+	"github.com/tsuru/tsuru/scopedconfig"
+)
+
+const (
+	schedulerStrategySpread   = "spread"
+	schedulerStrategyBinpack  = "binpack"
+	schedulerConfigCollection = "scheduler"
+)
+
+var ErrInvalidSchedulerStrategy = errors.New("invalid scheduler strategy, must be spread or binpack")
+
+type schedulerConfig struct {
+	Strategy string
+}
+
+func loadSchedulerConfig() *scopedconfig.ScopedConfig {
+	conf := scopedconfig.FindScopedConfig(schedulerConfigCollection)
+	conf.ShallowMerge = true
+	return conf
+}
+
+// schedulerStrategy returns the scheduling strategy used in the pool,
+// defaulting to spread.
+func schedulerStrategy(pool string) (string, error) {
+	var conf schedulerConfig
+	err := loadSchedulerConfig().Load(pool, &conf)
+	if err != nil {
+		return "", err
+	}
+	if conf.Strategy == "" {
+		return schedulerStrategySpread, nil
+	}
+	return conf.Strategy, nil
+}
+
+func schedulerConfigLoadAll() (map[string]schedulerConfig, error) {
+	var all map[string]schedulerConfig
+	err := loadSchedulerConfig().LoadAll(&all)
+	return all, err
+}
+
+func (c *schedulerConfig) validate() error {
+	switch c.Strategy {
+	case schedulerStrategySpread, schedulerStrategyBinpack:
+		return nil
+	}
+	return ErrInvalidSchedulerStrategy
+}
+
+func (c *schedulerConfig) save(pool string) error {
+	err := c.validate()
+	if err != nil {
+		return err
+	}
+	return loadSchedulerConfig().Save(pool, *c)
+}
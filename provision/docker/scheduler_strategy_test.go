@@ -0,0 +1,55 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docker
+
+import "gopkg.in/check.v1"
+
+func (s *S) TestSchedulerStrategyDefault(c *check.C) {
+	strategy, err := schedulerStrategy("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.Equals, schedulerStrategySpread)
+}
+
+func (s *S) TestSchedulerStrategyForPool(c *check.C) {
+	conf := schedulerConfig{Strategy: schedulerStrategyBinpack}
+	err := conf.save("pool1")
+	c.Assert(err, check.IsNil)
+	strategy, err := schedulerStrategy("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.Equals, schedulerStrategyBinpack)
+	strategy, err = schedulerStrategy("pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.Equals, schedulerStrategySpread)
+}
+
+func (s *S) TestSchedulerStrategyInheritsDefault(c *check.C) {
+	conf := schedulerConfig{Strategy: schedulerStrategyBinpack}
+	err := conf.save("")
+	c.Assert(err, check.IsNil)
+	strategy, err := schedulerStrategy("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.Equals, schedulerStrategyBinpack)
+	conf = schedulerConfig{Strategy: schedulerStrategySpread}
+	err = conf.save("pool1")
+	c.Assert(err, check.IsNil)
+	strategy, err = schedulerStrategy("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(strategy, check.Equals, schedulerStrategySpread)
+	all, err := schedulerConfigLoadAll()
+	c.Assert(err, check.IsNil)
+	c.Assert(all, check.DeepEquals, map[string]schedulerConfig{
+		"":      {Strategy: schedulerStrategyBinpack},
+		"pool1": {Strategy: schedulerStrategySpread},
+	})
+}
+
+func (s *S) TestSchedulerConfigSaveInvalidStrategy(c *check.C) {
+	conf := schedulerConfig{Strategy: "random"}
+	err := conf.save("pool1")
+	c.Assert(err, check.Equals, ErrInvalidSchedulerStrategy)
+	conf = schedulerConfig{}
+	err = conf.save("pool1")
+	c.Assert(err, check.Equals, ErrInvalidSchedulerStrategy)
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -17,6 +18,7 @@ import (
 	"github.com/tsuru/docker-cluster/cluster"
 	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/net"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/provision/docker/container"
 	"gopkg.in/check.v1"
@@ -80,6 +82,34 @@ func (s *S) TestSchedulerSchedule(c *check.C) {
 	c.Check(node.Address, check.Equals, localURL)
 }
 
+func (s *S) TestSchedulerScheduleSkipsCordonedNodes(c *check.C) {
+	a1 := app.App{Name: "impius", Teams: []string{"tsuruteam"}, Pool: "pool1"}
+	err := s.storage.Apps().Insert(a1)
+	c.Assert(err, check.IsNil)
+	o := provision.AddPoolOptions{Name: "pool1"}
+	err = provision.AddPool(o)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("pool1")
+	scheduler := segregatedScheduler{provisioner: s.p}
+	clusterInstance, err := cluster.New(&scheduler, &cluster.MapStorage{},
+		cluster.Node{Address: "http://server1:1234", Metadata: map[string]string{"pool": "pool1"}},
+		cluster.Node{Address: "http://server2:1234", Metadata: map[string]string{"pool": "pool1", "cordoned": "true"}},
+	)
+	c.Assert(err, check.IsNil)
+	s.p.cluster = clusterInstance
+	for i := 0; i < 3; i++ {
+		opts := docker.CreateContainerOptions{}
+		node, err := scheduler.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: a1.Name, ProcessName: "web"})
+		c.Assert(err, check.IsNil)
+		c.Assert(node.Address, check.Equals, "http://server1:1234")
+	}
+	_, err = clusterInstance.UpdateNode(cluster.Node{Address: "http://server1:1234", Metadata: map[string]string{"cordoned": "true"}})
+	c.Assert(err, check.IsNil)
+	opts := docker.CreateContainerOptions{}
+	_, err = scheduler.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: a1.Name, ProcessName: "web"})
+	c.Assert(err, check.ErrorMatches, ".*all nodes are cordoned.*")
+}
+
 func (s *S) TestSchedulerScheduleNoName(c *check.C) {
 	a1 := app.App{Name: "impius", Teams: []string{"tsuruteam", "nodockerforme"}, Pool: "pool1"}
 	a2 := app.App{Name: "mirror", Teams: []string{"tsuruteam"}, Pool: "pool1"}
@@ -239,6 +269,168 @@ func (s *S) TestSchedulerScheduleWithMemoryAwareness(c *check.C) {
 	c.Assert(node, check.DeepEquals, cluster.Node{})
 }
 
+func (s *S) TestSchedulerScheduleWithCpuAwareness(c *check.C) {
+	logBuf := bytes.NewBuffer(nil)
+	log.SetLogger(log.NewWriterLogger(logBuf, false))
+	defer log.SetLogger(nil)
+	app1 := app.App{Name: "skyrim", Plan: app.Plan{CpuShare: 60}, Pool: "mypool"}
+	err := s.storage.Apps().Insert(app1)
+	c.Assert(err, check.IsNil)
+	defer s.storage.Apps().Remove(bson.M{"name": app1.Name})
+	app2 := app.App{Name: "oblivion", Plan: app.Plan{CpuShare: 20}, Pool: "mypool"}
+	err = s.storage.Apps().Insert(app2)
+	c.Assert(err, check.IsNil)
+	defer s.storage.Apps().Remove(bson.M{"name": app2.Name})
+	segSched := segregatedScheduler{
+		maxCpuRatio:      0.8,
+		TotalCpuMetadata: "totalCpu",
+		provisioner:      s.p,
+	}
+	o := provision.AddPoolOptions{Name: "mypool"}
+	err = provision.AddPool(o)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("mypool")
+	server1, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server1.Stop()
+	server2, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server2.Stop()
+	localURL := strings.Replace(server2.URL(), "127.0.0.1", "localhost", -1)
+	clusterInstance, err := cluster.New(&segSched, &cluster.MapStorage{},
+		cluster.Node{Address: server1.URL(), Metadata: map[string]string{
+			"totalCpu": "100",
+			"pool":     "mypool",
+		}},
+		cluster.Node{Address: localURL, Metadata: map[string]string{
+			"totalCpu": "100",
+			"pool":     "mypool",
+		}},
+	)
+	c.Assert(err, check.Equals, nil)
+	s.p.cluster = clusterInstance
+	cont1 := container.Container{ID: "pre1", Name: "existingUnit1", AppName: "skyrim", HostAddr: "127.0.0.1"}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	defer contColl.RemoveAll(bson.M{"appname": "skyrim"})
+	defer contColl.RemoveAll(bson.M{"appname": "oblivion"})
+	err = contColl.Insert(cont1)
+	c.Assert(err, check.Equals, nil)
+	for i := 0; i < 5; i++ {
+		cont := container.Container{ID: strconv.Itoa(i), Name: fmt.Sprintf("unit%d", i), AppName: "oblivion"}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		opts := docker.CreateContainerOptions{
+			Name: cont.Name,
+		}
+		node, schedErr := segSched.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: cont.AppName, ProcessName: "web"})
+		c.Assert(schedErr, check.IsNil)
+		c.Assert(node, check.NotNil)
+	}
+	n, err := contColl.Find(bson.M{"hostaddr": "127.0.0.1"}).Count()
+	c.Assert(err, check.Equals, nil)
+	c.Check(n, check.Equals, 2)
+	n, err = contColl.Find(bson.M{"hostaddr": "localhost"}).Count()
+	c.Assert(err, check.Equals, nil)
+	c.Check(n, check.Equals, 4)
+	n, err = contColl.Find(bson.M{"hostaddr": "127.0.0.1", "appname": "oblivion"}).Count()
+	c.Assert(err, check.Equals, nil)
+	c.Check(n, check.Equals, 1)
+	n, err = contColl.Find(bson.M{"hostaddr": "localhost", "appname": "oblivion"}).Count()
+	c.Assert(err, check.Equals, nil)
+	c.Check(n, check.Equals, 4)
+	cont := container.Container{ID: "post-error", Name: "post-error-1", AppName: "oblivion"}
+	err = contColl.Insert(cont)
+	c.Assert(err, check.IsNil)
+	opts := docker.CreateContainerOptions{
+		Name: cont.Name,
+	}
+	node, err := segSched.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: cont.AppName, ProcessName: "web"})
+	c.Assert(err, check.ErrorMatches, `.*no nodes found with enough cpu for container of "oblivion": 20 cpu shares.*`)
+	c.Assert(node, check.DeepEquals, cluster.Node{})
+}
+
+func (s *S) TestSchedulerScheduleBinpack(c *check.C) {
+	app1 := app.App{Name: "oblivion", Plan: app.Plan{Memory: 20000}, Pool: "mypool"}
+	err := s.storage.Apps().Insert(app1)
+	c.Assert(err, check.IsNil)
+	defer s.storage.Apps().Remove(bson.M{"name": app1.Name})
+	conf := schedulerConfig{Strategy: schedulerStrategyBinpack}
+	err = conf.save("mypool")
+	c.Assert(err, check.IsNil)
+	segSched := segregatedScheduler{
+		maxMemoryRatio:      0.8,
+		TotalMemoryMetadata: "totalMemory",
+		provisioner:         s.p,
+	}
+	o := provision.AddPoolOptions{Name: "mypool"}
+	err = provision.AddPool(o)
+	c.Assert(err, check.IsNil)
+	defer provision.RemovePool("mypool")
+	server1, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server1.Stop()
+	server2, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer server2.Stop()
+	localURL := strings.Replace(server2.URL(), "127.0.0.1", "localhost", -1)
+	clusterInstance, err := cluster.New(&segSched, &cluster.MapStorage{},
+		cluster.Node{Address: server1.URL(), Metadata: map[string]string{
+			"totalMemory": "100000",
+			"pool":        "mypool",
+		}},
+		cluster.Node{Address: localURL, Metadata: map[string]string{
+			"totalMemory": "100000",
+			"pool":        "mypool",
+		}},
+	)
+	c.Assert(err, check.Equals, nil)
+	s.p.cluster = clusterInstance
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	defer contColl.RemoveAll(bson.M{"appname": "oblivion"})
+	schedule := func(i int) string {
+		cont := container.Container{ID: strconv.Itoa(i), Name: fmt.Sprintf("unit%d", i), AppName: "oblivion"}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		opts := docker.CreateContainerOptions{Name: cont.Name}
+		node, schedErr := segSched.Schedule(clusterInstance, opts, &container.SchedulerOpts{AppName: cont.AppName, ProcessName: "web"})
+		c.Assert(schedErr, check.IsNil)
+		return node.Address
+	}
+	packedNode := schedule(0)
+	for i := 1; i < 4; i++ {
+		c.Assert(schedule(i), check.Equals, packedNode)
+	}
+	c.Assert(schedule(4), check.Not(check.Equals), packedNode)
+	n, err := contColl.Find(bson.M{"hostaddr": net.URLToHost(packedNode)}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 4)
+}
+
+func (s *S) TestChooseNodeToPackWithoutMemoryInformation(c *check.C) {
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234"},
+		{Address: "http://server2:1234"},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	sched := segregatedScheduler{provisioner: s.p}
+	a := &app.App{Name: "anomander", Plan: app.Plan{Memory: 20000}}
+	for i := 0; i < 2; i++ {
+		cont := container.Container{Name: fmt.Sprintf("unit%d", i), AppName: a.Name, ProcessName: "web"}
+		err := contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		_, err = sched.chooseNodeToPack(a, nodes, cont.Name, "web")
+		c.Assert(err, check.IsNil)
+	}
+	for _, host := range []string{"server1", "server2"} {
+		n, err := contColl.Find(bson.M{"hostaddr": host}).Count()
+		c.Assert(err, check.IsNil)
+		c.Assert(n, check.Equals, 1)
+	}
+}
+
 func (s *S) TestSchedulerScheduleWithMemoryAwarenessWithAutoScale(c *check.C) {
 	config.Set("docker:auto-scale:enabled", true)
 	defer config.Unset("docker:auto-scale:enabled")
@@ -512,6 +704,98 @@ func (s *S) TestChooseNodeDistributesNodesConsideringMetadata(c *check.C) {
 	c.Assert(n3, check.Equals, 1)
 }
 
+func (s *S) TestChooseNodeDistributesNodesConsideringZones(c *check.C) {
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{
+			"zone": "a",
+			"type": "x",
+		}},
+		{Address: "http://server2:1234", Metadata: map[string]string{
+			"zone": "a",
+			"type": "y",
+		}},
+		{Address: "http://server3:1234", Metadata: map[string]string{
+			"zone": "b",
+			"type": "x",
+		}},
+		{Address: "http://server4:1234", Metadata: map[string]string{
+			"zone": "b",
+			"type": "y",
+		}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	sched := segregatedScheduler{provisioner: s.p, zoneMetadata: "zone"}
+	for i := 0; i < 2; i++ {
+		cont := container.Container{Name: fmt.Sprintf("unit%d", i), AppName: "anomander", ProcessName: "rake"}
+		err := contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		node, err := sched.chooseNodeToAdd(nodes, cont.Name, "anomander", "rake")
+		c.Assert(err, check.IsNil)
+		c.Assert(node, check.Not(check.Equals), "")
+	}
+	n1, err := contColl.Find(bson.M{"hostaddr": bson.M{"$in": []string{"server1", "server2"}}}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n1, check.Equals, 1)
+	n2, err := contColl.Find(bson.M{"hostaddr": bson.M{"$in": []string{"server3", "server4"}}}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n2, check.Equals, 1)
+	for i := 2; i < 4; i++ {
+		cont := container.Container{Name: fmt.Sprintf("unit%d", i), AppName: "anomander", ProcessName: "rake"}
+		err = contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+		_, err = sched.chooseNodeToAdd(nodes, cont.Name, "anomander", "rake")
+		c.Assert(err, check.IsNil)
+	}
+	for _, host := range []string{"server1", "server2", "server3", "server4"} {
+		n, err := contColl.Find(bson.M{"hostaddr": host}).Count()
+		c.Assert(err, check.IsNil)
+		c.Assert(n, check.Equals, 1)
+	}
+}
+
+func (s *S) TestChooseContainerToBeRemovedConsideringZones(c *check.C) {
+	nodes := []cluster.Node{
+		{Address: "http://server1:1234", Metadata: map[string]string{"zone": "a", "type": "x"}},
+		{Address: "http://server2:1234", Metadata: map[string]string{"zone": "a", "type": "y"}},
+		{Address: "http://server3:1234", Metadata: map[string]string{"zone": "a", "type": "z"}},
+		{Address: "http://server4:1234", Metadata: map[string]string{"zone": "b", "type": "x"}},
+	}
+	contColl := s.p.Collection()
+	defer contColl.Close()
+	conts := []container.Container{
+		{ID: "pre1", Name: "existingUnit1", AppName: "coolapp9", HostAddr: "server1", ProcessName: "web"},
+		{ID: "pre2", Name: "existingUnit2", AppName: "coolapp9", HostAddr: "server2", ProcessName: "web"},
+		{ID: "pre3", Name: "existingUnit3", AppName: "coolapp9", HostAddr: "server3", ProcessName: "web"},
+		{ID: "pre4", Name: "existingUnit4", AppName: "coolapp9", HostAddr: "server4", ProcessName: "web"},
+		{ID: "pre5", Name: "existingUnit5", AppName: "coolapp9", HostAddr: "server4", ProcessName: "web"},
+	}
+	for _, cont := range conts {
+		err := contColl.Insert(cont)
+		c.Assert(err, check.IsNil)
+	}
+	sched := segregatedScheduler{provisioner: s.p}
+	id, err := sched.chooseContainerToRemove(nodes, "coolapp9", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(id, check.Matches, "pre[45]")
+	sched.zoneMetadata = "zone"
+	id, err = sched.chooseContainerToRemove(nodes, "coolapp9", "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(id, check.Matches, "pre[123]")
+}
+
+func (s *S) TestCloneProvisionerKeepsZoneMetadata(c *check.C) {
+	s.p.scheduler.zoneMetadata = "zone"
+	defer func() { s.p.scheduler.zoneMetadata = "" }()
+	cloned, err := s.p.cloneProvisioner(nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(cloned.scheduler.zoneMetadata, check.Equals, "zone")
+	dry, err := s.p.dryMode(nil)
+	c.Assert(err, check.IsNil)
+	defer dry.stopDryMode()
+	c.Assert(dry.scheduler.zoneMetadata, check.Equals, "zone")
+}
+
 func (s *S) TestChooseContainerToBeRemoved(c *check.C) {
 	nodes := []cluster.Node{
 		{Address: "http://server1:1234"},
@@ -86,13 +86,14 @@ func LeanContainerCmds(processName, imageId string, app provision.App) ([]string
 	if err != nil {
 		return nil, "", err
 	}
+	if processName == "" {
+		processName = "web"
+	}
+	yamlData = yamlData.ProcessData(processName)
 	before := strings.Join(yamlData.Hooks.Restart.Before, " && ")
 	if before != "" {
 		before += " && "
 	}
-	if processName == "" {
-		processName = "web"
-	}
 	return []string{
 		"/bin/sh",
 		"-lc",
@@ -120,6 +120,39 @@ func (s *S) TestRunLeanContainersCmdHooks(c *check.C) {
 	c.Assert(cmds, check.DeepEquals, expected)
 }
 
+func (s *S) TestRunLeanContainersCmdProcessHooks(c *check.C) {
+	imageId := "tsuru/app-sample"
+	customData := map[string]interface{}{
+		"hooks": map[string]interface{}{
+			"restart": map[string]interface{}{
+				"before": []string{"cmd1", "cmd2"},
+			},
+		},
+		"procfile": "web: python web.py\nworker: python worker.py",
+		"processes": map[string]interface{}{
+			"worker": map[string]interface{}{
+				"hooks": map[string]interface{}{
+					"restart": map[string]interface{}{
+						"before": []string{"cmd3"},
+					},
+				},
+			},
+		},
+	}
+	err := image.SaveImageCustomData(imageId, customData)
+	c.Assert(err, check.IsNil)
+	cmds, process, err := LeanContainerCmds("worker", imageId, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(process, check.Equals, "worker")
+	expected := []string{"/bin/sh", "-lc", "[ -d /home/application/current ] && cd /home/application/current; cmd3 && exec python worker.py"}
+	c.Assert(cmds, check.DeepEquals, expected)
+	cmds, process, err = LeanContainerCmds("web", imageId, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(process, check.Equals, "web")
+	expected = []string{"/bin/sh", "-lc", "[ -d /home/application/current ] && cd /home/application/current; cmd1 && cmd2 && exec python web.py"}
+	c.Assert(cmds, check.DeepEquals, expected)
+}
+
 func (s *S) TestRunLeanContainersCmdNoProcesses(c *check.C) {
 	imageId := "tsuru/app-sample"
 	customData := map[string]interface{}{}
@@ -0,0 +1,142 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dockercommon
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
+)
+
+const (
+	// DefaultUnitPort is the port units listen to, unless the image was
+	// deployed with an exposed port.
+	DefaultUnitPort = 8888
+	WebProcessName  = "web"
+)
+
+// ImageProcesses returns the processes registered in the metadata of the
+// image. A process named "web", without a command, is assumed for images
+// without registered processes.
+func ImageProcesses(imgID string) (map[string]string, error) {
+	data, err := image.GetImageCustomData(imgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Processes) == 0 {
+		return map[string]string{WebProcessName: ""}, nil
+	}
+	return data.Processes, nil
+}
+
+// AppProcesses returns the processes declared in the current image of the
+// app, as returned by ImageProcesses.
+func AppProcesses(a provision.App) (map[string]string, error) {
+	imgID, err := image.AppCurrentImageName(a.GetName())
+	if err != nil {
+		if err == image.ErrNoImagesAvailable {
+			return nil, errors.Errorf("no images available for app %q, a deploy is required", a.GetName())
+		}
+		return nil, err
+	}
+	return ImageProcesses(imgID)
+}
+
+// ProcessesToChange returns the list of processes affected by an operation.
+// An empty process means every process of the app.
+func ProcessesToChange(a provision.App, process string) ([]string, error) {
+	processes, err := AppProcesses(a)
+	if err != nil {
+		return nil, err
+	}
+	if process != "" {
+		if _, ok := processes[process]; !ok {
+			return nil, provision.InvalidProcessError{Msg: fmt.Sprintf("process %q not found in app", process)}
+		}
+		return []string{process}, nil
+	}
+	names := make([]string, 0, len(processes))
+	for name := range processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SingleProcess returns the process to be changed when adding or removing
+// units. The process may only be omitted when the app has a single process.
+func SingleProcess(a provision.App, process string) (string, error) {
+	processes, err := ProcessesToChange(a, process)
+	if err != nil {
+		return "", err
+	}
+	if len(processes) > 1 {
+		return "", provision.InvalidProcessError{Msg: "no process name specified and more than one declared in Procfile"}
+	}
+	return processes[0], nil
+}
+
+// UnitPort returns the port the units of the image listen to, which is the
+// port exposed by images deployed with ImageDeploy or DefaultUnitPort.
+func UnitPort(data image.ImageMetadata) int {
+	if data.ExposedPort == "" {
+		return DefaultUnitPort
+	}
+	port, err := strconv.Atoi(strings.Split(data.ExposedPort, "/")[0])
+	if err != nil || port <= 0 {
+		return DefaultUnitPort
+	}
+	return port
+}
+
+// EnvsForApp returns the environment variables of the units of the app: the
+// envs set in the app, sorted by name, followed by TSURU_HOST and the port the
+// unit must listen to.
+func EnvsForApp(a provision.App, unitPort int) []bind.EnvVar {
+	appEnvs := a.Envs()
+	names := make([]string, 0, len(appEnvs))
+	for name := range appEnvs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	envs := make([]bind.EnvVar, 0, len(names)+3)
+	for _, name := range names {
+		envs = append(envs, appEnvs[name])
+	}
+	host, _ := config.GetString("host")
+	port := strconv.Itoa(unitPort)
+	return append(envs, []bind.EnvVar{
+		{Name: "TSURU_HOST", Value: host},
+		{Name: "port", Value: port},
+		{Name: "PORT", Value: port},
+	}...)
+}
+
+// RoutableUnits filters the units that should be added to the router of the
+// app, the ones running its web process.
+func RoutableUnits(a provision.App, units []provision.Unit) ([]provision.Unit, error) {
+	imgID, err := image.AppCurrentImageName(a.GetName())
+	if err != nil && err != image.ErrNoImagesAvailable {
+		return nil, err
+	}
+	webProcess, err := image.GetImageWebProcessName(imgID)
+	if err != nil {
+		return nil, err
+	}
+	var routableUnits []provision.Unit
+	for _, u := range units {
+		if webProcess == "" || u.ProcessName == webProcess {
+			routableUnits = append(routableUnits, u)
+		}
+	}
+	return routableUnits, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dockercommon
+
+import (
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/bind"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestProcessesToChange(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	err := image.SaveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{
+		"processes": map[string]interface{}{"worker": "python worker.py", "web": "python web.py"},
+	})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.GetName(), "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	processes, err := ProcessesToChange(a, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(processes, check.DeepEquals, []string{"web", "worker"})
+	processes, err = ProcessesToChange(a, "worker")
+	c.Assert(err, check.IsNil)
+	c.Assert(processes, check.DeepEquals, []string{"worker"})
+	_, err = ProcessesToChange(a, "clock")
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+	_, err = SingleProcess(a, "")
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+}
+
+func (s *S) TestProcessesToChangeNoRegisteredProcesses(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	err := image.SaveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.GetName(), "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	processes, err := ProcessesToChange(a, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(processes, check.DeepEquals, []string{"web"})
+	process, err := SingleProcess(a, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(process, check.Equals, "web")
+}
+
+func (s *S) TestProcessesToChangeNoImage(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	_, err := ProcessesToChange(a, "")
+	c.Assert(err, check.ErrorMatches, `no images available for app "myapp", a deploy is required`)
+}
+
+func (s *S) TestUnitPort(c *check.C) {
+	c.Assert(UnitPort(image.ImageMetadata{}), check.Equals, DefaultUnitPort)
+	c.Assert(UnitPort(image.ImageMetadata{ExposedPort: "80/tcp"}), check.Equals, 80)
+	c.Assert(UnitPort(image.ImageMetadata{ExposedPort: "invalid"}), check.Equals, DefaultUnitPort)
+}
+
+func (s *S) TestEnvsForApp(c *check.C) {
+	config.Set("host", "tsuru_host")
+	defer config.Unset("host")
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	a.SetEnv(bind.EnvVar{Name: "B", Value: "2"})
+	a.SetEnv(bind.EnvVar{Name: "A", Value: "1"})
+	c.Assert(EnvsForApp(a, 80), check.DeepEquals, []bind.EnvVar{
+		{Name: "A", Value: "1"},
+		{Name: "B", Value: "2"},
+		{Name: "TSURU_HOST", Value: "tsuru_host"},
+		{Name: "port", Value: "80"},
+		{Name: "PORT", Value: "80"},
+	})
+}
+
+func (s *S) TestRoutableUnits(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	err := image.SaveImageCustomData("tsuru/app-myapp:v1", map[string]interface{}{
+		"processes": map[string]interface{}{"worker": "python worker.py", "web": "python web.py"},
+	})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.GetName(), "tsuru/app-myapp:v1")
+	c.Assert(err, check.IsNil)
+	units := []provision.Unit{
+		{ID: "u1", ProcessName: "web"},
+		{ID: "u2", ProcessName: "worker"},
+	}
+	routable, err := RoutableUnits(a, units)
+	c.Assert(err, check.IsNil)
+	c.Assert(routable, check.DeepEquals, []provision.Unit{{ID: "u1", ProcessName: "web"}})
+}
@@ -0,0 +1,21 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dockercommon
+
+import (
+	"github.com/fsouza/go-dockerclient"
+	"github.com/tsuru/config"
+)
+
+// RegistryAuthConfig returns the credentials used to push and pull images
+// from the tsuru registry, as set in the docker:registry-auth config.
+func RegistryAuthConfig() docker.AuthConfiguration {
+	var authConfig docker.AuthConfiguration
+	authConfig.Email, _ = config.GetString("docker:registry-auth:email")
+	authConfig.Username, _ = config.GetString("docker:registry-auth:username")
+	authConfig.Password, _ = config.GetString("docker:registry-auth:password")
+	authConfig.ServerAddress, _ = config.GetString("docker:registry")
+	return authConfig
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	tsuruNet "github.com/tsuru/tsuru/net"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// UnreachableError is returned by healthchecks that couldn't connect to the
+// unit at all, which usually means the unit is still starting. Retries don't
+// count it as a failed healthcheck.
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return e.Err.Error()
+}
+
+// CheckAddr runs a single attempt of an http or tcp healthcheck against addr,
+// in the host:port format, failing if it doesn't finish within timeout. HTTP
+// healthchecks without a path check the root path. Command healthchecks must
+// run inside the unit and are not supported by CheckAddr.
+func (hc TsuruYamlHealthcheck) CheckAddr(addr string, timeout time.Duration) error {
+	switch hc.CheckType() {
+	case HealthcheckTypeHTTP:
+		return hc.checkHTTP(addr, timeout)
+	case HealthcheckTypeTCP:
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return &UnreachableError{Err: err}
+		}
+		return conn.Close()
+	default:
+		return fmt.Errorf("healthcheck type %q cannot be checked by address", hc.Type)
+	}
+}
+
+func (hc TsuruYamlHealthcheck) checkHTTP(addr string, timeout time.Duration) error {
+	method := strings.ToUpper(hc.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	status := hc.Status
+	if status == 0 && hc.Match == "" {
+		status = http.StatusOK
+	}
+	var matchRE *regexp.Regexp
+	if hc.Match != "" {
+		var err error
+		matchRE, err = regexp.Compile("(?s)" + hc.Match)
+		if err != nil {
+			return err
+		}
+	}
+	path := strings.TrimSpace(strings.TrimLeft(hc.Path, "/"))
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s/%s", addr, path), nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rsp, err := ctxhttp.Do(ctx, tsuruNet.Dial5Full60ClientNoKeepAlive, req)
+	if err != nil {
+		return &UnreachableError{Err: err}
+	}
+	defer rsp.Body.Close()
+	if status != 0 && rsp.StatusCode != status {
+		return fmt.Errorf("wrong status code, expected %d, got: %d", status, rsp.StatusCode)
+	}
+	if matchRE != nil {
+		body, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			return err
+		}
+		if !matchRE.Match(body) {
+			return fmt.Errorf("unexpected result, expected %q, got: %s", hc.Match, string(body))
+		}
+	}
+	return nil
+}
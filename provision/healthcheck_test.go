@@ -0,0 +1,56 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckCheckAddrHTTP(c *check.C) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("WORKING"))
+	}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+	err := TsuruYamlHealthcheck{}.CheckAddr(addr, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(path, check.Equals, "/")
+	err = TsuruYamlHealthcheck{Path: "/hc", Match: "WORK.*"}.CheckAddr(addr, time.Second)
+	c.Assert(err, check.IsNil)
+	c.Assert(path, check.Equals, "/hc")
+	err = TsuruYamlHealthcheck{Path: "/hc", Match: "OK"}.CheckAddr(addr, time.Second)
+	c.Assert(err, check.ErrorMatches, `unexpected result, expected "OK", got: WORKING`)
+	err = TsuruYamlHealthcheck{Path: "/fail"}.CheckAddr(addr, time.Second)
+	c.Assert(err, check.ErrorMatches, "wrong status code, expected 200, got: 500")
+	err = TsuruYamlHealthcheck{Path: "/fail", Status: 500}.CheckAddr(addr, time.Second)
+	c.Assert(err, check.IsNil)
+}
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckCheckAddrTCP(c *check.C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, check.IsNil)
+	addr := l.Addr().String()
+	hc := TsuruYamlHealthcheck{Type: "tcp"}
+	err = hc.CheckAddr(addr, time.Second)
+	c.Assert(err, check.IsNil)
+	l.Close()
+	err = hc.CheckAddr(addr, time.Second)
+	c.Assert(err, check.FitsTypeOf, &UnreachableError{})
+}
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckCheckAddrCommand(c *check.C) {
+	err := TsuruYamlHealthcheck{Type: "command", Command: "true"}.CheckAddr("127.0.0.1:1", time.Second)
+	c.Assert(err, check.ErrorMatches, `healthcheck type "command" cannot be checked by address`)
+}
@@ -0,0 +1,267 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/log"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/dockercommon"
+	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
+)
+
+const (
+	defaultSidecarImage = "docker:1.11.2"
+	dockerSockPath      = "/var/run/docker.sock"
+	sidecarContainer    = "committer"
+	registrySecretName  = "tsuru-registry-auth"
+)
+
+var (
+	labelIsBuild = tsuruLabel("tsuru.app.build")
+
+	annotationBuildImage = tsuruLabel("tsuru.io/build-image")
+)
+
+var podBuildTimeout = 10 * time.Minute
+
+type buildPodParams struct {
+	app              provision.App
+	sourceImage      string
+	destinationImage string
+	cmds             []string
+	// inspectImage makes the sidecar tag the source image instead of
+	// committing the build container, printing the image metadata in its
+	// output.
+	inspectImage bool
+}
+
+func buildPodNameForApp(a provision.App, destinationImage string) string {
+	parts := strings.Split(destinationImage, ":")
+	return fmt.Sprintf("%s-%s-build", a.GetName(), parts[len(parts)-1])
+}
+
+// sidecarCmds returns the shell script run by the container responsible for
+// waiting the build container to finish, committing or tagging the resulting
+// image and pushing it to the registry.
+func sidecarCmds(podName string, params buildPodParams) []string {
+	dest := params.destinationImage
+	script := []string{
+		`set -e`,
+		`id=""`,
+		fmt.Sprintf(`while [ -z "$id" ]; do sleep 1; id=$(docker ps -aq -f "label=io.kubernetes.pod.name=%s" -f "label=io.kubernetes.container.name=%s"); done`, podName, podName),
+		`exit_code=$(docker wait $id)`,
+		`if [ "$exit_code" != "0" ]; then exit $exit_code; fi`,
+	}
+	if params.inspectImage {
+		script = append(script,
+			fmt.Sprintf(`docker tag %s %s`, params.sourceImage, dest),
+			fmt.Sprintf(`docker inspect %s`, dest),
+		)
+	} else {
+		script = append(script, fmt.Sprintf(`docker commit $id %s >/dev/null`, dest))
+	}
+	if registry, _ := config.GetString("docker:registry"); registry != "" {
+		auth := dockercommon.RegistryAuthConfig()
+		if auth.Username != "" {
+			script = append(script, fmt.Sprintf(`docker login -u "$REGISTRY_USERNAME" -p "$REGISTRY_PASSWORD" %s >/dev/null`, registry))
+		}
+		script = append(script, fmt.Sprintf(`docker push %s >/dev/null`, dest))
+	}
+	return []string{"/bin/sh", "-c", strings.Join(script, "\n")}
+}
+
+// ensureRegistrySecret creates or updates the secret holding the credentials
+// used by the sidecar to push images to the registry, so they are never part
+// of the pod spec.
+func ensureRegistrySecret(cli clusterInterface) error {
+	auth := dockercommon.RegistryAuthConfig()
+	if auth.Username == "" {
+		return nil
+	}
+	secret := &api.Secret{
+		ObjectMeta: api.ObjectMeta{
+			Name:      registrySecretName,
+			Namespace: tsuruNamespace,
+		},
+		Data: map[string][]byte{
+			"username": []byte(auth.Username),
+			"password": []byte(auth.Password),
+		},
+	}
+	_, err := cli.Secrets(tsuruNamespace).Create(secret)
+	if k8sErrors.IsAlreadyExists(err) {
+		_, err = cli.Secrets(tsuruNamespace).Update(secret)
+	}
+	return errors.Wrap(err, "")
+}
+
+// sidecarEnvs returns the environment of the sidecar container, with the
+// registry credentials read from the registry secret.
+func sidecarEnvs() []api.EnvVar {
+	if dockercommon.RegistryAuthConfig().Username == "" {
+		return nil
+	}
+	secretEnv := func(name, key string) api.EnvVar {
+		return api.EnvVar{
+			Name: name,
+			ValueFrom: &api.EnvVarSource{
+				SecretKeyRef: &api.SecretKeySelector{
+					LocalObjectReference: api.LocalObjectReference{Name: registrySecretName},
+					Key:                  key,
+				},
+			},
+		}
+	}
+	return []api.EnvVar{
+		secretEnv("REGISTRY_USERNAME", "username"),
+		secretEnv("REGISTRY_PASSWORD", "password"),
+	}
+}
+
+func buildPodSpec(params buildPodParams) *api.Pod {
+	podName := buildPodNameForApp(params.app, params.destinationImage)
+	sidecarImage, _ := config.GetString("kubernetes:deploy-sidecar-image")
+	if sidecarImage == "" {
+		sidecarImage = defaultSidecarImage
+	}
+	podLabels := appLabels(params.app)
+	podLabels[labelIsBuild.String()] = strconv.FormatBool(true)
+	return &api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name:      podName,
+			Namespace: tsuruNamespace,
+			Labels:    podLabels,
+			Annotations: map[string]string{
+				annotationBuildImage.String(): params.destinationImage,
+			},
+		},
+		Spec: api.PodSpec{
+			RestartPolicy: api.RestartPolicyNever,
+			Volumes: []api.Volume{
+				{
+					Name: "dockersock",
+					VolumeSource: api.VolumeSource{
+						HostPath: &api.HostPathVolumeSource{Path: dockerSockPath},
+					},
+				},
+			},
+			Containers: []api.Container{
+				{
+					Name:    podName,
+					Image:   params.sourceImage,
+					Command: params.cmds,
+					Env:     containerEnvs(params.app, dockercommon.DefaultUnitPort),
+				},
+				{
+					Name:    sidecarContainer,
+					Image:   sidecarImage,
+					Command: sidecarCmds(podName, params),
+					Env:     sidecarEnvs(),
+					VolumeMounts: []api.VolumeMount{
+						{Name: "dockersock", MountPath: dockerSockPath},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runBuildPod creates the build pod and waits for it to finish, copying the
+// output of the build container to w. The returned string is the output of
+// the sidecar container.
+func runBuildPod(cli clusterInterface, params buildPodParams, w io.Writer) (string, error) {
+	err := ensureRegistrySecret(cli)
+	if err != nil {
+		return "", err
+	}
+	pod := buildPodSpec(params)
+	_, err = cli.Pods(tsuruNamespace).Create(pod)
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	defer removePodAndLog(cli, pod.Name)
+	phase, err := waitForPodDone(cli, pod.Name, podBuildTimeout)
+	if err != nil {
+		return "", err
+	}
+	err = copyPodLogs(cli, pod.Name, pod.Name, w)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = copyPodLogs(cli, pod.Name, sidecarContainer, &buf)
+	if err != nil {
+		return "", err
+	}
+	if phase != api.PodSucceeded {
+		return "", errors.Errorf("unexpected phase for build pod %q: %s", pod.Name, phase)
+	}
+	return buf.String(), nil
+}
+
+func waitForPodDone(cli clusterInterface, podName string, timeout time.Duration) (api.PodPhase, error) {
+	timeoutCh := time.After(timeout)
+	for {
+		pod, err := cli.Pods(tsuruNamespace).Get(podName)
+		if err != nil {
+			return "", errors.Wrap(err, "")
+		}
+		switch pod.Status.Phase {
+		case api.PodSucceeded, api.PodFailed:
+			return pod.Status.Phase, nil
+		}
+		select {
+		case <-timeoutCh:
+			return "", errors.Errorf("timeout waiting for pod %q to finish", podName)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func copyPodLogs(cli clusterInterface, podName, container string, w io.Writer) error {
+	if w == nil {
+		return nil
+	}
+	stream, err := cli.Pods(tsuruNamespace).GetLogs(podName, &api.PodLogOptions{
+		Container: container,
+	}).Stream()
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer stream.Close()
+	_, err = io.Copy(w, stream)
+	return errors.Wrap(err, "")
+}
+
+func removePodAndLog(cli clusterInterface, podName string) {
+	err := cli.Pods(tsuruNamespace).Delete(podName, nil)
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		log.Errorf("error removing pod %q: %+v", podName, errors.Wrap(err, ""))
+	}
+}
+
+func parseInspectOutput(output string) (*docker.Image, error) {
+	var images []docker.Image
+	err := json.NewDecoder(strings.NewReader(output)).Decode(&images)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse image inspect output %q", output)
+	}
+	if len(images) == 0 || images[0].Config == nil {
+		return nil, errors.Errorf("no image data found in inspect output %q", output)
+	}
+	return &images[0], nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+)
+
+const tsuruNamespace = "default"
+
+var errNoNodes = errors.New("no kubernetes nodes available")
+
+// clusterInterface is the subset of the kubernetes client used by the
+// provisioner, it's satisfied by *client.Client.
+type clusterInterface interface {
+	client.DeploymentsNamespacer
+	client.ReplicaSetsNamespacer
+	client.ServicesNamespacer
+	client.PodsNamespacer
+	client.SecretsNamespacer
+}
+
+var clusterClientForAddr = func(addr string) (clusterInterface, error) {
+	token, err := config.GetString("kubernetes:token")
+	if err != nil {
+		return nil, err
+	}
+	cli, err := client.New(&restclient.Config{
+		Host:        addr,
+		Insecure:    true,
+		BearerToken: token,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	return cli, nil
+}
+
+func (p *kubernetesProvisioner) clusterClient() (clusterInterface, error) {
+	nodes, err := p.ListNodes(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errNoNodes
+	}
+	return clusterClientForAddr(nodes[0].Address())
+}
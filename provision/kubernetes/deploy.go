@@ -0,0 +1,332 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/dockercommon"
+	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/util/intstr"
+)
+
+type tsuruLabel string
+
+func (l tsuruLabel) String() string {
+	return string(l)
+}
+
+var (
+	labelAppName     = tsuruLabel("tsuru.app.name")
+	labelAppProcess  = tsuruLabel("tsuru.app.process")
+	labelAppPlatform = tsuruLabel("tsuru.app.platform")
+
+	annotationStoppedReplicas = tsuruLabel("tsuru.io/stopped-replicas")
+	annotationRestartedAt     = tsuruLabel("tsuru.io/restarted-at")
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// deploymentNameForApp returns the name of the deployment, service and
+// container of the process. Kubernetes requires names to be valid DNS-1123
+// labels, so characters allowed in process names, like underscores, are
+// replaced by dashes.
+func deploymentNameForApp(a provision.App, process string) string {
+	name := fmt.Sprintf("%s-%s", a.GetName(), process)
+	name = invalidNameChars.ReplaceAllString(strings.ToLower(name), "-")
+	return strings.Trim(name, "-")
+}
+
+func appLabels(a provision.App) labels.Set {
+	return labels.Set{labelAppName.String(): a.GetName()}
+}
+
+func processLabels(a provision.App, process string) labels.Set {
+	return labels.Set{
+		labelAppName.String():     a.GetName(),
+		labelAppProcess.String():  process,
+		labelAppPlatform.String(): a.GetPlatform(),
+	}
+}
+
+func appSelector(a provision.App) api.ListOptions {
+	return api.ListOptions{LabelSelector: labels.SelectorFromSet(appLabels(a))}
+}
+
+func processSelector(a provision.App, process string) api.ListOptions {
+	return api.ListOptions{LabelSelector: labels.SelectorFromSet(labels.Set{
+		labelAppName.String():    a.GetName(),
+		labelAppProcess.String(): process,
+	})}
+}
+
+func containerEnvs(a provision.App, unitPort int) []api.EnvVar {
+	var envs []api.EnvVar
+	for _, envData := range dockercommon.EnvsForApp(a, unitPort) {
+		envs = append(envs, api.EnvVar{Name: envData.Name, Value: envData.Value})
+	}
+	return envs
+}
+
+func deploymentSpecForApp(a provision.App, process, imgID string, replicas int32) (*extensions.Deployment, error) {
+	var cmds []string
+	data, err := image.GetImageCustomData(imgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Processes) > 0 {
+		cmds, _, err = dockercommon.LeanContainerCmds(process, imgID, a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	resources := api.ResourceRequirements{}
+	if memory := a.GetMemory(); memory > 0 {
+		resources.Limits = api.ResourceList{
+			api.ResourceMemory: *resource.NewQuantity(memory, resource.BinarySI),
+		}
+	}
+	podLabels := processLabels(a, process)
+	name := deploymentNameForApp(a, process)
+	port := dockercommon.UnitPort(data)
+	return &extensions.Deployment{
+		ObjectMeta: api.ObjectMeta{
+			Name:      name,
+			Namespace: tsuruNamespace,
+			Labels:    podLabels,
+		},
+		Spec: extensions.DeploymentSpec{
+			Replicas: replicas,
+			Selector: &unversioned.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			Template: api.PodTemplateSpec{
+				ObjectMeta: api.ObjectMeta{
+					Labels: podLabels,
+				},
+				Spec: api.PodSpec{
+					Containers: []api.Container{
+						{
+							Name:      name,
+							Image:     imgID,
+							Command:   cmds,
+							Env:       containerEnvs(a, port),
+							Resources: resources,
+							Ports: []api.ContainerPort{
+								{ContainerPort: int32(port)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func serviceSpecForApp(a provision.App, process string, port int) *api.Service {
+	podLabels := processLabels(a, process)
+	return &api.Service{
+		ObjectMeta: api.ObjectMeta{
+			Name:      deploymentNameForApp(a, process),
+			Namespace: tsuruNamespace,
+			Labels:    podLabels,
+		},
+		Spec: api.ServiceSpec{
+			Type: api.ServiceTypeNodePort,
+			Selector: map[string]string{
+				labelAppName.String():    a.GetName(),
+				labelAppProcess.String(): process,
+			},
+			Ports: []api.ServicePort{
+				{
+					Protocol:   api.ProtocolTCP,
+					Port:       int32(port),
+					TargetPort: intstr.FromInt(port),
+				},
+			},
+		},
+	}
+}
+
+// deployProcess creates or updates the deployment and the service for a
+// process of the app, keeping the current number of replicas.
+func deployProcess(cli clusterInterface, a provision.App, process, imgID string) error {
+	dep, err := cli.Deployments(tsuruNamespace).Get(deploymentNameForApp(a, process))
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		return errors.Wrap(err, "")
+	}
+	var replicas int32 = 1
+	exists := err == nil
+	if exists {
+		replicas = dep.Spec.Replicas
+	}
+	newDep, err := deploymentSpecForApp(a, process, imgID, replicas)
+	if err != nil {
+		return err
+	}
+	if exists {
+		newDep.ObjectMeta.ResourceVersion = dep.ObjectMeta.ResourceVersion
+		newDep.ObjectMeta.Annotations = dep.ObjectMeta.Annotations
+		_, err = cli.Deployments(tsuruNamespace).Update(newDep)
+	} else {
+		_, err = cli.Deployments(tsuruNamespace).Create(newDep)
+	}
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	data, err := image.GetImageCustomData(imgID)
+	if err != nil {
+		return err
+	}
+	return deployService(cli, a, process, dockercommon.UnitPort(data))
+}
+
+// deployService creates the service for a process of the app, updating the
+// ports of an existing service when the image listens to a different port.
+func deployService(cli clusterInterface, a provision.App, process string, port int) error {
+	srv := serviceSpecForApp(a, process, port)
+	existing, err := cli.Services(tsuruNamespace).Get(srv.Name)
+	if err != nil {
+		if !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "")
+		}
+		_, err = cli.Services(tsuruNamespace).Create(srv)
+		return errors.Wrap(err, "")
+	}
+	if len(existing.Spec.Ports) == 1 && existing.Spec.Ports[0].TargetPort.IntValue() == port {
+		return nil
+	}
+	if len(existing.Spec.Ports) > 0 {
+		srv.Spec.Ports[0].NodePort = existing.Spec.Ports[0].NodePort
+	}
+	existing.Spec.Ports = srv.Spec.Ports
+	_, err = cli.Services(tsuruNamespace).Update(existing)
+	return errors.Wrap(err, "")
+}
+
+// deployProcesses rolls out every process registered in the image metadata,
+// recording the image as the current one for the app. Deployments and services
+// of processes no longer registered in the image are removed.
+func deployProcesses(cli clusterInterface, a provision.App, imgID string) error {
+	processes, err := dockercommon.ImageProcesses(imgID)
+	if err != nil {
+		return err
+	}
+	for process := range processes {
+		err = deployProcess(cli, a, process, imgID)
+		if err != nil {
+			return err
+		}
+	}
+	deps, err := cli.Deployments(tsuruNamespace).List(appSelector(a))
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	for _, dep := range deps.Items {
+		process := dep.Labels[labelAppProcess.String()]
+		if _, ok := processes[process]; ok {
+			continue
+		}
+		err = removeResources(cli, processSelector(a, process))
+		if err != nil {
+			return err
+		}
+	}
+	return image.AppendAppImageName(a.GetName(), imgID)
+}
+
+// removeResources scales down and removes the deployments, replica sets,
+// pods and services matching opts.
+func removeResources(cli clusterInterface, opts api.ListOptions) error {
+	deps, err := cli.Deployments(tsuruNamespace).List(opts)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	for i := range deps.Items {
+		dep := &deps.Items[i]
+		dep.Spec.Replicas = 0
+		_, err = cli.Deployments(tsuruNamespace).Update(dep)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		err = cli.Deployments(tsuruNamespace).Delete(dep.Name, nil)
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "")
+		}
+	}
+	replicaSets, err := cli.ReplicaSets(tsuruNamespace).List(opts)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	for _, rs := range replicaSets.Items {
+		err = cli.ReplicaSets(tsuruNamespace).Delete(rs.Name, nil)
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "")
+		}
+	}
+	pods, err := cli.Pods(tsuruNamespace).List(opts)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	for _, pod := range pods.Items {
+		err = cli.Pods(tsuruNamespace).Delete(pod.Name, nil)
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "")
+		}
+	}
+	services, err := cli.Services(tsuruNamespace).List(opts)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	for _, srv := range services.Items {
+		err = cli.Services(tsuruNamespace).Delete(srv.Name)
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			return errors.Wrap(err, "")
+		}
+	}
+	return nil
+}
+
+// changeDeployment runs fn against the deployment of the process and saves the
+// result.
+func changeDeployment(cli clusterInterface, a provision.App, process string, fn func(*extensions.Deployment) error) error {
+	dep, err := cli.Deployments(tsuruNamespace).Get(deploymentNameForApp(a, process))
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return errors.Errorf("no units found for process %q, a deploy is required", process)
+		}
+		return errors.Wrap(err, "")
+	}
+	err = fn(dep)
+	if err != nil {
+		return err
+	}
+	_, err = cli.Deployments(tsuruNamespace).Update(dep)
+	return errors.Wrap(err, "")
+}
+
+func unitStatusFromPod(pod *api.Pod) provision.Status {
+	switch pod.Status.Phase {
+	case api.PodRunning:
+		return provision.StatusStarted
+	case api.PodPending:
+		return provision.StatusStarting
+	case api.PodSucceeded:
+		return provision.StatusStopped
+	case api.PodFailed:
+		return provision.StatusError
+	}
+	return provision.StatusCreated
+}
@@ -0,0 +1,440 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package kubernetes
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/restclient"
+	client "k8s.io/kubernetes/pkg/client/unversioned"
+	"k8s.io/kubernetes/pkg/labels"
+	"k8s.io/kubernetes/pkg/watch"
+)
+
+var errFakeNotSupported = errors.New("not supported by fake cluster")
+
+// fakeCluster is an in memory implementation of clusterInterface, objects are
+// stored without any kind of validation or controller behavior.
+type fakeCluster struct {
+	mu           sync.Mutex
+	deployments  map[string]extensions.Deployment
+	replicaSets  map[string]extensions.ReplicaSet
+	services     map[string]api.Service
+	pods         map[string]api.Pod
+	secrets      map[string]api.Secret
+	logs         map[string]string
+	nextNodePort int32
+	// podReaction is called after a pod is created, it may be used to
+	// simulate the kubelet changing the pod status.
+	podReaction func(*api.Pod)
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{
+		deployments:  map[string]extensions.Deployment{},
+		replicaSets:  map[string]extensions.ReplicaSet{},
+		services:     map[string]api.Service{},
+		pods:         map[string]api.Pod{},
+		secrets:      map[string]api.Secret{},
+		logs:         map[string]string{},
+		nextNodePort: 30000,
+	}
+}
+
+func (f *fakeCluster) Deployments(namespace string) client.DeploymentInterface {
+	return &fakeDeployments{f}
+}
+
+func (f *fakeCluster) ReplicaSets(namespace string) client.ReplicaSetInterface {
+	return &fakeReplicaSets{f}
+}
+
+func (f *fakeCluster) Services(namespace string) client.ServiceInterface {
+	return &fakeServices{f}
+}
+
+func (f *fakeCluster) Pods(namespace string) client.PodInterface {
+	return &fakePods{f}
+}
+
+func (f *fakeCluster) Secrets(namespace string) client.SecretsInterface {
+	return &fakeSecrets{f}
+}
+
+func matches(opts api.ListOptions, objLabels map[string]string) bool {
+	return opts.LabelSelector == nil || opts.LabelSelector.Matches(labels.Set(objLabels))
+}
+
+func notFound(resource, name string) error {
+	return k8sErrors.NewNotFound(unversioned.GroupResource{Resource: resource}, name)
+}
+
+func alreadyExists(resource, name string) error {
+	return k8sErrors.NewAlreadyExists(unversioned.GroupResource{Resource: resource}, name)
+}
+
+type fakeDeployments struct{ f *fakeCluster }
+
+func (d *fakeDeployments) List(opts api.ListOptions) (*extensions.DeploymentList, error) {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	list := &extensions.DeploymentList{}
+	for _, dep := range d.f.deployments {
+		if matches(opts, dep.Labels) {
+			list.Items = append(list.Items, dep)
+		}
+	}
+	return list, nil
+}
+
+func (d *fakeDeployments) Get(name string) (*extensions.Deployment, error) {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	dep, ok := d.f.deployments[name]
+	if !ok {
+		return nil, notFound("deployments", name)
+	}
+	return &dep, nil
+}
+
+func (d *fakeDeployments) Delete(name string, options *api.DeleteOptions) error {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	if _, ok := d.f.deployments[name]; !ok {
+		return notFound("deployments", name)
+	}
+	delete(d.f.deployments, name)
+	return nil
+}
+
+func (d *fakeDeployments) Create(dep *extensions.Deployment) (*extensions.Deployment, error) {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	if _, ok := d.f.deployments[dep.Name]; ok {
+		return nil, alreadyExists("deployments", dep.Name)
+	}
+	d.f.deployments[dep.Name] = *dep
+	return dep, nil
+}
+
+func (d *fakeDeployments) Update(dep *extensions.Deployment) (*extensions.Deployment, error) {
+	d.f.mu.Lock()
+	defer d.f.mu.Unlock()
+	if _, ok := d.f.deployments[dep.Name]; !ok {
+		return nil, notFound("deployments", dep.Name)
+	}
+	d.f.deployments[dep.Name] = *dep
+	return dep, nil
+}
+
+func (d *fakeDeployments) UpdateStatus(dep *extensions.Deployment) (*extensions.Deployment, error) {
+	return d.Update(dep)
+}
+
+func (d *fakeDeployments) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, errFakeNotSupported
+}
+
+func (d *fakeDeployments) Rollback(*extensions.DeploymentRollback) error {
+	return errFakeNotSupported
+}
+
+type fakeReplicaSets struct{ f *fakeCluster }
+
+func (r *fakeReplicaSets) List(opts api.ListOptions) (*extensions.ReplicaSetList, error) {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	list := &extensions.ReplicaSetList{}
+	for _, rs := range r.f.replicaSets {
+		if matches(opts, rs.Labels) {
+			list.Items = append(list.Items, rs)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeReplicaSets) Get(name string) (*extensions.ReplicaSet, error) {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	rs, ok := r.f.replicaSets[name]
+	if !ok {
+		return nil, notFound("replicasets", name)
+	}
+	return &rs, nil
+}
+
+func (r *fakeReplicaSets) Create(rs *extensions.ReplicaSet) (*extensions.ReplicaSet, error) {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	if _, ok := r.f.replicaSets[rs.Name]; ok {
+		return nil, alreadyExists("replicasets", rs.Name)
+	}
+	r.f.replicaSets[rs.Name] = *rs
+	return rs, nil
+}
+
+func (r *fakeReplicaSets) Update(rs *extensions.ReplicaSet) (*extensions.ReplicaSet, error) {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	if _, ok := r.f.replicaSets[rs.Name]; !ok {
+		return nil, notFound("replicasets", rs.Name)
+	}
+	r.f.replicaSets[rs.Name] = *rs
+	return rs, nil
+}
+
+func (r *fakeReplicaSets) UpdateStatus(rs *extensions.ReplicaSet) (*extensions.ReplicaSet, error) {
+	return r.Update(rs)
+}
+
+func (r *fakeReplicaSets) Delete(name string, options *api.DeleteOptions) error {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+	if _, ok := r.f.replicaSets[name]; !ok {
+		return notFound("replicasets", name)
+	}
+	delete(r.f.replicaSets, name)
+	return nil
+}
+
+func (r *fakeReplicaSets) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, errFakeNotSupported
+}
+
+type fakeServices struct{ f *fakeCluster }
+
+func (s *fakeServices) List(opts api.ListOptions) (*api.ServiceList, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	list := &api.ServiceList{}
+	for _, srv := range s.f.services {
+		if matches(opts, srv.Labels) {
+			list.Items = append(list.Items, srv)
+		}
+	}
+	return list, nil
+}
+
+func (s *fakeServices) Get(name string) (*api.Service, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	srv, ok := s.f.services[name]
+	if !ok {
+		return nil, notFound("services", name)
+	}
+	return &srv, nil
+}
+
+func (s *fakeServices) Create(srv *api.Service) (*api.Service, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.services[srv.Name]; ok {
+		return nil, alreadyExists("services", srv.Name)
+	}
+	if srv.Spec.Type == api.ServiceTypeNodePort {
+		for i := range srv.Spec.Ports {
+			if srv.Spec.Ports[i].NodePort == 0 {
+				srv.Spec.Ports[i].NodePort = s.f.nextNodePort
+				s.f.nextNodePort++
+			}
+		}
+	}
+	s.f.services[srv.Name] = *srv
+	return srv, nil
+}
+
+func (s *fakeServices) Update(srv *api.Service) (*api.Service, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.services[srv.Name]; !ok {
+		return nil, notFound("services", srv.Name)
+	}
+	s.f.services[srv.Name] = *srv
+	return srv, nil
+}
+
+func (s *fakeServices) UpdateStatus(srv *api.Service) (*api.Service, error) {
+	return s.Update(srv)
+}
+
+func (s *fakeServices) Delete(name string) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.services[name]; !ok {
+		return notFound("services", name)
+	}
+	delete(s.f.services, name)
+	return nil
+}
+
+func (s *fakeServices) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, errFakeNotSupported
+}
+
+func (s *fakeServices) ProxyGet(scheme, name, port, path string, params map[string]string) restclient.ResponseWrapper {
+	return nil
+}
+
+type fakePods struct{ f *fakeCluster }
+
+func (p *fakePods) List(opts api.ListOptions) (*api.PodList, error) {
+	p.f.mu.Lock()
+	defer p.f.mu.Unlock()
+	list := &api.PodList{}
+	for _, pod := range p.f.pods {
+		if matches(opts, pod.Labels) {
+			list.Items = append(list.Items, pod)
+		}
+	}
+	return list, nil
+}
+
+func (p *fakePods) Get(name string) (*api.Pod, error) {
+	p.f.mu.Lock()
+	defer p.f.mu.Unlock()
+	pod, ok := p.f.pods[name]
+	if !ok {
+		return nil, notFound("pods", name)
+	}
+	return &pod, nil
+}
+
+func (p *fakePods) Delete(name string, options *api.DeleteOptions) error {
+	p.f.mu.Lock()
+	defer p.f.mu.Unlock()
+	if _, ok := p.f.pods[name]; !ok {
+		return notFound("pods", name)
+	}
+	delete(p.f.pods, name)
+	return nil
+}
+
+func (p *fakePods) Create(pod *api.Pod) (*api.Pod, error) {
+	p.f.mu.Lock()
+	if _, ok := p.f.pods[pod.Name]; ok {
+		p.f.mu.Unlock()
+		return nil, alreadyExists("pods", pod.Name)
+	}
+	p.f.pods[pod.Name] = *pod
+	reaction := p.f.podReaction
+	p.f.mu.Unlock()
+	if reaction != nil {
+		reaction(pod)
+	}
+	return pod, nil
+}
+
+func (p *fakePods) Update(pod *api.Pod) (*api.Pod, error) {
+	p.f.mu.Lock()
+	defer p.f.mu.Unlock()
+	if _, ok := p.f.pods[pod.Name]; !ok {
+		return nil, notFound("pods", pod.Name)
+	}
+	p.f.pods[pod.Name] = *pod
+	return pod, nil
+}
+
+func (p *fakePods) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, errFakeNotSupported
+}
+
+func (p *fakePods) Bind(binding *api.Binding) error {
+	return errFakeNotSupported
+}
+
+func (p *fakePods) UpdateStatus(pod *api.Pod) (*api.Pod, error) {
+	return p.Update(pod)
+}
+
+func (p *fakePods) GetLogs(name string, opts *api.PodLogOptions) *restclient.Request {
+	p.f.mu.Lock()
+	logs := p.f.logs[name+"/"+opts.Container]
+	p.f.mu.Unlock()
+	baseURL := &url.URL{Scheme: "http", Host: "fake.k8s"}
+	return restclient.NewRequest(fakeLogsClient(logs), "GET", baseURL, "", restclient.ContentConfig{}, restclient.Serializers{}, nil, nil)
+}
+
+func (f *fakeCluster) setLogs(podName, container, logs string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.logs[podName+"/"+container] = logs
+}
+
+type fakeLogsClient string
+
+func (c fakeLogsClient) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(string(c))),
+		Request:    req,
+	}, nil
+}
+
+type fakeSecrets struct{ f *fakeCluster }
+
+func (s *fakeSecrets) Create(secret *api.Secret) (*api.Secret, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.secrets[secret.Name]; ok {
+		return nil, alreadyExists("secrets", secret.Name)
+	}
+	s.f.secrets[secret.Name] = *secret
+	return secret, nil
+}
+
+func (s *fakeSecrets) Update(secret *api.Secret) (*api.Secret, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.secrets[secret.Name]; !ok {
+		return nil, notFound("secrets", secret.Name)
+	}
+	s.f.secrets[secret.Name] = *secret
+	return secret, nil
+}
+
+func (s *fakeSecrets) Delete(name string) error {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	if _, ok := s.f.secrets[name]; !ok {
+		return notFound("secrets", name)
+	}
+	delete(s.f.secrets, name)
+	return nil
+}
+
+func (s *fakeSecrets) List(opts api.ListOptions) (*api.SecretList, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	list := &api.SecretList{}
+	for _, secret := range s.f.secrets {
+		if matches(opts, secret.Labels) {
+			list.Items = append(list.Items, secret)
+		}
+	}
+	return list, nil
+}
+
+func (s *fakeSecrets) Get(name string) (*api.Secret, error) {
+	s.f.mu.Lock()
+	defer s.f.mu.Unlock()
+	secret, ok := s.f.secrets[name]
+	if !ok {
+		return nil, notFound("secrets", name)
+	}
+	return &secret, nil
+}
+
+func (s *fakeSecrets) Watch(opts api.ListOptions) (watch.Interface, error) {
+	return nil, errFakeNotSupported
+}
@@ -5,19 +5,22 @@
 package kubernetes
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
-	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/dockercommon"
 	"gopkg.in/mgo.v2/bson"
-	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
 	"k8s.io/kubernetes/pkg/apis/extensions"
-	"k8s.io/kubernetes/pkg/client/restclient"
-	client "k8s.io/kubernetes/pkg/client/unversioned"
 )
 
 const (
@@ -42,44 +45,265 @@ func (p *kubernetesProvisioner) Provision(provision.App) error {
 	return nil
 }
 
-func (p *kubernetesProvisioner) Destroy(provision.App) error {
-	return errNotImplemented
+func (p *kubernetesProvisioner) Destroy(a provision.App) error {
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	return removeResources(cli, appSelector(a))
 }
 
-func (p *kubernetesProvisioner) AddUnits(provision.App, uint, string, io.Writer) ([]provision.Unit, error) {
-	return nil, errNotImplemented
+// RemoveAllUnits removes the deployments and services of the app, which is
+// everything Destroy removes as images are kept in the registry.
+func (p *kubernetesProvisioner) RemoveAllUnits(a provision.App) error {
+	return p.Destroy(a)
 }
 
-func (p *kubernetesProvisioner) RemoveUnits(provision.App, uint, string, io.Writer) error {
-	return errNotImplemented
+func (p *kubernetesProvisioner) AddUnits(a provision.App, units uint, process string, w io.Writer) ([]provision.Unit, error) {
+	if units == 0 {
+		return nil, errors.New("cannot add 0 units")
+	}
+	process, err := dockercommon.SingleProcess(a, process)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return nil, err
+	}
+	err = changeDeployment(cli, a, process, func(dep *extensions.Deployment) error {
+		dep.Spec.Replicas += int32(units)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
 }
 
-func (p *kubernetesProvisioner) SetUnitStatus(provision.Unit, provision.Status) error {
-	return errNotImplemented
+func (p *kubernetesProvisioner) RemoveUnits(a provision.App, units uint, process string, w io.Writer) error {
+	if units == 0 {
+		return errors.New("cannot remove 0 units")
+	}
+	process, err := dockercommon.SingleProcess(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	return changeDeployment(cli, a, process, func(dep *extensions.Deployment) error {
+		if int32(units) > dep.Spec.Replicas {
+			return errors.Errorf("cannot remove %d units from process %q, only %d available", units, process, dep.Spec.Replicas)
+		}
+		dep.Spec.Replicas -= int32(units)
+		return nil
+	})
 }
 
-func (p *kubernetesProvisioner) Restart(provision.App, string, io.Writer) error {
-	return errNotImplemented
+func (p *kubernetesProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	pod, err := cli.Pods(tsuruNamespace).Get(unit.ID)
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return &provision.UnitNotFoundError{ID: unit.ID}
+		}
+		return errors.Wrap(err, "")
+	}
+	// Pod status is managed by kubernetes itself, the reported status is
+	// only used to find unresponsive units.
+	unit.AppName = pod.Labels[labelAppName.String()]
+	return provision.ReportUnitStatus(unit, status)
 }
 
-func (p *kubernetesProvisioner) Start(provision.App, string) error {
-	return errNotImplemented
+func (p *kubernetesProvisioner) HealUnit(unit provision.Unit) error {
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	// Deleting the pod makes its replica set create a new one in its place.
+	err = cli.Pods(tsuruNamespace).Delete(unit.ID, nil)
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return &provision.UnitNotFoundError{ID: unit.ID}
+		}
+		return errors.Wrap(err, "")
+	}
+	return nil
 }
 
-func (p *kubernetesProvisioner) Stop(provision.App, string) error {
-	return errNotImplemented
+func (p *kubernetesProvisioner) Restart(a provision.App, process string, w io.Writer) error {
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	restartedAt := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, name := range processes {
+		if w != nil {
+			fmt.Fprintf(w, "---- Restarting process %q ----\n", name)
+		}
+		err = changeDeployment(cli, a, name, func(dep *extensions.Deployment) error {
+			if dep.Spec.Template.ObjectMeta.Annotations == nil {
+				dep.Spec.Template.ObjectMeta.Annotations = map[string]string{}
+			}
+			dep.Spec.Template.ObjectMeta.Annotations[annotationRestartedAt.String()] = restartedAt
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (p *kubernetesProvisioner) Units(app provision.App) ([]provision.Unit, error) {
-	return nil, nil
+func (p *kubernetesProvisioner) Start(a provision.App, process string) error {
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		err = changeDeployment(cli, a, name, func(dep *extensions.Deployment) error {
+			stopped, ok := dep.ObjectMeta.Annotations[annotationStoppedReplicas.String()]
+			if !ok {
+				if dep.Spec.Replicas == 0 {
+					dep.Spec.Replicas = 1
+				}
+				return nil
+			}
+			replicas, err := strconv.Atoi(stopped)
+			if err != nil {
+				return errors.Wrapf(err, "invalid stopped replicas annotation in %q", dep.Name)
+			}
+			dep.Spec.Replicas = int32(replicas)
+			delete(dep.ObjectMeta.Annotations, annotationStoppedReplicas.String())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (p *kubernetesProvisioner) RoutableUnits(app provision.App) ([]provision.Unit, error) {
-	return nil, errNotImplemented
+func (p *kubernetesProvisioner) Stop(a provision.App, process string) error {
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		err = changeDeployment(cli, a, name, func(dep *extensions.Deployment) error {
+			if dep.Spec.Replicas == 0 {
+				return nil
+			}
+			if dep.ObjectMeta.Annotations == nil {
+				dep.ObjectMeta.Annotations = map[string]string{}
+			}
+			dep.ObjectMeta.Annotations[annotationStoppedReplicas.String()] = strconv.Itoa(int(dep.Spec.Replicas))
+			dep.Spec.Replicas = 0
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *kubernetesProvisioner) Units(a provision.App) ([]provision.Unit, error) {
+	cli, err := p.clusterClient()
+	if err != nil {
+		if err == errNoNodes {
+			return nil, nil
+		}
+		return nil, err
+	}
+	opts := appSelector(a)
+	services, err := cli.Services(tsuruNamespace).List(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	nodePorts := map[string]int32{}
+	for _, srv := range services.Items {
+		if len(srv.Spec.Ports) > 0 {
+			nodePorts[srv.Labels[labelAppProcess.String()]] = srv.Spec.Ports[0].NodePort
+		}
+	}
+	pods, err := cli.Pods(tsuruNamespace).List(opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	var units []provision.Unit
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		process := pod.Labels[labelAppProcess.String()]
+		if process == "" {
+			continue
+		}
+		host := pod.Status.HostIP
+		units = append(units, provision.Unit{
+			ID:          pod.Name,
+			Name:        pod.Name,
+			AppName:     a.GetName(),
+			ProcessName: process,
+			Type:        a.GetPlatform(),
+			Ip:          host,
+			Status:      unitStatusFromPod(pod),
+			Address: &url.URL{
+				Scheme: "http",
+				Host:   fmt.Sprintf("%s:%d", host, nodePorts[process]),
+			},
+		})
+	}
+	return units, nil
+}
+
+func (p *kubernetesProvisioner) RoutableUnits(a provision.App) ([]provision.Unit, error) {
+	units, err := p.Units(a)
+	if err != nil {
+		return nil, err
+	}
+	return dockercommon.RoutableUnits(a, units)
 }
 
 func (p *kubernetesProvisioner) RegisterUnit(unit provision.Unit, customData map[string]interface{}) error {
-	return errNotImplemented
+	if customData == nil {
+		return provision.ReportUnitStatus(unit, provision.StatusStarting)
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return err
+	}
+	pod, err := cli.Pods(tsuruNamespace).Get(unit.ID)
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "")
+	}
+	if pod.Labels[labelIsBuild.String()] != strconv.FormatBool(true) {
+		return nil
+	}
+	buildingImage := pod.Annotations[annotationBuildImage.String()]
+	if buildingImage == "" {
+		return errors.Errorf("invalid build image annotation for build pod: %#v", pod)
+	}
+	return image.SaveImageCustomData(buildingImage, customData)
 }
 
 func (p *kubernetesProvisioner) ListNodes(addressFilter []string) ([]provision.Node, error) {
@@ -123,15 +347,7 @@ func (p *kubernetesProvisioner) AddNode(opts provision.AddNodeOptions) error {
 		return err
 	}
 	defer coll.Close()
-	token, err := config.GetString("kubernetes:token")
-	if err != nil {
-		return err
-	}
-	_, err = client.New(&restclient.Config{
-		Host:        opts.Address,
-		Insecure:    true,
-		BearerToken: token,
-	})
+	_, err = clusterClientForAddr(opts.Address)
 	if err != nil {
 		return err
 	}
@@ -157,51 +373,119 @@ func (p *kubernetesProvisioner) UpdateNode(provision.UpdateNodeOptions) error {
 	return errNotImplemented
 }
 
-func (p *kubernetesProvisioner) ArchiveDeploy(app provision.App, archiveURL string, evt *event.Event) (imgID string, err error) {
-	return "", errNotImplemented
+func (p *kubernetesProvisioner) ArchiveDeploy(a provision.App, archiveURL string, evt *event.Event) (string, error) {
+	cli, err := p.clusterClient()
+	if err != nil {
+		return "", err
+	}
+	baseImage := image.GetBuildImage(a)
+	buildingImage, err := image.AppNewImageName(a.GetName())
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	fmt.Fprintln(evt, "---- Building application image ----")
+	_, err = runBuildPod(cli, buildPodParams{
+		app:              a,
+		sourceImage:      baseImage,
+		destinationImage: buildingImage,
+		cmds:             dockercommon.ArchiveDeployCmds(a, archiveURL),
+	}, evt)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(evt, "---- Deploying processes ----")
+	err = deployProcesses(cli, a, buildingImage)
+	if err != nil {
+		return "", err
+	}
+	return buildingImage, nil
 }
 
 func (p *kubernetesProvisioner) ImageDeploy(a provision.App, imgID string, evt *event.Event) (string, error) {
-	hosts, err := p.ListNodes(nil)
+	cli, err := p.clusterClient()
 	if err != nil {
 		return "", err
 	}
-	token, err := config.GetString("kubernetes:token")
+	if !strings.Contains(imgID, ":") {
+		imgID = fmt.Sprintf("%s:latest", imgID)
+	}
+	newImage, err := image.AppNewImageName(a.GetName())
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	fmt.Fprintln(evt, "---- Pulling image to tsuru ----")
+	var buf bytes.Buffer
+	inspectOutput, err := runBuildPod(cli, buildPodParams{
+		app:              a,
+		sourceImage:      imgID,
+		destinationImage: newImage,
+		cmds:             []string{"/bin/sh", "-c", "cat /home/application/current/Procfile || cat /app/user/Procfile || cat /Procfile || true"},
+		inspectImage:     true,
+	}, &buf)
 	if err != nil {
 		return "", err
 	}
-	client, err := client.New(&restclient.Config{
-		Host:        hosts[0].Address(),
-		Insecure:    true,
-		BearerToken: token,
-	})
+	imageInspect, err := parseInspectOutput(inspectOutput)
 	if err != nil {
 		return "", err
 	}
-	if !strings.Contains(imgID, ":") {
-		imgID = fmt.Sprintf("%s:latest", imgID)
+	procfile := image.GetProcessesFromProcfile(buf.String())
+	if len(procfile) == 0 {
+		fmt.Fprintln(evt, "  ---> Procfile not found, trying to get entrypoint")
+		if len(imageInspect.Config.Entrypoint) == 0 {
+			return "", errors.New("no procfile or entrypoint found in image")
+		}
+		webProcess := imageInspect.Config.Entrypoint[0]
+		for _, c := range imageInspect.Config.Entrypoint[1:] {
+			webProcess += fmt.Sprintf(" %q", c)
+		}
+		procfile[dockercommon.WebProcessName] = webProcess
 	}
-	deployment := extensions.Deployment{
-		ObjectMeta: api.ObjectMeta{
-			Name: a.GetName(),
-		},
-		Spec: extensions.DeploymentSpec{
-			Replicas: 1,
-			Template: api.PodTemplateSpec{
-				ObjectMeta: api.ObjectMeta{
-					Name: a.GetName(),
-					Labels: map[string]string{
-						"name": a.GetName(),
-					},
-				},
-				Spec: api.PodSpec{
-					Containers: []api.Container{
-						{Name: a.GetName(), Image: imgID},
-					},
-				},
-			},
-		},
+	for k, v := range procfile {
+		fmt.Fprintf(evt, "  ---> Process %s found with command: %v\n", k, v)
+	}
+	if len(imageInspect.Config.ExposedPorts) > 1 {
+		return "", errors.New("Too many ports. You should especify which one you want to.")
+	}
+	imageData := image.CreateImageMetadata(newImage, procfile)
+	for k := range imageInspect.Config.ExposedPorts {
+		imageData.CustomData["exposedPort"] = string(k)
+	}
+	err = image.SaveImageCustomData(newImage, imageData.CustomData)
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	a.SetUpdatePlatform(true)
+	err = deployProcesses(cli, a, newImage)
+	if err != nil {
+		return "", err
+	}
+	return newImage, nil
+}
+
+func (p *kubernetesProvisioner) Rollback(a provision.App, imgID string, evt *event.Event) (string, error) {
+	validImgs, err := image.ListValidAppImages(a.GetName())
+	if err != nil {
+		return "", err
+	}
+	valid := false
+	for _, img := range validImgs {
+		if img == imgID {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", errors.Errorf("Image %q not found in app", imgID)
+	}
+	cli, err := p.clusterClient()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(evt, "---- Rolling back to image %q ----\n", imgID)
+	err = deployProcesses(cli, a, imgID)
+	if err != nil {
+		return "", err
 	}
-	_, err = client.Deployments("default").Create(&deployment)
-	return "", err
+	return imgID, nil
 }
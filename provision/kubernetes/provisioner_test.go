@@ -5,11 +5,19 @@
 package kubernetes
 
 import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
-	"github.com/tsuru/tsuru/event"
-	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/dockercommon"
 	"gopkg.in/check.v1"
+	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
 )
 
 func (s *S) TestListNodes(c *check.C) {
@@ -78,25 +86,430 @@ func (s *S) TestRemoveNode(c *check.C) {
 }
 
 func (s *S) TestImageDeploy(c *check.C) {
-	url := "https://192.168.99.100:8443"
-	opts := provision.AddNodeOptions{
-		Address: url,
+	a, _ := s.prepareNodeAndApp(c)
+	inspect := `[{"Config": {"Entrypoint": ["/bin/sh", "-c", "python test.py"], "ExposedPorts": {"80/tcp": {}}}}]`
+	created := s.reactBuildPods(c, api.PodSucceeded, nil, "", inspect)
+	evt := s.newDeployEvent(c, a)
+	img, err := s.p.ImageDeploy(a, "myimg", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "tsuru/app-myapp:v1")
+	pod := <-created
+	c.Assert(pod.Name, check.Equals, "myapp-v1-build")
+	c.Assert(pod.Spec.Containers[0].Image, check.Equals, "myimg:latest")
+	c.Assert(pod.Spec.Containers[1].Command[2], check.Matches, `(?s).*docker tag myimg:latest tsuru/app-myapp:v1\ndocker inspect tsuru/app-myapp:v1.*`)
+	_, err = s.client.Pods(tsuruNamespace).Get(pod.Name)
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+	data, err := image.GetImageCustomData(img)
+	c.Assert(err, check.IsNil)
+	c.Assert(data.Processes, check.DeepEquals, map[string]string{"web": `/bin/sh "-c" "python test.py"`})
+	c.Assert(data.ExposedPort, check.Equals, "80/tcp")
+	dbImg, err := image.AppCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbImg, check.Equals, img)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(1))
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Image, check.Equals, img)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Command, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		`[ -d /home/application/current ] && cd /home/application/current; exec /bin/sh "-c" "python test.py"`,
+	})
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Ports, check.DeepEquals, []api.ContainerPort{{ContainerPort: 80}})
+	envs := dep.Spec.Template.Spec.Containers[0].Env
+	c.Assert(envs[len(envs)-2:], check.DeepEquals, []api.EnvVar{
+		{Name: "port", Value: "80"},
+		{Name: "PORT", Value: "80"},
+	})
+	srv, err := s.client.Services(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(srv.Spec.Selector, check.DeepEquals, map[string]string{
+		"tsuru.app.name":    "myapp",
+		"tsuru.app.process": "web",
+	})
+	c.Assert(srv.Spec.Ports, check.HasLen, 1)
+	c.Assert(srv.Spec.Ports[0].TargetPort.IntValue(), check.Equals, 80)
+}
+
+func (s *S) TestDeployServiceUpdatesPort(c *check.C) {
+	a, _ := s.prepareNodeAndApp(c)
+	err := deployService(s.client, a, "web", dockercommon.DefaultUnitPort)
+	c.Assert(err, check.IsNil)
+	srv, err := s.client.Services(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	nodePort := srv.Spec.Ports[0].NodePort
+	err = deployService(s.client, a, "web", 8080)
+	c.Assert(err, check.IsNil)
+	srv, err = s.client.Services(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(srv.Spec.Ports, check.HasLen, 1)
+	c.Assert(srv.Spec.Ports[0].TargetPort.IntValue(), check.Equals, 8080)
+	c.Assert(srv.Spec.Ports[0].NodePort, check.Equals, nodePort)
+}
+
+func (s *S) TestImageDeployWithProcfile(c *check.C) {
+	a, _ := s.prepareNodeAndApp(c)
+	inspect := `[{"Config": {"Entrypoint": ["/bin/sh"]}}]`
+	s.reactBuildPods(c, api.PodSucceeded, nil, "web: python web.py\nworker: python worker.py\n", inspect)
+	img, err := s.p.ImageDeploy(a, "myimg:v2", s.newDeployEvent(c, a))
+	c.Assert(err, check.IsNil)
+	data, err := image.GetImageCustomData(img)
+	c.Assert(err, check.IsNil)
+	c.Assert(data.Processes, check.DeepEquals, map[string]string{
+		"web":    "python web.py",
+		"worker": "python worker.py",
+	})
+	deps, err := s.client.Deployments(tsuruNamespace).List(appSelector(a))
+	c.Assert(err, check.IsNil)
+	c.Assert(deps.Items, check.HasLen, 2)
+}
+
+func (s *S) TestImageDeployNoProcfileNoEntrypoint(c *check.C) {
+	a, _ := s.prepareNodeAndApp(c)
+	s.reactBuildPods(c, api.PodSucceeded, nil, "", `[{"Config": {}}]`)
+	_, err := s.p.ImageDeploy(a, "myimg", s.newDeployEvent(c, a))
+	c.Assert(err, check.ErrorMatches, "no procfile or entrypoint found in image")
+}
+
+func (s *S) TestArchiveDeploy(c *check.C) {
+	a, _ := s.prepareNodeAndApp(c)
+	created := s.reactBuildPods(c, api.PodSucceeded, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	}, "building the app\n", "")
+	evt := s.newDeployEvent(c, a)
+	var buf bytes.Buffer
+	evt.SetLogWriter(&buf)
+	img, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "tsuru/app-myapp:v1")
+	pod := <-created
+	c.Assert(pod.Spec.Containers[0].Image, check.Equals, "tsuru/python:latest")
+	c.Assert(pod.Spec.Containers[0].Command[2], check.Matches, `.*/var/lib/tsuru/deploy archive http://server/myfile.tgz.*`)
+	c.Assert(pod.Spec.Containers[1].Command[2], check.Matches, `(?s).*docker commit \$id tsuru/app-myapp:v1.*`)
+	c.Assert(pod.Spec.RestartPolicy, check.Equals, api.RestartPolicyNever)
+	c.Assert(buf.String(), check.Matches, `(?s).*building the app.*`)
+	dbImg, err := image.AppCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbImg, check.Equals, img)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Command, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; exec python myapp.py",
+	})
+}
+
+func (s *S) TestArchiveDeployRegistryAuth(c *check.C) {
+	config.Set("docker:registry", "registry.tsuru.io")
+	config.Set("docker:registry-auth:username", "myuser")
+	config.Set("docker:registry-auth:password", "mypassword")
+	defer config.Unset("docker:registry")
+	defer config.Unset("docker:registry-auth")
+	a, _ := s.prepareNodeAndApp(c)
+	created := s.reactBuildPods(c, api.PodSucceeded, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python myapp.py",
+		},
+	}, "", "")
+	_, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", s.newDeployEvent(c, a))
+	c.Assert(err, check.IsNil)
+	pod := <-created
+	sidecar := pod.Spec.Containers[1]
+	c.Assert(sidecar.Command[2], check.Matches, `(?s).*docker login -u "\$REGISTRY_USERNAME" -p "\$REGISTRY_PASSWORD" registry.tsuru.io.*`)
+	c.Assert(sidecar.Command[2], check.Not(check.Matches), `(?s).*mypassword.*`)
+	c.Assert(sidecar.Env, check.HasLen, 2)
+	c.Assert(sidecar.Env[1].Name, check.Equals, "REGISTRY_PASSWORD")
+	c.Assert(sidecar.Env[1].Value, check.Equals, "")
+	c.Assert(sidecar.Env[1].ValueFrom.SecretKeyRef.Name, check.Equals, registrySecretName)
+	c.Assert(sidecar.Env[1].ValueFrom.SecretKeyRef.Key, check.Equals, "password")
+	secret, err := s.client.Secrets(tsuruNamespace).Get(registrySecretName)
+	c.Assert(err, check.IsNil)
+	c.Assert(secret.Data, check.DeepEquals, map[string][]byte{
+		"username": []byte("myuser"),
+		"password": []byte("mypassword"),
+	})
+}
+
+func (s *S) TestArchiveDeployBuildFailure(c *check.C) {
+	a, _ := s.prepareNodeAndApp(c)
+	s.reactBuildPods(c, api.PodFailed, nil, "", "")
+	_, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", s.newDeployEvent(c, a))
+	c.Assert(err, check.ErrorMatches, `unexpected phase for build pod "myapp-v1-build": Failed`)
+	deps, err := s.client.Deployments(tsuruNamespace).List(appSelector(a))
+	c.Assert(err, check.IsNil)
+	c.Assert(deps.Items, check.HasLen, 0)
+	imgs, err := image.ListAppImages(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(imgs, check.HasLen, 0)
+}
+
+func (s *S) TestRollback(c *check.C) {
+	a, oldImg := s.prepareDeployedApp(c)
+	s.reactBuildPods(c, api.PodSucceeded, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python web2.py",
+			"worker": "python worker.py",
+		},
+	}, "", "")
+	evt := s.newDeployEvent(c, a)
+	newImg, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(newImg, check.Not(check.Equals), oldImg)
+	err = evt.Done(nil)
+	c.Assert(err, check.IsNil)
+	img, err := s.p.Rollback(a, oldImg, s.newDeployEvent(c, a))
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, oldImg)
+	dbImg, err := image.AppCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbImg, check.Equals, oldImg)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Image, check.Equals, oldImg)
+}
+
+func (s *S) TestRollbackInvalidImage(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.Rollback(a, "tsuru/app-myapp:v20", s.newDeployEvent(c, a))
+	c.Assert(err, check.ErrorMatches, `Image "tsuru/app-myapp:v20" not found in app`)
+}
+
+func (s *S) TestRegisterUnitIgnoresRegularPods(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "web", "myapp-web-1")
+	err := s.p.RegisterUnit(provision.Unit{ID: "myapp-web-1"}, map[string]interface{}{"x": "y"})
+	c.Assert(err, check.IsNil)
+	err = s.p.RegisterUnit(provision.Unit{ID: "unknown"}, map[string]interface{}{"x": "y"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestDeployProcessesKeepsReplicas(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	imgName, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData(imgName, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python web.py",
+			"worker": "python worker.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	cli, err := s.p.clusterClient()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(cli, a, imgName)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(3))
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Image, check.Equals, imgName)
+}
+
+func (s *S) TestDeployProcessesRemovesOldProcesses(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "worker", "myapp-worker-1")
+	imgName, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData(imgName, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":         "python web.py",
+			"celery_beat": "celery beat",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	cli, err := s.p.clusterClient()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(cli, a, imgName)
+	c.Assert(err, check.IsNil)
+	_, err = s.client.Deployments(tsuruNamespace).Get("myapp-worker")
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+	_, err = s.client.Services(tsuruNamespace).Get("myapp-worker")
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+	pods, err := s.client.Pods(tsuruNamespace).List(processSelector(a, "worker"))
+	c.Assert(err, check.IsNil)
+	c.Assert(pods.Items, check.HasLen, 0)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-celery-beat")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.Spec.Containers[0].Name, check.Equals, "myapp-celery-beat")
+	c.Assert(dep.Labels[labelAppProcess.String()], check.Equals, "celery_beat")
+	_, err = s.client.Services(tsuruNamespace).Get("myapp-celery-beat")
+	c.Assert(err, check.IsNil)
+	_, err = s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TestDestroy(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "web", "myapp-web-1")
+	err := s.p.Destroy(a)
+	c.Assert(err, check.IsNil)
+	deps, err := s.client.Deployments(tsuruNamespace).List(appSelector(a))
+	c.Assert(err, check.IsNil)
+	c.Assert(deps.Items, check.HasLen, 0)
+	srvs, err := s.client.Services(tsuruNamespace).List(appSelector(a))
+	c.Assert(err, check.IsNil)
+	c.Assert(srvs.Items, check.HasLen, 0)
+	pods, err := s.client.Pods(tsuruNamespace).List(appSelector(a))
+	c.Assert(err, check.IsNil)
+	c.Assert(pods.Items, check.HasLen, 0)
+}
+
+func (s *S) TestAddUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 3, "worker", nil)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-worker")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(4))
+	dep, err = s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(1))
+}
+
+func (s *S) TestAddUnitsNoProcessMultipleProcesses(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 1, "", nil)
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+}
+
+func (s *S) TestAddUnitsInvalidProcess(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 1, "unknown", nil)
+	c.Assert(err, check.FitsTypeOf, provision.InvalidProcessError{})
+}
+
+func (s *S) TestRemoveUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 3, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(2))
+}
+
+func (s *S) TestRemoveUnitsTooMany(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	err := s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.ErrorMatches, `cannot remove 2 units from process "web", only 1 available`)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(1))
+}
+
+func (s *S) TestRestart(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	var buf bytes.Buffer
+	err := s.p.Restart(a, "web", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Equals, "---- Restarting process \"web\" ----\n")
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.ObjectMeta.Annotations[annotationRestartedAt.String()], check.Not(check.Equals), "")
+	dep, err = s.client.Deployments(tsuruNamespace).Get("myapp-worker")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Template.ObjectMeta.Annotations[annotationRestartedAt.String()], check.Equals, "")
+}
+
+func (s *S) TestStopStart(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.Stop(a, "")
+	c.Assert(err, check.IsNil)
+	for _, name := range []string{"myapp-web", "myapp-worker"} {
+		dep, err := s.client.Deployments(tsuruNamespace).Get(name)
+		c.Assert(err, check.IsNil)
+		c.Assert(dep.Spec.Replicas, check.Equals, int32(0))
 	}
-	err := s.p.AddNode(opts)
+	err = s.p.Start(a, "")
 	c.Assert(err, check.IsNil)
-	defer s.p.RemoveNode(provision.RemoveNodeOptions{})
-	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
-	err = app.CreateApp(a, s.user)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(3))
+	c.Assert(dep.ObjectMeta.Annotations, check.DeepEquals, map[string]string{})
+	dep, err = s.client.Deployments(tsuruNamespace).Get("myapp-worker")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(1))
+}
+
+func (s *S) TestStartWithoutStop(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	err := s.p.RemoveUnits(a, 1, "web", nil)
 	c.Assert(err, check.IsNil)
-	evt, err := event.New(&event.Opts{
-		Target:  event.Target{Type: event.TargetTypeApp, Value: a.GetName()},
-		Kind:    permission.PermAppDeploy,
-		Owner:   s.token,
-		Allowed: event.Allowed(permission.PermAppDeploy),
+	err = s.p.Start(a, "web")
+	c.Assert(err, check.IsNil)
+	dep, err := s.client.Deployments(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(dep.Spec.Replicas, check.Equals, int32(1))
+}
+
+func (s *S) TestUnitsWithPods(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "web", "myapp-web-1")
+	s.addPod(c, a, "worker", "myapp-worker-1")
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	if units[0].ID != "myapp-web-1" {
+		units[0], units[1] = units[1], units[0]
+	}
+	webSrv, err := s.client.Services(tsuruNamespace).Get("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(units[0], check.DeepEquals, provision.Unit{
+		ID:          "myapp-web-1",
+		Name:        "myapp-web-1",
+		AppName:     "myapp",
+		ProcessName: "web",
+		Type:        "python",
+		Ip:          "192.168.99.100",
+		Status:      provision.StatusStarted,
+		Address: &url.URL{
+			Scheme: "http",
+			Host:   fmt.Sprintf("192.168.99.100:%d", webSrv.Spec.Ports[0].NodePort),
+		},
 	})
+	c.Assert(units[1].ProcessName, check.Equals, "worker")
+}
+
+func (s *S) TestRoutableUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "web", "myapp-web-1")
+	s.addPod(c, a, "worker", "myapp-worker-1")
+	units, err := s.p.RoutableUnits(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(units[0].ID, check.Equals, "myapp-web-1")
+}
+
+func (s *S) TestSetUnitStatus(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "web", "myapp-web-1")
+	err := s.p.SetUnitStatus(provision.Unit{ID: "myapp-web-1"}, provision.StatusStarted)
+	c.Assert(err, check.IsNil)
+	err = s.p.SetUnitStatus(provision.Unit{ID: "myapp-web-2"}, provision.StatusStarted)
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: "myapp-web-2"})
+	reports, err := provision.ListUnresponsiveUnitReports(-time.Minute)
 	c.Assert(err, check.IsNil)
-	s.p.ImageDeploy(a, "imageName", evt)
+	c.Assert(reports, check.HasLen, 1)
+	c.Assert(reports[0].ID, check.Equals, "myapp-web-1")
+	c.Assert(reports[0].AppName, check.Equals, "myapp")
+}
+
+func (s *S) TestHealUnit(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	s.addPod(c, a, "web", "myapp-web-1")
+	err := s.p.HealUnit(provision.Unit{ID: "myapp-web-1"})
 	c.Assert(err, check.IsNil)
+	_, err = s.client.Pods(tsuruNamespace).Get("myapp-web-1")
+	c.Assert(k8sErrors.IsNotFound(err), check.Equals, true)
+	err = s.p.HealUnit(provision.Unit{ID: "myapp-web-1"})
+	c.Assert(err, check.DeepEquals, &provision.UnitNotFoundError{ID: "myapp-web-1"})
 }
 
 func (s *S) TestUnits(c *check.C) {
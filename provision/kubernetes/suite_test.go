@@ -10,23 +10,29 @@ import (
 
 	"github.com/tsuru/config"
 	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/auth"
 	"github.com/tsuru/tsuru/auth/native"
 	"github.com/tsuru/tsuru/db"
 	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
 	"github.com/tsuru/tsuru/provision"
 	"github.com/tsuru/tsuru/quota"
 	"github.com/tsuru/tsuru/router/routertest"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/check.v1"
+	"k8s.io/kubernetes/pkg/api"
 )
 
 type S struct {
-	p     *kubernetesProvisioner
-	conn  *db.Storage
-	user  *auth.User
-	team  *auth.Team
-	token auth.Token
+	p             *kubernetesProvisioner
+	conn          *db.Storage
+	user          *auth.User
+	team          *auth.Team
+	token         auth.Token
+	client        *fakeCluster
+	clientFactory func(string) (clusterInterface, error)
 }
 
 var _ = check.Suite(&S{})
@@ -44,9 +50,11 @@ func (s *S) SetUpSuite(c *check.C) {
 	var err error
 	s.conn, err = db.Conn()
 	c.Assert(err, check.IsNil)
+	s.clientFactory = clusterClientForAddr
 }
 
 func (s *S) TearDownSuite(c *check.C) {
+	clusterClientForAddr = s.clientFactory
 	s.conn.Close()
 }
 
@@ -70,6 +78,10 @@ func (s *S) SetUpTest(c *check.C) {
 	err = p.Save()
 	c.Assert(err, check.IsNil)
 	s.p = &kubernetesProvisioner{}
+	s.client = newFakeCluster()
+	clusterClientForAddr = func(string) (clusterInterface, error) {
+		return s.client, nil
+	}
 	s.user = &auth.User{Email: "whiskeyjack@genabackis.com", Password: "123456", Quota: quota.Unlimited}
 	nativeScheme := auth.ManagedScheme(native.NativeScheme{})
 	app.AuthScheme = nativeScheme
@@ -82,3 +94,79 @@ func (s *S) SetUpTest(c *check.C) {
 	s.token, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
 	c.Assert(err, check.IsNil)
 }
+
+func (s *S) prepareNodeAndApp(c *check.C) (*app.App, provision.Node) {
+	err := s.p.AddNode(provision.AddNodeOptions{Address: "https://192.168.99.100:8443"})
+	c.Assert(err, check.IsNil)
+	node, err := s.p.GetNode("https://192.168.99.100:8443")
+	c.Assert(err, check.IsNil)
+	a := &app.App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err = app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	return a, node
+}
+
+func (s *S) prepareDeployedApp(c *check.C) (*app.App, string) {
+	a, _ := s.prepareNodeAndApp(c)
+	imgName, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData(imgName, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python web.py",
+			"worker": "python worker.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	cli, err := s.p.clusterClient()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(cli, a, imgName)
+	c.Assert(err, check.IsNil)
+	return a, imgName
+}
+
+func (s *S) addPod(c *check.C, a provision.App, process, name string) {
+	_, err := s.client.Pods(tsuruNamespace).Create(&api.Pod{
+		ObjectMeta: api.ObjectMeta{
+			Name:   name,
+			Labels: processLabels(a, process),
+		},
+		Status: api.PodStatus{
+			Phase:  api.PodRunning,
+			HostIP: "192.168.99.100",
+		},
+	})
+	c.Assert(err, check.IsNil)
+}
+
+// reactBuildPods makes build pods finish with the given phase, registering
+// customData as if the unit agent had run inside the build container.
+func (s *S) reactBuildPods(c *check.C, phase api.PodPhase, customData map[string]interface{}, buildLogs, sidecarLogs string) <-chan *api.Pod {
+	created := make(chan *api.Pod, 1)
+	s.client.podReaction = func(pod *api.Pod) {
+		if pod.Labels[labelIsBuild.String()] != "true" {
+			return
+		}
+		if customData != nil {
+			err := s.p.RegisterUnit(provision.Unit{ID: pod.Name}, customData)
+			c.Assert(err, check.IsNil)
+		}
+		s.client.setLogs(pod.Name, pod.Name, buildLogs)
+		s.client.setLogs(pod.Name, sidecarContainer, sidecarLogs)
+		pod.Status.Phase = phase
+		_, err := s.client.Pods(tsuruNamespace).Update(pod)
+		c.Assert(err, check.IsNil)
+		created <- pod
+	}
+	return created
+}
+
+func (s *S) newDeployEvent(c *check.C, a provision.App) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: a.GetName()},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppDeploy),
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tsuru/tsuru/scopedconfig"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const maintenanceCollection = "pool-maintenance"
+
+var (
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+	ErrInvalidMaintenanceWindow  = errors.New("maintenance window end must be after its start")
+)
+
+// MaintenanceWindow is a period of time during which automatic operations
+// on a pool, like healing and autoscaling, are paused.
+type MaintenanceWindow struct {
+	ID     string
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+// Active returns whether the window includes the given time.
+func (w *MaintenanceWindow) Active(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// ErrPoolInMaintenance is returned by CheckMaintenance when the pool has an
+// active maintenance window.
+type ErrPoolInMaintenance struct {
+	Pool   string
+	Window MaintenanceWindow
+}
+
+func (e *ErrPoolInMaintenance) Error() string {
+	msg := fmt.Sprintf("pool %q is under maintenance until %s", e.Pool, e.Window.End.Format(time.RFC3339))
+	if e.Window.Reason != "" {
+		msg += ": " + e.Window.Reason
+	}
+	return msg
+}
+
+type maintenanceConfig struct {
+	Windows []MaintenanceWindow
+}
+
+func maintenanceConf() *scopedconfig.ScopedConfig {
+	return scopedconfig.FindScopedConfig(maintenanceCollection)
+}
+
+func loadMaintenanceWindows(pool string) ([]MaintenanceWindow, error) {
+	all := map[string]maintenanceConfig{}
+	err := maintenanceConf().LoadPoolsMerge([]string{pool}, &all, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return all[pool].Windows, nil
+}
+
+// AddMaintenanceWindow adds a maintenance window to the pool, starting now
+// if no start time is set. Windows already finished are discarded.
+func AddMaintenanceWindow(pool string, w *MaintenanceWindow) error {
+	if pool == "" {
+		return ErrPoolNameIsRequired
+	}
+	now := time.Now().UTC()
+	if w.Start.IsZero() {
+		w.Start = now
+	}
+	if !w.End.After(w.Start) {
+		return ErrInvalidMaintenanceWindow
+	}
+	windows, err := loadMaintenanceWindows(pool)
+	if err != nil {
+		return err
+	}
+	w.ID = bson.NewObjectId().Hex()
+	conf := maintenanceConfig{Windows: []MaintenanceWindow{*w}}
+	for _, existing := range windows {
+		if existing.End.After(now) {
+			conf.Windows = append(conf.Windows, existing)
+		}
+	}
+	return maintenanceConf().Save(pool, conf)
+}
+
+// ListMaintenanceWindows returns the maintenance windows of the pool which
+// have not finished yet.
+func ListMaintenanceWindows(pool string) ([]MaintenanceWindow, error) {
+	windows, err := loadMaintenanceWindows(pool)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	result := []MaintenanceWindow{}
+	for _, w := range windows {
+		if w.End.After(now) {
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+// RemoveMaintenanceWindow removes the maintenance window with the given id
+// from the pool.
+func RemoveMaintenanceWindow(pool, id string) error {
+	windows, err := loadMaintenanceWindows(pool)
+	if err != nil {
+		return err
+	}
+	var conf maintenanceConfig
+	found := false
+	for _, w := range windows {
+		if w.ID == id {
+			found = true
+			continue
+		}
+		conf.Windows = append(conf.Windows, w)
+	}
+	if !found {
+		return ErrMaintenanceWindowNotFound
+	}
+	if len(conf.Windows) == 0 {
+		return maintenanceConf().Remove(pool)
+	}
+	return maintenanceConf().Save(pool, conf)
+}
+
+// CheckMaintenance returns an *ErrPoolInMaintenance if the pool has an
+// active maintenance window. Components performing automatic operations,
+// like healers and autoscalers, must skip the pool in this case.
+func CheckMaintenance(pool string) error {
+	if pool == "" {
+		return nil
+	}
+	windows, err := loadMaintenanceWindows(pool)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for _, w := range windows {
+		if w.Active(now) {
+			return &ErrPoolInMaintenance{Pool: pool, Window: w}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestAddMaintenanceWindow(c *check.C) {
+	end := time.Now().UTC().Add(time.Hour)
+	w := MaintenanceWindow{End: end, Reason: "kernel upgrade"}
+	err := AddMaintenanceWindow("pool1", &w)
+	c.Assert(err, check.IsNil)
+	c.Assert(w.ID, check.Not(check.Equals), "")
+	c.Assert(w.Start.IsZero(), check.Equals, false)
+	windows, err := ListMaintenanceWindows("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 1)
+	c.Assert(windows[0].ID, check.Equals, w.ID)
+	c.Assert(windows[0].Reason, check.Equals, "kernel upgrade")
+	c.Assert(windows[0].End.Unix(), check.Equals, end.Unix())
+	windows, err = ListMaintenanceWindows("pool2")
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 0)
+}
+
+func (s *S) TestAddMaintenanceWindowInvalid(c *check.C) {
+	now := time.Now().UTC()
+	err := AddMaintenanceWindow("pool1", &MaintenanceWindow{Start: now, End: now.Add(-time.Minute)})
+	c.Assert(err, check.Equals, ErrInvalidMaintenanceWindow)
+	err = AddMaintenanceWindow("", &MaintenanceWindow{End: now.Add(time.Minute)})
+	c.Assert(err, check.Equals, ErrPoolNameIsRequired)
+}
+
+func (s *S) TestRemoveMaintenanceWindow(c *check.C) {
+	w1 := MaintenanceWindow{End: time.Now().UTC().Add(time.Hour)}
+	err := AddMaintenanceWindow("pool1", &w1)
+	c.Assert(err, check.IsNil)
+	w2 := MaintenanceWindow{End: time.Now().UTC().Add(2 * time.Hour)}
+	err = AddMaintenanceWindow("pool1", &w2)
+	c.Assert(err, check.IsNil)
+	err = RemoveMaintenanceWindow("pool1", w1.ID)
+	c.Assert(err, check.IsNil)
+	windows, err := ListMaintenanceWindows("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 1)
+	c.Assert(windows[0].ID, check.Equals, w2.ID)
+	err = RemoveMaintenanceWindow("pool1", w1.ID)
+	c.Assert(err, check.Equals, ErrMaintenanceWindowNotFound)
+	err = RemoveMaintenanceWindow("pool1", w2.ID)
+	c.Assert(err, check.IsNil)
+	windows, err = ListMaintenanceWindows("pool1")
+	c.Assert(err, check.IsNil)
+	c.Assert(windows, check.HasLen, 0)
+}
+
+func (s *S) TestCheckMaintenance(c *check.C) {
+	err := CheckMaintenance("pool1")
+	c.Assert(err, check.IsNil)
+	now := time.Now().UTC()
+	future := MaintenanceWindow{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}
+	err = AddMaintenanceWindow("pool1", &future)
+	c.Assert(err, check.IsNil)
+	err = CheckMaintenance("pool1")
+	c.Assert(err, check.IsNil)
+	active := MaintenanceWindow{End: now.Add(time.Hour), Reason: "network changes"}
+	err = AddMaintenanceWindow("pool1", &active)
+	c.Assert(err, check.IsNil)
+	err = CheckMaintenance("pool1")
+	c.Assert(err, check.NotNil)
+	maintErr, ok := err.(*ErrPoolInMaintenance)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(maintErr.Pool, check.Equals, "pool1")
+	c.Assert(maintErr.Window.ID, check.Equals, active.ID)
+	c.Assert(err, check.ErrorMatches, `pool "pool1" is under maintenance until .*: network changes`)
+	err = CheckMaintenance("pool2")
+	c.Assert(err, check.IsNil)
+}
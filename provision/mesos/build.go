@@ -0,0 +1,137 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mesos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/net"
+	"github.com/tsuru/tsuru/provision/dockercommon"
+)
+
+// dockerClient returns a client to the docker daemon used to pull external
+// images and push them to the tsuru registry, as marathon only runs images
+// already available in a registry.
+func (p *mesosProvisioner) dockerClient() (*docker.Client, error) {
+	endpoint, err := config.GetString("mesos:docker:endpoint")
+	if err != nil {
+		return nil, errors.Wrap(err, "docker endpoint must be set in mesos:docker:endpoint")
+	}
+	return docker.NewClient(endpoint)
+}
+
+// importImage pulls the external image imgID, tags it as newImage and pushes
+// it to the tsuru registry, saving the processes found in its Procfile, or its
+// entrypoint, and its exposed port as the image metadata.
+func importImage(client *docker.Client, imgID, newImage string, w io.Writer) error {
+	fmt.Fprintln(w, "---- Pulling image to tsuru ----")
+	pullOpts := docker.PullImageOptions{
+		Repository:        imgID,
+		OutputStream:      w,
+		InactivityTimeout: net.StreamInactivityTimeout,
+	}
+	err := client.PullImage(pullOpts, docker.AuthConfiguration{})
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	fmt.Fprintln(w, "---- Getting process from image ----")
+	cmd := "cat /home/application/current/Procfile || cat /app/user/Procfile || cat /Procfile"
+	output, err := runCommandInImage(client, imgID, cmd)
+	if err != nil {
+		return err
+	}
+	procfile := image.GetProcessesFromProcfile(output.String())
+	imageInspect, err := client.InspectImage(imgID)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if len(procfile) == 0 {
+		fmt.Fprintln(w, "  ---> Procfile not found, trying to get entrypoint")
+		if imageInspect.Config == nil || len(imageInspect.Config.Entrypoint) == 0 {
+			return errors.New("no procfile or entrypoint found in image")
+		}
+		webProcess := imageInspect.Config.Entrypoint[0]
+		for _, c := range imageInspect.Config.Entrypoint[1:] {
+			webProcess += fmt.Sprintf(" %q", c)
+		}
+		procfile[dockercommon.WebProcessName] = webProcess
+	}
+	for k, v := range procfile {
+		fmt.Fprintf(w, "  ---> Process %s found with command: %v\n", k, v)
+	}
+	imageData := image.CreateImageMetadata(newImage, procfile)
+	if imageInspect.Config != nil {
+		if len(imageInspect.Config.ExposedPorts) > 1 {
+			return errors.New("Too many ports. You should especify which one you want to.")
+		}
+		for k := range imageInspect.Config.ExposedPorts {
+			imageData.CustomData["exposedPort"] = string(k)
+		}
+	}
+	imageInfo := strings.Split(newImage, ":")
+	repo, tag := strings.Join(imageInfo[:len(imageInfo)-1], ":"), imageInfo[len(imageInfo)-1]
+	err = client.TagImage(imgID, docker.TagImageOptions{Repo: repo, Tag: tag, Force: true})
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if registry, _ := config.GetString("docker:registry"); registry != "" {
+		fmt.Fprintln(w, "---- Pushing image to tsuru ----")
+		pushOpts := docker.PushImageOptions{
+			Name:              repo,
+			Tag:               tag,
+			Registry:          registry,
+			OutputStream:      w,
+			InactivityTimeout: net.StreamInactivityTimeout,
+		}
+		err = client.PushImage(pushOpts, dockercommon.RegistryAuthConfig())
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+	return image.SaveImageCustomData(newImage, imageData.CustomData)
+}
+
+func runCommandInImage(client *docker.Client, imgID, cmd string) (*bytes.Buffer, error) {
+	var output bytes.Buffer
+	cont, err := client.CreateContainer(docker.CreateContainerOptions{
+		Config: &docker.Config{
+			AttachStdout: true,
+			AttachStderr: true,
+			Image:        imgID,
+			Entrypoint:   []string{"/bin/sh", "-c"},
+			Cmd:          []string{cmd},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	defer client.RemoveContainer(docker.RemoveContainerOptions{ID: cont.ID, Force: true})
+	attachOptions := docker.AttachToContainerOptions{
+		Container:    cont.ID,
+		OutputStream: &output,
+		Stream:       true,
+		Stdout:       true,
+		Success:      make(chan struct{}),
+	}
+	waiter, err := client.AttachToContainerNonBlocking(attachOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	<-attachOptions.Success
+	close(attachOptions.Success)
+	err = client.StartContainer(cont.ID, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	waiter.Wait()
+	return &output, nil
+}
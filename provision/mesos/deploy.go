@@ -0,0 +1,179 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mesos
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/dockercommon"
+)
+
+const defaultCPUs = 0.1
+
+type tsuruLabel string
+
+func (l tsuruLabel) String() string {
+	return string(l)
+}
+
+var (
+	labelAppName     = tsuruLabel("tsuru.app.name")
+	labelAppProcess  = tsuruLabel("tsuru.app.process")
+	labelAppPlatform = tsuruLabel("tsuru.app.platform")
+
+	labelStoppedInstances = tsuruLabel("tsuru.stopped-instances")
+)
+
+// marathonAppID returns the id of the marathon app running a process. Ids in
+// marathon are paths, thus the leading slash.
+func marathonAppID(a provision.App, process string) string {
+	return fmt.Sprintf("/%s-%s", a.GetName(), process)
+}
+
+func appLabels(a provision.App) map[string]string {
+	return map[string]string{labelAppName.String(): a.GetName()}
+}
+
+func processLabels(a provision.App, process string) map[string]string {
+	return map[string]string{
+		labelAppName.String():     a.GetName(),
+		labelAppProcess.String():  process,
+		labelAppPlatform.String(): a.GetPlatform(),
+	}
+}
+
+func containerEnvs(a provision.App, unitPort int) map[string]string {
+	envs := map[string]string{}
+	for _, envData := range dockercommon.EnvsForApp(a, unitPort) {
+		envs[envData.Name] = envData.Value
+	}
+	return envs
+}
+
+// processCPUs returns the cpus reserved in marathon for each unit of the app,
+// converted from the cpu share of its plan, where 1024 shares are a full cpu.
+// Apps without a cpu share use the value set in mesos:marathon:cpus, which
+// defaults to 0.1.
+func processCPUs(a provision.App) float64 {
+	if share := a.GetCpuShare(); share > 0 {
+		return float64(share) / 1024
+	}
+	cpus, err := config.GetFloat("mesos:marathon:cpus")
+	if err != nil || cpus <= 0 {
+		return defaultCPUs
+	}
+	return cpus
+}
+
+func marathonAppSpec(a provision.App, process, imgID string, instances int) (*marathonApp, error) {
+	var cmds []string
+	data, err := image.GetImageCustomData(imgID)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Processes) > 0 {
+		cmds, _, err = dockercommon.LeanContainerCmds(process, imgID, a)
+		if err != nil {
+			return nil, err
+		}
+	}
+	port := dockercommon.UnitPort(data)
+	mApp := &marathonApp{
+		ID:        marathonAppID(a, process),
+		Args:      cmds,
+		Instances: instances,
+		CPUs:      processCPUs(a),
+		Env:       containerEnvs(a, port),
+		Labels:    processLabels(a, process),
+		Container: &marathonContainer{
+			Type: "DOCKER",
+			Docker: &marathonDocker{
+				Image:   imgID,
+				Network: "BRIDGE",
+				PortMappings: []marathonPortMapping{
+					{ContainerPort: port, HostPort: 0, Protocol: "tcp"},
+				},
+			},
+		},
+	}
+	if memory := a.GetMemory(); memory > 0 {
+		mApp.Mem = float64(memory) / (1024 * 1024)
+	}
+	return mApp, nil
+}
+
+// deployProcess creates or updates the marathon app running a process of the
+// app, keeping the current number of instances.
+func deployProcess(cli *marathonClient, a provision.App, process, imgID string) error {
+	current, err := cli.getApp(marathonAppID(a, process))
+	if err != nil && errors.Cause(err) != errMarathonAppNotFound {
+		return err
+	}
+	instances := 1
+	exists := err == nil
+	if exists {
+		instances = current.Instances
+	}
+	mApp, err := marathonAppSpec(a, process, imgID, instances)
+	if err != nil {
+		return err
+	}
+	if exists {
+		if stopped, ok := current.Labels[labelStoppedInstances.String()]; ok {
+			mApp.Labels[labelStoppedInstances.String()] = stopped
+		}
+		return cli.updateApp(mApp)
+	}
+	return cli.createApp(mApp)
+}
+
+// deployProcesses deploys every process registered in the image metadata,
+// recording the image as the current one for the app.
+func deployProcesses(cli *marathonClient, a provision.App, imgID string) error {
+	processes, err := dockercommon.ImageProcesses(imgID)
+	if err != nil {
+		return err
+	}
+	for process := range processes {
+		err = deployProcess(cli, a, process, imgID)
+		if err != nil {
+			return err
+		}
+	}
+	return image.AppendAppImageName(a.GetName(), imgID)
+}
+
+// changeMarathonApp runs fn against the marathon app of the process and saves
+// the result.
+func changeMarathonApp(cli *marathonClient, a provision.App, process string, fn func(*marathonApp) error) error {
+	mApp, err := cli.getApp(marathonAppID(a, process))
+	if err != nil {
+		if errors.Cause(err) == errMarathonAppNotFound {
+			return errors.Errorf("no units found for process %q, a deploy is required", process)
+		}
+		return err
+	}
+	err = fn(mApp)
+	if err != nil {
+		return err
+	}
+	return cli.updateApp(mApp)
+}
+
+func unitStatusFromTask(task marathonTask) provision.Status {
+	switch task.State {
+	case "TASK_STAGING", "TASK_STARTING":
+		return provision.StatusStarting
+	case "TASK_FAILED", "TASK_LOST", "TASK_ERROR":
+		return provision.StatusError
+	case "TASK_FINISHED", "TASK_KILLED", "TASK_KILLING":
+		return provision.StatusStopped
+	}
+	return provision.StatusStarted
+}
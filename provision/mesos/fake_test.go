@@ -0,0 +1,151 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mesos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeMarathon is an in memory implementation of the subset of the marathon
+// API used by the provisioner. Every instance of an app is reported as a
+// running task.
+type fakeMarathon struct {
+	sync.Mutex
+	server   *httptest.Server
+	apps     map[string]*marathonApp
+	restarts map[string]int
+}
+
+func newFakeMarathon() *fakeMarathon {
+	m := &fakeMarathon{
+		apps:     map[string]*marathonApp{},
+		restarts: map[string]int{},
+	}
+	m.server = httptest.NewServer(m)
+	return m
+}
+
+func (m *fakeMarathon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.Lock()
+	defer m.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/apps")
+	if path == r.URL.Path {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	switch {
+	case path == "" && r.Method == "GET":
+		m.listApps(w, r)
+	case path == "" && r.Method == "POST":
+		var app marathonApp
+		json.NewDecoder(r.Body).Decode(&app)
+		if _, ok := m.apps[app.ID]; ok {
+			writeMarathonError(w, http.StatusConflict, "app already exists")
+			return
+		}
+		m.apps[app.ID] = &app
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasSuffix(path, "/restart") && r.Method == "POST":
+		id := strings.TrimSuffix(path, "/restart")
+		if _, ok := m.apps[id]; !ok {
+			writeMarathonError(w, http.StatusNotFound, "not found")
+			return
+		}
+		m.restarts[id]++
+		w.Write([]byte(`{}`))
+	default:
+		m.handleApp(w, r, path)
+	}
+}
+
+func (m *fakeMarathon) handleApp(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case "PUT":
+		var app marathonApp
+		json.NewDecoder(r.Body).Decode(&app)
+		app.ID = id
+		m.apps[id] = &app
+		w.Write([]byte(`{}`))
+		return
+	}
+	app, ok := m.apps[id]
+	if !ok {
+		writeMarathonError(w, http.StatusNotFound, "not found")
+		return
+	}
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(map[string]interface{}{"app": m.withTasks(app)})
+	case "DELETE":
+		delete(m.apps, id)
+		w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *fakeMarathon) listApps(w http.ResponseWriter, r *http.Request) {
+	filter := map[string]string{}
+	if labels := r.URL.Query().Get("label"); labels != "" {
+		for _, part := range strings.Split(labels, ",") {
+			kv := strings.SplitN(part, "==", 2)
+			if len(kv) == 2 {
+				filter[kv[0]] = kv[1]
+			}
+		}
+	}
+	var ids []string
+	for id := range m.apps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	apps := []marathonApp{}
+	for _, id := range ids {
+		app := m.apps[id]
+		matches := true
+		for k, v := range filter {
+			if app.Labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			apps = append(apps, m.withTasks(app))
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"apps": apps})
+}
+
+func (m *fakeMarathon) withTasks(app *marathonApp) marathonApp {
+	result := *app
+	result.Tasks = nil
+	for i := 0; i < app.Instances; i++ {
+		result.Tasks = append(result.Tasks, marathonTask{
+			ID:    fmt.Sprintf("%s.task-%d", strings.TrimPrefix(app.ID, "/"), i),
+			AppID: app.ID,
+			Host:  "10.0.0.1",
+			Ports: []int{31000 + i},
+			State: "TASK_RUNNING",
+		})
+	}
+	return result
+}
+
+func (m *fakeMarathon) getApp(id string) *marathonApp {
+	m.Lock()
+	defer m.Unlock()
+	return m.apps[id]
+}
+
+func writeMarathonError(w http.ResponseWriter, status int, msg string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mesos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tsuru/tsuru/net"
+)
+
+type notFoundError struct{ error }
+
+func (e notFoundError) NotFound() bool {
+	return true
+}
+
+var errMarathonAppNotFound = notFoundError{errors.New("marathon app not found")}
+
+type marathonApp struct {
+	ID        string             `json:"id"`
+	Args      []string           `json:"args,omitempty"`
+	Instances int                `json:"instances"`
+	CPUs      float64            `json:"cpus,omitempty"`
+	Mem       float64            `json:"mem,omitempty"`
+	Env       map[string]string  `json:"env,omitempty"`
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Container *marathonContainer `json:"container,omitempty"`
+	Tasks     []marathonTask     `json:"tasks,omitempty"`
+}
+
+type marathonContainer struct {
+	Type   string          `json:"type"`
+	Docker *marathonDocker `json:"docker"`
+}
+
+type marathonDocker struct {
+	Image        string                `json:"image"`
+	Network      string                `json:"network"`
+	PortMappings []marathonPortMapping `json:"portMappings,omitempty"`
+}
+
+type marathonPortMapping struct {
+	ContainerPort int    `json:"containerPort"`
+	HostPort      int    `json:"hostPort"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+type marathonTask struct {
+	ID    string `json:"id"`
+	AppID string `json:"appId"`
+	Host  string `json:"host"`
+	Ports []int  `json:"ports"`
+	State string `json:"state,omitempty"`
+}
+
+type marathonClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+func newMarathonClient(endpoint string) *marathonClient {
+	return &marathonClient{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		httpClient: net.Dial5Full60ClientNoKeepAlive,
+	}
+}
+
+func (c *marathonClient) do(method, path string, body interface{}, result interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&reqBody).Encode(body)
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, &reqBody)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	defer rsp.Body.Close()
+	data, err := ioutil.ReadAll(rsp.Body)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if rsp.StatusCode == http.StatusNotFound {
+		return errors.Wrapf(errMarathonAppNotFound, "%s %s", method, path)
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		var msg struct{ Message string }
+		json.Unmarshal(data, &msg)
+		if msg.Message == "" {
+			msg.Message = string(data)
+		}
+		return errors.Errorf("invalid response from marathon for %s %s (%d): %s", method, path, rsp.StatusCode, msg.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(data, result), "")
+}
+
+func (c *marathonClient) listApps(labels map[string]string) ([]marathonApp, error) {
+	var selector []string
+	for k, v := range labels {
+		selector = append(selector, fmt.Sprintf("%s==%s", k, v))
+	}
+	path := "/v2/apps?embed=apps.tasks"
+	if len(selector) > 0 {
+		path += "&label=" + url.QueryEscape(strings.Join(selector, ","))
+	}
+	var result struct {
+		Apps []marathonApp `json:"apps"`
+	}
+	err := c.do("GET", path, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result.Apps, nil
+}
+
+func (c *marathonClient) getApp(id string) (*marathonApp, error) {
+	var result struct {
+		App marathonApp `json:"app"`
+	}
+	err := c.do("GET", "/v2/apps"+id+"?embed=app.tasks", nil, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.App, nil
+}
+
+func (c *marathonClient) createApp(app *marathonApp) error {
+	return c.do("POST", "/v2/apps", app, nil)
+}
+
+func (c *marathonClient) updateApp(app *marathonApp) error {
+	toUpdate := *app
+	toUpdate.Tasks = nil
+	return c.do("PUT", "/v2/apps"+app.ID+"?force=true", &toUpdate, nil)
+}
+
+func (c *marathonClient) deleteApp(id string) error {
+	return c.do("DELETE", "/v2/apps"+id+"?force=true", nil, nil)
+}
+
+func (c *marathonClient) restartApp(id string) error {
+	return c.do("POST", "/v2/apps"+id+"/restart?force=true", nil, nil)
+}
@@ -5,11 +5,18 @@
 package mesos
 
 import (
-	"errors"
+	"fmt"
 	"io"
+	"net/url"
+	"strconv"
+	"strings"
 
+	"github.com/pkg/errors"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/event"
 	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/dockercommon"
 )
 
 const (
@@ -26,52 +33,221 @@ func init() {
 	})
 }
 
+func (p *mesosProvisioner) client() (*marathonClient, error) {
+	endpoint, err := config.GetString("mesos:marathon:url")
+	if err != nil {
+		return nil, errors.Wrap(err, "marathon url must be set in mesos:marathon:url")
+	}
+	return newMarathonClient(endpoint), nil
+}
+
 func (p *mesosProvisioner) GetName() string {
 	return provisionerName
 }
 
 func (p *mesosProvisioner) Provision(provision.App) error {
-	return errNotImplemented
+	return nil
 }
 
-func (p *mesosProvisioner) Destroy(provision.App) error {
-	return errNotImplemented
+func (p *mesosProvisioner) Destroy(a provision.App) error {
+	cli, err := p.client()
+	if err != nil {
+		return err
+	}
+	apps, err := cli.listApps(appLabels(a))
+	if err != nil {
+		return err
+	}
+	for _, mApp := range apps {
+		err = cli.deleteApp(mApp.ID)
+		if err != nil && errors.Cause(err) != errMarathonAppNotFound {
+			return err
+		}
+	}
+	return nil
 }
 
-func (p *mesosProvisioner) AddUnits(provision.App, uint, string, io.Writer) ([]provision.Unit, error) {
-	return nil, errNotImplemented
+// RemoveAllUnits removes the marathon apps of the app, which is everything
+// Destroy removes as images are kept in the registry.
+func (p *mesosProvisioner) RemoveAllUnits(a provision.App) error {
+	return p.Destroy(a)
 }
 
-func (p *mesosProvisioner) RemoveUnits(provision.App, uint, string, io.Writer) error {
-	return errNotImplemented
+func (p *mesosProvisioner) AddUnits(a provision.App, units uint, process string, w io.Writer) ([]provision.Unit, error) {
+	if units == 0 {
+		return nil, errors.New("cannot add 0 units")
+	}
+	process, err := dockercommon.SingleProcess(a, process)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	err = changeMarathonApp(cli, a, process, func(mApp *marathonApp) error {
+		mApp.Instances += int(units)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
 }
 
-func (p *mesosProvisioner) SetUnitStatus(provision.Unit, provision.Status) error {
-	return errNotImplemented
+func (p *mesosProvisioner) RemoveUnits(a provision.App, units uint, process string, w io.Writer) error {
+	if units == 0 {
+		return errors.New("cannot remove 0 units")
+	}
+	process, err := dockercommon.SingleProcess(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.client()
+	if err != nil {
+		return err
+	}
+	return changeMarathonApp(cli, a, process, func(mApp *marathonApp) error {
+		if int(units) > mApp.Instances {
+			return errors.Errorf("cannot remove %d units from process %q, only %d available", units, process, mApp.Instances)
+		}
+		mApp.Instances -= int(units)
+		return nil
+	})
 }
 
-func (p *mesosProvisioner) Restart(provision.App, string, io.Writer) error {
-	return errNotImplemented
+func (p *mesosProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
+	// Task status is managed by mesos itself, there's nothing to store.
+	return nil
 }
 
-func (p *mesosProvisioner) Start(provision.App, string) error {
-	return errNotImplemented
+func (p *mesosProvisioner) Restart(a provision.App, process string, w io.Writer) error {
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.client()
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		if w != nil {
+			fmt.Fprintf(w, "---- Restarting process %q ----\n", name)
+		}
+		err = cli.restartApp(marathonAppID(a, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (p *mesosProvisioner) Stop(provision.App, string) error {
-	return errNotImplemented
+func (p *mesosProvisioner) Start(a provision.App, process string) error {
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.client()
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		err = changeMarathonApp(cli, a, name, func(mApp *marathonApp) error {
+			stopped, ok := mApp.Labels[labelStoppedInstances.String()]
+			if !ok {
+				if mApp.Instances == 0 {
+					mApp.Instances = 1
+				}
+				return nil
+			}
+			instances, err := strconv.Atoi(stopped)
+			if err != nil {
+				return errors.Wrapf(err, "invalid stopped instances label in %q", mApp.ID)
+			}
+			mApp.Instances = instances
+			delete(mApp.Labels, labelStoppedInstances.String())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (p *mesosProvisioner) Units(app provision.App) ([]provision.Unit, error) {
-	return nil, errNotImplemented
+func (p *mesosProvisioner) Stop(a provision.App, process string) error {
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	cli, err := p.client()
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		err = changeMarathonApp(cli, a, name, func(mApp *marathonApp) error {
+			if mApp.Instances == 0 {
+				return nil
+			}
+			if mApp.Labels == nil {
+				mApp.Labels = map[string]string{}
+			}
+			mApp.Labels[labelStoppedInstances.String()] = strconv.Itoa(mApp.Instances)
+			mApp.Instances = 0
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (p *mesosProvisioner) RoutableUnits(app provision.App) ([]provision.Unit, error) {
-	return nil, errNotImplemented
+func (p *mesosProvisioner) Units(a provision.App) ([]provision.Unit, error) {
+	cli, err := p.client()
+	if err != nil {
+		return nil, err
+	}
+	apps, err := cli.listApps(appLabels(a))
+	if err != nil {
+		return nil, err
+	}
+	var units []provision.Unit
+	for _, mApp := range apps {
+		process := mApp.Labels[labelAppProcess.String()]
+		for _, task := range mApp.Tasks {
+			var port int
+			if len(task.Ports) > 0 {
+				port = task.Ports[0]
+			}
+			units = append(units, provision.Unit{
+				ID:          task.ID,
+				Name:        task.ID,
+				AppName:     a.GetName(),
+				ProcessName: process,
+				Type:        a.GetPlatform(),
+				Ip:          task.Host,
+				Status:      unitStatusFromTask(task),
+				Address: &url.URL{
+					Scheme: "http",
+					Host:   fmt.Sprintf("%s:%d", task.Host, port),
+				},
+			})
+		}
+	}
+	return units, nil
+}
+
+func (p *mesosProvisioner) RoutableUnits(a provision.App) ([]provision.Unit, error) {
+	units, err := p.Units(a)
+	if err != nil {
+		return nil, err
+	}
+	return dockercommon.RoutableUnits(a, units)
 }
 
 func (p *mesosProvisioner) RegisterUnit(unit provision.Unit, customData map[string]interface{}) error {
-	return errNotImplemented
+	return nil
 }
 
 func (p *mesosProvisioner) ListNodes(addressFilter []string) ([]provision.Node, error) {
@@ -99,5 +275,29 @@ func (p *mesosProvisioner) ArchiveDeploy(app provision.App, archiveURL string, e
 }
 
 func (p *mesosProvisioner) ImageDeploy(a provision.App, imgID string, evt *event.Event) (string, error) {
-	return "", errNotImplemented
+	cli, err := p.client()
+	if err != nil {
+		return "", err
+	}
+	dockerClient, err := p.dockerClient()
+	if err != nil {
+		return "", err
+	}
+	if !strings.Contains(imgID, ":") {
+		imgID = fmt.Sprintf("%s:latest", imgID)
+	}
+	newImage, err := image.AppNewImageName(a.GetName())
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	err = importImage(dockerClient, imgID, newImage, evt)
+	if err != nil {
+		return "", err
+	}
+	a.SetUpdatePlatform(true)
+	err = deployProcesses(cli, a, newImage)
+	if err != nil {
+		return "", err
+	}
+	return newImage, nil
 }
@@ -0,0 +1,244 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mesos
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/fsouza/go-dockerclient"
+	dtesting "github.com/fsouza/go-dockerclient/testing"
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/provision/provisiontest"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestImageDeploy(c *check.C) {
+	srv, err := dtesting.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	defer srv.Stop()
+	srv.CustomHandler("/images/myimg:latest/json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(docker.Image{
+			Config: &docker.Config{
+				Entrypoint:   []string{"/bin/sh", "-c", "python test.py"},
+				ExposedPorts: map[docker.Port]struct{}{"80/tcp": {}},
+			},
+		})
+	}))
+	config.Set("mesos:docker:endpoint", srv.URL())
+	defer config.Unset("mesos:docker:endpoint")
+	a := s.newApp(c)
+	evt := s.newDeployEvent(c, a)
+	img, err := s.p.ImageDeploy(a, "myimg", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "tsuru/app-myapp:v1")
+	dbImg, err := image.AppCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbImg, check.Equals, img)
+	imgData, err := image.GetImageCustomData(img)
+	c.Assert(err, check.IsNil)
+	c.Assert(imgData.Processes, check.DeepEquals, map[string]string{"web": `/bin/sh "-c" "python test.py"`})
+	c.Assert(imgData.ExposedPort, check.Equals, "80/tcp")
+	mApp := s.marathon.getApp("/myapp-web")
+	c.Assert(mApp, check.NotNil)
+	c.Assert(mApp.Instances, check.Equals, 1)
+	c.Assert(mApp.Args, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		`[ -d /home/application/current ] && cd /home/application/current; exec /bin/sh "-c" "python test.py"`,
+	})
+	c.Assert(mApp.Container.Docker.Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(mApp.Container.Docker.Network, check.Equals, "BRIDGE")
+	c.Assert(mApp.Container.Docker.PortMappings, check.DeepEquals, []marathonPortMapping{
+		{ContainerPort: 80, HostPort: 0, Protocol: "tcp"},
+	})
+	c.Assert(mApp.Labels, check.DeepEquals, map[string]string{
+		"tsuru.app.name":     "myapp",
+		"tsuru.app.process":  "web",
+		"tsuru.app.platform": "python",
+	})
+	c.Assert(mApp.Env["PORT"], check.Equals, "80")
+}
+
+func (s *S) TestImageDeployNoDockerEndpoint(c *check.C) {
+	a := s.newApp(c)
+	evt := s.newDeployEvent(c, a)
+	_, err := s.p.ImageDeploy(a, "myimg", evt)
+	c.Assert(err, check.ErrorMatches, "docker endpoint must be set in mesos:docker:endpoint.*")
+	c.Assert(s.marathon.getApp("/myapp-web"), check.IsNil)
+}
+
+func (s *S) TestProcessCPUs(c *check.C) {
+	a := provisiontest.NewFakeApp("myapp", "python", 0)
+	c.Assert(processCPUs(a), check.Equals, defaultCPUs)
+	config.Set("mesos:marathon:cpus", 0.5)
+	defer config.Unset("mesos:marathon:cpus")
+	c.Assert(processCPUs(a), check.Equals, 0.5)
+	a.CpuShare = 512
+	c.Assert(processCPUs(a), check.Equals, 0.5)
+	a.CpuShare = 2048
+	c.Assert(processCPUs(a), check.Equals, 2.0)
+}
+
+func (s *S) TestDeployProcesses(c *check.C) {
+	_, img := s.prepareDeployedApp(c)
+	web := s.marathon.getApp("/myapp-web")
+	c.Assert(web, check.NotNil)
+	c.Assert(web.Container.Docker.Image, check.Equals, img)
+	c.Assert(web.Args, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; exec python web.py",
+	})
+	worker := s.marathon.getApp("/myapp-worker")
+	c.Assert(worker, check.NotNil)
+	c.Assert(worker.Labels["tsuru.app.process"], check.Equals, "worker")
+}
+
+func (s *S) TestDeployProcessesKeepsInstances(c *check.C) {
+	a, img := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	cli, err := s.p.client()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(cli, a, img)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.marathon.getApp("/myapp-web").Instances, check.Equals, 3)
+	c.Assert(s.marathon.getApp("/myapp-worker").Instances, check.Equals, 1)
+}
+
+func (s *S) TestArchiveDeployNotImplemented(c *check.C) {
+	a := s.newApp(c)
+	evt := s.newDeployEvent(c, a)
+	_, err := s.p.ArchiveDeploy(a, "http://server/myfile.tgz", evt)
+	c.Assert(err, check.Equals, errNotImplemented)
+}
+
+func (s *S) TestDestroy(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	err := s.p.Destroy(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.marathon.getApp("/myapp-web"), check.IsNil)
+	c.Assert(s.marathon.getApp("/myapp-worker"), check.IsNil)
+}
+
+func (s *S) TestAddUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 3, "worker", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.marathon.getApp("/myapp-worker").Instances, check.Equals, 4)
+	c.Assert(s.marathon.getApp("/myapp-web").Instances, check.Equals, 1)
+}
+
+func (s *S) TestAddUnitsNoProcessMultipleProcesses(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 1, "", nil)
+	c.Assert(err, check.ErrorMatches, "process error: no process name specified and more than one declared in Procfile")
+}
+
+func (s *S) TestAddUnitsInvalidProcess(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 1, "invalid", nil)
+	c.Assert(err, check.ErrorMatches, `process error: process "invalid" not found in app`)
+}
+
+func (s *S) TestRemoveUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.marathon.getApp("/myapp-web").Instances, check.Equals, 1)
+}
+
+func (s *S) TestRemoveUnitsTooMany(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	err := s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.ErrorMatches, `cannot remove 2 units from process "web", only 1 available`)
+}
+
+func (s *S) TestRestart(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	var buf bytes.Buffer
+	err := s.p.Restart(a, "web", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*Restarting process "web".*`)
+	c.Assert(s.marathon.restarts, check.DeepEquals, map[string]int{"/myapp-web": 1})
+	err = s.p.Restart(a, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.marathon.restarts, check.DeepEquals, map[string]int{"/myapp-web": 2, "/myapp-worker": 1})
+}
+
+func (s *S) TestStopStart(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.Stop(a, "")
+	c.Assert(err, check.IsNil)
+	web := s.marathon.getApp("/myapp-web")
+	c.Assert(web.Instances, check.Equals, 0)
+	c.Assert(web.Labels["tsuru.stopped-instances"], check.Equals, "3")
+	c.Assert(s.marathon.getApp("/myapp-worker").Instances, check.Equals, 0)
+	err = s.p.Start(a, "")
+	c.Assert(err, check.IsNil)
+	web = s.marathon.getApp("/myapp-web")
+	c.Assert(web.Instances, check.Equals, 3)
+	_, ok := web.Labels["tsuru.stopped-instances"]
+	c.Assert(ok, check.Equals, false)
+	c.Assert(s.marathon.getApp("/myapp-worker").Instances, check.Equals, 1)
+}
+
+func (s *S) TestStartWithoutStop(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	err := s.p.Start(a, "web")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.marathon.getApp("/myapp-web").Instances, check.Equals, 1)
+}
+
+func (s *S) TestUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	_, err := s.p.AddUnits(a, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 3)
+	c.Assert(units[0].ID, check.Equals, "myapp-web.task-0")
+	c.Assert(units[0].ProcessName, check.Equals, "web")
+	c.Assert(units[0].Ip, check.Equals, "10.0.0.1")
+	c.Assert(units[0].Address.String(), check.Equals, "http://10.0.0.1:31000")
+	c.Assert(units[0].Status, check.Equals, provision.StatusStarted)
+	c.Assert(units[1].Address.String(), check.Equals, "http://10.0.0.1:31001")
+	c.Assert(units[2].ProcessName, check.Equals, "worker")
+}
+
+func (s *S) TestUnitsNoApps(c *check.C) {
+	a := s.newApp(c)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.IsNil)
+}
+
+func (s *S) TestRoutableUnits(c *check.C) {
+	a, _ := s.prepareDeployedApp(c)
+	units, err := s.p.RoutableUnits(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	c.Assert(units[0].ProcessName, check.Equals, "web")
+}
+
+func (s *S) TestUnitStatusFromTask(c *check.C) {
+	tests := map[string]provision.Status{
+		"TASK_RUNNING":  provision.StatusStarted,
+		"TASK_STAGING":  provision.StatusStarting,
+		"TASK_FAILED":   provision.StatusError,
+		"TASK_FINISHED": provision.StatusStopped,
+	}
+	for state, expected := range tests {
+		c.Assert(unitStatusFromTask(marathonTask{State: state}), check.Equals, expected)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mesos
+
+import (
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
+	"github.com/tsuru/tsuru/app/image"
+	"github.com/tsuru/tsuru/auth"
+	"github.com/tsuru/tsuru/auth/native"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/event"
+	"github.com/tsuru/tsuru/permission"
+	"github.com/tsuru/tsuru/provision"
+	"github.com/tsuru/tsuru/quota"
+	"github.com/tsuru/tsuru/router/routertest"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/check.v1"
+)
+
+type S struct {
+	p        *mesosProvisioner
+	conn     *db.Storage
+	user     *auth.User
+	team     *auth.Team
+	token    auth.Token
+	marathon *fakeMarathon
+}
+
+var _ = check.Suite(&S{})
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("auth:hash-cost", bcrypt.MinCost)
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "provision_mesos_tests_s")
+	config.Set("routers:fake:type", "fake")
+	var err error
+	s.conn, err = db.Conn()
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	s.conn.Close()
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	routertest.FakeRouter.Reset()
+	err := dbtest.ClearAllCollections(s.conn.Apps().Database)
+	c.Assert(err, check.IsNil)
+	err = provision.AddPool(provision.AddPoolOptions{
+		Name:        "bonehunters",
+		Default:     true,
+		Provisioner: provisionerName,
+	})
+	c.Assert(err, check.IsNil)
+	p := app.Plan{
+		Name:     "default",
+		Router:   "fake",
+		Default:  true,
+		CpuShare: 100,
+	}
+	err = p.Save()
+	c.Assert(err, check.IsNil)
+	s.p = &mesosProvisioner{}
+	s.marathon = newFakeMarathon()
+	config.Set("mesos:marathon:url", s.marathon.server.URL)
+	s.user = &auth.User{Email: "whiskeyjack@genabackis.com", Password: "123456", Quota: quota.Unlimited}
+	nativeScheme := auth.ManagedScheme(native.NativeScheme{})
+	app.AuthScheme = nativeScheme
+	_, err = nativeScheme.Create(s.user)
+	c.Assert(err, check.IsNil)
+	s.team = &auth.Team{Name: "admin"}
+	err = s.conn.Teams().Insert(s.team)
+	c.Assert(err, check.IsNil)
+	s.token, err = nativeScheme.Login(map[string]string{"email": s.user.Email, "password": "123456"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	s.marathon.server.Close()
+}
+
+func (s *S) newApp(c *check.C) *app.App {
+	a := &app.App{Name: "myapp", Platform: "python", TeamOwner: s.team.Name}
+	err := app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	return a
+}
+
+func (s *S) prepareDeployedApp(c *check.C) (*app.App, string) {
+	a := s.newApp(c)
+	imgName, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData(imgName, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python web.py",
+			"worker": "python worker.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	cli, err := s.p.client()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(cli, a, imgName)
+	c.Assert(err, check.IsNil)
+	return a, imgName
+}
+
+func (s *S) newDeployEvent(c *check.C, a provision.App) *event.Event {
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: a.GetName()},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppDeploy),
+	})
+	c.Assert(err, check.IsNil)
+	return evt
+}
@@ -13,6 +13,7 @@ import (
 	"io"
 	"net"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/tsuru/tsuru/app/bind"
@@ -23,11 +24,29 @@ import (
 
 const defaultDockerProvisioner = "docker"
 
+const (
+	// DeployStrategyRolling replaces the units of the app gradually, old and
+	// new units may serve requests at the same time during a deploy. It's the
+	// default strategy.
+	DeployStrategyRolling = "rolling"
+
+	// DeployStrategyBlueGreen starts a complete set of new units and only
+	// switches the routes after all of them pass the healthcheck.
+	DeployStrategyBlueGreen = "blue-green"
+
+	// DeployStrategyCanary starts a few units with the new image receiving a
+	// fraction of the traffic and waits for the deploy to be promoted or
+	// aborted before replacing the remaining units.
+	DeployStrategyCanary = "canary"
+)
+
 var (
 	ErrInvalidStatus = errors.New("invalid status")
 	ErrEmptyApp      = errors.New("no units for this app")
 	ErrNodeNotFound  = errors.New("node not found")
 
+	ErrCanaryNotFound = errors.New("no canary deploy in progress for the app")
+
 	DefaultProvisioner = defaultDockerProvisioner
 )
 
@@ -222,6 +241,10 @@ type App interface {
 	SetUpdatePlatform(bool) error
 	GetUpdatePlatform() bool
 
+	// GetDeployStrategy returns the strategy used by the provisioner to
+	// replace units during a deploy.
+	GetDeployStrategy() string
+
 	GetRouter() (string, error)
 
 	GetPool() string
@@ -282,6 +305,13 @@ type ImageDeployer interface {
 	ImageDeploy(app App, image string, evt *event.Event) (string, error)
 }
 
+// CanaryDeployer is a provisioner that supports the canary deploy strategy,
+// allowing a canary deploy in progress to be promoted or aborted.
+type CanaryDeployer interface {
+	PromoteCanary(App) error
+	AbortCanary(App) error
+}
+
 // RollbackableDeployer is a provisioner that allows rolling back to a
 // previously deployed version.
 type RollbackableDeployer interface {
@@ -347,6 +377,40 @@ type MetricsProvisioner interface {
 	MetricEnvs(App) map[string]string
 }
 
+// UnitMetric contains the resource usage of a unit, as percentages of the
+// limits assigned to it.
+type UnitMetric struct {
+	ID          string
+	ProcessName string
+	CPU         float64
+	Memory      float64
+}
+
+// UnitsMetricsProvisioner is a provisioner that collects resource usage
+// metrics from the units of an app.
+type UnitsMetricsProvisioner interface {
+	// UnitsMetrics returns the current resource usage of each unit in the
+	// app.
+	UnitsMetrics(App) ([]UnitMetric, error)
+}
+
+// UnitHealerProvisioner is a provisioner whose units can be recreated by the
+// unit healer when they stop reporting their status. Provisioners
+// implementing it must call ReportUnitStatus whenever a unit reports its
+// status through RegisterUnit or SetUnitStatus.
+type UnitHealerProvisioner interface {
+	// HealUnit destroys the given unit, letting a new one be created in its
+	// place.
+	HealUnit(Unit) error
+}
+
+// UnitsRemoverProvisioner is a provisioner able to remove every unit of an
+// app, along with the objects created to run them, without removing the images
+// of the app. It's used to clean up the old provisioner of migrated apps.
+type UnitsRemoverProvisioner interface {
+	RemoveAllUnits(App) error
+}
+
 // ShellProvisioner is a provisioner that allows opening a shell to existing
 // units.
 type ShellProvisioner interface {
@@ -432,6 +496,21 @@ type NodeProvisioner interface {
 	UpdateNode(UpdateNodeOptions) error
 }
 
+// NodeMaintenanceProvisioner is a provisioner that allows taking nodes out of
+// scheduling, so they can go through maintenance without downtime.
+type NodeMaintenanceProvisioner interface {
+	// CordonNode stops new units from being scheduled in the node. Units
+	// already running in the node are kept.
+	CordonNode(address string) error
+
+	// UncordonNode allows new units to be scheduled in a cordoned node.
+	UncordonNode(address string) error
+
+	// DrainNode cordons the node and moves all its units to other nodes,
+	// writing progress to w.
+	DrainNode(address string, w io.Writer) error
+}
+
 type NodeContainerProvisioner interface {
 	UpgradeNodeContainer(name string, pool string, writer io.Writer) error
 }
@@ -636,18 +715,37 @@ type TsuruYamlHooks struct {
 	Build   []string
 }
 
+const (
+	HealthcheckTypeHTTP    = "http"
+	HealthcheckTypeTCP     = "tcp"
+	HealthcheckTypeCommand = "command"
+)
+
 type TsuruYamlHealthcheck struct {
+	Type            string
 	Path            string
 	Method          string
 	Status          int
 	Match           string
+	Command         string
 	RouterBody      string
 	UseInRouter     bool `json:"use_in_router" bson:"use_in_router"`
 	AllowedFailures int  `json:"allowed_failures" bson:"allowed_failures"`
 }
 
+// CheckType returns the type of the healthcheck, which may be one of
+// HealthcheckTypeHTTP, HealthcheckTypeTCP or HealthcheckTypeCommand. Types
+// are case insensitive and healthchecks without a type are HTTP healthchecks.
+func (hc TsuruYamlHealthcheck) CheckType() string {
+	t := strings.ToLower(strings.TrimSpace(hc.Type))
+	if t == "" {
+		return HealthcheckTypeHTTP
+	}
+	return t
+}
+
 func (hc TsuruYamlHealthcheck) ToRouterHC() router.HealthcheckData {
-	if hc.UseInRouter {
+	if hc.UseInRouter && hc.CheckType() == HealthcheckTypeHTTP {
 		return router.HealthcheckData{
 			Path:   hc.Path,
 			Status: hc.Status,
@@ -659,7 +757,57 @@ func (hc TsuruYamlHealthcheck) ToRouterHC() router.HealthcheckData {
 	}
 }
 
+// TsuruYamlLiveness configures the periodic liveness checks of the units of
+// an app. Liveness checks use the probe described in the healthcheck section,
+// Interval and Timeout are expressed in seconds.
+type TsuruYamlLiveness struct {
+	Interval         int
+	Timeout          int
+	FailureThreshold int `json:"failure_threshold" bson:"failure_threshold"`
+}
+
+// TsuruYamlProcess holds the settings declared for a single process under
+// the processes section of tsuru.yaml. They override the app wide restart
+// hooks, healthcheck and allowed failures, build hooks can't be overridden.
+type TsuruYamlProcess struct {
+	Hooks           TsuruYamlHooks
+	Healthcheck     *TsuruYamlHealthcheck
+	AllowedFailures *int `json:"allowed_failures" bson:"allowed_failures"`
+}
+
 type TsuruYamlData struct {
 	Hooks       TsuruYamlHooks
 	Healthcheck TsuruYamlHealthcheck
+	Liveness    *TsuruYamlLiveness
+	// Processes is stored apart from the processes of the Procfile, which
+	// are sent under the same key of the image custom data.
+	Processes map[string]TsuruYamlProcess `bson:"process_settings"`
+}
+
+// ProcessData returns the tsuru.yaml data applying to the given process, with
+// the settings declared for it under processes replacing the app wide ones.
+func (y TsuruYamlData) ProcessData(process string) TsuruYamlData {
+	proc, ok := y.Processes[process]
+	if !ok {
+		return y
+	}
+	if proc.Hooks.Restart.Before != nil {
+		y.Hooks.Restart.Before = proc.Hooks.Restart.Before
+	}
+	if proc.Hooks.Restart.After != nil {
+		y.Hooks.Restart.After = proc.Hooks.Restart.After
+	}
+	if proc.Healthcheck != nil {
+		y.Healthcheck = *proc.Healthcheck
+	}
+	if proc.AllowedFailures != nil {
+		y.Healthcheck.AllowedFailures = *proc.AllowedFailures
+	}
+	return y
+}
+
+// HasProcessHealthcheck returns whether a healthcheck was declared
+// specifically for the given process.
+func (y TsuruYamlData) HasProcessHealthcheck(process string) bool {
+	return y.Processes[process].Healthcheck != nil
 }
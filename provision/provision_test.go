@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/tsuru/tsuru/router"
 	"gopkg.in/check.v1"
 )
 
@@ -158,3 +159,51 @@ func (ProvisionSuite) TestNodeToSpec(c *check.C) {
 	spec := NodeToSpec(&n)
 	c.Assert(spec, check.DeepEquals, NodeSpec{Address: "b", Metadata: map[string]string{"d": "e"}, Status: "c", Pool: "a"})
 }
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckCheckType(c *check.C) {
+	c.Assert(TsuruYamlHealthcheck{}.CheckType(), check.Equals, HealthcheckTypeHTTP)
+	c.Assert(TsuruYamlHealthcheck{Type: " TCP "}.CheckType(), check.Equals, HealthcheckTypeTCP)
+	c.Assert(TsuruYamlHealthcheck{Type: "command"}.CheckType(), check.Equals, HealthcheckTypeCommand)
+	c.Assert(TsuruYamlHealthcheck{Type: "udp"}.CheckType(), check.Equals, "udp")
+}
+
+func (ProvisionSuite) TestTsuruYamlHealthcheckToRouterHC(c *check.C) {
+	hc := TsuruYamlHealthcheck{Path: "/status", Status: 200, UseInRouter: true}
+	c.Assert(hc.ToRouterHC(), check.DeepEquals, router.HealthcheckData{Path: "/status", Status: 200})
+	hc = TsuruYamlHealthcheck{Type: "tcp", UseInRouter: true}
+	c.Assert(hc.ToRouterHC(), check.DeepEquals, router.HealthcheckData{Path: "/"})
+}
+
+func (ProvisionSuite) TestTsuruYamlDataProcessData(c *check.C) {
+	allowedFailures := 5
+	data := TsuruYamlData{
+		Hooks: TsuruYamlHooks{
+			Restart: TsuruYamlRestartHooks{Before: []string{"b1"}, After: []string{"a1"}},
+			Build:   []string{"build"},
+		},
+		Healthcheck: TsuruYamlHealthcheck{Path: "/status", AllowedFailures: 1},
+		Processes: map[string]TsuruYamlProcess{
+			"worker": {
+				Hooks:       TsuruYamlHooks{Restart: TsuruYamlRestartHooks{After: []string{}}},
+				Healthcheck: &TsuruYamlHealthcheck{Type: "tcp"},
+			},
+			"api": {
+				Hooks:           TsuruYamlHooks{Restart: TsuruYamlRestartHooks{Before: []string{"b2"}}},
+				AllowedFailures: &allowedFailures,
+			},
+		},
+	}
+	c.Assert(data.ProcessData("web"), check.DeepEquals, data)
+	worker := data.ProcessData("worker")
+	c.Assert(worker.Hooks, check.DeepEquals, TsuruYamlHooks{
+		Restart: TsuruYamlRestartHooks{Before: []string{"b1"}, After: []string{}},
+		Build:   []string{"build"},
+	})
+	c.Assert(worker.Healthcheck, check.DeepEquals, TsuruYamlHealthcheck{Type: "tcp"})
+	api := data.ProcessData("api")
+	c.Assert(api.Hooks.Restart, check.DeepEquals, TsuruYamlRestartHooks{Before: []string{"b2"}, After: []string{"a1"}})
+	c.Assert(api.Healthcheck, check.DeepEquals, TsuruYamlHealthcheck{Path: "/status", AllowedFailures: 5})
+	c.Assert(data.HasProcessHealthcheck("worker"), check.Equals, true)
+	c.Assert(data.HasProcessHealthcheck("api"), check.Equals, false)
+	c.Assert(data.HasProcessHealthcheck("web"), check.Equals, false)
+}
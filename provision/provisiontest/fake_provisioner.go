@@ -70,6 +70,7 @@ type FakeApp struct {
 	instancesLock  sync.Mutex
 	Pool           string
 	UpdatePlatform bool
+	DeployStrategy string
 	TeamOwner      string
 	Teams          []string
 	quota.Quota
@@ -336,6 +337,13 @@ func (a *FakeApp) GetUpdatePlatform() bool {
 	return a.UpdatePlatform
 }
 
+func (a *FakeApp) GetDeployStrategy() string {
+	if a.DeployStrategy == "" {
+		return provision.DeployStrategyRolling
+	}
+	return a.DeployStrategy
+}
+
 func (a *FakeApp) SetUpdatePlatform(check bool) error {
 	a.commMut.Lock()
 	a.UpdatePlatform = check
@@ -533,6 +541,44 @@ func (p *FakeProvisioner) UpdateNode(opts provision.UpdateNodeOptions) error {
 	return nil
 }
 
+func (p *FakeProvisioner) CordonNode(address string) error {
+	if err := p.getError("CordonNode"); err != nil {
+		return err
+	}
+	return p.setNodeStatus(address, "cordoned")
+}
+
+func (p *FakeProvisioner) UncordonNode(address string) error {
+	if err := p.getError("UncordonNode"); err != nil {
+		return err
+	}
+	return p.setNodeStatus(address, "enabled")
+}
+
+func (p *FakeProvisioner) DrainNode(address string, w io.Writer) error {
+	if err := p.getError("DrainNode"); err != nil {
+		return err
+	}
+	err := p.setNodeStatus(address, "cordoned")
+	if err != nil {
+		return err
+	}
+	if w != nil {
+		w.Write([]byte("drain done!"))
+	}
+	return nil
+}
+
+func (p *FakeProvisioner) setNodeStatus(address, status string) error {
+	n, ok := p.nodes[address]
+	if !ok {
+		return provision.ErrNodeNotFound
+	}
+	n.status = status
+	p.nodes[address] = n
+	return nil
+}
+
 func (p *FakeProvisioner) ListNodes(addressFilter []string) ([]provision.Node, error) {
 	if err := p.getError("ListNodes"); err != nil {
 		return nil, err
@@ -561,6 +607,69 @@ func (p *FakeProvisioner) MetricEnvs(app provision.App) map[string]string {
 	}
 }
 
+// SetUnitsUsage sets the cpu and memory usage reported by UnitsMetrics for
+// all units of the given process in the app. Like the real provisioners,
+// UnitsMetrics only reports started or starting units.
+func (p *FakeProvisioner) SetUnitsUsage(app provision.App, process string, cpu, memory float64) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp := p.apps[app.GetName()]
+	if pApp.usage == nil {
+		pApp.usage = make(map[string]provision.UnitMetric)
+	}
+	pApp.usage[process] = provision.UnitMetric{CPU: cpu, Memory: memory}
+	p.apps[app.GetName()] = pApp
+}
+
+func (p *FakeProvisioner) UnitsMetrics(app provision.App) ([]provision.UnitMetric, error) {
+	if err := p.getError("UnitsMetrics"); err != nil {
+		return nil, err
+	}
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return nil, errNotProvisioned
+	}
+	var metrics []provision.UnitMetric
+	for _, u := range pApp.units {
+		if u.Status != provision.StatusStarted && u.Status != provision.StatusStarting {
+			continue
+		}
+		usage := pApp.usage[u.ProcessName]
+		metrics = append(metrics, provision.UnitMetric{
+			ID:          u.ID,
+			ProcessName: u.ProcessName,
+			CPU:         usage.CPU,
+			Memory:      usage.Memory,
+		})
+	}
+	return metrics, nil
+}
+
+// HealUnit replaces the unit with a new one, with a new id, in the same
+// process.
+func (p *FakeProvisioner) HealUnit(unit provision.Unit) error {
+	if err := p.getError("HealUnit"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	for name, pApp := range p.apps {
+		for i, u := range pApp.units {
+			if u.ID != unit.ID {
+				continue
+			}
+			u.ID = fmt.Sprintf("%s-%d", name, pApp.unitLen)
+			pApp.units[i] = u
+			pApp.unitLen++
+			p.apps[name] = pApp
+			return nil
+		}
+	}
+	return &provision.UnitNotFoundError{ID: unit.ID}
+}
+
 // Restarts returns the number of restarts for a given app.
 func (p *FakeProvisioner) Restarts(a provision.App, process string) int {
 	p.mut.RLock()
@@ -589,6 +698,23 @@ func (p *FakeProvisioner) Sleeps(app provision.App, process string) int {
 	return p.apps[app.GetName()].sleeps[process]
 }
 
+// StartCanary simulates a canary deploy in progress for the given app.
+func (p *FakeProvisioner) StartCanary(app provision.App) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp := p.apps[app.GetName()]
+	pApp.canary = "running"
+	p.apps[app.GetName()] = pApp
+}
+
+// CanaryState returns the state of the last canary deploy of the given app,
+// it may be "running", "promoted" or "aborted".
+func (p *FakeProvisioner) CanaryState(app provision.App) string {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.apps[app.GetName()].canary
+}
+
 func (p *FakeProvisioner) CustomData(app provision.App) map[string]interface{} {
 	p.mut.RLock()
 	defer p.mut.RUnlock()
@@ -881,6 +1007,30 @@ func (p *FakeProvisioner) Destroy(app provision.App) error {
 	return nil
 }
 
+// RemoveAllUnits removes every unit of the app and their routes, keeping the
+// app provisioned.
+func (p *FakeProvisioner) RemoveAllUnits(app provision.App) error {
+	if err := p.getError("RemoveAllUnits"); err != nil {
+		return err
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	for _, u := range pApp.units {
+		err := routertest.FakeRouter.RemoveRoute(app.GetName(), u.Address)
+		if err != nil {
+			return err
+		}
+	}
+	pApp.units = nil
+	pApp.unitLen = 0
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
 func (p *FakeProvisioner) AddUnits(app provision.App, n uint, process string, w io.Writer) ([]provision.Unit, error) {
 	return p.AddUnitsToNode(app, n, process, w, "")
 }
@@ -1208,6 +1358,29 @@ func (p *FakeProvisioner) Sleep(app provision.App, process string) error {
 	return nil
 }
 
+func (p *FakeProvisioner) PromoteCanary(app provision.App) error {
+	return p.setCanaryState(app, "promoted")
+}
+
+func (p *FakeProvisioner) AbortCanary(app provision.App) error {
+	return p.setCanaryState(app, "aborted")
+}
+
+func (p *FakeProvisioner) setCanaryState(app provision.App, state string) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	pApp, ok := p.apps[app.GetName()]
+	if !ok {
+		return errNotProvisioned
+	}
+	if pApp.canary != "running" {
+		return provision.ErrCanaryNotFound
+	}
+	pApp.canary = state
+	p.apps[app.GetName()] = pApp
+	return nil
+}
+
 func (p *FakeProvisioner) RegisterUnit(unit provision.Unit, customData map[string]interface{}) error {
 	p.mut.Lock()
 	defer p.mut.Unlock()
@@ -1392,6 +1565,8 @@ type provisionedApp struct {
 	unitLen     int
 	lastData    map[string]interface{}
 	image       string
+	canary      string
+	usage       map[string]provision.UnitMetric
 }
 
 type provisionedPlatform struct {
@@ -64,6 +64,13 @@ func (s *S) TestFakeAppGetSwap(c *check.C) {
 	c.Assert(app.GetSwap(), check.Equals, int64(0))
 }
 
+func (s *S) TestFakeAppGetDeployStrategy(c *check.C) {
+	app := NewFakeApp("sou", "otm", 0)
+	c.Assert(app.GetDeployStrategy(), check.Equals, provision.DeployStrategyRolling)
+	app.DeployStrategy = provision.DeployStrategyCanary
+	c.Assert(app.GetDeployStrategy(), check.Equals, provision.DeployStrategyCanary)
+}
+
 func (s *S) TestFakeAppSerializeEnvVars(c *check.C) {
 	app := NewFakeApp("sou", "otm", 0)
 	err := app.SerializeEnvVars()
@@ -711,6 +718,42 @@ func (s *S) TestAddUnits(c *check.C) {
 	c.Assert(routertest.FakeRouter.HasRoute(app.GetName(), allUnits[3].Address.String()), check.Equals, true)
 }
 
+func (s *S) TestUnitsMetrics(c *check.C) {
+	app := NewFakeApp("mystic-rhythms", "rush", 0)
+	p := NewFakeProvisioner()
+	err := p.Provision(app)
+	c.Assert(err, check.IsNil)
+	_, err = p.AddUnits(app, 1, "web", nil)
+	c.Assert(err, check.IsNil)
+	_, err = p.AddUnits(app, 1, "worker", nil)
+	c.Assert(err, check.IsNil)
+	p.SetUnitsUsage(app, "web", 80, 30)
+	units := p.GetUnits(app)
+	metrics, err := p.UnitsMetrics(app)
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []provision.UnitMetric{
+		{ID: units[0].ID, ProcessName: "web", CPU: 80, Memory: 30},
+		{ID: units[1].ID, ProcessName: "worker"},
+	})
+}
+
+func (s *S) TestUnitsMetricsIgnoresStoppedUnits(c *check.C) {
+	app := NewFakeApp("mystic-rhythms", "rush", 0)
+	p := NewFakeProvisioner()
+	err := p.Provision(app)
+	c.Assert(err, check.IsNil)
+	_, err = p.AddUnits(app, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	units := p.GetUnits(app)
+	err = p.SetUnitStatus(units[0], provision.StatusStopped)
+	c.Assert(err, check.IsNil)
+	metrics, err := p.UnitsMetrics(app)
+	c.Assert(err, check.IsNil)
+	c.Assert(metrics, check.DeepEquals, []provision.UnitMetric{
+		{ID: units[1].ID, ProcessName: "web"},
+	})
+}
+
 func (s *S) TestAddUnitsCopiesTheUnitsSlice(c *check.C) {
 	app := NewFakeApp("fiction", "python", 0)
 	p := NewFakeProvisioner()
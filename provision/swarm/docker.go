@@ -5,7 +5,9 @@
 package swarm
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"strconv"
@@ -37,14 +39,16 @@ func (l tsuruLabel) String() string {
 }
 
 var (
-	labelService           = tsuruLabel("tsuru.service")
-	labelServiceDeploy     = tsuruLabel("tsuru.service.deploy")
-	labelServiceBuildImage = tsuruLabel("tsuru.service.buildImage")
-	labelAppName           = tsuruLabel("tsuru.app.name")
-	labelAppProcess        = tsuruLabel("tsuru.app.process")
-	labelAppPlatform       = tsuruLabel("tsuru.app.platform")
-	labelRouterName        = tsuruLabel("tsuru.router.name")
-	labelRouterType        = tsuruLabel("tsuru.router.type")
+	labelService                = tsuruLabel("tsuru.service")
+	labelServiceDeploy          = tsuruLabel("tsuru.service.deploy")
+	labelServiceBuildImage      = tsuruLabel("tsuru.service.buildImage")
+	labelServiceRestart         = tsuruLabel("tsuru.service.restart")
+	labelServiceStoppedReplicas = tsuruLabel("tsuru.service.stopped-replicas")
+	labelAppName                = tsuruLabel("tsuru.app.name")
+	labelAppProcess             = tsuruLabel("tsuru.app.process")
+	labelAppPlatform            = tsuruLabel("tsuru.app.platform")
+	labelRouterName             = tsuruLabel("tsuru.router.name")
+	labelRouterType             = tsuruLabel("tsuru.router.type")
 )
 
 func newClient(address string) (*docker.Client, error) {
@@ -211,7 +215,7 @@ func pushImage(client *docker.Client, repo, tag string) error {
 	if _, err := config.GetString("docker:registry"); err == nil {
 		var buf safe.Buffer
 		pushOpts := docker.PushImageOptions{Name: repo, Tag: tag, OutputStream: &buf, InactivityTimeout: tsuruNet.StreamInactivityTimeout}
-		err = client.PushImage(pushOpts, registryAuthConfig())
+		err = client.PushImage(pushOpts, dockercommon.RegistryAuthConfig())
 		if err != nil {
 			return errors.Wrap(err, "")
 		}
@@ -219,15 +223,6 @@ func pushImage(client *docker.Client, repo, tag string) error {
 	return nil
 }
 
-func registryAuthConfig() docker.AuthConfiguration {
-	var authConfig docker.AuthConfiguration
-	authConfig.Email, _ = config.GetString("docker:registry-auth:email")
-	authConfig.Username, _ = config.GetString("docker:registry-auth:username")
-	authConfig.Password, _ = config.GetString("docker:registry-auth:password")
-	authConfig.ServerAddress, _ = config.GetString("docker:registry")
-	return authConfig
-}
-
 func serviceNameForApp(a provision.App, process string) string {
 	return fmt.Sprintf("%s-%s", a.GetName(), process)
 }
@@ -265,8 +260,10 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 		}
 	}
 	var unitCount uint64 = 1
+	var stoppedReplicas string
 	if opts.baseSpec != nil {
 		unitCount = *opts.baseSpec.Mode.Replicated.Replicas
+		stoppedReplicas = opts.baseSpec.Annotations.Labels[labelServiceStoppedReplicas.String()]
 	}
 	routerName, err := opts.app.GetRouter()
 	if err != nil {
@@ -290,6 +287,13 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 		labelRouterName.String():        routerName,
 		labelRouterType.String():        routerType,
 	}
+	srvLabels := map[string]string{}
+	for k, v := range labels {
+		srvLabels[k] = v
+	}
+	if stoppedReplicas != "" {
+		srvLabels[labelServiceStoppedReplicas.String()] = stoppedReplicas
+	}
 	spec := swarm.ServiceSpec{
 		TaskTemplate: swarm.TaskSpec{
 			ContainerSpec: swarm.ContainerSpec{
@@ -313,7 +317,7 @@ func serviceSpecForApp(opts tsuruServiceOpts) (*swarm.ServiceSpec, error) {
 		},
 		Annotations: swarm.Annotations{
 			Name:   srvName,
-			Labels: labels,
+			Labels: srvLabels,
 		},
 		Mode: swarm.ServiceMode{
 			Replicated: &swarm.ReplicatedService{
@@ -340,3 +344,103 @@ func clientForNode(baseClient *docker.Client, nodeID string) (*docker.Client, er
 	}
 	return newClient(node.Spec.Annotations.Labels[labelDockerAddr])
 }
+
+type execOpts struct {
+	client      *docker.Client
+	containerID string
+	cmds        []string
+	stdout      io.Writer
+	stderr      io.Writer
+	stdin       io.Reader
+	useTty      bool
+	width       int
+	height      int
+}
+
+func execInContainer(opts execOpts) error {
+	exec, err := opts.client.CreateExec(docker.CreateExecOptions{
+		AttachStdin:  opts.stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          opts.useTty,
+		Cmd:          opts.cmds,
+		Container:    opts.containerID,
+	})
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	startOpts := docker.StartExecOptions{
+		InputStream:  opts.stdin,
+		OutputStream: opts.stdout,
+		ErrorStream:  opts.stderr,
+		Tty:          opts.useTty,
+		RawTerminal:  opts.useTty,
+	}
+	if opts.useTty {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- opts.client.StartExec(exec.ID, startOpts)
+		}()
+		execInfo, err := opts.client.InspectExec(exec.ID)
+		for err == nil && !execInfo.Running {
+			select {
+			case startErr := <-errs:
+				return errors.Wrap(startErr, "")
+			default:
+				execInfo, err = opts.client.InspectExec(exec.ID)
+			}
+		}
+		if err != nil {
+			return errors.Wrap(err, "")
+		}
+		opts.client.ResizeExecTTY(exec.ID, opts.height, opts.width)
+		return errors.Wrap(<-errs, "")
+	}
+	err = opts.client.StartExec(exec.ID, startOpts)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	execData, err := opts.client.InspectExec(exec.ID)
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	if execData.ExitCode != 0 {
+		return errors.Errorf("unexpected exit code %d", execData.ExitCode)
+	}
+	return nil
+}
+
+// uploadArchive uploads the content of file to dirPath in the container,
+// naming it fileName.
+func uploadArchive(client *docker.Client, contID, dirPath, fileName string, file io.Reader, fileSize int64) error {
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		tarball := tar.NewWriter(writer)
+		header := tar.Header{
+			Name: fileName,
+			Mode: 0666,
+			Size: fileSize,
+		}
+		err := tarball.WriteHeader(&header)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		n, err := io.Copy(tarball, file)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		if n != fileSize {
+			writer.CloseWithError(errors.New("upload-deploy: short-write copying to tarball"))
+			return
+		}
+		writer.CloseWithError(tarball.Close())
+	}()
+	err := client.UploadToContainer(contID, docker.UploadToContainerOptions{
+		InputStream: reader,
+		Path:        dirPath,
+	})
+	return errors.Wrap(err, "")
+}
@@ -8,13 +8,17 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/pkg/errors"
 	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/app"
 	"github.com/tsuru/tsuru/app/image"
 	"github.com/tsuru/tsuru/event"
 	tsuruNet "github.com/tsuru/tsuru/net"
@@ -67,28 +71,212 @@ func (p *swarmProvisioner) Destroy(provision.App) error {
 	return errNotImplemented
 }
 
-func (p *swarmProvisioner) AddUnits(provision.App, uint, string, io.Writer) ([]provision.Unit, error) {
-	return nil, errNotImplemented
+// RemoveAllUnits removes every service running units of the app.
+func (p *swarmProvisioner) RemoveAllUnits(a provision.App) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	services, err := client.ListServices(docker.ListServicesOptions{})
+	if err != nil {
+		return errors.Wrap(err, "")
+	}
+	for _, srv := range services {
+		if srv.Spec.Labels[labelAppName.String()] != a.GetName() {
+			continue
+		}
+		err = client.RemoveService(docker.RemoveServiceOptions{ID: srv.ID})
+		if err != nil {
+			if _, notFound := err.(*docker.NoSuchService); !notFound {
+				return errors.Wrap(err, "")
+			}
+		}
+	}
+	return nil
 }
 
-func (p *swarmProvisioner) RemoveUnits(provision.App, uint, string, io.Writer) error {
-	return errNotImplemented
+func (p *swarmProvisioner) AddUnits(a provision.App, units uint, process string, w io.Writer) ([]provision.Unit, error) {
+	if units == 0 {
+		return nil, errors.New("cannot add 0 units")
+	}
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return nil, err
+	}
+	process, err = dockercommon.SingleProcess(a, process)
+	if err != nil {
+		return nil, err
+	}
+	err = changeServiceSpec(client, a, process, func(spec *swarm.ServiceSpec) error {
+		*spec.Mode.Replicated.Replicas += uint64(units)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
 }
 
-func (p *swarmProvisioner) SetUnitStatus(provision.Unit, provision.Status) error {
-	return errNotImplemented
+func (p *swarmProvisioner) RemoveUnits(a provision.App, units uint, process string, w io.Writer) error {
+	if units == 0 {
+		return errors.New("cannot remove 0 units")
+	}
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	process, err = dockercommon.SingleProcess(a, process)
+	if err != nil {
+		return err
+	}
+	return changeServiceSpec(client, a, process, func(spec *swarm.ServiceSpec) error {
+		replicas := spec.Mode.Replicated.Replicas
+		if uint64(units) > *replicas {
+			return errors.Errorf("cannot remove %d units from process %q, only %d available", units, process, *replicas)
+		}
+		*replicas -= uint64(units)
+		return nil
+	})
 }
 
-func (p *swarmProvisioner) Restart(provision.App, string, io.Writer) error {
-	return errNotImplemented
+func (p *swarmProvisioner) SetUnitStatus(unit provision.Unit, status provision.Status) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		if errors.Cause(err) == errNoSwarmNode {
+			return &provision.UnitNotFoundError{ID: unit.ID}
+		}
+		return err
+	}
+	task, err := taskForUnit(client, unit)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return &provision.UnitNotFoundError{ID: unit.ID}
+	}
+	// Task status is managed by swarm itself, the reported status is only
+	// used to find unresponsive units.
+	unit.AppName = task.Spec.ContainerSpec.Labels[labelAppName.String()]
+	return provision.ReportUnitStatus(unit, status)
 }
 
-func (p *swarmProvisioner) Start(provision.App, string) error {
-	return errNotImplemented
+func (p *swarmProvisioner) HealUnit(unit provision.Unit) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	task, err := taskForUnit(client, unit)
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		return &provision.UnitNotFoundError{ID: unit.ID}
+	}
+	nodeClient, err := clientForNode(client, task.NodeID)
+	if err != nil {
+		return err
+	}
+	// Removing the container makes swarm start a new task in its place.
+	err = nodeClient.RemoveContainer(docker.RemoveContainerOptions{ID: unit.ID, Force: true})
+	if err != nil {
+		if _, ok := err.(*docker.NoSuchContainer); !ok {
+			return errors.Wrap(err, "")
+		}
+	}
+	return nil
 }
 
-func (p *swarmProvisioner) Stop(provision.App, string) error {
-	return errNotImplemented
+func (p *swarmProvisioner) Restart(a provision.App, process string, w io.Writer) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		w = ioutil.Discard
+	}
+	for _, name := range processes {
+		fmt.Fprintf(w, "---- Restarting process %q ----\n", name)
+		var spec *swarm.ServiceSpec
+		err = changeServiceSpec(client, a, name, func(s *swarm.ServiceSpec) error {
+			labels := s.TaskTemplate.ContainerSpec.Labels
+			restarts, _ := strconv.Atoi(labels[labelServiceRestart.String()])
+			labels[labelServiceRestart.String()] = strconv.Itoa(restarts + 1)
+			spec = s
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = runRestartAfterHooks(client, spec, w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *swarmProvisioner) Start(a provision.App, process string) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		err = changeServiceSpec(client, a, name, func(spec *swarm.ServiceSpec) error {
+			replicas := spec.Mode.Replicated.Replicas
+			stopped, ok := spec.Annotations.Labels[labelServiceStoppedReplicas.String()]
+			if !ok {
+				if *replicas == 0 {
+					*replicas = 1
+				}
+				return nil
+			}
+			count, err := strconv.ParseUint(stopped, 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "invalid stopped replicas label in service %q", spec.Annotations.Name)
+			}
+			*replicas = count
+			delete(spec.Annotations.Labels, labelServiceStoppedReplicas.String())
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *swarmProvisioner) Stop(a provision.App, process string) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	processes, err := dockercommon.ProcessesToChange(a, process)
+	if err != nil {
+		return err
+	}
+	for _, name := range processes {
+		err = changeServiceSpec(client, a, name, func(spec *swarm.ServiceSpec) error {
+			replicas := spec.Mode.Replicated.Replicas
+			if *replicas == 0 {
+				return nil
+			}
+			spec.Annotations.Labels[labelServiceStoppedReplicas.String()] = strconv.FormatUint(*replicas, 10)
+			*replicas = 0
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (p *swarmProvisioner) Units(app provision.App) ([]provision.Unit, error) {
@@ -171,7 +359,7 @@ func (p *swarmProvisioner) RoutableUnits(app provision.App) ([]provision.Unit, e
 
 func (p *swarmProvisioner) RegisterUnit(unit provision.Unit, customData map[string]interface{}) error {
 	if customData == nil {
-		return nil
+		return provision.ReportUnitStatus(unit, provision.StatusStarting)
 	}
 	client, err := chooseDBSwarmNode()
 	if err != nil {
@@ -325,6 +513,137 @@ func (p *swarmProvisioner) UpdateNode(provision.UpdateNodeOptions) error {
 	return errNotImplemented
 }
 
+func (p *swarmProvisioner) CordonNode(address string) error {
+	return p.setNodeAvailability(address, swarm.NodeAvailabilityPause)
+}
+
+func (p *swarmProvisioner) UncordonNode(address string) error {
+	return p.setNodeAvailability(address, swarm.NodeAvailabilityActive)
+}
+
+var drainNodeTimeout = 10 * time.Minute
+
+// DrainNode sets the node availability to drain and waits for swarm to
+// reschedule the units running in the node to other nodes. The apps with units
+// in the node are locked while their units are moved.
+func (p *swarmProvisioner) DrainNode(address string, w io.Writer) error {
+	node, err := p.GetNode(address)
+	if err != nil {
+		return err
+	}
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	nodeID := node.(*swarmNodeWrapper).Node.ID
+	tasks, err := runningUnitTasksInNode(client, nodeID)
+	if err != nil {
+		return err
+	}
+	serviceIDs := map[string]struct{}{}
+	var lockedApps []string
+	defer func() {
+		for _, appName := range lockedApps {
+			app.ReleaseApplicationLock(appName)
+		}
+	}()
+	for _, t := range tasks {
+		serviceIDs[t.ServiceID] = struct{}{}
+		appName := t.Spec.ContainerSpec.Labels[labelAppName.String()]
+		if appName == "" || containsString(lockedApps, appName) {
+			continue
+		}
+		var locked bool
+		locked, err = app.AcquireApplicationLock(appName, app.InternalAppName, "node drain")
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return errors.Errorf("unable to lock %q", appName)
+		}
+		lockedApps = append(lockedApps, appName)
+	}
+	err = p.setNodeAvailability(address, swarm.NodeAvailabilityDrain)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Node %s drained, waiting for %d units to be moved to other nodes...\n", address, len(tasks))
+	timeout := time.After(drainNodeTimeout)
+	remaining := len(tasks)
+	for remaining > 0 {
+		select {
+		case <-timeout:
+			return errors.Errorf("timeout waiting for %d units to be moved from node %s", remaining, address)
+		case <-time.After(time.Second):
+		}
+		tasks, err = runningUnitTasksInNode(client, nodeID)
+		if err != nil {
+			return err
+		}
+		if len(tasks) != remaining {
+			remaining = len(tasks)
+			fmt.Fprintf(w, "  ---> %d units remaining in node %s\n", remaining, address)
+		}
+	}
+	for serviceID := range serviceIDs {
+		_, err = waitForTasks(client, serviceID, swarm.TaskStateRunning)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "Node %s drained, all units moved to other nodes.\n", address)
+	return nil
+}
+
+// runningUnitTasksInNode returns the tasks running units of apps in the node
+// that swarm has not yet shut down.
+func runningUnitTasksInNode(client *docker.Client, nodeID string) ([]swarm.Task, error) {
+	tasks, err := client.ListTasks(docker.ListTasksOptions{
+		Filters: map[string][]string{
+			"node": {nodeID},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	var result []swarm.Task
+	for _, t := range tasks {
+		labels := t.Spec.ContainerSpec.Labels
+		if t.DesiredState != swarm.TaskStateRunning || labels[labelService.String()] != "true" || labels[labelServiceDeploy.String()] == "true" {
+			continue
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *swarmProvisioner) setNodeAvailability(address string, availability swarm.NodeAvailability) error {
+	node, err := p.GetNode(address)
+	if err != nil {
+		return err
+	}
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	swarmNode := node.(*swarmNodeWrapper).Node
+	swarmNode.Spec.Availability = availability
+	err = client.UpdateNode(swarmNode.ID, docker.UpdateNodeOptions{
+		NodeSpec: swarmNode.Spec,
+		Version:  swarmNode.Version.Index,
+	})
+	return errors.Wrap(err, "")
+}
+
 func (p *swarmProvisioner) ArchiveDeploy(app provision.App, archiveURL string, evt *event.Event) (imgID string, err error) {
 	baseImage := image.GetBuildImage(app)
 	buildingImage, err := image.AppNewImageName(app.GetName())
@@ -354,6 +673,86 @@ func (p *swarmProvisioner) ArchiveDeploy(app provision.App, archiveURL string, e
 	return buildingImage, nil
 }
 
+func (p *swarmProvisioner) UploadDeploy(a provision.App, archiveFile io.ReadCloser, fileSize int64, build bool, evt *event.Event) (string, error) {
+	defer archiveFile.Close()
+	if build {
+		return "", errors.New("running UploadDeploy with build=true is not yet supported")
+	}
+	baseImage := image.GetBuildImage(a)
+	buildingImage, err := image.AppNewImageName(a.GetName())
+	if err != nil {
+		return "", errors.Wrap(err, "")
+	}
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return "", err
+	}
+	srvID, task, err := runOnceIdleBuildService(client, a, baseImage, buildingImage)
+	if srvID != "" {
+		defer removeServiceAndLog(client, srvID)
+	}
+	if err != nil {
+		return "", err
+	}
+	client, err = clientForNode(client, task.NodeID)
+	if err != nil {
+		return "", err
+	}
+	contID := task.Status.ContainerStatus.ContainerID
+	dirPath := "/home/application/"
+	err = uploadArchive(client, contID, dirPath, "archive.tar.gz", archiveFile, fileSize)
+	if err != nil {
+		return "", err
+	}
+	cmds := dockercommon.ArchiveDeployCmds(a, "file://"+dirPath+"archive.tar.gz")
+	err = execInContainer(execOpts{
+		client:      client,
+		containerID: contID,
+		cmds:        cmds,
+		stdout:      evt,
+		stderr:      evt,
+	})
+	if err != nil {
+		return "", err
+	}
+	_, err = commitPushBuildImage(client, buildingImage, contID, a)
+	if err != nil {
+		return "", err
+	}
+	err = deployProcesses(client, a, buildingImage)
+	if err != nil {
+		return "", err
+	}
+	return buildingImage, nil
+}
+
+func (p *swarmProvisioner) Rollback(a provision.App, imgID string, evt *event.Event) (string, error) {
+	validImgs, err := image.ListValidAppImages(a.GetName())
+	if err != nil {
+		return "", err
+	}
+	valid := false
+	for _, img := range validImgs {
+		if img == imgID {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return "", errors.Errorf("Image %q not found in app", imgID)
+	}
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(evt, "---- Rolling back to image %q ----\n", imgID)
+	err = deployProcesses(client, a, imgID)
+	if err != nil {
+		return "", err
+	}
+	return imgID, nil
+}
+
 func (p *swarmProvisioner) ImageDeploy(a provision.App, imgID string, evt *event.Event) (string, error) {
 	client, err := chooseDBSwarmNode()
 	if err != nil {
@@ -429,6 +828,151 @@ func (p *swarmProvisioner) ImageDeploy(a provision.App, imgID string, evt *event
 	return newImage, nil
 }
 
+func (p *swarmProvisioner) Shell(opts provision.ShellOptions) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	tasks, err := runningTasksForApp(client, opts.App, opts.Unit)
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		if opts.Unit != "" {
+			return &provision.UnitNotFoundError{ID: opts.Unit}
+		}
+		return provision.ErrEmptyApp
+	}
+	nodeClient, err := clientForNode(client, tasks[0].NodeID)
+	if err != nil {
+		return err
+	}
+	return execInContainer(execOpts{
+		client:      nodeClient,
+		containerID: tasks[0].Status.ContainerStatus.ContainerID,
+		cmds:        []string{"/usr/bin/env", "TERM=" + opts.Term, "bash", "-l"},
+		stdout:      opts.Conn,
+		stderr:      opts.Conn,
+		stdin:       opts.Conn,
+		useTty:      true,
+		width:       opts.Width,
+		height:      opts.Height,
+	})
+}
+
+func (p *swarmProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, a provision.App, cmd string, args ...string) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	tasks, err := runningTasksForApp(client, a, "")
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return provision.ErrEmptyApp
+	}
+	return execInTask(client, tasks[0], stdout, stderr, cmd, args...)
+}
+
+func (p *swarmProvisioner) ExecuteCommand(stdout, stderr io.Writer, a provision.App, cmd string, args ...string) error {
+	client, err := chooseDBSwarmNode()
+	if err != nil {
+		return err
+	}
+	tasks, err := runningTasksForApp(client, a, "")
+	if err != nil {
+		return err
+	}
+	if len(tasks) == 0 {
+		return provision.ErrEmptyApp
+	}
+	for _, t := range tasks {
+		err = execInTask(client, t, stdout, stderr, cmd, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runningTasksForApp returns the tasks running the units of the app, ignoring
+// build services and tasks being shut down. When unitID is not empty, only
+// the task running the unit with the given container id (or id prefix) is
+// returned.
+func runningTasksForApp(client *docker.Client, a provision.App, unitID string) ([]swarm.Task, error) {
+	tasks, err := client.ListTasks(docker.ListTasksOptions{
+		Filters: map[string][]string{
+			"label": {fmt.Sprintf("%s=%s", labelAppName, a.GetName())},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	var result []swarm.Task
+	for _, t := range tasks {
+		if t.DesiredState == swarm.TaskStateShutdown || t.Spec.ContainerSpec.Labels[labelServiceDeploy.String()] == "true" {
+			continue
+		}
+		contID := t.Status.ContainerStatus.ContainerID
+		if contID == "" {
+			continue
+		}
+		if unitID != "" {
+			if strings.HasPrefix(contID, unitID) {
+				return []swarm.Task{t}, nil
+			}
+			continue
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// taskForUnit returns the task running the unit with the given container
+// id. Tasks are listed only for the app of the unit when it's known, or by the
+// task id found in the name swarm gives to task containers
+// (<service>.<slot>.<task id>), to avoid listing every task in the cluster.
+func taskForUnit(client *docker.Client, unit provision.Unit) (*swarm.Task, error) {
+	filters := map[string][]string{
+		"label": {labelService.String() + "=true"},
+	}
+	if unit.AppName != "" {
+		filters = map[string][]string{
+			"label": {fmt.Sprintf("%s=%s", labelAppName, unit.AppName)},
+		}
+	} else if parts := strings.Split(strings.TrimPrefix(unit.Name, "/"), "."); len(parts) == 3 {
+		filters = map[string][]string{
+			"id": {parts[2]},
+		}
+	}
+	tasks, err := client.ListTasks(docker.ListTasksOptions{Filters: filters})
+	if err != nil {
+		return nil, errors.Wrap(err, "")
+	}
+	for i, t := range tasks {
+		if t.Status.ContainerStatus.ContainerID == unit.ID {
+			return &tasks[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func execInTask(client *docker.Client, t swarm.Task, stdout, stderr io.Writer, cmd string, args ...string) error {
+	nodeClient, err := clientForNode(client, t.NodeID)
+	if err != nil {
+		return err
+	}
+	cmds := append([]string{"/bin/bash", "-lc", cmd}, args...)
+	return execInContainer(execOpts{
+		client:      nodeClient,
+		containerID: t.Status.ContainerStatus.ContainerID,
+		cmds:        cmds,
+		stdout:      stdout,
+		stderr:      stderr,
+	})
+}
+
 func deployProcesses(client *docker.Client, a provision.App, imgID string) error {
 	imageData, err := image.GetImageCustomData(imgID)
 	if err != nil {
@@ -540,3 +1084,110 @@ func runOnceBuildCmds(client *docker.Client, a provision.App, cmds []string, img
 	}
 	return createdID, &tasks[0], nil
 }
+
+// changeServiceSpec runs fn against the spec of the service running the
+// process and updates the service with the result.
+func changeServiceSpec(client *docker.Client, a provision.App, process string, fn func(*swarm.ServiceSpec) error) error {
+	srv, err := client.InspectService(serviceNameForApp(a, process))
+	if err != nil {
+		if _, isNotFound := err.(*docker.NoSuchService); isNotFound {
+			return errors.Errorf("no units found for process %q, a deploy is required", process)
+		}
+		return errors.Wrap(err, "")
+	}
+	if srv.Spec.Annotations.Labels == nil {
+		srv.Spec.Annotations.Labels = map[string]string{}
+	}
+	if srv.Spec.TaskTemplate.ContainerSpec.Labels == nil {
+		srv.Spec.TaskTemplate.ContainerSpec.Labels = map[string]string{}
+	}
+	if srv.Spec.Mode.Replicated == nil {
+		srv.Spec.Mode.Replicated = &swarm.ReplicatedService{}
+	}
+	if srv.Spec.Mode.Replicated.Replicas == nil {
+		var replicas uint64
+		srv.Spec.Mode.Replicated.Replicas = &replicas
+	}
+	err = fn(&srv.Spec)
+	if err != nil {
+		return err
+	}
+	err = client.UpdateService(srv.ID, docker.UpdateServiceOptions{
+		Version:     srv.Version.Index,
+		ServiceSpec: srv.Spec,
+	})
+	return errors.Wrap(err, "")
+}
+
+// runRestartAfterHooks runs the restart:after hooks declared in the tsuru.yaml
+// of the service image for the service process in every unit of the service,
+// once they're running.
+func runRestartAfterHooks(client *docker.Client, spec *swarm.ServiceSpec, w io.Writer) error {
+	if *spec.Mode.Replicated.Replicas == 0 {
+		return nil
+	}
+	yamlData, err := image.GetImageTsuruYamlData(spec.TaskTemplate.ContainerSpec.Image)
+	if err != nil {
+		return err
+	}
+	process := spec.TaskTemplate.ContainerSpec.Labels[labelAppProcess.String()]
+	cmds := yamlData.ProcessData(process).Hooks.Restart.After
+	if len(cmds) == 0 {
+		return nil
+	}
+	tasks, err := waitForTasks(client, spec.Annotations.Name, swarm.TaskStateRunning)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if t.DesiredState == swarm.TaskStateShutdown {
+			continue
+		}
+		nodeClient, err := clientForNode(client, t.NodeID)
+		if err != nil {
+			return err
+		}
+		contID := t.Status.ContainerStatus.ContainerID
+		for _, cmd := range cmds {
+			err = execInContainer(execOpts{
+				client:      nodeClient,
+				containerID: contID,
+				cmds:        []string{"/bin/sh", "-lc", cmd},
+				stdout:      w,
+				stderr:      w,
+			})
+			if err != nil {
+				return errors.Wrapf(err, "couldn't execute restart:after hook %q(%s)", cmd, contID)
+			}
+		}
+	}
+	return nil
+}
+
+// runOnceIdleBuildService creates a build service whose single task keeps
+// running doing nothing, allowing files to be uploaded and commands to be
+// executed in it.
+func runOnceIdleBuildService(client *docker.Client, a provision.App, imgID, buildingImage string) (string, *swarm.Task, error) {
+	spec, err := serviceSpecForApp(tsuruServiceOpts{
+		app:        a,
+		image:      imgID,
+		isDeploy:   true,
+		buildImage: buildingImage,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	spec.TaskTemplate.ContainerSpec.Command = []string{"/bin/sh", "-c", "tail -f /dev/null"}
+	spec.TaskTemplate.RestartPolicy.Condition = swarm.RestartPolicyConditionNone
+	srv, err := client.CreateService(docker.CreateServiceOptions{
+		ServiceSpec: *spec,
+	})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "")
+	}
+	tasks, err := waitForTasks(client, srv.ID, swarm.TaskStateRunning)
+	if err != nil {
+		return srv.ID, nil, err
+	}
+	return srv.ID, &tasks[0], nil
+}
@@ -5,13 +5,16 @@
 package swarm
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/fsouza/go-dockerclient/testing"
 	"github.com/pkg/errors"
@@ -182,6 +185,82 @@ func (s *S) TestRemoveNodeRebalance(c *check.C) {
 	c.Assert(reqs[1].Method, check.Equals, "DELETE")
 }
 
+func (s *S) TestCordonNode(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	opts := provision.AddNodeOptions{
+		Address:  srv.URL(),
+		Metadata: map[string]string{"pool": "p1"},
+	}
+	err = s.p.AddNode(opts)
+	c.Assert(err, check.IsNil)
+	err = s.p.CordonNode(srv.URL())
+	c.Assert(err, check.IsNil)
+	node, err := s.p.GetNode(srv.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(node.(*swarmNodeWrapper).Spec.Availability, check.Equals, swarm.NodeAvailabilityPause)
+	err = s.p.UncordonNode(srv.URL())
+	c.Assert(err, check.IsNil)
+	node, err = s.p.GetNode(srv.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(node.(*swarmNodeWrapper).Spec.Availability, check.Equals, swarm.NodeAvailabilityActive)
+}
+
+func (s *S) TestDrainNode(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	opts := provision.AddNodeOptions{
+		Address:  srv.URL(),
+		Metadata: map[string]string{"pool": "p1"},
+	}
+	err = s.p.AddNode(opts)
+	c.Assert(err, check.IsNil)
+	var buf bytes.Buffer
+	err = s.p.DrainNode(srv.URL(), &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, "(?s)Node .* drained, waiting for 0 units.*Node .* drained, all units moved to other nodes.\n")
+	node, err := s.p.GetNode(srv.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(node.(*swarmNodeWrapper).Spec.Availability, check.Equals, swarm.NodeAvailabilityDrain)
+}
+
+func (s *S) TestDrainNodeWaitsForUnits(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	oldTimeout := drainNodeTimeout
+	drainNodeTimeout = 100 * time.Millisecond
+	defer func() { drainNodeTimeout = oldTimeout }()
+	var buf bytes.Buffer
+	err = s.p.DrainNode(srv.URL(), &buf)
+	c.Assert(err, check.ErrorMatches, `timeout waiting for 2 units to be moved from node .*`)
+	c.Assert(buf.String(), check.Matches, "Node .* drained, waiting for 2 units to be moved to other nodes...\n")
+	locked, err := app.AcquireApplicationLock(a.Name, "x", "y")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	app.ReleaseApplicationLock(a.Name)
+}
+
+func (s *S) TestDrainNodeAppLocked(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	locked, err := app.AcquireApplicationLock(a.Name, "x", "y")
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	defer app.ReleaseApplicationLock(a.Name)
+	err = s.p.DrainNode(srv.URL(), ioutil.Discard)
+	c.Assert(err, check.ErrorMatches, `unable to lock "myapp"`)
+	node, err := s.p.GetNode(srv.URL())
+	c.Assert(err, check.IsNil)
+	c.Assert(node.(*swarmNodeWrapper).Spec.Availability, check.Equals, swarm.NodeAvailabilityActive)
+}
+
+func (s *S) TestCordonNodeNotFound(c *check.C) {
+	err := s.p.CordonNode("localhost:1000")
+	c.Assert(errors.Cause(err), check.Equals, provision.ErrNodeNotFound)
+}
+
 func (s *S) TestRemoveNodeNotFound(c *check.C) {
 	err := s.p.RemoveNode(provision.RemoveNodeOptions{
 		Address: "localhost:1000",
@@ -306,3 +385,573 @@ func (s *S) attachRegister(c *check.C, srv *testing.DockerServer, register bool)
 	}))
 	return chAttached
 }
+
+func (s *S) prepareDeployedApp(c *check.C, srv *testing.DockerServer, customData map[string]interface{}) *app.App {
+	err := s.p.AddNode(provision.AddNodeOptions{Address: srv.URL()})
+	c.Assert(err, check.IsNil)
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err = app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	imgName, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	if customData == nil {
+		customData = map[string]interface{}{
+			"processes": map[string]interface{}{
+				"web":    "python web.py",
+				"worker": "python worker.py",
+			},
+		}
+	}
+	err = image.SaveImageCustomData(imgName, customData)
+	c.Assert(err, check.IsNil)
+	client, err := chooseDBSwarmNode()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(client, a, imgName)
+	c.Assert(err, check.IsNil)
+	return a
+}
+
+func (s *S) serviceReplicas(c *check.C, a provision.App, process string) uint64 {
+	client, err := chooseDBSwarmNode()
+	c.Assert(err, check.IsNil)
+	srv, err := client.InspectService(serviceNameForApp(a, process))
+	c.Assert(err, check.IsNil)
+	return *srv.Spec.Mode.Replicated.Replicas
+}
+
+func (s *S) TestRemoveAllUnits(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	err = s.p.RemoveAllUnits(a)
+	c.Assert(err, check.IsNil)
+	client, err := chooseDBSwarmNode()
+	c.Assert(err, check.IsNil)
+	services, err := client.ListServices(docker.ListServicesOptions{})
+	c.Assert(err, check.IsNil)
+	c.Assert(services, check.HasLen, 0)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 0)
+}
+
+func (s *S) TestAddUnits(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	_, err = s.p.AddUnits(a, 2, "worker", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serviceReplicas(c, a, "worker"), check.Equals, uint64(3))
+	c.Assert(s.serviceReplicas(c, a, "web"), check.Equals, uint64(1))
+}
+
+func (s *S) TestAddUnitsNoProcessMultipleProcesses(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	_, err = s.p.AddUnits(a, 1, "", nil)
+	c.Assert(err, check.ErrorMatches, "process error: no process name specified and more than one declared in Procfile")
+}
+
+func (s *S) TestRemoveUnits(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	_, err = s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serviceReplicas(c, a, "web"), check.Equals, uint64(1))
+}
+
+func (s *S) TestRemoveUnitsTooMany(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	err = s.p.RemoveUnits(a, 2, "web", nil)
+	c.Assert(err, check.ErrorMatches, `cannot remove 2 units from process "web", only 1 available`)
+}
+
+func (s *S) TestRemoveUnitsInvalidProcess(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	err = s.p.RemoveUnits(a, 1, "invalid", nil)
+	c.Assert(err, check.ErrorMatches, `process error: no command declared in Procfile for process "invalid"`)
+}
+
+func (s *S) TestRestart(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	var buf bytes.Buffer
+	err = s.p.Restart(a, "web", &buf)
+	c.Assert(err, check.IsNil)
+	c.Assert(buf.String(), check.Matches, `(?s).*Restarting process "web".*`)
+	client, err := chooseDBSwarmNode()
+	c.Assert(err, check.IsNil)
+	service, err := client.InspectService("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(service.Spec.TaskTemplate.ContainerSpec.Labels["tsuru.service.restart"], check.Equals, "1")
+	newUnits, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(newUnits, check.HasLen, 2)
+	oldIDs := map[string]string{}
+	for _, u := range units {
+		oldIDs[u.ProcessName] = u.ID
+	}
+	for _, u := range newUnits {
+		if u.ProcessName == "web" {
+			c.Assert(u.ID, check.Not(check.Equals), oldIDs["web"])
+		} else {
+			c.Assert(u.ID, check.Equals, oldIDs["worker"])
+		}
+	}
+	err = s.p.Restart(a, "", nil)
+	c.Assert(err, check.IsNil)
+	service, err = client.InspectService("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(service.Spec.TaskTemplate.ContainerSpec.Labels["tsuru.service.restart"], check.Equals, "2")
+}
+
+func (s *S) TestRestartRunsAfterHooks(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web": "python web.py",
+		},
+		"hooks": map[string]interface{}{
+			"restart": map[string]interface{}{
+				"before": []string{"echo before"},
+				"after":  []string{"echo after"},
+			},
+		},
+	})
+	executed := make(chan bool, 1)
+	srv.PrepareExec("*", func() {
+		executed <- true
+	})
+	err = s.p.Restart(a, "", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(<-executed, check.Equals, true)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	cont, err := cli.InspectContainer(units[0].ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(cont.ExecIDs, check.HasLen, 1)
+	execData, err := cli.InspectExec(cont.ExecIDs[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(execData.ProcessConfig.EntryPoint, check.Equals, "/bin/sh")
+	c.Assert(execData.ProcessConfig.Arguments, check.DeepEquals, []string{"-lc", "echo after"})
+	c.Assert(cont.Config.Entrypoint, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; echo before && exec python web.py",
+	})
+}
+
+func (s *S) TestRestartRunsProcessHooks(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, map[string]interface{}{
+		"procfile": "web: python web.py\nworker: python worker.py",
+		"hooks": map[string]interface{}{
+			"restart": map[string]interface{}{
+				"before": []string{"echo before"},
+				"after":  []string{"echo after"},
+			},
+		},
+		"processes": map[string]interface{}{
+			"worker": map[string]interface{}{
+				"hooks": map[string]interface{}{
+					"restart": map[string]interface{}{
+						"before": []string{"echo worker before"},
+						"after":  []string{"echo worker after"},
+					},
+				},
+			},
+		},
+	})
+	executed := make(chan bool, 1)
+	srv.PrepareExec("*", func() {
+		executed <- true
+	})
+	err = s.p.Restart(a, "worker", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(<-executed, check.Equals, true)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	var unit provision.Unit
+	for _, u := range units {
+		if u.ProcessName == "worker" {
+			unit = u
+		}
+	}
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	cont, err := cli.InspectContainer(unit.ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(cont.ExecIDs, check.HasLen, 1)
+	execData, err := cli.InspectExec(cont.ExecIDs[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(execData.ProcessConfig.Arguments, check.DeepEquals, []string{"-lc", "echo worker after"})
+	c.Assert(cont.Config.Entrypoint, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; echo worker before && exec python worker.py",
+	})
+}
+
+func (s *S) TestStopStart(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	_, err = s.p.AddUnits(a, 2, "web", nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.Stop(a, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serviceReplicas(c, a, "web"), check.Equals, uint64(0))
+	c.Assert(s.serviceReplicas(c, a, "worker"), check.Equals, uint64(0))
+	client, err := chooseDBSwarmNode()
+	c.Assert(err, check.IsNil)
+	service, err := client.InspectService("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(service.Spec.Annotations.Labels["tsuru.service.stopped-replicas"], check.Equals, "3")
+	err = s.p.Start(a, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serviceReplicas(c, a, "web"), check.Equals, uint64(3))
+	c.Assert(s.serviceReplicas(c, a, "worker"), check.Equals, uint64(1))
+	service, err = client.InspectService("myapp-web")
+	c.Assert(err, check.IsNil)
+	_, ok := service.Spec.Annotations.Labels["tsuru.service.stopped-replicas"]
+	c.Assert(ok, check.Equals, false)
+}
+
+func (s *S) TestStopNoRegisteredProcesses(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	imgName, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData(imgName, map[string]interface{}{})
+	c.Assert(err, check.IsNil)
+	err = image.AppendAppImageName(a.GetName(), imgName)
+	c.Assert(err, check.IsNil)
+	err = s.p.Stop(a, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serviceReplicas(c, a, "web"), check.Equals, uint64(0))
+	c.Assert(s.serviceReplicas(c, a, "worker"), check.Equals, uint64(1))
+}
+
+func (s *S) TestStopSingleProcess(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	err = s.p.Stop(a, "worker")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.serviceReplicas(c, a, "web"), check.Equals, uint64(1))
+	c.Assert(s.serviceReplicas(c, a, "worker"), check.Equals, uint64(0))
+	err = s.p.Start(a, "invalid")
+	c.Assert(err, check.ErrorMatches, `process error: process "invalid" not found in app`)
+}
+
+type fakeConn struct {
+	buf *bytes.Buffer
+}
+
+func (c *fakeConn) Read(b []byte) (int, error) {
+	return c.buf.Read(b)
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	return c.buf.Write(b)
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (s *S) TestShell(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	conn := &fakeConn{buf: bytes.NewBufferString("echo hi\n")}
+	err = s.p.Shell(provision.ShellOptions{
+		App:    a,
+		Conn:   conn,
+		Width:  140,
+		Height: 38,
+		Unit:   units[1].ID[:10],
+		Term:   "xterm",
+	})
+	c.Assert(err, check.IsNil)
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	cont, err := cli.InspectContainer(units[1].ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(cont.ExecIDs, check.HasLen, 1)
+	execData, err := cli.InspectExec(cont.ExecIDs[0])
+	c.Assert(err, check.IsNil)
+	c.Assert(execData.ProcessConfig.EntryPoint, check.Equals, "/usr/bin/env")
+	c.Assert(execData.ProcessConfig.Arguments, check.DeepEquals, []string{"TERM=xterm", "bash", "-l"})
+	c.Assert(execData.ProcessConfig.Tty, check.Equals, true)
+}
+
+func (s *S) TestShellUnitNotFound(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	err = s.p.Shell(provision.ShellOptions{App: a, Conn: &fakeConn{buf: &bytes.Buffer{}}, Unit: "invalid"})
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+}
+
+func (s *S) TestSetUnitStatus(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	err = s.p.SetUnitStatus(provision.Unit{ID: units[0].ID}, provision.StatusStarted)
+	c.Assert(err, check.IsNil)
+	reports, err := provision.ListUnresponsiveUnitReports(-time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 1)
+	c.Assert(reports[0].ID, check.Equals, units[0].ID)
+	c.Assert(reports[0].AppName, check.Equals, a.Name)
+}
+
+func (s *S) TestSetUnitStatusByContainerName(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	tasks, err := cli.ListTasks(docker.ListTasksOptions{
+		Filters: map[string][]string{
+			"label": {fmt.Sprintf("%s=%s", labelAppName, a.Name)},
+		},
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(tasks, check.Not(check.HasLen), 0)
+	unit := provision.Unit{
+		ID:   tasks[0].Status.ContainerStatus.ContainerID,
+		Name: fmt.Sprintf("/%s-web.1.%s", a.Name, tasks[0].ID),
+	}
+	err = s.p.SetUnitStatus(unit, provision.StatusStarted)
+	c.Assert(err, check.IsNil)
+	reports, err := provision.ListUnresponsiveUnitReports(-time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 1)
+	c.Assert(reports[0].ID, check.Equals, unit.ID)
+	c.Assert(reports[0].AppName, check.Equals, a.Name)
+	unit.Name = fmt.Sprintf("/%s-web.1.invalid", a.Name)
+	err = s.p.SetUnitStatus(unit, provision.StatusStarted)
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+}
+
+func (s *S) TestSetUnitStatusNotFound(c *check.C) {
+	err := s.p.SetUnitStatus(provision.Unit{ID: "invalid"}, provision.StatusStarted)
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	s.prepareDeployedApp(c, srv, nil)
+	err = s.p.SetUnitStatus(provision.Unit{ID: "invalid"}, provision.StatusStarted)
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+}
+
+func (s *S) TestHealUnit(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	err = s.p.HealUnit(units[0])
+	c.Assert(err, check.IsNil)
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	_, err = cli.InspectContainer(units[0].ID)
+	c.Assert(err, check.FitsTypeOf, &docker.NoSuchContainer{})
+	err = s.p.HealUnit(provision.Unit{ID: "invalid"})
+	c.Assert(err, check.FitsTypeOf, &provision.UnitNotFoundError{})
+}
+
+func (s *S) TestExecuteCommand(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	var stdout, stderr bytes.Buffer
+	err = s.p.ExecuteCommand(&stdout, &stderr, a, "ls", "-l")
+	c.Assert(err, check.IsNil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 2)
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	for _, u := range units {
+		cont, err := cli.InspectContainer(u.ID)
+		c.Assert(err, check.IsNil)
+		c.Assert(cont.ExecIDs, check.HasLen, 1)
+		execData, err := cli.InspectExec(cont.ExecIDs[0])
+		c.Assert(err, check.IsNil)
+		c.Assert(execData.ProcessConfig.EntryPoint, check.Equals, "/bin/bash")
+		c.Assert(execData.ProcessConfig.Arguments, check.DeepEquals, []string{"-lc", "ls", "-l"})
+	}
+}
+
+func (s *S) TestExecuteCommandOnce(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	var stdout, stderr bytes.Buffer
+	err = s.p.ExecuteCommandOnce(&stdout, &stderr, a, "ls", "-l")
+	c.Assert(err, check.IsNil)
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	var execCount int
+	for _, u := range units {
+		cont, err := cli.InspectContainer(u.ID)
+		c.Assert(err, check.IsNil)
+		execCount += len(cont.ExecIDs)
+	}
+	c.Assert(execCount, check.Equals, 1)
+}
+
+func (s *S) TestExecuteCommandNoUnits(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.AddNode(provision.AddNodeOptions{Address: srv.URL()})
+	c.Assert(err, check.IsNil)
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	err = app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	err = s.p.ExecuteCommand(nil, nil, a, "ls")
+	c.Assert(err, check.Equals, provision.ErrEmptyApp)
+	err = s.p.ExecuteCommandOnce(nil, nil, a, "ls")
+	c.Assert(err, check.Equals, provision.ErrEmptyApp)
+}
+
+func (s *S) TestUploadDeploy(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	err = s.p.AddNode(provision.AddNodeOptions{Address: srv.URL()})
+	c.Assert(err, check.IsNil)
+	a := &app.App{Name: "myapp", Platform: "whitespace", TeamOwner: s.team.Name}
+	err = app.CreateApp(a, s.user)
+	c.Assert(err, check.IsNil)
+	var buildContID string
+	srv.PrepareExec("*", func() {
+		client, err := chooseDBSwarmNode()
+		c.Assert(err, check.IsNil)
+		tasks, err := client.ListTasks(docker.ListTasksOptions{
+			Filters: map[string][]string{"label": {"tsuru.service.deploy=true"}},
+		})
+		c.Assert(err, check.IsNil)
+		c.Assert(tasks, check.HasLen, 1)
+		buildContID = tasks[0].Status.ContainerStatus.ContainerID
+		err = s.p.RegisterUnit(provision.Unit{ID: buildContID}, map[string]interface{}{
+			"processes": map[string]interface{}{
+				"web": "python myapp.py",
+			},
+		})
+		c.Assert(err, check.IsNil)
+	})
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: a.GetName()},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppDeploy),
+	})
+	c.Assert(err, check.IsNil)
+	archive := ioutil.NopCloser(bytes.NewBufferString("my archive data"))
+	imgID, err := s.p.UploadDeploy(a, archive, int64(len("my archive data")), false, evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(imgID, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(buildContID, check.Not(check.Equals), "")
+	cli, err := docker.NewClient(srv.URL())
+	c.Assert(err, check.IsNil)
+	_, err = cli.InspectContainer(buildContID)
+	c.Assert(err, check.FitsTypeOf, &docker.NoSuchContainer{})
+	dbImg, err := image.AppCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbImg, check.Equals, "tsuru/app-myapp:v1")
+	units, err := s.p.Units(a)
+	c.Assert(err, check.IsNil)
+	c.Assert(units, check.HasLen, 1)
+	cont, err := cli.InspectContainer(units[0].ID)
+	c.Assert(err, check.IsNil)
+	c.Assert(cont.Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(cont.Config.Entrypoint, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; exec python myapp.py",
+	})
+}
+
+func (s *S) TestUploadDeployWithBuild(c *check.C) {
+	a := &app.App{Name: "myapp", TeamOwner: s.team.Name}
+	_, err := s.p.UploadDeploy(a, ioutil.NopCloser(&bytes.Buffer{}), 0, true, nil)
+	c.Assert(err, check.ErrorMatches, "running UploadDeploy with build=true is not yet supported")
+}
+
+func (s *S) TestRollback(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	newImg, err := image.AppNewImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	err = image.SaveImageCustomData(newImg, map[string]interface{}{
+		"processes": map[string]interface{}{
+			"web":    "python web2.py",
+			"worker": "python worker2.py",
+		},
+	})
+	c.Assert(err, check.IsNil)
+	client, err := chooseDBSwarmNode()
+	c.Assert(err, check.IsNil)
+	err = deployProcesses(client, a, newImg)
+	c.Assert(err, check.IsNil)
+	evt, err := event.New(&event.Opts{
+		Target:  event.Target{Type: event.TargetTypeApp, Value: a.GetName()},
+		Kind:    permission.PermAppDeploy,
+		Owner:   s.token,
+		Allowed: event.Allowed(permission.PermAppDeploy),
+	})
+	c.Assert(err, check.IsNil)
+	img, err := s.p.Rollback(a, "tsuru/app-myapp:v1", evt)
+	c.Assert(err, check.IsNil)
+	c.Assert(img, check.Equals, "tsuru/app-myapp:v1")
+	service, err := client.InspectService("myapp-web")
+	c.Assert(err, check.IsNil)
+	c.Assert(service.Spec.TaskTemplate.ContainerSpec.Image, check.Equals, "tsuru/app-myapp:v1")
+	c.Assert(service.Spec.TaskTemplate.ContainerSpec.Command, check.DeepEquals, []string{
+		"/bin/sh",
+		"-lc",
+		"[ -d /home/application/current ] && cd /home/application/current; exec python web.py",
+	})
+	dbImg, err := image.AppCurrentImageName(a.GetName())
+	c.Assert(err, check.IsNil)
+	c.Assert(dbImg, check.Equals, "tsuru/app-myapp:v1")
+}
+
+func (s *S) TestRollbackInvalidImage(c *check.C) {
+	srv, err := testing.NewServer("127.0.0.1:0", nil, nil)
+	c.Assert(err, check.IsNil)
+	a := s.prepareDeployedApp(c, srv, nil)
+	_, err = s.p.Rollback(a, "tsuru/app-myapp:v9", nil)
+	c.Assert(err, check.ErrorMatches, `Image "tsuru/app-myapp:v9" not found in app`)
+}
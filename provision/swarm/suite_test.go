@@ -65,6 +65,7 @@ func (s *S) SetUpTest(c *check.C) {
 	}
 	err = p.Save()
 	c.Assert(err, check.IsNil)
+	swarmConfig.tlsConfig = nil
 	s.p = &swarmProvisioner{}
 	err = s.p.Initialize()
 	c.Assert(err, check.IsNil)
@@ -0,0 +1,88 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"time"
+
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const unitReportsCollection = "unit_reports"
+
+// UnitReport holds the last time a unit successfully reported its status. It
+// is used to find units which must be healed in provisioners that don't
+// track the status of their units.
+type UnitReport struct {
+	ID                      string `bson:"_id"`
+	AppName                 string
+	LastSuccessStatusUpdate time.Time
+}
+
+func unitReportsColl() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	coll := conn.Collection(unitReportsCollection)
+	coll.EnsureIndex(mgo.Index{Key: []string{"lastsuccessstatusupdate"}})
+	return coll, nil
+}
+
+// ReportUnitStatus records that the unit reported the given status. Only
+// statuses of healthy units are recorded, so a unit continuously reporting
+// errors is considered unresponsive.
+func ReportUnitStatus(unit Unit, status Status) error {
+	if status != StatusStarted && status != StatusStarting && status != StatusStopped {
+		return nil
+	}
+	coll, err := unitReportsColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	_, err = coll.UpsertId(unit.ID, UnitReport{
+		ID:                      unit.ID,
+		AppName:                 unit.AppName,
+		LastSuccessStatusUpdate: time.Now().UTC(),
+	})
+	return err
+}
+
+// ListUnresponsiveUnitReports returns the reports of units which haven't
+// reported a healthy status for longer than maxUnresponsiveTime.
+func ListUnresponsiveUnitReports(maxUnresponsiveTime time.Duration) ([]UnitReport, error) {
+	coll, err := unitReportsColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var reports []UnitReport
+	err = coll.Find(bson.M{
+		"lastsuccessstatusupdate": bson.M{"$lt": time.Now().UTC().Add(-maxUnresponsiveTime)},
+	}).All(&reports)
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// RemoveUnitReport removes the report of a unit, which won't be considered
+// for healing until it reports its status again.
+func RemoveUnitReport(id string) error {
+	coll, err := unitReportsColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
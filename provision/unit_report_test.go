@@ -0,0 +1,55 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package provision
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *S) TestReportUnitStatus(c *check.C) {
+	err := ReportUnitStatus(Unit{ID: "u1", AppName: "myapp"}, StatusStarted)
+	c.Assert(err, check.IsNil)
+	err = ReportUnitStatus(Unit{ID: "u2", AppName: "myapp"}, StatusError)
+	c.Assert(err, check.IsNil)
+	var reports []UnitReport
+	err = s.storage.Collection(unitReportsCollection).Find(nil).All(&reports)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 1)
+	c.Assert(reports[0].ID, check.Equals, "u1")
+	c.Assert(reports[0].AppName, check.Equals, "myapp")
+	c.Assert(time.Since(reports[0].LastSuccessStatusUpdate) < time.Minute, check.Equals, true)
+}
+
+func (s *S) TestListUnresponsiveUnitReports(c *check.C) {
+	coll := s.storage.Collection(unitReportsCollection)
+	err := coll.Insert(UnitReport{ID: "u1", AppName: "myapp", LastSuccessStatusUpdate: time.Now().UTC().Add(-10 * time.Minute)})
+	c.Assert(err, check.IsNil)
+	err = ReportUnitStatus(Unit{ID: "u2", AppName: "myapp"}, StatusStarting)
+	c.Assert(err, check.IsNil)
+	reports, err := ListUnresponsiveUnitReports(5 * time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 1)
+	c.Assert(reports[0].ID, check.Equals, "u1")
+	err = ReportUnitStatus(Unit{ID: "u1", AppName: "myapp"}, StatusStopped)
+	c.Assert(err, check.IsNil)
+	reports, err = ListUnresponsiveUnitReports(5 * time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(reports, check.HasLen, 0)
+}
+
+func (s *S) TestRemoveUnitReport(c *check.C) {
+	err := ReportUnitStatus(Unit{ID: "u1", AppName: "myapp"}, StatusStarted)
+	c.Assert(err, check.IsNil)
+	err = RemoveUnitReport("u1")
+	c.Assert(err, check.IsNil)
+	n, err := s.storage.Collection(unitReportsCollection).Find(bson.M{"_id": "u1"}).Count()
+	c.Assert(err, check.IsNil)
+	c.Assert(n, check.Equals, 0)
+	err = RemoveUnitReport("u1")
+	c.Assert(err, check.IsNil)
+}
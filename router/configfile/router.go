@@ -0,0 +1,491 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package configfile provides a router implementation that renders the
+// configuration file of a standard proxy, like nginx or HAProxy, and reloads
+// the proxy whenever a backend, route, CName or healthcheck changes.
+//
+// The state of the router is stored in MongoDB, the configuration file is
+// always rendered from scratch using all backends of the router. In order to
+// use this router, you need to define "routers:<name>:type = nginx" or
+// "routers:<name>:type = haproxy" in your config.
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/storage"
+	"github.com/tsuru/tsuru/exec"
+	"github.com/tsuru/tsuru/router"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	nginxType   = "nginx"
+	haproxyType = "haproxy"
+
+	backendsCollection = "router_configfile_backends"
+)
+
+var (
+	defaultTemplates = map[string]string{
+		nginxType:   nginxTemplate,
+		haproxyType: haproxyTemplate,
+	}
+
+	idReplace = regexp.MustCompile(`[^\w\d]+`)
+
+	// reloadMut serializes the rendering of configuration files and the
+	// execution of reload commands in this process.
+	reloadMut sync.Mutex
+)
+
+func init() {
+	router.Register(nginxType, createRouter)
+	router.Register(haproxyType, createRouter)
+}
+
+type configFileRouter struct {
+	routerName string
+	routerType string
+	domain     string
+	configFile string
+	reloadCmd  string
+	tmpl       *template.Template
+	executor   exec.Executor
+}
+
+// backend is the stored state of a backend of the router.
+type backend struct {
+	Router      string
+	Name        string
+	Routes      []string
+	CNames      []string
+	Healthcheck router.HealthcheckData
+	Opts        map[string]string
+}
+
+// templateBackend is the representation of a backend available to the
+// configuration file template.
+type templateBackend struct {
+	ID          string
+	Name        string
+	Hostname    string
+	Routes      []string
+	CNames      []string
+	Healthcheck router.HealthcheckData
+	Opts        map[string]string
+}
+
+type templateData struct {
+	Router   string
+	Domain   string
+	Backends []templateBackend
+}
+
+var quoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// templateFuncs are the functions available to configuration file templates.
+// quote wraps a value in double quotes, escaping it to be used as a single
+// argument in the configuration file.
+var templateFuncs = template.FuncMap{
+	"quote": func(value string) string {
+		return `"` + quoteReplacer.Replace(value) + `"`
+	},
+}
+
+func createRouter(routerName, configPrefix string) (router.Router, error) {
+	routerType, err := config.GetString(configPrefix + ":type")
+	if err != nil {
+		return nil, err
+	}
+	domain, err := config.GetString(configPrefix + ":domain")
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := config.GetString(configPrefix + ":config-file")
+	if err != nil {
+		return nil, err
+	}
+	reloadCmd, _ := config.GetString(configPrefix + ":reload-command")
+	tmplText := defaultTemplates[routerType]
+	if tmplFile, _ := config.GetString(configPrefix + ":template"); tmplFile != "" {
+		data, err := ioutil.ReadFile(tmplFile)
+		if err != nil {
+			return nil, err
+		}
+		tmplText = string(data)
+	}
+	if tmplText == "" {
+		return nil, fmt.Errorf("no template available for router type %q", routerType)
+	}
+	tmpl, err := template.New(routerName).Funcs(templateFuncs).Parse(tmplText)
+	if err != nil {
+		return nil, err
+	}
+	return &configFileRouter{
+		routerName: routerName,
+		routerType: routerType,
+		domain:     domain,
+		configFile: configFile,
+		reloadCmd:  reloadCmd,
+		tmpl:       tmpl,
+		executor:   exec.OsExecutor{},
+	}, nil
+}
+
+func backendsColl() (*storage.Collection, error) {
+	conn, err := db.Conn()
+	if err != nil {
+		return nil, err
+	}
+	coll := conn.Collection(backendsCollection)
+	coll.EnsureIndex(mgo.Index{Key: []string{"router", "name"}, Unique: true})
+	return coll, nil
+}
+
+func (r *configFileRouter) backendQuery(name string) bson.M {
+	return bson.M{"router": r.routerName, "name": name}
+}
+
+func (r *configFileRouter) hostname(name string) string {
+	return name + "." + r.domain
+}
+
+func (r *configFileRouter) getBackend(name string) (*backend, error) {
+	coll, err := backendsColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var b backend
+	err = coll.Find(r.backendQuery(name)).One(&b)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return nil, router.ErrBackendNotFound
+		}
+		return nil, err
+	}
+	return &b, nil
+}
+
+// updateBackend applies the update to the stored backend and reloads the
+// proxy. It returns notFoundErr when no backend matches the query.
+func (r *configFileRouter) updateBackend(query, update bson.M, notFoundErr error, op string) error {
+	coll, err := backendsColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Update(query, update)
+	if err != nil {
+		if err == mgo.ErrNotFound {
+			return notFoundErr
+		}
+		return &router.RouterError{Op: op, Err: err}
+	}
+	return r.reload()
+}
+
+func (r *configFileRouter) AddBackend(name string) error {
+	return r.AddBackendOpts(name, nil)
+}
+
+func (r *configFileRouter) AddBackendOpts(name string, opts map[string]string) error {
+	coll, err := backendsColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Insert(backend{
+		Router: r.routerName,
+		Name:   name,
+		Routes: []string{},
+		CNames: []string{},
+		Opts:   opts,
+	})
+	if err != nil {
+		if mgo.IsDup(err) {
+			return router.ErrBackendExists
+		}
+		return &router.RouterError{Op: "add", Err: err}
+	}
+	err = router.Store(name, name, r.routerType)
+	if err != nil {
+		return err
+	}
+	return r.reload()
+}
+
+func (r *configFileRouter) RemoveBackend(name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if backendName != name {
+		return router.ErrBackendSwapped
+	}
+	coll, err := backendsColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	err = coll.Remove(r.backendQuery(backendName))
+	if err != nil && err != mgo.ErrNotFound {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	err = router.Remove(backendName)
+	if err != nil {
+		return &router.RouterError{Op: "remove", Err: err}
+	}
+	return r.reload()
+}
+
+func (r *configFileRouter) AddRoute(name string, address *url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	b, err := r.getBackend(backendName)
+	if err != nil {
+		return err
+	}
+	for _, host := range b.Routes {
+		if host == address.Host {
+			return router.ErrRouteExists
+		}
+	}
+	update := bson.M{"$addToSet": bson.M{"routes": address.Host}}
+	return r.updateBackend(r.backendQuery(backendName), update, router.ErrBackendNotFound, "add-route")
+}
+
+func (r *configFileRouter) AddRoutes(name string, addresses []*url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	hosts := make([]string, len(addresses))
+	for i, addr := range addresses {
+		hosts[i] = addr.Host
+	}
+	update := bson.M{"$addToSet": bson.M{"routes": bson.M{"$each": hosts}}}
+	return r.updateBackend(r.backendQuery(backendName), update, router.ErrBackendNotFound, "add-route")
+}
+
+func (r *configFileRouter) RemoveRoute(name string, address *url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	query := r.backendQuery(backendName)
+	query["routes"] = address.Host
+	return r.updateBackend(query, bson.M{"$pull": bson.M{"routes": address.Host}}, router.ErrRouteNotFound, "remove-route")
+}
+
+func (r *configFileRouter) RemoveRoutes(name string, addresses []*url.URL) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	hosts := make([]string, len(addresses))
+	for i, addr := range addresses {
+		hosts[i] = addr.Host
+	}
+	update := bson.M{"$pull": bson.M{"routes": bson.M{"$in": hosts}}}
+	return r.updateBackend(r.backendQuery(backendName), update, router.ErrBackendNotFound, "remove-route")
+}
+
+func (r *configFileRouter) Routes(name string) ([]*url.URL, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.getBackend(backendName)
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]*url.URL, len(b.Routes))
+	for i, host := range b.Routes {
+		routes[i] = &url.URL{Scheme: router.HttpScheme, Host: host}
+	}
+	return routes, nil
+}
+
+func (r *configFileRouter) Addr(name string) (string, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return "", err
+	}
+	_, err = r.getBackend(backendName)
+	if err != nil {
+		return "", err
+	}
+	return r.hostname(backendName), nil
+}
+
+func (r *configFileRouter) Swap(backend1, backend2 string, cnameOnly bool) error {
+	return router.Swap(r, backend1, backend2, cnameOnly)
+}
+
+func (r *configFileRouter) SetCName(cname, name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if !router.ValidCName(cname, r.domain) {
+		return router.ErrCNameNotAllowed
+	}
+	_, err = r.getBackend(backendName)
+	if err != nil {
+		return err
+	}
+	coll, err := backendsColl()
+	if err != nil {
+		return err
+	}
+	defer coll.Close()
+	count, err := coll.Find(bson.M{"router": r.routerName, "cnames": cname}).Count()
+	if err != nil {
+		return &router.RouterError{Op: "set-cname", Err: err}
+	}
+	if count > 0 {
+		return router.ErrCNameExists
+	}
+	return r.updateBackend(r.backendQuery(backendName), bson.M{"$addToSet": bson.M{"cnames": cname}}, router.ErrBackendNotFound, "set-cname")
+}
+
+func (r *configFileRouter) UnsetCName(cname, name string) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	query := r.backendQuery(backendName)
+	query["cnames"] = cname
+	return r.updateBackend(query, bson.M{"$pull": bson.M{"cnames": cname}}, router.ErrCNameNotFound, "unset-cname")
+}
+
+func (r *configFileRouter) CNames(name string) ([]*url.URL, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.getBackend(backendName)
+	if err != nil {
+		return nil, err
+	}
+	cnames := make([]*url.URL, len(b.CNames))
+	for i, cname := range b.CNames {
+		cnames[i] = &url.URL{Host: cname}
+	}
+	return cnames, nil
+}
+
+func (r *configFileRouter) SetHealthcheck(name string, data router.HealthcheckData) error {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	if data.Path == "" {
+		data.Path = "/"
+	}
+	update := bson.M{"$set": bson.M{"healthcheck": data}}
+	return r.updateBackend(r.backendQuery(backendName), update, router.ErrBackendNotFound, "set-healthcheck")
+}
+
+func (r *configFileRouter) StartupMessage() (string, error) {
+	message := fmt.Sprintf("%s router %q writing configuration to %q", r.routerType, r.domain, r.configFile)
+	return message, nil
+}
+
+func (r *configFileRouter) templateData() (*templateData, error) {
+	coll, err := backendsColl()
+	if err != nil {
+		return nil, err
+	}
+	defer coll.Close()
+	var backends []backend
+	err = coll.Find(bson.M{"router": r.routerName}).Sort("name").All(&backends)
+	if err != nil {
+		return nil, err
+	}
+	data := templateData{
+		Router:   r.routerName,
+		Domain:   r.domain,
+		Backends: make([]templateBackend, len(backends)),
+	}
+	for i, b := range backends {
+		sort.Strings(b.Routes)
+		sort.Strings(b.CNames)
+		data.Backends[i] = templateBackend{
+			ID:          "tsuru_" + idReplace.ReplaceAllString(b.Name, "_"),
+			Name:        b.Name,
+			Hostname:    r.hostname(b.Name),
+			Routes:      b.Routes,
+			CNames:      b.CNames,
+			Healthcheck: b.Healthcheck,
+			Opts:        b.Opts,
+		}
+	}
+	return &data, nil
+}
+
+// reload renders the configuration file using the current state of all
+// backends of the router and runs the configured reload command.
+func (r *configFileRouter) reload() error {
+	reloadMut.Lock()
+	defer reloadMut.Unlock()
+	data, err := r.templateData()
+	if err != nil {
+		return &router.RouterError{Op: "reload", Err: err}
+	}
+	var buf bytes.Buffer
+	err = r.tmpl.Execute(&buf, data)
+	if err != nil {
+		return &router.RouterError{Op: "reload", Err: err}
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(r.configFile), filepath.Base(r.configFile))
+	if err != nil {
+		return &router.RouterError{Op: "reload", Err: err}
+	}
+	_, err = tmpFile.Write(buf.Bytes())
+	tmpFile.Close()
+	if err == nil {
+		err = os.Chmod(tmpFile.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), r.configFile)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return &router.RouterError{Op: "reload", Err: err}
+	}
+	if r.reloadCmd == "" {
+		return nil
+	}
+	var out bytes.Buffer
+	err = r.executor.Execute(exec.ExecuteOptions{
+		Cmd:    "/bin/sh",
+		Args:   []string{"-c", r.reloadCmd},
+		Stdout: &out,
+		Stderr: &out,
+	})
+	if err != nil {
+		msg := strings.TrimSpace(out.String())
+		return &router.RouterError{Op: "reload", Err: fmt.Errorf("%s: %s", err, msg)}
+	}
+	return nil
+}
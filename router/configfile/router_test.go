@@ -0,0 +1,289 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tsuru/config"
+	"github.com/tsuru/tsuru/db"
+	"github.com/tsuru/tsuru/db/dbtest"
+	"github.com/tsuru/tsuru/exec/exectest"
+	"github.com/tsuru/tsuru/router"
+	"github.com/tsuru/tsuru/router/routertest"
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct {
+	dir      string
+	executor *exectest.FakeExecutor
+}
+
+var _ = check.Suite(&S{})
+
+func init() {
+	var dir string
+	suite := &routertest.RouterSuite{
+		SetUpSuiteFunc: func(c *check.C) {
+			config.Set("database:url", "127.0.0.1:27017")
+			config.Set("database:name", "router_generic_configfile_tests")
+		},
+	}
+	suite.SetUpTestFunc = func(c *check.C) {
+		var err error
+		dir, err = ioutil.TempDir("", "configfile")
+		c.Assert(err, check.IsNil)
+		config.Set("routers:generic_nginx:type", "nginx")
+		config.Set("routers:generic_nginx:domain", "nginx.router")
+		config.Set("routers:generic_nginx:config-file", filepath.Join(dir, "nginx.conf"))
+		r, err := createRouter("generic_nginx", "routers:generic_nginx")
+		c.Assert(err, check.IsNil)
+		r.(*configFileRouter).executor = &exectest.FakeExecutor{}
+		suite.Router = r
+		conn, err := db.Conn()
+		c.Assert(err, check.IsNil)
+		defer conn.Close()
+		dbtest.ClearAllCollections(conn.Collection("router_generic_configfile_tests").Database)
+	}
+	suite.TearDownTestFunc = func(c *check.C) {
+		os.RemoveAll(dir)
+	}
+	check.Suite(suite)
+}
+
+func (s *S) SetUpSuite(c *check.C) {
+	config.Set("database:url", "127.0.0.1:27017")
+	config.Set("database:name", "router_configfile_tests")
+}
+
+func (s *S) SetUpTest(c *check.C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "configfile")
+	c.Assert(err, check.IsNil)
+	s.executor = &exectest.FakeExecutor{}
+	for _, tp := range []string{"nginx", "haproxy"} {
+		config.Set("routers:my"+tp+":type", tp)
+		config.Set("routers:my"+tp+":domain", tp+".router")
+		config.Set("routers:my"+tp+":config-file", filepath.Join(s.dir, tp+".conf"))
+		config.Set("routers:my"+tp+":reload-command", "service "+tp+" reload")
+	}
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	dbtest.ClearAllCollections(conn.Collection("router_configfile_tests").Database)
+}
+
+func (s *S) TearDownTest(c *check.C) {
+	os.RemoveAll(s.dir)
+}
+
+func (s *S) TearDownSuite(c *check.C) {
+	conn, err := db.Conn()
+	c.Assert(err, check.IsNil)
+	defer conn.Close()
+	conn.Apps().Database.DropDatabase()
+}
+
+func (s *S) newRouter(c *check.C, name string) *configFileRouter {
+	r, err := router.Get(name)
+	c.Assert(err, check.IsNil)
+	fileRouter := r.(*configFileRouter)
+	fileRouter.executor = s.executor
+	return fileRouter
+}
+
+func (s *S) readFile(c *check.C, name string) string {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	c.Assert(err, check.IsNil)
+	return string(data)
+}
+
+func (s *S) TestCreateRouterMissingConfigFile(c *check.C) {
+	config.Unset("routers:mynginx:config-file")
+	_, err := router.Get("mynginx")
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestCreateRouterCustomTemplate(c *check.C) {
+	tmplFile := filepath.Join(s.dir, "custom.tmpl")
+	err := ioutil.WriteFile(tmplFile, []byte("{{range .Backends}}{{.Hostname}}={{range .Routes}}{{.}}{{end}}\n{{end}}"), 0644)
+	c.Assert(err, check.IsNil)
+	config.Set("routers:mynginx:template", tmplFile)
+	defer config.Unset("routers:mynginx:template")
+	r := s.newRouter(c, "mynginx")
+	err = r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddRoute("myapp", &url.URL{Scheme: "http", Host: "10.0.0.1:8080"})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.readFile(c, "nginx.conf"), check.Equals, "myapp.nginx.router=10.0.0.1:8080\n")
+}
+
+func (s *S) TestNginxConfig(c *check.C) {
+	r := s.newRouter(c, "mynginx")
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddRoutes("myapp", []*url.URL{
+		{Scheme: "http", Host: "10.0.0.2:8080"},
+		{Scheme: "http", Host: "10.0.0.1:8080"},
+	})
+	c.Assert(err, check.IsNil)
+	err = r.SetCName("myapp.example.com", "myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddBackend("emptyapp")
+	c.Assert(err, check.IsNil)
+	expected := `# Generated by tsuru router mynginx, do not edit.
+
+upstream tsuru_emptyapp {
+    server 127.0.0.1:1 down;
+}
+
+server {
+    listen 80;
+    server_name emptyapp.nginx.router;
+
+    location / {
+        proxy_pass http://tsuru_emptyapp;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_next_upstream error timeout http_502 http_503 http_504;
+    }
+}
+
+upstream tsuru_myapp {
+    server 10.0.0.1:8080 max_fails=3 fail_timeout=10s;
+    server 10.0.0.2:8080 max_fails=3 fail_timeout=10s;
+}
+
+server {
+    listen 80;
+    server_name myapp.nginx.router myapp.example.com;
+
+    location / {
+        proxy_pass http://tsuru_myapp;
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_next_upstream error timeout http_502 http_503 http_504;
+    }
+}
+`
+	c.Assert(s.readFile(c, "nginx.conf"), check.Equals, expected)
+}
+
+func (s *S) TestHAProxyConfig(c *check.C) {
+	r := s.newRouter(c, "myhaproxy")
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddRoutes("myapp", []*url.URL{
+		{Scheme: "http", Host: "10.0.0.1:8080"},
+		{Scheme: "http", Host: "10.0.0.2:8080"},
+	})
+	c.Assert(err, check.IsNil)
+	err = r.SetCName("myapp.example.com", "myapp")
+	c.Assert(err, check.IsNil)
+	err = r.SetHealthcheck("myapp", router.HealthcheckData{Path: "/status", Status: 200})
+	c.Assert(err, check.IsNil)
+	expected := `# Generated by tsuru router myhaproxy, do not edit.
+frontend tsuru_myhaproxy
+    bind *:80
+    mode http
+    option forwardfor
+    acl host_tsuru_myapp req.hdr(host),field(1,:) -i myapp.haproxy.router myapp.example.com
+    use_backend tsuru_myapp if host_tsuru_myapp
+
+backend tsuru_myapp
+    mode http
+    balance roundrobin
+    option httpchk GET /status
+    http-check expect status 200
+    server route0 10.0.0.1:8080 check
+    server route1 10.0.0.2:8080 check
+`
+	c.Assert(s.readFile(c, "haproxy.conf"), check.Equals, expected)
+}
+
+func (s *S) TestHAProxyConfigHealthcheckBody(c *check.C) {
+	r := s.newRouter(c, "myhaproxy")
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddRoutes("myapp", []*url.URL{{Scheme: "http", Host: "10.0.0.1:8080"}})
+	c.Assert(err, check.IsNil)
+	err = r.SetHealthcheck("myapp", router.HealthcheckData{Path: "/status", Body: `say "WORKING" \ ok`})
+	c.Assert(err, check.IsNil)
+	expected := `# Generated by tsuru router myhaproxy, do not edit.
+frontend tsuru_myhaproxy
+    bind *:80
+    mode http
+    option forwardfor
+    acl host_tsuru_myapp req.hdr(host),field(1,:) -i myapp.haproxy.router
+    use_backend tsuru_myapp if host_tsuru_myapp
+
+backend tsuru_myapp
+    mode http
+    balance roundrobin
+    option httpchk GET /status
+    http-check expect string "say \"WORKING\" \\ ok"
+    server route0 10.0.0.1:8080 check
+`
+	c.Assert(s.readFile(c, "haproxy.conf"), check.Equals, expected)
+}
+
+func (s *S) TestReloadCommand(c *check.C) {
+	r := s.newRouter(c, "mynginx")
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	err = r.AddRoute("myapp", &url.URL{Scheme: "http", Host: "10.0.0.1:8080"})
+	c.Assert(err, check.IsNil)
+	c.Assert(s.executor.ExecutedCmd("/bin/sh", []string{"-c", "service nginx reload"}), check.Equals, true)
+	c.Assert(s.executor.GetCommands("/bin/sh"), check.HasLen, 2)
+}
+
+func (s *S) TestReloadCommandFailure(c *check.C) {
+	r := s.newRouter(c, "mynginx")
+	r.executor = &exectest.ErrorExecutor{
+		FakeExecutor: exectest.FakeExecutor{
+			Output: map[string][][]byte{"*": {[]byte("invalid config")}},
+		},
+		Err: errors.New("exit status 1"),
+	}
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.ErrorMatches, `\[router reload\] exit status 1: invalid config`)
+}
+
+func (s *S) TestRemoveBackendRemovesFromConfig(c *check.C) {
+	r := s.newRouter(c, "myhaproxy")
+	err := r.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.readFile(c, "haproxy.conf"), check.Matches, `(?s).*backend tsuru_myapp.*`)
+	err = r.RemoveBackend("myapp")
+	c.Assert(err, check.IsNil)
+	c.Assert(s.readFile(c, "haproxy.conf"), check.Not(check.Matches), `(?s).*backend tsuru_myapp.*`)
+}
+
+func (s *S) TestBackendsAreIsolatedByRouter(c *check.C) {
+	nginx := s.newRouter(c, "mynginx")
+	err := nginx.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	haproxy := s.newRouter(c, "myhaproxy")
+	_, err = haproxy.Routes("myapp")
+	c.Assert(err, check.Equals, router.ErrBackendNotFound)
+}
+
+func (s *S) TestStartupMessage(c *check.C) {
+	r := s.newRouter(c, "mynginx")
+	msg, err := r.StartupMessage()
+	c.Assert(err, check.IsNil)
+	c.Assert(msg, check.Equals, `nginx router "nginx.router" writing configuration to "`+filepath.Join(s.dir, "nginx.conf")+`"`)
+}
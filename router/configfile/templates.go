@@ -0,0 +1,60 @@
+// Copyright 2016 tsuru authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package configfile
+
+// nginxTemplate is the default template of the nginx router. The open source
+// version of nginx has no active health checks, unhealthy routes are detected
+// passively using max_fails.
+const nginxTemplate = `# Generated by tsuru router {{.Router}}, do not edit.
+{{range .Backends}}
+upstream {{.ID}} {
+{{- range .Routes}}
+    server {{.}} max_fails=3 fail_timeout=10s;
+{{- else}}
+    server 127.0.0.1:1 down;
+{{- end}}
+}
+
+server {
+    listen 80;
+    server_name {{.Hostname}}{{range .CNames}} {{.}}{{end}};
+
+    location / {
+        proxy_pass http://{{.ID}};
+        proxy_set_header Host $host;
+        proxy_set_header X-Real-IP $remote_addr;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+        proxy_next_upstream error timeout http_502 http_503 http_504;
+    }
+}
+{{end}}`
+
+// haproxyTemplate is the default template of the haproxy router.
+const haproxyTemplate = `# Generated by tsuru router {{.Router}}, do not edit.
+frontend tsuru_{{.Router}}
+    bind *:80
+    mode http
+    option forwardfor
+{{- range .Backends}}
+    acl host_{{.ID}} req.hdr(host),field(1,:) -i {{.Hostname}}{{range .CNames}} {{.}}{{end}}
+    use_backend {{.ID}} if host_{{.ID}}
+{{- end}}
+{{range .Backends}}
+backend {{.ID}}
+    mode http
+    balance roundrobin
+{{- if .Healthcheck.Path}}
+    option httpchk GET {{.Healthcheck.Path}}
+{{- if .Healthcheck.Status}}
+    http-check expect status {{.Healthcheck.Status}}
+{{- else if .Healthcheck.Body}}
+    http-check expect string {{quote .Healthcheck.Body}}
+{{- end}}
+{{- end}}
+{{- $check := .Healthcheck.Path}}
+{{- range $i, $route := .Routes}}
+    server route{{$i}} {{$route}}{{if $check}} check{{end}}
+{{- end}}
+{{end}}`
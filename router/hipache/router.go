@@ -409,36 +409,19 @@ func (r *hipacheRouter) SetCName(cname, name string) error {
 	}
 	frontend := "frontend:" + backendName + "." + domain
 	cnameFrontend := "frontend:" + cname
+	// The CName frontend is rebuilt as an exact copy of the app frontend,
+	// keeping weighted routes repeated once per unit of weight.
 	wantedRoutes, err := conn.LRange(frontend, 1, -1).Result()
 	if err != nil {
 		return &router.RouterError{Op: "get", Err: err}
 	}
-	currentRoutes, err := conn.LRange(cnameFrontend, 0, -1).Result()
+	pipe := conn.Pipeline()
+	defer pipe.Close()
+	pipe.Del(cnameFrontend)
+	pipe.RPush(cnameFrontend, append([]string{backendName}, wantedRoutes...)...)
+	_, err = pipe.Exec()
 	if err != nil {
-		return &router.RouterError{Op: "get", Err: err}
-	}
-	// Routes are always added again and duplicates removed this will ensure
-	// that after a call to SetCName is made routes will be identical to the
-	// original entry.
-	if len(currentRoutes) == 0 {
-		err = conn.RPush(cnameFrontend, backendName).Err()
-		if err != nil {
-			return &router.RouterError{Op: "setCName", Err: err}
-		}
-	} else {
-		currentRoutes = currentRoutes[1:]
-	}
-	for _, r := range wantedRoutes {
-		err = conn.RPush(cnameFrontend, r).Err()
-		if err != nil {
-			return &router.RouterError{Op: "setCName", Err: err}
-		}
-	}
-	for _, r := range currentRoutes {
-		err = conn.LRem(cnameFrontend, 1, r).Err()
-		if err != nil {
-			return &router.RouterError{Op: "setCName", Err: err}
-		}
+		return &router.RouterError{Op: "setCName", Err: err}
 	}
 	if cnameExists {
 		return router.ErrCNameExists
@@ -502,6 +485,29 @@ func (r *hipacheRouter) Addr(name string) (string, error) {
 }
 
 func (r *hipacheRouter) Routes(name string) ([]*url.URL, error) {
+	routes, err := r.rawRoutes(name)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*url.URL, 0, len(routes))
+	seen := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if seen[route] {
+			continue
+		}
+		seen[route] = true
+		u, err := url.Parse(route)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+// rawRoutes returns the routes of a backend as stored in redis, weighted
+// routes are stored repeated once per unit of weight.
+func (r *hipacheRouter) rawRoutes(name string) ([]string, error) {
 	backendName, err := router.Retrieve(name)
 	if err != nil {
 		return nil, err
@@ -522,15 +528,97 @@ func (r *hipacheRouter) Routes(name string) ([]*url.URL, error) {
 	if len(routes) == 0 {
 		return nil, router.ErrBackendNotFound
 	}
-	routes = routes[1:]
-	result := make([]*url.URL, len(routes))
-	for i, route := range routes {
-		result[i], err = url.Parse(route)
+	return routes[1:], nil
+}
+
+func (r *hipacheRouter) RoutesWeight(name string) (map[string]int, error) {
+	routes, err := r.rawRoutes(name)
+	if err != nil {
+		return nil, err
+	}
+	weights := make(map[string]int, len(routes))
+	for _, route := range routes {
+		u, err := url.Parse(route)
 		if err != nil {
 			return nil, err
 		}
+		weights[u.Host]++
 	}
-	return result, nil
+	return weights, nil
+}
+
+// SetRoutesWeight sets the weight of the given routes. As hipache picks
+// backends randomly from the frontend list, the weight is represented by
+// repeating the route in the list.
+func (r *hipacheRouter) SetRoutesWeight(name string, addresses []*url.URL, weight int) error {
+	if weight < 1 || weight > router.MaxRouteWeight {
+		return router.ErrInvalidWeight
+	}
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	domain, err := config.GetString(r.prefix + ":domain")
+	if err != nil {
+		return &router.RouterError{Op: "setWeight", Err: err}
+	}
+	routes, err := r.rawRoutes(name)
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]string, len(routes))
+	for _, route := range routes {
+		u, err := url.Parse(route)
+		if err != nil {
+			return err
+		}
+		stored[u.Host] = route
+	}
+	toSet := make([]string, len(addresses))
+	for i, addr := range addresses {
+		route, ok := stored[addr.Host]
+		if !ok {
+			return router.ErrRouteNotFound
+		}
+		toSet[i] = route
+	}
+	err = r.setWeight("frontend:"+backendName+"."+domain, toSet, weight)
+	if err != nil {
+		return err
+	}
+	cnames, err := r.getCNames(backendName)
+	if err != nil {
+		return err
+	}
+	for _, cname := range cnames {
+		err = r.setWeight("frontend:"+cname, toSet, weight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *hipacheRouter) setWeight(name string, addresses []string, weight int) error {
+	conn, err := r.connect()
+	if err != nil {
+		return &router.RouterError{Op: "setWeight", Err: err}
+	}
+	pipe := conn.Pipeline()
+	defer pipe.Close()
+	for _, addr := range addresses {
+		copies := make([]string, weight)
+		for i := range copies {
+			copies[i] = addr
+		}
+		pipe.LRem(name, 0, addr)
+		pipe.RPush(name, copies...)
+	}
+	_, err = pipe.Exec()
+	if err != nil {
+		return &router.RouterError{Op: "setWeight", Err: err}
+	}
+	return nil
 }
 
 func (r *hipacheRouter) removeElement(name, address string) (int, error) {
@@ -465,6 +465,26 @@ func (s *S) TestSetCNameWithPreviousRoutes(c *check.C) {
 	c.Assert([]string{"myapp", addr1.String(), addr2.String()}, check.DeepEquals, cnameRoutes)
 }
 
+func (s *S) TestSetCNameKeepsRoutesWeight(c *check.C) {
+	router := hipacheRouter{prefix: "hipache"}
+	err := router.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	defer router.RemoveBackend("myapp")
+	addr1, _ := url.Parse("http://10.10.10.10")
+	addr2, _ := url.Parse("http://10.10.10.11")
+	err = router.AddRoutes("myapp", []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = router.SetRoutesWeight("myapp", []*url.URL{addr1}, 3)
+	c.Assert(err, check.IsNil)
+	err = router.SetCName("mycname.com", "myapp")
+	c.Assert(err, check.IsNil)
+	conn, err := router.connect()
+	c.Assert(err, check.IsNil)
+	cnameRoutes, err := conn.LRange("frontend:mycname.com", 0, -1).Result()
+	c.Assert(err, check.IsNil)
+	c.Assert(cnameRoutes, check.DeepEquals, []string{"myapp", addr2.String(), addr1.String(), addr1.String(), addr1.String()})
+}
+
 func (s *S) TestSetCNameTwiceFixInconsistencies(c *check.C) {
 	r := hipacheRouter{prefix: "hipache"}
 	err := r.AddBackend("myapp")
@@ -657,6 +677,36 @@ func (s *S) TestRoutes(c *check.C) {
 	c.Assert(routes, check.DeepEquals, []*url.URL{addr})
 }
 
+func (s *S) TestSetRoutesWeight(c *check.C) {
+	router := hipacheRouter{prefix: "hipache"}
+	err := router.AddBackend("tip")
+	c.Assert(err, check.IsNil)
+	defer router.RemoveBackend("tip")
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err = router.AddRoutes("tip", []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = router.SetCName("mycname.com", "tip")
+	c.Assert(err, check.IsNil)
+	err = router.SetRoutesWeight("tip", []*url.URL{addr2}, 3)
+	c.Assert(err, check.IsNil)
+	conn, err := router.connect()
+	c.Assert(err, check.IsNil)
+	for _, frontend := range []string{"frontend:tip.golang.org", "frontend:mycname.com"} {
+		routes, err := conn.LRange(frontend, 1, -1).Result()
+		c.Assert(err, check.IsNil)
+		c.Assert(routes, check.DeepEquals, []string{addr1.String(), addr2.String(), addr2.String(), addr2.String()})
+	}
+	routes, err := router.Routes("tip")
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, check.DeepEquals, []*url.URL{addr1, addr2})
+	err = router.RemoveRoute("tip", addr2)
+	c.Assert(err, check.IsNil)
+	weights, err := router.RoutesWeight("tip")
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.Host: 1})
+}
+
 func (s *S) TestSwap(c *check.C) {
 	backend1 := "b1"
 	backend2 := "b2"
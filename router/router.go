@@ -24,18 +24,23 @@ import (
 type routerFactory func(routerName, configPrefix string) (Router, error)
 
 var (
-	ErrBackendExists   = errors.New("Backend already exists")
-	ErrBackendNotFound = errors.New("Backend not found")
-	ErrBackendSwapped  = errors.New("Backend is swapped cannot remove")
-	ErrRouteExists     = errors.New("Route already exists")
-	ErrRouteNotFound   = errors.New("Route not found")
-	ErrCNameExists     = errors.New("CName already exists")
-	ErrCNameNotFound   = errors.New("CName not found")
-	ErrCNameNotAllowed = errors.New("CName as router subdomain not allowed")
+	ErrBackendExists       = errors.New("Backend already exists")
+	ErrBackendNotFound     = errors.New("Backend not found")
+	ErrBackendSwapped      = errors.New("Backend is swapped cannot remove")
+	ErrRouteExists         = errors.New("Route already exists")
+	ErrRouteNotFound       = errors.New("Route not found")
+	ErrCNameExists         = errors.New("CName already exists")
+	ErrCNameNotFound       = errors.New("CName not found")
+	ErrCNameNotAllowed     = errors.New("CName as router subdomain not allowed")
+	ErrCertificateNotFound = errors.New("Certificate not found")
+	ErrInvalidWeight       = fmt.Errorf("Route weight must be between 1 and %d", MaxRouteWeight)
 )
 
 const HttpScheme = "http"
 
+// MaxRouteWeight is the highest weight accepted by weighted routers.
+const MaxRouteWeight = 100
+
 var routers = make(map[string]routerFactory)
 
 // Register registers a new router.
@@ -115,6 +120,27 @@ type OptsRouter interface {
 	AddBackendOpts(name string, opts map[string]string) error
 }
 
+// WeightedRouter is implemented by routers able to split the traffic of a
+// backend between its routes proportionally to the weight of each route.
+// Routes without an explicit weight have weight 1.
+type WeightedRouter interface {
+	SetRoutesWeight(name string, addresses []*url.URL, weight int) error
+	// RoutesWeight returns the weight of each route of a backend, keyed by
+	// the route host.
+	RoutesWeight(name string) (map[string]int, error)
+}
+
+// TLSRouter is implemented by routers able to serve the CNames of a backend
+// over TLS, using a certificate and a private key in PEM format for each
+// CName.
+type TLSRouter interface {
+	AddCertificate(cname, certificate, key string) error
+	RemoveCertificate(cname string) error
+	// GetCertificate returns the certificate in PEM format used by the
+	// CName, or ErrCertificateNotFound.
+	GetCertificate(cname string) (string, error)
+}
+
 type HealthcheckData struct {
 	Path   string
 	Status int
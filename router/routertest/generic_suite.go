@@ -610,3 +610,101 @@ func (s *RouterSuite) TestSetHealthcheck(c *check.C) {
 	err = s.Router.RemoveBackend(testBackend1)
 	c.Assert(err, check.IsNil)
 }
+
+func (s *RouterSuite) TestSetRoutesWeight(c *check.C) {
+	weightedRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement WeightedRouter", s.Router))
+	}
+	err := s.Router.AddBackend(testBackend1)
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err = s.Router.AddRoutes(testBackend1, []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	weights, err := weightedRouter.RoutesWeight(testBackend1)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.Host: 1, addr2.Host: 1})
+	err = weightedRouter.SetRoutesWeight(testBackend1, []*url.URL{addr2}, 3)
+	c.Assert(err, check.IsNil)
+	weights, err = weightedRouter.RoutesWeight(testBackend1)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.Host: 1, addr2.Host: 3})
+	routes, err := s.Router.Routes(testBackend1)
+	c.Assert(err, check.IsNil)
+	sort.Sort(URLList(routes))
+	c.Assert(routes, HostEquals, []*url.URL{addr1, addr2})
+	err = weightedRouter.SetRoutesWeight(testBackend1, []*url.URL{addr2}, 1)
+	c.Assert(err, check.IsNil)
+	weights, err = weightedRouter.RoutesWeight(testBackend1)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr1.Host: 1, addr2.Host: 1})
+	err = s.Router.RemoveBackend(testBackend1)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestSetRoutesWeightRemoveRoute(c *check.C) {
+	weightedRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement WeightedRouter", s.Router))
+	}
+	err := s.Router.AddBackend(testBackend1)
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err = s.Router.AddRoutes(testBackend1, []*url.URL{addr1, addr2})
+	c.Assert(err, check.IsNil)
+	err = weightedRouter.SetRoutesWeight(testBackend1, []*url.URL{addr1}, 4)
+	c.Assert(err, check.IsNil)
+	err = s.Router.RemoveRoute(testBackend1, addr1)
+	c.Assert(err, check.IsNil)
+	routes, err := s.Router.Routes(testBackend1)
+	c.Assert(err, check.IsNil)
+	c.Assert(routes, HostEquals, []*url.URL{addr2})
+	weights, err := weightedRouter.RoutesWeight(testBackend1)
+	c.Assert(err, check.IsNil)
+	c.Assert(weights, check.DeepEquals, map[string]int{addr2.Host: 1})
+	err = s.Router.RemoveBackend(testBackend1)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestSetRoutesWeightInvalid(c *check.C) {
+	weightedRouter, ok := s.Router.(router.WeightedRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement WeightedRouter", s.Router))
+	}
+	err := s.Router.AddBackend(testBackend1)
+	c.Assert(err, check.IsNil)
+	addr1, _ := url.Parse("http://10.10.10.10:8080")
+	err = s.Router.AddRoute(testBackend1, addr1)
+	c.Assert(err, check.IsNil)
+	err = weightedRouter.SetRoutesWeight(testBackend1, []*url.URL{addr1}, 0)
+	c.Assert(err, check.Equals, router.ErrInvalidWeight)
+	err = weightedRouter.SetRoutesWeight(testBackend1, []*url.URL{addr1}, router.MaxRouteWeight+1)
+	c.Assert(err, check.Equals, router.ErrInvalidWeight)
+	addr2, _ := url.Parse("http://10.10.10.11:8080")
+	err = weightedRouter.SetRoutesWeight(testBackend1, []*url.URL{addr2}, 2)
+	c.Assert(err, check.Equals, router.ErrRouteNotFound)
+	err = s.Router.RemoveBackend(testBackend1)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *RouterSuite) TestAddRemoveCertificate(c *check.C) {
+	tlsRouter, ok := s.Router.(router.TLSRouter)
+	if !ok {
+		c.Skip(fmt.Sprintf("%T does not implement TLSRouter", s.Router))
+	}
+	cert, key, err := GenerateCertificate("my.host.com")
+	c.Assert(err, check.IsNil)
+	err = tlsRouter.AddCertificate("my.host.com", cert, key)
+	c.Assert(err, check.IsNil)
+	stored, err := tlsRouter.GetCertificate("my.host.com")
+	c.Assert(err, check.IsNil)
+	c.Assert(stored, check.Equals, cert)
+	err = tlsRouter.RemoveCertificate("my.host.com")
+	c.Assert(err, check.IsNil)
+	_, err = tlsRouter.GetCertificate("my.host.com")
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+	err = tlsRouter.RemoveCertificate("my.host.com")
+	c.Assert(err, check.Equals, router.ErrCertificateNotFound)
+}
@@ -34,7 +34,7 @@ func createHCRouter(name, prefix string) (router.Router, error) {
 }
 
 func newFakeRouter() fakeRouter {
-	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), mutex: &sync.Mutex{}}
+	return fakeRouter{cnames: make(map[string]string), backends: make(map[string][]string), failuresByIp: make(map[string]bool), healthcheck: make(map[string]router.HealthcheckData), weights: make(map[string]map[string]int), certificates: make(map[string]string), mutex: &sync.Mutex{}}
 }
 
 type fakeRouter struct {
@@ -42,6 +42,8 @@ type fakeRouter struct {
 	cnames       map[string]string
 	failuresByIp map[string]bool
 	healthcheck  map[string]router.HealthcheckData
+	weights      map[string]map[string]int
+	certificates map[string]string
 	mutex        *sync.Mutex
 }
 
@@ -158,6 +160,7 @@ func (r *fakeRouter) RemoveBackend(name string) error {
 		}
 	}
 	delete(r.backends, backendName)
+	delete(r.weights, backendName)
 	return router.Remove(backendName)
 }
 
@@ -213,6 +216,7 @@ func (r *fakeRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 				break
 			}
 		}
+		delete(r.weights[backendName], addr.Host)
 	}
 	r.backends[backendName] = routes
 	return nil
@@ -266,6 +270,7 @@ func (r *fakeRouter) RemoveRoute(name string, address *url.URL) error {
 	}
 	routes[index] = routes[len(routes)-1]
 	r.backends[backendName] = routes[:len(routes)-1]
+	delete(r.weights[backendName], address.Host)
 	return nil
 }
 
@@ -332,6 +337,8 @@ func (r *fakeRouter) Reset() {
 	r.failuresByIp = make(map[string]bool)
 	r.cnames = make(map[string]string)
 	r.healthcheck = make(map[string]router.HealthcheckData)
+	r.weights = make(map[string]map[string]int)
+	r.certificates = make(map[string]string)
 }
 
 func (r *fakeRouter) Routes(name string) ([]*url.URL, error) {
@@ -349,6 +356,90 @@ func (r *fakeRouter) Routes(name string) ([]*url.URL, error) {
 	return result, nil
 }
 
+func (r *fakeRouter) SetRoutesWeight(name string, addresses []*url.URL, weight int) error {
+	if weight < 1 || weight > router.MaxRouteWeight {
+		return router.ErrInvalidWeight
+	}
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	routes, ok := r.backends[backendName]
+	if !ok {
+		return router.ErrBackendNotFound
+	}
+addresses:
+	for _, addr := range addresses {
+		for _, route := range routes {
+			if route == addr.Host {
+				continue addresses
+			}
+		}
+		return router.ErrRouteNotFound
+	}
+	if r.weights[backendName] == nil {
+		r.weights[backendName] = make(map[string]int)
+	}
+	for _, addr := range addresses {
+		r.weights[backendName][addr.Host] = weight
+	}
+	return nil
+}
+
+func (r *fakeRouter) RoutesWeight(name string) (map[string]int, error) {
+	backendName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	routes, ok := r.backends[backendName]
+	if !ok {
+		return nil, router.ErrBackendNotFound
+	}
+	result := make(map[string]int, len(routes))
+	for _, route := range routes {
+		weight := r.weights[backendName][route]
+		if weight == 0 {
+			weight = 1
+		}
+		result[route] = weight
+	}
+	return result, nil
+}
+
+func (r *fakeRouter) AddCertificate(cname, certificate, key string) error {
+	if r.failuresByIp[cname] {
+		return ErrForcedFailure
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.certificates[cname] = certificate
+	return nil
+}
+
+func (r *fakeRouter) RemoveCertificate(cname string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.certificates[cname]; !ok {
+		return router.ErrCertificateNotFound
+	}
+	delete(r.certificates, cname)
+	return nil
+}
+
+func (r *fakeRouter) GetCertificate(cname string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	certificate, ok := r.certificates[cname]
+	if !ok {
+		return "", router.ErrCertificateNotFound
+	}
+	return certificate, nil
+}
+
 func (r *fakeRouter) Swap(backend1, backend2 string, cnameOnly bool) error {
 	return router.Swap(r, backend1, backend2, cnameOnly)
 }
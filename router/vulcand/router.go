@@ -68,6 +68,12 @@ func (r *vulcandRouter) serverName(address string) string {
 	return fmt.Sprintf("tsuru_%x", md5.Sum([]byte(address)))
 }
 
+// replicaServerName returns the name of the i-th additional server entry
+// pointing to the same address, used to give weight to a route.
+func (r *vulcandRouter) replicaServerName(address string, i int) string {
+	return fmt.Sprintf("%s_w%d", r.serverName(address), i)
+}
+
 func (r *vulcandRouter) AddBackend(name string) error {
 	backendName := r.backendName(name)
 	frontendName := r.frontendName(r.frontendHostname(name))
@@ -217,7 +223,7 @@ func (r *vulcandRouter) RemoveRoute(name string, address *url.URL) error {
 		}
 		return &router.RouterError{Err: err, Op: "remove-route"}
 	}
-	return nil
+	return r.removeReplicas(serverKey.BackendKey, []string{address.Host})
 }
 
 func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) error {
@@ -225,10 +231,13 @@ func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 	if err != nil {
 		return err
 	}
-	for _, addr := range addresses {
+	backendKey := engine.BackendKey{Id: r.backendName(usedName)}
+	hosts := make([]string, len(addresses))
+	for i, addr := range addresses {
+		hosts[i] = addr.Host
 		serverKey := engine.ServerKey{
 			Id:         r.serverName(addr.Host),
-			BackendKey: engine.BackendKey{Id: r.backendName(usedName)},
+			BackendKey: backendKey,
 		}
 		err = r.client.DeleteServer(serverKey)
 		if err != nil {
@@ -238,6 +247,28 @@ func (r *vulcandRouter) RemoveRoutes(name string, addresses []*url.URL) error {
 			return &router.RouterError{Err: err, Op: "remove-route"}
 		}
 	}
+	return r.removeReplicas(backendKey, hosts)
+}
+
+func (r *vulcandRouter) removeReplicas(backendKey engine.BackendKey, hosts []string) error {
+	servers, err := r.client.GetServers(backendKey)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "remove-route"}
+	}
+	for _, server := range servers {
+		for _, host := range hosts {
+			if !strings.HasPrefix(server.Id, r.serverName(host)+"_w") {
+				continue
+			}
+			err = r.client.DeleteServer(engine.ServerKey{Id: server.Id, BackendKey: backendKey})
+			if err != nil {
+				if _, ok := err.(*engine.NotFoundError); ok {
+					continue
+				}
+				return &router.RouterError{Err: err, Op: "remove-route"}
+			}
+		}
+	}
 	return nil
 }
 
@@ -331,14 +362,131 @@ func (r *vulcandRouter) Routes(name string) ([]*url.URL, error) {
 	if err != nil {
 		return nil, &router.RouterError{Err: err, Op: "routes"}
 	}
-	routes := make([]*url.URL, len(servers))
-	for i, server := range servers {
+	routes := make([]*url.URL, 0, len(servers))
+	seen := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		if seen[server.URL] {
+			continue
+		}
+		seen[server.URL] = true
 		parsedUrl, _ := url.Parse(server.URL)
-		routes[i] = parsedUrl
+		routes = append(routes, parsedUrl)
 	}
 	return routes, nil
 }
 
+func (r *vulcandRouter) RoutesWeight(name string) (map[string]int, error) {
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return nil, err
+	}
+	servers, err := r.client.GetServers(engine.BackendKey{
+		Id: r.backendName(usedName),
+	})
+	if err != nil {
+		return nil, &router.RouterError{Err: err, Op: "routes-weight"}
+	}
+	weights := make(map[string]int, len(servers))
+	for _, server := range servers {
+		parsedUrl, err := url.Parse(server.URL)
+		if err != nil {
+			return nil, &router.RouterError{Err: err, Op: "routes-weight"}
+		}
+		weights[parsedUrl.Host]++
+	}
+	return weights, nil
+}
+
+// SetRoutesWeight sets the weight of the given routes. Vulcand balances
+// requests between servers using round robin, so the weight is represented
+// by additional server entries pointing to the same address.
+func (r *vulcandRouter) SetRoutesWeight(name string, addresses []*url.URL, weight int) error {
+	if weight < 1 || weight > router.MaxRouteWeight {
+		return router.ErrInvalidWeight
+	}
+	usedName, err := router.Retrieve(name)
+	if err != nil {
+		return err
+	}
+	backendKey := engine.BackendKey{Id: r.backendName(usedName)}
+	servers := make([]*engine.Server, len(addresses))
+	hosts := make([]string, len(addresses))
+	for i, addr := range addresses {
+		server, err := r.client.GetServer(engine.ServerKey{
+			Id:         r.serverName(addr.Host),
+			BackendKey: backendKey,
+		})
+		if err != nil {
+			if _, ok := err.(*engine.NotFoundError); ok {
+				return router.ErrRouteNotFound
+			}
+			return &router.RouterError{Err: err, Op: "set-weight"}
+		}
+		servers[i] = server
+		hosts[i] = addr.Host
+	}
+	err = r.removeReplicas(backendKey, hosts)
+	if err != nil {
+		return err
+	}
+	for i, server := range servers {
+		for j := 1; j < weight; j++ {
+			replica, err := engine.NewServer(r.replicaServerName(hosts[i], j), server.URL)
+			if err != nil {
+				return &router.RouterError{Err: err, Op: "set-weight"}
+			}
+			err = r.client.UpsertServer(backendKey, *replica, engine.NoTTL)
+			if err != nil {
+				return &router.RouterError{Err: err, Op: "set-weight"}
+			}
+		}
+	}
+	return nil
+}
+
+// AddCertificate stores the certificate and key as the key pair of the vulcand
+// host matching the CName, used by HTTPS listeners to serve it.
+func (r *vulcandRouter) AddCertificate(cname, certificate, key string) error {
+	keyPair, err := engine.NewKeyPair([]byte(certificate), []byte(key))
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-certificate"}
+	}
+	host, err := engine.NewHost(cname, engine.HostSettings{KeyPair: keyPair})
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-certificate"}
+	}
+	err = r.client.UpsertHost(*host)
+	if err != nil {
+		return &router.RouterError{Err: err, Op: "add-certificate"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) RemoveCertificate(cname string) error {
+	err := r.client.DeleteHost(engine.HostKey{Name: cname})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return router.ErrCertificateNotFound
+		}
+		return &router.RouterError{Err: err, Op: "remove-certificate"}
+	}
+	return nil
+}
+
+func (r *vulcandRouter) GetCertificate(cname string) (string, error) {
+	host, err := r.client.GetHost(engine.HostKey{Name: cname})
+	if err != nil {
+		if _, ok := err.(*engine.NotFoundError); ok {
+			return "", router.ErrCertificateNotFound
+		}
+		return "", &router.RouterError{Err: err, Op: "get-certificate"}
+	}
+	if host.Settings.KeyPair == nil {
+		return "", router.ErrCertificateNotFound
+	}
+	return string(host.Settings.KeyPair.Cert), nil
+}
+
 func (r *vulcandRouter) StartupMessage() (string, error) {
 	message := fmt.Sprintf("vulcand router %q with API at %q", r.domain, r.client.Addr)
 	return message, nil
@@ -284,6 +284,39 @@ func (s *S) TestRemoveRouteNotExist(c *check.C) {
 	c.Assert(err, check.ErrorMatches, router.ErrRouteNotFound.Error())
 }
 
+func (s *S) TestSetRoutesWeight(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	err = vRouter.AddBackend("myapp")
+	c.Assert(err, check.IsNil)
+	u1, _ := url.Parse("http://1.1.1.1:111")
+	u2, _ := url.Parse("http://2.2.2.2:222")
+	err = vRouter.AddRoutes("myapp", []*url.URL{u1, u2})
+	c.Assert(err, check.IsNil)
+	wRouter := vRouter.(router.WeightedRouter)
+	err = wRouter.SetRoutesWeight("myapp", []*url.URL{u1}, 3)
+	c.Assert(err, check.IsNil)
+	servers, err := s.engine.GetServers(engine.BackendKey{Id: "tsuru_myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(servers, check.HasLen, 4)
+	urls := map[string]int{}
+	for _, server := range servers {
+		urls[server.URL]++
+	}
+	c.Assert(urls, check.DeepEquals, map[string]int{u1.String(): 3, u2.String(): 1})
+	err = wRouter.SetRoutesWeight("myapp", []*url.URL{u1}, 2)
+	c.Assert(err, check.IsNil)
+	servers, err = s.engine.GetServers(engine.BackendKey{Id: "tsuru_myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(servers, check.HasLen, 3)
+	err = vRouter.RemoveRoutes("myapp", []*url.URL{u1})
+	c.Assert(err, check.IsNil)
+	servers, err = s.engine.GetServers(engine.BackendKey{Id: "tsuru_myapp"})
+	c.Assert(err, check.IsNil)
+	c.Assert(servers, check.HasLen, 1)
+	c.Assert(servers[0].URL, check.Equals, u2.String())
+}
+
 func (s *S) TestSetCName(c *check.C) {
 	vRouter, err := router.Get("vulcand")
 	c.Assert(err, check.IsNil)
@@ -433,6 +466,34 @@ func (s *S) TestRoutes(c *check.C) {
 	c.Assert(routes, check.DeepEquals, []*url.URL{u1, u2})
 }
 
+func (s *S) TestAddCertificate(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	cert, key, err := routertest.GenerateCertificate("myapp.cname.example.com")
+	c.Assert(err, check.IsNil)
+	tlsRouter, ok := vRouter.(router.TLSRouter)
+	c.Assert(ok, check.Equals, true)
+	err = tlsRouter.AddCertificate("myapp.cname.example.com", cert, key)
+	c.Assert(err, check.IsNil)
+	host, err := s.engine.GetHost(engine.HostKey{Name: "myapp.cname.example.com"})
+	c.Assert(err, check.IsNil)
+	c.Assert(host.Settings.KeyPair, check.DeepEquals, &engine.KeyPair{Cert: []byte(cert), Key: []byte(key)})
+}
+
+func (s *S) TestAddCertificateInvalidKeyPair(c *check.C) {
+	vRouter, err := router.Get("vulcand")
+	c.Assert(err, check.IsNil)
+	cert, _, err := routertest.GenerateCertificate("myapp.cname.example.com")
+	c.Assert(err, check.IsNil)
+	_, otherKey, err := routertest.GenerateCertificate("myapp.cname.example.com")
+	c.Assert(err, check.IsNil)
+	err = vRouter.(router.TLSRouter).AddCertificate("myapp.cname.example.com", cert, otherKey)
+	c.Assert(err, check.ErrorMatches, `\[router add-certificate\] .*private key does not match public key`)
+	hosts, err := s.engine.GetHosts()
+	c.Assert(err, check.IsNil)
+	c.Assert(hosts, check.HasLen, 0)
+}
+
 func (s *S) TestStartupMessage(c *check.C) {
 	got, err := router.Get("vulcand")
 	c.Assert(err, check.IsNil)